@@ -0,0 +1,61 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"time"
+)
+
+// WithMinAge makes notify drop the events for the files and directories, which
+// were modified less than d ago, i.e. which modification time is after
+// the time the event was dispatched less d. See WithMaxAge.
+func WithMinAge(d time.Duration) Option {
+	return func(o *options) {
+		o.minAge = d
+	}
+}
+
+// WithMaxAge makes notify drop the events for the files and directories, which
+// were modified more than d ago, e.g. ignoring the changes made by the backup
+// tools merely touching the attributes of old files, while reacting to fresh
+// changes:
+//
+//   notify.WatchWithOptions("./...", c, notify.All, notify.WithMaxAge(24*time.Hour))
+//
+// The age is told by the modification time of the path, which is looked up
+// with os.Lstat when the event is dispatched, which costs a system call per
+// event, unless the event carries its os.FileInfo already, see WithStat.
+// Remove events, the events for the paths, which no longer exist, and the ones
+// for which os.Lstat failed cannot be told the age of, so they are always
+// delivered. Combined with WithMinAge, only the events for the files, which age
+// is within both bounds, are delivered.
+func WithMaxAge(d time.Duration) Option {
+	return func(o *options) {
+		o.maxAge = d
+	}
+}
+
+// aged gives a stage, which drops the events for the paths modified less than
+// min or more than max ago. A zero bound is not checked.
+func aged(min, max time.Duration) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			var fi os.FileInfo
+			if s, ok := ei.(StatEventInfo); ok {
+				fi = s.FileInfo()
+			} else if ei.Event()&Remove == 0 {
+				fi, _ = os.Lstat(ei.Path())
+			}
+			if fi != nil {
+				age := now().Sub(fi.ModTime())
+				if min > 0 && age < min || max > 0 && age > max {
+					return
+				}
+			}
+			next(ei)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	at := time.Now()
+	for name, age := range map[string]time.Duration{"new": time.Minute, "mid": time.Hour, "old": 48 * time.Hour} {
+		file := filepath.Join(dir, name)
+		must(ioutil.WriteFile(file, nil, 0644))
+		must(os.Chtimes(file, at.Add(-age), at.Add(-age)))
+	}
+	clk := newFakeClock()
+	clk.now = at
+	SetClock(clk)
+	defer SetClock(nil)
+	events := []EventInfo{
+		&Call{P: filepath.Join(dir, "new"), E: Write},
+		&Call{P: filepath.Join(dir, "mid"), E: Write},
+		&Call{P: filepath.Join(dir, "old"), E: Write},
+		&Call{P: filepath.Join(dir, "gone"), E: Write},
+		&Call{P: filepath.Join(dir, "old"), E: Remove},
+	}
+	cases := [...]struct {
+		min, max time.Duration
+		want     []string
+	}{
+		{0, 24 * time.Hour, []string{"new", "mid", "gone", "old"}},         // i=0
+		{10 * time.Minute, 0, []string{"mid", "old", "gone", "old"}},       // i=1
+		{10 * time.Minute, 24 * time.Hour, []string{"mid", "gone", "old"}}, // i=2
+	}
+	for i, cas := range cases {
+		var got []string
+		fn := aged(cas.min, cas.max)(func(ei EventInfo) {
+			got = append(got, filepath.Base(ei.Path()))
+		})
+		for _, ei := range events {
+			fn(ei)
+		}
+		if !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want %v; got %v (i=%d)", cas.want, got, i)
+		}
+	}
+}
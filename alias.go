@@ -0,0 +1,61 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithAliasPaths makes notify report the events under the path given to
+// WatchWithOptions, even if it goes through symlinks. By default the symlinks
+// are resolved, so that watching both /link-to-dir and /real-dir shares
+// a single watch of /real-dir, and the events of both watchpoints are reported
+// under /real-dir. With the option the watch is still shared, but the events
+// of the watchpoint are reported under /link-to-dir, the alias it was set up
+// with:
+//
+//   notify.WatchWithOptions("/link-to-dir/...", c, notify.All, notify.WithAliasPaths())
+//
+// The paths are rewritten after the other options saw the events, e.g. the
+// function given to WithTransform gets the resolved paths. The option has no
+// effect if the path has no symlinks in it.
+func WithAliasPaths() Option {
+	return func(o *options) {
+		o.alias = true
+	}
+}
+
+// aliaspath gives the absolute path of the watched path with the symlinks
+// in it kept, false if it is the same as the resolved one.
+func aliaspath(path, dir string) (string, bool) {
+	as, err := filepath.Abs(strings.TrimSuffix(path, "..."))
+	if err != nil {
+		return "", false
+	}
+	as = normalize(longpath(as))
+	return as, as != dir
+}
+
+// alias gives a stage, which reports the events for the paths under dir under
+// as instead.
+func alias(dir, as string) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			if _, ok := ei.(RenameSelfEventInfo); ok {
+				next(ei)
+				return
+			}
+			switch path := ei.Path(); {
+			case path == dir:
+				ei = remap(ei, as)
+			case strings.HasPrefix(path, dir) && os.IsPathSeparator(path[len(dir)]):
+				ei = remap(ei, as+path[len(dir):])
+			}
+			next(ei)
+		}
+	}
+}
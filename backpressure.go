@@ -0,0 +1,260 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WithBackpressure makes notify wait for the receiving channel instead of
+// dropping events when the channel is not ready. Under Linux the wait is
+// propagated to the kernel: while any watchpoint set up with the option is
+// waiting for its consumer, notify stops reading the inotify descriptor and
+// resumes once the consumer catches up, so events queue up in the kernel
+// rather than being dropped on the way to the channel. The events already
+// read when the consumer fell behind are queued for the watchpoint.
+//
+// The inotify descriptor is shared by all the watchpoints of the process, so
+// a slow consumer pauses the delivery of events for every watchpoint, not only
+// its own one. The kernel queue is bounded as well, see
+// /proc/sys/fs/inotify/max_queued_events; once it fills up, the kernel
+// discards further events and reports a queue overflow, after which the
+// events which were lost cannot be recovered. Consumers of heavily loaded
+// paths should rather keep up with the events than rely on the option.
+//
+// Under the other watchers the events are still queued, but the watcher is
+// not paused, so the events may get dropped before they reach the
+// watchpoint. The option has no effect on watchpoints set up with
+// WithPriority, whose events are queued in the channel's outbox.
+func WithBackpressure() Option {
+	return func(o *options) {
+		o.backpressure = true
+	}
+}
+
+// pacer is implemented by the watchers, which are able to stop reading events
+// from the OS while a gate is held.
+type pacer interface {
+	// pace makes the watcher wait for g before reading more events, it reports
+	// whether the watcher is going to. It is expected to be called before
+	// the first watch is set up.
+	pace(g *gate) bool
+}
+
+// pace forwards the gate to the watcher w wraps, if it is a pacer.
+func (w hookWatcher) pace(g *gate) bool {
+	p, ok := w.watcher.(pacer)
+	return ok && p.pace(g)
+}
+
+// throttle paces the watcher of a tree by the pipes set up with backpressure.
+// The events the pipes are not ready for are queued instead of being dropped,
+// and the gate, which the watcher waits for before reading more events, is held
+// while any of them are queued or any of the pipes waits for its receiver.
+type throttle struct {
+	g       *gate
+	act     *activity  // counts the events queued
+	n       int32      // number of the channels throttled, read atomically
+	mu      sync.Mutex // protects backlog
+	backlog map[chan<- EventInfo]*backlog
+}
+
+// backlog is the queue of the events for a single throttled channel.
+type backlog struct {
+	quit <-chan struct{}
+	q    []EventInfo
+	done chan struct{} // closed once q is drained, nil if it is empty
+}
+
+// throttleFor gives a throttle pacing w, nil if w is not able to be paced.
+// The events queued are counted with act.
+func throttleFor(w watcher, act *activity) *throttle {
+	th := &throttle{g: newGate(), act: act, backlog: make(map[chan<- EventInfo]*backlog)}
+	if p, ok := w.(pacer); !ok || !p.pace(th.g) {
+		return nil
+	}
+	return th
+}
+
+// throttleOf gives the throttle of the tree, nil if it has none.
+func throttleOf(t tree) *throttle {
+	if t, ok := t.(*nonrecursiveTree); ok {
+		return t.th
+	}
+	return nil
+}
+
+// add makes th queue the events for c, which c is not ready for, until quit
+// is closed.
+func (th *throttle) add(c chan<- EventInfo, quit <-chan struct{}) {
+	th.mu.Lock()
+	th.backlog[c] = &backlog{quit: quit}
+	atomic.AddInt32(&th.n, 1)
+	th.mu.Unlock()
+}
+
+// remove stops queueing the events for c, it waits until the ones already
+// queued are sent or dropped. It expects quit given to add to be closed.
+func (th *throttle) remove(c chan<- EventInfo) {
+	var done chan struct{}
+	th.mu.Lock()
+	if b, ok := th.backlog[c]; ok {
+		delete(th.backlog, c)
+		atomic.AddInt32(&th.n, -1)
+		done = b.done
+	}
+	th.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// send sends ei to c, queueing it if c is not ready, in which case it holds
+// the gate until the queue is drained. It reports whether c is throttled,
+// if it is not, ei is left for the caller to deliver.
+func (th *throttle) send(c chan<- EventInfo, ei EventInfo) bool {
+	if th == nil || atomic.LoadInt32(&th.n) == 0 {
+		return false
+	}
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	b, ok := th.backlog[c]
+	if !ok {
+		return false
+	}
+	if b.done == nil {
+		// The events queued go first, so c is tried only if there are none.
+		select {
+		case c <- ei:
+			return true
+		default:
+		}
+		b.done = make(chan struct{})
+		th.g.hold()
+		go th.drain(c, b)
+	}
+	th.act.enter()
+	b.q = append(b.q, ei)
+	return true
+}
+
+// drain sends the events queued in b to c in order, until either all of them
+// are sent or quit is closed.
+func (th *throttle) drain(c chan<- EventInfo, b *backlog) {
+	for {
+		th.mu.Lock()
+		if len(b.q) == 0 {
+			done := b.done
+			b.done = nil
+			th.mu.Unlock()
+			th.g.release()
+			close(done)
+			return
+		}
+		ei := b.q[0]
+		th.mu.Unlock()
+		select {
+		case c <- ei:
+			th.mu.Lock()
+			b.q[0] = nil
+			b.q = b.q[1:]
+			th.mu.Unlock()
+			th.act.leave()
+		case <-b.quit:
+			th.mu.Lock()
+			for range b.q {
+				th.act.leave()
+			}
+			b.q = nil
+			th.mu.Unlock()
+		}
+	}
+}
+
+// gate counts its holders and lets the waiters through only when it is not
+// held by anyone.
+type gate struct {
+	mu   sync.Mutex
+	n    int
+	cond *sync.Cond
+}
+
+func newGate() *gate {
+	g := &gate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// hold closes the gate until the matching release.
+func (g *gate) hold() {
+	g.mu.Lock()
+	g.n++
+	g.mu.Unlock()
+}
+
+// release undoes a single hold, opening the gate once no one holds it.
+func (g *gate) release() {
+	g.mu.Lock()
+	if g.n--; g.n == 0 {
+		g.cond.Broadcast()
+	}
+	g.mu.Unlock()
+}
+
+// held reports whether anyone holds the gate.
+func (g *gate) held() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.n != 0
+}
+
+// wait blocks while the gate is held.
+func (g *gate) wait() {
+	g.mu.Lock()
+	for g.n != 0 {
+		g.cond.Wait()
+	}
+	g.mu.Unlock()
+}
+
+// block delivers ei to the user channel, waiting for the receiver while
+// holding the gate of its throttle, if any, until either the event is received
+// or the pipe is halted.
+func (p *pipe) block(ei EventInfo) {
+	select {
+	case p.dst <- ei:
+		return
+	default:
+	}
+	if p.th != nil {
+		p.th.g.hold()
+		defer p.th.g.release()
+	}
+	select {
+	case p.dst <- ei:
+	case <-p.quit:
+	}
+}
+
+// Throttle makes p wait for its receiver instead of dropping events, unless
+// p was already stopped. The events the tree dispatches to p, which p is not
+// ready for, are queued by the throttle of the tree, if it has any.
+func (t *pipeTree) Throttle(p *pipe) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p.closed() {
+		return
+	}
+	t.promote(p)
+	p.mu.Lock()
+	p.bp = true
+	if th := throttleOf(t.tree); th != nil {
+		th.add(p.c, p.quit)
+		p.th = th
+	}
+	p.mu.Unlock()
+	p.plain = false // keep plain watchpoints from sharing the backpressure
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortBatch sorts a batch of events, e.g. the ones drained from a channel at
+// once or given by Journal, into the order they are safe to be applied in to
+// a model of the filesystem. The less function reports whether a is to be
+// applied before b; the events, which neither is to be applied before
+// the other, keep their relative order. If less is nil, BatchOrder is used.
+func SortBatch(batch []EventInfo, less func(a, b EventInfo) bool) {
+	if less == nil {
+		less = BatchOrder
+	}
+	sort.Stable(lessBatch{batch, less})
+}
+
+// BatchOrder is the default order of SortBatch. The events, which remove
+// a path from the filesystem, i.e. Remove and Rename, come first, the children
+// before their parent directories, so that a directory is removed after its
+// contents. The other events follow, the parent directories before their
+// children, so that a directory is created before its contents.
+func BatchOrder(a, b EventInfo) bool {
+	ra, rb := a.Event()&(Remove|Rename) != 0, b.Event()&(Remove|Rename) != 0
+	if ra != rb {
+		return ra
+	}
+	da, db := depth(a.Path()), depth(b.Path())
+	if ra {
+		return da > db
+	}
+	return da < db
+}
+
+// depth gives the number of path separators in path.
+func depth(path string) int {
+	return strings.Count(path, sep)
+}
+
+// lessBatch implements sort.Interface with a less function.
+type lessBatch struct {
+	b    []EventInfo
+	less func(a, b EventInfo) bool
+}
+
+func (l lessBatch) Len() int           { return len(l.b) }
+func (l lessBatch) Less(i, j int) bool { return l.less(l.b[i], l.b[j]) }
+func (l lessBatch) Swap(i, j int)      { l.b[i], l.b[j] = l.b[j], l.b[i] }
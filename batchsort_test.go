@@ -0,0 +1,36 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSortBatch(t *testing.T) {
+	p := filepath.FromSlash
+	batch := []EventInfo{
+		&Call{P: p("/a/b/c"), E: Create}, // i=0
+		&Call{P: p("/a/b"), E: Create},   // i=1
+		&Call{P: p("/x"), E: Remove},     // i=2
+		&Call{P: p("/x/y"), E: Remove},   // i=3
+		&Call{P: p("/a/d"), E: Write},    // i=4
+		&Call{P: p("/x/z"), E: Rename},   // i=5
+	}
+	want := []EventInfo{batch[3], batch[5], batch[2], batch[1], batch[4], batch[0]}
+	SortBatch(batch, nil)
+	for i := range want {
+		if batch[i] != want[i] {
+			t.Errorf("want %v; got %v (i=%d)", want[i], batch[i], i)
+		}
+	}
+	bypath := func(a, b EventInfo) bool { return a.Path() < b.Path() }
+	SortBatch(batch, bypath)
+	for i := 1; i < len(batch); i++ {
+		if batch[i-1].Path() > batch[i].Path() {
+			t.Errorf("want %q before %q (i=%d)", batch[i].Path(), batch[i-1].Path(), i)
+		}
+	}
+}
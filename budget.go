@@ -0,0 +1,76 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "sync"
+
+// WithReadBudget bounds the total number of bytes the options reading
+// the content of the files, i.e. WithContentHash, read concurrently for
+// the watchpoint to n. Once it is used up, the following reads are queued up
+// until the ones in progress are done, so that a burst of events for large
+// files, e.g. a directory of them changed at once, does not read them all into
+// memory at the same time:
+//
+//   opts := []notify.Option{
+//       notify.WithContentHash(nil, 64<<20),
+//       notify.WithReadBudget(128 << 20),
+//   }
+//
+// A file larger than n is read once no other reads are in progress. The option
+// does not apply to the options, which do not read the content, e.g. WithStat,
+// nor to the functions given by the user, e.g. the decode one of WatchTyped.
+// If n is not positive, the reads are not bounded.
+func WithReadBudget(n int64) Option {
+	return func(o *options) {
+		o.budget = n
+	}
+}
+
+// budget bounds the total of the sizes acquired at the same time.
+type budget struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	max  int64
+	used int64
+}
+
+// newBudget gives a budget of max bytes, nil if max is not positive.
+func newBudget(max int64) *budget {
+	if max <= 0 {
+		return nil
+	}
+	b := &budget{max: max}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire blocks until n bytes, at most the whole budget, are available
+// and takes them. It gives back the number of bytes to release.
+func (b *budget) acquire(n int64) int64 {
+	if b == nil {
+		return 0
+	}
+	if n > b.max {
+		n = b.max
+	}
+	b.mu.Lock()
+	for b.used+n > b.max {
+		b.cond.Wait()
+	}
+	b.used += n
+	b.mu.Unlock()
+	return n
+}
+
+// release gives back n bytes taken with acquire.
+func (b *budget) release(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
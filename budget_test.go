@@ -0,0 +1,44 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudget(t *testing.T) {
+	if b := newBudget(0); b != nil {
+		t.Fatalf("want nil budget; got %v", b)
+	}
+	var none *budget
+	none.release(none.acquire(1 << 40))
+	b := newBudget(10)
+	first := b.acquire(6)
+	done := make(chan int64)
+	go func() {
+		done <- b.acquire(6)
+	}()
+	select {
+	case <-done:
+		t.Fatal("want acquire blocked over budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.release(first)
+	select {
+	case n := <-done:
+		b.release(n)
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	// Sizes larger than the budget take all of it.
+	if n := b.acquire(1 << 20); n != 10 {
+		t.Fatalf("want n=10; got %d", n)
+	}
+	b.release(10)
+	if b.used != 0 {
+		t.Fatalf("want used=0; got %d", b.used)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// internalBuffer is the size of the channels the watchers deliver events
+// to the trees with.
+var internalBuffer int32 = buffer
+
+var errBufferInUse = errors.New("internal buffer size cannot be changed while watchpoints are set up")
+
+// SetInternalBufferSize sets the number of events, which the underlying
+// watcher is able to report before it waits for notify to dispatch them, 128
+// by default. While it waits, it does not read from the kernel either, e.g.
+// from the inotify queue, which may overflow under a burst of changes; a larger
+// buffer lets the watcher keep reading while the events are filtered and
+// delivered, e.g. by the stages of the watchpoints set up with options. Sizing
+// the user channels does not help with that, since the events are dispatched
+// to them only after they are taken from the buffer.
+//
+// Every slot of a full buffer holds an event, which takes about a hundred
+// bytes plus the length of its path, so e.g. a buffer of 65536 events may hold
+// several megabytes under a burst. The memory is allocated for the slots
+// up front.
+//
+// SetInternalBufferSize fails if any watchpoint is set up, so it is meant to
+// be called before any of them are. The size of 0 or less restores the default.
+func SetInternalBufferSize(n int) error {
+	return defaultTree.SetInternalBufferSize(n)
+}
+
+// SetInternalBufferSize makes the underlying tree queue up to n events
+// reported by its watcher, unless any watchpoint is set up.
+func (t *pipeTree) SetInternalBufferSize(n int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pipes) != 0 {
+		return errBufferInUse
+	}
+	if n <= 0 {
+		n = buffer
+	}
+	atomic.StoreInt32(&internalBuffer, int32(n))
+	if q := queueOf(t.tree); q != nil {
+		q.resize(n)
+	}
+	return nil
+}
+
+// underlying gives the tree t delivers the events of.
+func (t *pipeTree) underlying() tree {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree
+}
+
+// queueOf gives the queue of the events reported to t by its watcher, nil if
+// t is not backed by a watcher.
+func queueOf(t tree) *queue {
+	switch t := t.(type) {
+	case *nonrecursiveTree:
+		return t.q
+	case *recursiveTree:
+		return t.q
+	}
+	return nil
+}
+
+// queue holds the events reported by a watcher until its tree dispatches
+// them. They are queued in the channel the watcher writes to, unless the queue
+// was resized to another size; then a relay takes them from the channel as
+// soon as they are reported and queues them in a buffer of that size.
+type queue struct {
+	c    chan EventInfo      // written by the watcher
+	next chan chan EventInfo // passes the channel to read the events from to loop
+	exit chan struct{}       // closed once loop returned
+	mu   sync.Mutex          // protects buf, stop and done
+	buf  chan EventInfo      // buffer of the relay, nil if there is none
+	stop chan struct{}       // stops the relay
+	done chan struct{}       // closed once the relay returned
+}
+
+func newQueue(c chan EventInfo) *queue {
+	return &queue{c: c, next: make(chan chan EventInfo), exit: make(chan struct{})}
+}
+
+// loop passes the events to fn, one at a time, until the channel of the
+// watcher is closed.
+func (q *queue) loop(fn func(EventInfo)) {
+	defer close(q.exit)
+	for c := q.c; ; {
+		select {
+		case ei, ok := <-c:
+			if !ok {
+				return
+			}
+			fn(ei)
+		case c = <-q.next:
+		}
+	}
+}
+
+// resize makes the queue hold up to n events. The events held by the relay
+// set up before are dropped, the queue is expected not to be delivering events
+// to any watchpoint.
+func (q *queue) resize(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		close(q.stop)
+		<-q.done
+		q.buf, q.stop, q.done = nil, nil, nil
+	}
+	c := q.c
+	if n != cap(q.c) {
+		q.buf, q.stop, q.done = make(chan EventInfo, n), make(chan struct{}), make(chan struct{})
+		go q.relay(q.buf, q.stop, q.done)
+		c = q.buf
+	}
+	select {
+	case q.next <- c:
+	case <-q.exit:
+	}
+}
+
+// relay moves the events from the channel of the watcher to buf, closing it
+// once the former is closed.
+func (q *queue) relay(buf chan<- EventInfo, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	for {
+		select {
+		case ei, ok := <-q.c:
+			if !ok {
+				close(buf)
+				return
+			}
+			select {
+			case buf <- ei:
+			case <-stop:
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// len gives the number of the events, which were not dispatched yet.
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.c) + len(q.buf)
+}
+
+// size gives the number of the events the queue holds before the watcher
+// waits for them to be dispatched.
+func (q *queue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.buf != nil {
+		return cap(q.buf)
+	}
+	return cap(q.c)
+}
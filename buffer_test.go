@@ -0,0 +1,51 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetInternalBufferSize(t *testing.T) {
+	defer atomic.StoreInt32(&internalBuffer, buffer)
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	cases := [...]struct {
+		n, want int
+	}{
+		{1024, 1024}, // i=0
+		{0, buffer},  // i=1
+	}
+	for i, cas := range cases {
+		must(tr.SetInternalBufferSize(cas.n))
+		if n := queueOf(tr.tree).size(); n != cas.want {
+			t.Errorf("want size=%d; got %d (i=%d)", cas.want, n, i)
+		}
+	}
+	// The tree is kept, the events are queued by the relay.
+	old := tr.tree
+	must(tr.SetInternalBufferSize(1024))
+	if tr.tree != old {
+		t.Fatal("want the tree kept")
+	}
+	must(tr.Watch(dir, ch[0], Create))
+	file := filepath.Join(dir, "file")
+	must(ioutil.WriteFile(file, nil, 0644))
+	select {
+	case ei := <-ch[0]:
+		if ei.Path() != file {
+			t.Fatalf("want event for %q; got %v", file, ei)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	if err := tr.SetInternalBufferSize(1024); err != errBufferInUse {
+		t.Errorf("want err=%v; got %v", errBufferInUse, err)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "sync"
+
+// Tee copies every event received from src to each of the dst channels.
+//
+// Watching the same path with several channels is already supported by Watch,
+// Tee is meant for the cases when the event stream is produced by a single
+// channel which is then fanned out to independent consumers. Like Watch, Tee
+// does not block sending to a dst channel - if a consumer is too slow to keep
+// up, the event is dropped for that consumer only.
+//
+// Tee returns immediately, copying is done in a separate goroutine which exits
+// once src gets closed. Since Stop does not close channels, it is up to the
+// caller to close src after it was stopped.
+func Tee(src <-chan EventInfo, dst ...chan<- EventInfo) {
+	go func() {
+		for ei := range src {
+			for _, c := range dst {
+				select {
+				case c <- ei:
+				default: // Drop event if receiver is too slow
+					dropped(ei)
+				}
+			}
+		}
+	}()
+}
+
+// merge describes a running Merge call.
+type merge struct {
+	once sync.Once // closes quit
+	quit chan struct{}
+	done chan struct{}
+}
+
+var merges = struct {
+	sync.Mutex
+	m map[chan EventInfo]*merge
+}{m: make(map[chan EventInfo]*merge)}
+
+// Merge fans in events received from all the given channels into a single
+// channel, e.g. to consume events of watchpoints set up with different
+// watchers as a single stream. Events received from the same channel are
+// delivered in the same order.
+//
+// The merged channel is closed once all the channels are closed, or once
+// the merge is stopped with Unmerge. Closed channels are skipped, so the
+// merge goes on as long as any of them is still open.
+func Merge(channels ...chan EventInfo) chan EventInfo {
+	c := make(chan EventInfo, buffer)
+	m := &merge{
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	merges.Lock()
+	merges.m[c] = m
+	merges.Unlock()
+	var wg sync.WaitGroup
+	wg.Add(len(channels))
+	for _, src := range channels {
+		go func(src chan EventInfo) {
+			defer wg.Done()
+			for {
+				select {
+				case ei, ok := <-src:
+					if !ok {
+						return
+					}
+					select {
+					case c <- ei:
+					case <-m.quit:
+						return
+					}
+				case <-m.quit:
+					return
+				}
+			}
+		}(src)
+	}
+	go func() {
+		wg.Wait()
+		merges.Lock()
+		delete(merges.m, c)
+		merges.Unlock()
+		close(c)
+		close(m.done)
+	}()
+	return c
+}
+
+// Unmerge stops the merge, which gave the merged channel. When Unmerge returns,
+// the merged channel is closed. Events already received from the merged
+// channels, but not delivered yet, are dropped. It is a nop if the merge was
+// already done, e.g. all the merged channels were closed.
+func Unmerge(merged chan EventInfo) {
+	merges.Lock()
+	m, ok := merges.m[merged]
+	merges.Unlock()
+	if !ok {
+		return
+	}
+	m.once.Do(func() { close(m.quit) })
+	<-m.done
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTee(t *testing.T) {
+	src := make(chan EventInfo)
+	ch := NewChans(2)
+	slow := make(chan EventInfo) // never ready, must not block the others
+	Tee(src, ch[0], slow, ch[1])
+	want := []Call{
+		{P: "/a", E: Create},
+		{P: "/a/b", E: Write},
+		{P: "/a", E: Remove},
+	}
+	for i := range want {
+		src <- &want[i]
+	}
+	close(src)
+	for i, c := range ch {
+		for j := range want {
+			select {
+			case ei := <-c:
+				if err := EqualEventInfo(&want[j], ei); err != nil {
+					t.Fatalf("%v (i=%d, j=%d)", err, i, j)
+				}
+			case <-time.After(timeout()):
+				t.Fatalf("timed out waiting for %v (i=%d, j=%d)", &want[j], i, j)
+			}
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	src := []chan EventInfo{make(chan EventInfo), make(chan EventInfo), make(chan EventInfo)}
+	close(src[2]) // closed channels are skipped
+	c := Merge(src...)
+	src[0] <- &Call{P: "/a", E: Create}
+	src[1] <- &Call{P: "/b", E: Write}
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case ei := <-c:
+			got[ei.Path()] = true
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+	if !got["/a"] || !got["/b"] {
+		t.Fatalf("want events for /a and /b; got %v", got)
+	}
+	close(src[0])
+	close(src[1])
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Fatal("want merged channel closed")
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out waiting for merged channel to be closed")
+	}
+	Unmerge(c) // nop
+}
+
+func TestUnmerge(t *testing.T) {
+	src := make(chan EventInfo)
+	c := Merge(src)
+	Unmerge(c)
+	if _, ok := <-c; ok {
+		t.Fatal("want merged channel closed")
+	}
+	select {
+	case src <- &Call{P: "/a", E: Create}:
+		t.Fatal("want no more events received after Unmerge")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
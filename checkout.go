@@ -0,0 +1,132 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkoutBurst is the number of events, which are delivered as a single one
+// by WatchCheckout, even if HEAD did not change.
+var checkoutBurst = 64
+
+// WatchCheckout watches the working tree of a git repository under root and
+// delivers to c the changes made to it, telling the bursts of changes made
+// by git itself, e.g. by a checkout, a branch switch or a rebase, with
+// a single event, instead of one per file:
+//
+//   c := make(chan notify.EventInfo, 16)
+//   if err := notify.WatchCheckout("/path/to/repo", c, 200*time.Millisecond); err != nil {
+//       log.Fatal(err)
+//   }
+//   for ei := range c {
+//       if ei.Path() == "/path/to/repo" {
+//           // the working tree changed as a whole, re-evaluate everything
+//       }
+//   }
+//
+// The events are held back until no change was made for window. If .git/HEAD
+// changed in the meantime, or more than 64 events were held back, a single
+// Write event for root is delivered instead of them. It implements
+// the CoalescedEventInfo interface, which Count tells how many changes it
+// stands for. Otherwise the events are delivered as they were reported.
+// The events for the paths under .git are never delivered themselves.
+//
+// Use Stop to remove watchpoints set up with WatchCheckout; the changes not
+// yet delivered are delivered before the watchpoint is removed.
+func WatchCheckout(root string, c chan<- EventInfo, window time.Duration) error {
+	return defaultTree.WatchCheckout(root, c, window)
+}
+
+// WatchCheckout watches root recursively with a pipe of c, which consolidates
+// the bursts of changes.
+func (t *pipeTree) WatchCheckout(root string, c chan<- EventInfo, window time.Duration) error {
+	dir, _, err := cleanpath(root)
+	if err != nil {
+		return err
+	}
+	s, flush := checkout(dir, window)
+	p, err := t.WatchPipe(filepath.Join(dir, "..."), c, []stage{s}, All)
+	if err != nil {
+		return err
+	}
+	t.OnStop(p, flush)
+	return nil
+}
+
+// checkout gives a stage, which holds back the events for root until there
+// were none for window, and then passes either them or, if they were a burst
+// or .git/HEAD changed, a single event for root on. The events under .git
+// are dropped. Calling flush passes on the events held back right away.
+func checkout(root string, window time.Duration) (s stage, flush func()) {
+	git := filepath.Join(root, ".git")
+	head := filepath.Join(git, "HEAD")
+	var fl flushers
+	s = func(next handler) handler {
+		var mu sync.Mutex
+		var t Timer
+		var gen int          // bumped on every event, invalidates older timers
+		var held []EventInfo // nil once the events are a burst
+		var n int            // number of the changes held back
+		var moved, burst bool
+		// fire passes on the events held back. It expects mu to be held.
+		fire := func() {
+			switch {
+			case moved || burst:
+				next(&coalesced{EventInfo: &synthetic{path: root, event: Write, dir: true}, n: n})
+			default:
+				for _, ei := range held {
+					next(ei)
+				}
+			}
+			held, n, moved, burst = nil, 0, false, false
+		}
+		fl.add(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if t != nil {
+				t.Stop()
+				t = nil
+			}
+			fire()
+		})
+		return func(ei EventInfo) {
+			path := normalize(ei.Path())
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case path == head:
+				moved = true
+				n += count(ei)
+			case path == git || strings.HasPrefix(path, git+sep):
+			case burst:
+				n += count(ei)
+			case len(held) == checkoutBurst:
+				held, burst = nil, true
+				n += count(ei)
+			default:
+				held = append(held, ei)
+				n += count(ei)
+			}
+			gen++
+			g := gen
+			if t != nil {
+				t.Stop()
+			}
+			t = afterFunc(window, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if gen == g {
+					t = nil
+					fire()
+				}
+			})
+		}
+	}
+	return s, fl.flush
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckout(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	root := filepath.Join(string(filepath.Separator), "repo")
+	path := func(rel string) string {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+	files := func(n int) (calls []Call) {
+		for i := 0; i < n; i++ {
+			calls = append(calls, Call{P: path("src/" + strconv.Itoa(i)), E: Write})
+		}
+		return calls
+	}
+	type delivered struct {
+		path string
+		n    int
+	}
+	var got []delivered
+	s, _ := checkout(root, time.Second)
+	fn := s(func(ei EventInfo) {
+		got = append(got, delivered{filepath.ToSlash(ei.Path()), count(ei)})
+	})
+	cases := [...]struct {
+		calls []Call
+		want  []delivered
+	}{
+		// i=0: a few changes are delivered as they are
+		{
+			[]Call{{P: path("a.go"), E: Write}, {P: path(".git/index"), E: Write}, {P: path("b.go"), E: Create}},
+			[]delivered{{"/repo/a.go", 1}, {"/repo/b.go", 1}},
+		},
+		// i=1: HEAD changed along with the working tree
+		{
+			append([]Call{{P: path(".git/HEAD"), E: Create}}, files(3)...),
+			[]delivered{{"/repo", 4}},
+		},
+		// i=2: a burst with no change of HEAD
+		{
+			files(checkoutBurst + 6),
+			[]delivered{{"/repo", checkoutBurst + 6}},
+		},
+		// i=3: changes under .git only
+		{
+			[]Call{{P: path(".git/objects/ab/cdef"), E: Create}, {P: path(".git"), E: Write}},
+			nil,
+		},
+	}
+	for i, cas := range cases {
+		got = nil
+		for j := range cas.calls {
+			fn(&cas.calls[j])
+			clk.Advance(time.Second / 2)
+		}
+		if len(got) != 0 {
+			t.Fatalf("want no events within the window; got %v (i=%d)", got, i)
+		}
+		clk.Advance(time.Second)
+		if !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want %v; got %v (i=%d)", cas.want, got, i)
+		}
+	}
+}
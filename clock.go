@@ -0,0 +1,121 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is a source of time for the time-dependent features of notify, like
+// WithDedup, WithStartupGrace, WithIdleTimeout or WithRetry, and for polling.
+type Clock interface {
+	// Now gives the current time.
+	Now() time.Time
+
+	// NewTimer calls fn in its own goroutine once d elapses, like
+	// time.AfterFunc does.
+	NewTimer(d time.Duration, fn func()) Timer
+}
+
+// Timer is a timer created by a Clock. *time.Timer implements it.
+type Timer interface {
+	// Stop prevents the timer from firing, it reports whether the call
+	// stopped the timer.
+	Stop() bool
+
+	// Reset makes the timer fire once d elapses, even if it has already
+	// fired or was stopped. It reports whether the timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// systemClock is a Clock backed by the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration, fn func()) Timer {
+	return time.AfterFunc(d, fn)
+}
+
+type clockValue struct {
+	Clock
+}
+
+var clock atomic.Value
+
+func init() {
+	clock.Store(clockValue{systemClock{}})
+}
+
+// SetClock makes notify use clk instead of the system clock, e.g. so that
+// tests can drive time forward manually and check time-dependent behavior
+// deterministically. Calling SetClock with nil clock restores the system one.
+// It affects timers started after the call only, so it is meant to be called
+// before any watchpoints are set up.
+func SetClock(clk Clock) {
+	if clk == nil {
+		clk = systemClock{}
+	}
+	clock.Store(clockValue{clk})
+}
+
+func loadClock() Clock {
+	return clock.Load().(clockValue).Clock
+}
+
+// now gives the current time of the registered clock.
+func now() time.Time {
+	return loadClock().Now()
+}
+
+// afterFunc calls fn once d elapses on the registered clock.
+func afterFunc(d time.Duration, fn func()) Timer {
+	return loadClock().NewTimer(d, fn)
+}
+
+// sleep pauses the current goroutine for d of the registered clock.
+func sleep(d time.Duration) {
+	c := make(chan struct{})
+	afterFunc(d, func() { close(c) })
+	<-c
+}
+
+// ticker delivers the ticks of the registered clock to C every d, dropping
+// them if the receiver is not ready, like time.Ticker does.
+type ticker struct {
+	C       <-chan time.Time
+	mu      sync.Mutex // protects stopped
+	t       Timer
+	stopped bool
+}
+
+func newTicker(d time.Duration) *ticker {
+	c := make(chan time.Time, 1)
+	tk := &ticker{C: c}
+	tk.mu.Lock()
+	defer tk.mu.Unlock()
+	tk.t = afterFunc(d, func() {
+		select {
+		case c <- now():
+		default:
+		}
+		tk.mu.Lock()
+		if !tk.stopped {
+			tk.t.Reset(d)
+		}
+		tk.mu.Unlock()
+	})
+	return tk
+}
+
+// Stop turns the ticker off.
+func (tk *ticker) Stop() {
+	tk.mu.Lock()
+	tk.stopped = true
+	tk.t.Stop()
+	tk.mu.Unlock()
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock, which time is moved forward manually. Timers fire
+// synchronously within Advance.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clk    *fakeClock
+	at     time.Time
+	fn     func()
+	active bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{clk: c, fn: fn}
+	t.Reset(d)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clk.mu.Lock()
+	defer t.clk.mu.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clk.mu.Lock()
+	defer t.clk.mu.Unlock()
+	active := t.active
+	if !active {
+		t.clk.timers = append(t.clk.timers, t)
+	}
+	t.at, t.active = t.clk.now.Add(d), true
+	return active
+}
+
+// Advance moves the time forward by d, firing the timers which are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		switch {
+		case !t.active:
+		case !t.at.After(c.now):
+			t.active = false
+			due = append(due, t)
+		default:
+			timers = append(timers, t)
+		}
+	}
+	c.timers = timers
+	c.mu.Unlock()
+	for _, t := range due {
+		t.fn()
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	if !now().Equal(clk.Now()) {
+		t.Fatalf("want now()=%v; got %v", clk.Now(), now())
+	}
+	var n int
+	fn := dedup(time.Minute, dedupSize, pathKey)(func(EventInfo) { n++ })
+	cases := [...]struct {
+		advance time.Duration
+		n       int
+	}{
+		{0, 1},                // i=0
+		{59 * time.Second, 1}, // i=1: within the window
+		{time.Minute, 2},      // i=2: window passed
+	}
+	for i, cas := range cases {
+		clk.Advance(cas.advance)
+		fn(&Call{P: "/a", E: Write})
+		if n != cas.n {
+			t.Fatalf("want n=%d; got %d (i=%d)", cas.n, n, i)
+		}
+	}
+	tk := newTicker(time.Second)
+	for i := 0; i < 3; i++ {
+		clk.Advance(time.Second)
+		select {
+		case <-tk.C:
+		default:
+			t.Fatalf("want tick (i=%d)", i)
+		}
+	}
+	tk.Stop()
+	clk.Advance(time.Second)
+	select {
+	case <-tk.C:
+		t.Fatal("want no tick after Stop")
+	default:
+	}
+	SetClock(nil)
+	if _, ok := loadClock().(systemClock); !ok {
+		t.Fatalf("want system clock; got %T", loadClock())
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+// CoalescedEventInfo is an EventInfo, which tells how many changes it stands
+// for, e.g. to decide between a lightweight update and a full rescan after
+// a burst of changes. The events which do not implement it, or report 1,
+// stand for a single change.
+//
+// FSEvents folds all the changes made to a path within its latency into
+// a single event, which flags tell only which kinds of changes were made, so
+// the count of its events is the number of the kinds, a lower bound of
+// the number of changes. Events delivered for watchpoints set up with
+// WithCreateCollapse count the Write events folded into the Create.
+type CoalescedEventInfo interface {
+	EventInfo
+	Count() int // number of native events folded into the event, at least 1
+}
+
+// count gives the number of changes ei stands for.
+func count(ei EventInfo) int {
+	if c, ok := ei.(CoalescedEventInfo); ok {
+		if n := c.Count(); n > 1 {
+			return n
+		}
+	}
+	return 1
+}
+
+// coalesced is an event, which stands for n changes.
+type coalesced struct {
+	EventInfo
+	n int
+}
+
+var _ CoalescedEventInfo = (*coalesced)(nil)
+
+func (e *coalesced) Count() int { return e.n }
@@ -0,0 +1,363 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config describes a set of watchpoints, which can be serialized, e.g. with
+// encoding/json, and set up again, e.g. by a new binary after a graceful
+// upgrade. It does not carry any events, only the watchpoints themselves;
+// see WatchSince for replaying the changes made in between under FSEvents.
+type Config struct {
+	Watches []WatchpointConfig
+
+	// Channels are the channels the events of the watchpoints are delivered
+	// to, indexed by WatchpointConfig.Channel. They are not serialized, so they
+	// must be set before calling Import in a new process.
+	Channels []chan<- EventInfo `json:"-"`
+}
+
+// WatchpointConfig describes a single watchpoint.
+type WatchpointConfig struct {
+	Channel int           // index of the channel in Config.Channels
+	Path    string        // watched path, with the "..." suffix if recursive
+	Events  Event         // events the path is watched for
+	Options *WatchOptions `json:",omitempty"` // nil if set up with Watch
+}
+
+// WatchOptions describes the options a watchpoint was set up with, see
+// WatchWithOptions. Options given by functions, i.e. WithProgress,
+// WithTransform, WithCoalesceKey and WithContentHash, cannot be described, so
+// they are not restored by Import.
+type WatchOptions struct {
+	Dedup                time.Duration `json:",omitempty"`
+	CreateCollapse       time.Duration `json:",omitempty"`
+	LeafEvents           bool          `json:",omitempty"`
+	StartupGrace         time.Duration `json:",omitempty"`
+	HardLinks            bool          `json:",omitempty"`
+	RetryAttempts        int           `json:",omitempty"`
+	RetryBackoff         time.Duration `json:",omitempty"`
+	RootEvents           bool          `json:",omitempty"`
+	IdleTimeout          time.Duration `json:",omitempty"`
+	Stat                 bool          `json:",omitempty"`
+	NoDotfiles           bool          `json:",omitempty"`
+	Gitignore            string        `json:",omitempty"`
+	Journal              int           `json:",omitempty"`
+	NoOwnEvents          bool          `json:",omitempty"`
+	NoSystemEvents       bool          `json:",omitempty"`
+	SizeTracking         bool          `json:",omitempty"`
+	Priority             int           `json:",omitempty"`
+	SymlinkTrack         bool          `json:",omitempty"`
+	DontFollow           bool          `json:",omitempty"`
+	OnlyDir              bool          `json:",omitempty"`
+	Backpressure         bool          `json:",omitempty"`
+	FollowMoves          bool          `json:",omitempty"`
+	MaxNodes             int           `json:",omitempty"`
+	NoOverlap            bool          `json:",omitempty"`
+	LingerUnwatch        time.Duration `json:",omitempty"`
+	DiscardOnStop        bool          `json:",omitempty"`
+	Serialize            bool          `json:",omitempty"`
+	Heartbeat            time.Duration `json:",omitempty"`
+	PathPair             bool          `json:",omitempty"`
+	AliasPaths           bool          `json:",omitempty"`
+	ExpansionConcurrency int           `json:",omitempty"`
+	OverflowBackoff      time.Duration `json:",omitempty"`
+	OverflowBackoffMax   time.Duration `json:",omitempty"`
+	Inode                bool          `json:",omitempty"`
+	Rules                []Rule        `json:",omitempty"`
+	CaseFolding          bool          `json:",omitempty"`
+	TotalOrder           bool          `json:",omitempty"`
+	Regexp               string        `json:",omitempty"`
+	HistoryDone          bool          `json:",omitempty"`
+	ReadBudget           int64         `json:",omitempty"`
+	PathState            bool          `json:",omitempty"`
+	DeliverPending       bool          `json:",omitempty"`
+	ScanBatch            time.Duration `json:",omitempty"`
+	MaxChildrenPerDir    int           `json:",omitempty"`
+	PathStyle            PathStyle     `json:",omitempty"`
+	MinAge               time.Duration `json:",omitempty"`
+	MaxAge               time.Duration `json:",omitempty"`
+	Confirm              bool          `json:",omitempty"`
+
+	// The profiles given to WithEditorProfiles.
+	EditorWindow time.Duration   `json:",omitempty"`
+	Editors      []EditorProfile `json:",omitempty"`
+}
+
+// Export describes the watchpoints set up with Watch, WatchWithOptions,
+// Reconcile and WatchReplace, which are in effect at the moment, sorted by
+// the channel and the path. The watchpoints set up by other means, e.g. with
+// WatchDigest or WatchFD, are not described.
+func Export() Config {
+	return defaultTree.Export()
+}
+
+// Import sets up the watchpoints described by cfg, delivering their events
+// to cfg.Channels. It sets up as many of them as it can, failing with
+// a ReconcileError listing the paths, which could not be watched.
+func Import(cfg Config) error {
+	return defaultTree.Import(cfg)
+}
+
+// watchSpec tells how a watchpoint was set up by the user.
+type watchSpec struct {
+	path   string // watched path, cleaned
+	events Event  // events given by the user, 0 for plain pipes
+	o      *options
+}
+
+// spec records how p was set up, unless it already was. It expects t.mu to
+// be held.
+func (t *pipeTree) spec(p *pipe, path string, e Event, o *options) {
+	if p == nil || p.spec != nil {
+		return
+	}
+	dir, isrec, err := cleanpath(path)
+	if err != nil {
+		return
+	}
+	if isrec {
+		dir = filepath.Join(dir, "...")
+	}
+	p.spec = &watchSpec{path: dir, events: e, o: o}
+}
+
+// Export describes the watchpoints of the tree.
+func (t *pipeTree) Export() (cfg Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	index := make(map[chan<- EventInfo]int)
+	for c, m := range t.pipes {
+		for key, pipes := range m {
+			for _, p := range pipes {
+				if p.spec == nil || p.closed() {
+					continue
+				}
+				i, ok := index[c]
+				if !ok {
+					i = len(cfg.Channels)
+					index[c] = i
+					cfg.Channels = append(cfg.Channels, c)
+				}
+				cfg.Watches = append(cfg.Watches, p.spec.export(i, key, p))
+			}
+		}
+	}
+	// Channels are numbered by the first path they watch, so that exporting
+	// the same watchpoints gives the same configuration.
+	sort.Sort(byWatchPath(cfg.Watches))
+	renum := make(map[int]int)
+	chans := make([]chan<- EventInfo, 0, len(cfg.Channels))
+	for i := range cfg.Watches {
+		w := &cfg.Watches[i]
+		n, ok := renum[w.Channel]
+		if !ok {
+			n = len(chans)
+			renum[w.Channel] = n
+			chans = append(chans, cfg.Channels[w.Channel])
+		}
+		w.Channel = n
+	}
+	cfg.Channels = chans
+	sort.Stable(byWatchChannel(cfg.Watches))
+	return cfg
+}
+
+// Import sets up the watchpoints described by cfg.
+func (t *pipeTree) Import(cfg Config) error {
+	var errs ReconcileError
+	fail := func(path string, err error) {
+		we, ok := err.(*WatchError)
+		if !ok {
+			we = &WatchError{Op: "import", Path: path, Err: err}
+		}
+		errs = append(errs, we)
+	}
+	for _, w := range cfg.Watches {
+		if w.Channel < 0 || w.Channel >= len(cfg.Channels) || cfg.Channels[w.Channel] == nil {
+			fail(w.Path, &WatchError{Op: "import", Path: w.Path, Err: errNoChannel(w.Channel)})
+			continue
+		}
+		c := cfg.Channels[w.Channel]
+		var err error
+		if w.Options == nil {
+			err = t.Watch(w.Path, c, w.Events)
+		} else {
+			err = t.WatchWithOptions(w.Path, c, w.Events, w.Options.options()...)
+		}
+		if err != nil {
+			fail(w.Path, err)
+		}
+	}
+	if len(errs) != 0 {
+		sort.Sort(errs)
+		return errs
+	}
+	return nil
+}
+
+// errNoChannel is the error of a watchpoint, which channel is missing.
+type errNoChannel int
+
+func (e errNoChannel) Error() string {
+	return "no channel " + strconv.Itoa(int(e)) + " given"
+}
+
+// export describes the watchpoint of p, registered under the key.
+func (s *watchSpec) export(channel int, key string, p *pipe) WatchpointConfig {
+	w := WatchpointConfig{Channel: channel, Path: s.path, Events: s.events}
+	if s.o == nil {
+		// Later watchpoints sharing the pipe may have made it recursive.
+		if w.Path, w.Events = key, p.events&^internal; p.rec {
+			w.Path = filepath.Join(key, "...")
+		}
+		return w
+	}
+	if s.o.follow {
+		// The watchpoint may have been moved along with the directory.
+		if w.Path = key; strings.HasSuffix(s.path, "...") {
+			w.Path = filepath.Join(key, "...")
+		}
+	}
+	w.Options = s.o.export()
+	return w
+}
+
+// export describes the options.
+func (o *options) export() *WatchOptions {
+	return &WatchOptions{
+		Dedup:                o.dedup,
+		CreateCollapse:       o.collapse,
+		LeafEvents:           o.leaves,
+		StartupGrace:         o.grace,
+		HardLinks:            o.hard,
+		RetryAttempts:        o.attempts,
+		RetryBackoff:         o.backoff,
+		RootEvents:           o.root,
+		IdleTimeout:          o.idle,
+		Stat:                 o.stat,
+		NoDotfiles:           o.nodot,
+		Gitignore:            o.ignore,
+		Journal:              o.journal,
+		NoOwnEvents:          o.noown,
+		NoSystemEvents:       o.nosys,
+		SizeTracking:         o.sizes,
+		Priority:             o.priority,
+		SymlinkTrack:         o.symlinks,
+		DontFollow:           o.flags&flagDontFollow != 0,
+		OnlyDir:              o.flags&flagOnlyDir != 0,
+		Backpressure:         o.backpressure,
+		FollowMoves:          o.follow,
+		MaxNodes:             o.maxNodes,
+		NoOverlap:            o.nooverlap,
+		LingerUnwatch:        o.linger,
+		DiscardOnStop:        o.discard,
+		Serialize:            o.serialize,
+		Heartbeat:            o.heartbeat,
+		PathPair:             o.pair,
+		AliasPaths:           o.alias,
+		ExpansionConcurrency: o.expansion,
+		OverflowBackoff:      o.overflow,
+		OverflowBackoffMax:   o.overflowMax,
+		Inode:                o.inode,
+		Rules:                o.rules,
+		CaseFolding:          o.fold,
+		TotalOrder:           o.order,
+		Regexp:               o.regexp,
+		HistoryDone:          o.history,
+		ReadBudget:           o.budget,
+		PathState:            o.state,
+		DeliverPending:       o.pending == DeliverPending,
+		ScanBatch:            o.scanBatch,
+		MaxChildrenPerDir:    o.maxChildren,
+		PathStyle:            o.style,
+		MinAge:               o.minAge,
+		MaxAge:               o.maxAge,
+		Confirm:              o.confirm,
+		EditorWindow:         o.editorWindow,
+		Editors:              o.editors,
+	}
+}
+
+// options gives the options described by o.
+func (o *WatchOptions) options() []Option {
+	opt := func(fn func(*options)) Option { return fn }
+	return []Option{opt(func(op *options) {
+		op.dedup = o.Dedup
+		op.collapse = o.CreateCollapse
+		op.leaves = o.LeafEvents
+		op.grace = o.StartupGrace
+		op.hard = o.HardLinks
+		op.attempts = o.RetryAttempts
+		op.backoff = o.RetryBackoff
+		op.root = o.RootEvents
+		op.idle = o.IdleTimeout
+		op.stat = o.Stat
+		op.nodot = o.NoDotfiles
+		op.ignore = o.Gitignore
+		op.journal = o.Journal
+		op.noown = o.NoOwnEvents
+		op.nosys = o.NoSystemEvents
+		op.sizes = o.SizeTracking
+		op.priority = o.Priority
+		op.symlinks = o.SymlinkTrack
+		if o.DontFollow {
+			op.flags |= flagDontFollow
+		}
+		if o.OnlyDir {
+			op.flags |= flagOnlyDir
+		}
+		op.backpressure = o.Backpressure
+		op.follow = o.FollowMoves
+		op.maxNodes = o.MaxNodes
+		op.nooverlap = o.NoOverlap
+		op.linger = o.LingerUnwatch
+		op.discard = o.DiscardOnStop
+		op.serialize = o.Serialize
+		op.heartbeat = o.Heartbeat
+		op.pair = o.PathPair
+		op.alias = o.AliasPaths
+		op.expansion = o.ExpansionConcurrency
+		op.overflow = o.OverflowBackoff
+		op.overflowMax = o.OverflowBackoffMax
+		op.inode = o.Inode
+		op.rules = o.Rules
+		op.fold = o.CaseFolding
+		op.order = o.TotalOrder
+		op.regexp = o.Regexp
+		op.history = o.HistoryDone
+		op.budget = o.ReadBudget
+		op.state = o.PathState
+		if o.DeliverPending {
+			op.pending = DeliverPending
+		}
+		op.scanBatch = o.ScanBatch
+		op.maxChildren = o.MaxChildrenPerDir
+		op.style = o.PathStyle
+		op.minAge = o.MinAge
+		op.maxAge = o.MaxAge
+		op.confirm = o.Confirm
+		op.editorWindow = o.EditorWindow
+		op.editors = o.Editors
+	})}
+}
+
+type byWatchPath []WatchpointConfig
+
+func (w byWatchPath) Len() int           { return len(w) }
+func (w byWatchPath) Less(i, j int) bool { return w[i].Path < w[j].Path }
+func (w byWatchPath) Swap(i, j int)      { w[i], w[j] = w[j], w[i] }
+
+type byWatchChannel []WatchpointConfig
+
+func (w byWatchChannel) Len() int           { return len(w) }
+func (w byWatchChannel) Less(i, j int) bool { return w[i].Channel < w[j].Channel }
+func (w byWatchChannel) Swap(i, j int)      { w[i], w[j] = w[j], w[i] }
@@ -0,0 +1,74 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 2)
+	defer tr.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		must(os.Mkdir(filepath.Join(dir, name), 0755))
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	if err := tr.Watch(path("a"), ch[0], Create, Remove); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Watch(path("b"), ch[1], Create); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Watch(path("b/..."), ch[1], Write); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.WatchWithOptions(path("c"), ch[1], Rename, WithDedup(time.Second), WithDotfiles(false)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.WatchDigest(path("c"), make(chan Digest), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	want := []WatchpointConfig{
+		{Channel: 0, Path: path("a"), Events: Create | Remove},
+		{Channel: 1, Path: path("b/..."), Events: Create | Write},
+		{Channel: 1, Path: path("c"), Events: Rename, Options: &WatchOptions{Dedup: time.Second, NoDotfiles: true}},
+	}
+	cfg := tr.Export()
+	if !reflect.DeepEqual(cfg.Watches, want) {
+		t.Fatalf("want cfg.Watches=%+v; got %+v", want, cfg.Watches)
+	}
+	if len(cfg.Channels) != 2 || cfg.Channels[0] != ch[0] || cfg.Channels[1] != ch[1] {
+		t.Fatalf("want cfg.Channels=%v; got %v", ch, cfg.Channels)
+	}
+	p, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Config
+	if err := json.Unmarshal(p, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dec.Watches, want) {
+		t.Fatalf("want dec.Watches=%+v; got %+v", want, dec.Watches)
+	}
+	imp := newPipeTree(newTree())
+	defer imp.Close()
+	dec.Channels = []chan<- EventInfo{ch[0], ch[1]}
+	dec.Watches = append(dec.Watches, WatchpointConfig{Channel: 2, Path: path("a"), Events: Write})
+	err = imp.Import(dec)
+	if e, ok := err.(ReconcileError); !ok || len(e) != 1 || e[0].Path != path("a") {
+		t.Fatalf("want a ReconcileError for %q; got %v", path("a"), err)
+	}
+	if got := imp.Export(); !reflect.DeepEqual(got.Watches, want) {
+		t.Fatalf("want imported Watches=%+v; got %+v", want, got.Watches)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// WithConfirm makes notify confirm the Write events by checking whether
+// the file was changed at all, dropping the events, e.g. spurious IN_MODIFY,
+// which the filesystem or the application writing to the file report even
+// though neither its modification time nor its size changed. On each Write
+// event the file is stat'ed and its modification time and size are compared
+// with the ones it had, when the previous Write event was delivered for it.
+//
+// It costs a system call per Write event, which is cheaper than hashing the
+// content of the file, see WithContentHash, but a change keeping the size of
+// the file, made within the resolution of the modification times of the
+// filesystem, e.g. 2 seconds for FAT, is not told from no change at all.
+// The first Write event for a file is always delivered, as the state it had
+// before is not known, and so are the Write events for the files removed
+// before they were stat'ed, followed by their Remove events. The events, which
+// carry other events along with Write, are delivered as well.
+func WithConfirm() Option {
+	return func(o *options) {
+		o.confirm = true
+	}
+}
+
+// stamp is the state of a file compared by confirmWrites.
+type stamp struct {
+	mtime time.Time
+	size  int64
+}
+
+// confirmWrites gives a stage, which drops the Write events for the files,
+// which modification time and size did not change.
+func confirmWrites() stage {
+	var mu sync.Mutex
+	stamps := make(map[string]stamp)
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			path := normalize(ei.Path())
+			if ei.Event()&(Remove|Rename) != 0 {
+				mu.Lock()
+				delete(stamps, path)
+				mu.Unlock()
+			}
+			if ei.Event()&Write == 0 {
+				next(ei)
+				return
+			}
+			fi, err := os.Lstat(ei.Path())
+			mu.Lock()
+			prev, ok := stamps[path]
+			var cur stamp
+			if err == nil {
+				cur = stamp{mtime: fi.ModTime(), size: fi.Size()}
+				stamps[path] = cur
+			} else {
+				delete(stamps, path)
+			}
+			mu.Unlock()
+			if ok && err == nil && ei.Event() == Write && prev.size == cur.size && prev.mtime.Equal(cur.mtime) {
+				dbgprintf("dropped %v on %q: not changed", ei.Event(), ei.Path())
+				return
+			}
+			next(ei)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfirmWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_confirm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	var passed bool
+	fn := confirmWrites()(func(EventInfo) { passed = true })
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	cases := [...]struct {
+		content string // the file is removed, if empty
+		mtime   time.Duration
+		e       Event
+		passed  bool
+	}{
+		{"abc", 0, Write, true},                     // i=0
+		{"abc", 0, Write, false},                    // i=1: spurious
+		{"abc", time.Second, Write, true},           // i=2: touched
+		{"abcd", time.Second, Write, true},          // i=3: grown
+		{"abcd", time.Second, Write | Create, true}, // i=4
+		{"", 0, Write, true},                        // i=5: removed before the stat
+		{"abcd", time.Second, Write, true},          // i=6: not known since
+		{"abcd", time.Second, Remove, true},         // i=7
+		{"abcd", time.Second, Write, true},          // i=8
+	}
+	for i, cas := range cases {
+		if cas.content == "" {
+			os.Remove(file)
+		} else {
+			must(ioutil.WriteFile(file, []byte(cas.content), 0644))
+			must(os.Chtimes(file, mtime.Add(cas.mtime), mtime.Add(cas.mtime)))
+		}
+		passed = false
+		fn(&Call{P: file, E: cas.e})
+		if passed != cas.passed {
+			t.Errorf("want passed=%t; got %t (i=%d)", cas.passed, passed, i)
+		}
+	}
+}
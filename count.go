@@ -0,0 +1,209 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CountEvent tells that the number of entries of a directory watched with
+// WatchCount crossed one of the thresholds.
+type CountEvent struct {
+	Dir       string // the watched directory
+	Count     int    // number of the entries of the directory
+	Threshold int    // the threshold, which was crossed
+	Above     bool   // whether Count reached Threshold or dropped below it
+}
+
+// WatchCount watches the directory dir and, instead of the individual events,
+// delivers to c a CountEvent whenever the number of its entries reaches one
+// of the thresholds, or drops below one of them, e.g. for alerting on work
+// queues implemented as directories:
+//
+//   c := make(chan notify.CountEvent, 1)
+//   if err := notify.WatchCount("/var/spool/queue", c, 100, 1000); err != nil {
+//       log.Fatal(err)
+//   }
+//
+// The entries are counted once when the watchpoint is set up, and then kept
+// track of with the events reported for the directory. The thresholds crossed
+// by the initial count are not reported, and neither are the ones an entry
+// renamed within the directory would cross for a moment. Like events,
+// the CountEvents are delivered without blocking; if c is not ready, the latest
+// crossing is delivered on the next change of the count instead. Like with
+// WatchDiff, the events of the watchpoint are never dropped, so the count does
+// not go out of sync.
+//
+// Use StopCount to remove watchpoints set up with WatchCount.
+func WatchCount(dir string, c chan<- CountEvent, thresholds ...int) error {
+	return defaultTree.WatchCount(dir, c, thresholds...)
+}
+
+// StopCount removes all watchpoints set up with WatchCount for c. When
+// StopCount returns, no more events are delivered to c.
+func StopCount(c chan<- CountEvent) {
+	defaultTree.StopCount(c)
+}
+
+// counter keeps track of the entries of a directory, delivering the crossings
+// of the thresholds.
+type counter struct {
+	c    chan EventInfo
+	done chan struct{}
+}
+
+// level gives the number of thresholds, which are reached by n.
+func level(thresholds []int, n int) int {
+	return sort.Search(len(thresholds), func(i int) bool { return thresholds[i] > n })
+}
+
+// countRenameWait is the time the counter waits for the Create reported for
+// the new name of an entry renamed within the watched directory.
+var countRenameWait = 50 * time.Millisecond
+
+// apply updates the entries of dir with the event, reporting whether it was
+// an entry renamed to a name, which may be reported next.
+func (cn *counter) apply(dir string, names map[string]struct{}, ei EventInfo) (renamed bool) {
+	path := ei.Path()
+	if filepath.Dir(path) != dir {
+		return false
+	}
+	name := filepath.Base(path)
+	if _, err := os.Lstat(path); err == nil {
+		names[name] = struct{}{}
+	} else if os.IsNotExist(err) {
+		if _, ok := names[name]; ok && ei.Event()&Rename != 0 {
+			renamed = true
+		}
+		delete(names, name)
+	}
+	return renamed
+}
+
+// loop updates the entries of dir with the events from cn.c, delivering
+// the crossings of the sorted thresholds to dst, until cn.c is closed.
+func (cn *counter) loop(dir string, names map[string]struct{}, dst chan<- CountEvent, thresholds []int) {
+	defer close(cn.done)
+	// delivered is the level of the count, which dst was last told about.
+	delivered := level(thresholds, len(names))
+	for ei := range cn.c {
+		renamed := cn.apply(dir, names, ei)
+		// The events already queued are applied before the count is checked,
+		// and so is the Create of the new name of a renamed entry, which is
+		// waited for; otherwise a rename would make the count drop for
+		// a moment, crossing the thresholds twice.
+		for open := true; open; {
+			if !renamed {
+				select {
+				case ei, ok := <-cn.c:
+					if open = ok; ok {
+						renamed = cn.apply(dir, names, ei)
+					}
+				default:
+					open = false
+				}
+				continue
+			}
+			wait := make(chan struct{})
+			t := afterFunc(countRenameWait, func() { close(wait) })
+			select {
+			case ei, ok := <-cn.c:
+				if open = ok; ok {
+					renamed = cn.apply(dir, names, ei) || ei.Event()&Create == 0
+				}
+			case <-wait:
+				renamed = false
+			}
+			t.Stop()
+		}
+		n := len(names)
+		cur := level(thresholds, n)
+		if cur == delivered {
+			continue
+		}
+		ce := CountEvent{Dir: dir, Count: n, Above: cur > delivered}
+		if ce.Above {
+			ce.Threshold = thresholds[cur-1]
+		} else {
+			ce.Threshold = thresholds[cur]
+		}
+		select {
+		case dst <- ce:
+			delivered = cur
+		default:
+			dbgprintf("count: dropped %+v: receiver too slow", ce)
+		}
+	}
+}
+
+// stop closes the channel of cn, which must have been already stopped within
+// the tree, and waits for its loop to exit.
+func (cn *counter) stop() {
+	close(cn.c)
+	<-cn.done
+}
+
+// WatchCount watches dir with a channel of its own, which events update
+// the entries of dir counted for c.
+func (t *pipeTree) WatchCount(dir string, c chan<- CountEvent, thresholds ...int) error {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	dir, _, err := cleanpath(dir)
+	if err != nil {
+		return err
+	}
+	thresholds = append([]int(nil), thresholds...)
+	sort.Ints(thresholds)
+	cn := &counter{c: make(chan EventInfo, buffer), done: make(chan struct{})}
+	p, err := t.WatchPipe(dir, cn.c, nil, Create|Remove|Rename)
+	if err != nil {
+		return err
+	}
+	t.Throttle(p)
+	// The entries are listed once the directory is watched, so that no entry
+	// created in the meantime is missed.
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Stop(cn.c)
+		return err
+	}
+	names := make(map[string]struct{}, len(fis))
+	for _, fi := range fis {
+		names[fi.Name()] = struct{}{}
+	}
+	t.mu.Lock()
+	t.counts[c] = append(t.counts[c], cn)
+	t.mu.Unlock()
+	go cn.loop(dir, names, c, thresholds)
+	return nil
+}
+
+// StopCount removes the watchpoints of all the counters of c.
+func (t *pipeTree) StopCount(c chan<- CountEvent) {
+	t.mu.Lock()
+	cns := t.counts[c]
+	delete(t.counts, c)
+	t.mu.Unlock()
+	for _, cn := range cns {
+		t.Stop(cn.c)
+		cn.stop()
+	}
+}
+
+// stopCounts stops the counters of all channels, once their pipes were
+// stopped. It expects t.mu to be held.
+func (t *pipeTree) stopCounts() {
+	for c, cns := range t.counts {
+		for _, cn := range cns {
+			cn.stop()
+		}
+		delete(t.counts, c)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLevel(t *testing.T) {
+	thresholds := []int{2, 5}
+	cases := [...]struct {
+		n     int
+		level int
+	}{
+		{0, 0}, // i=0
+		{1, 0}, // i=1
+		{2, 1}, // i=2
+		{4, 1}, // i=3
+		{5, 2}, // i=4
+		{9, 2}, // i=5
+	}
+	for i, cas := range cases {
+		if l := level(thresholds, cas.n); l != cas.level {
+			t.Errorf("want level=%d; got %d (i=%d)", cas.level, l, i)
+		}
+	}
+}
+
+func TestWatchCount(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	must(ioutil.WriteFile(filepath.Join(dir, "a"), nil, 0644))
+	c := make(chan CountEvent, 10)
+	must(tr.WatchCount(dir, c, 3, 2))
+	defer tr.StopCount(c)
+	cases := [...]struct {
+		fn func()
+		ce *CountEvent
+	}{
+		// i=0
+		{
+			func() { must(ioutil.WriteFile(filepath.Join(dir, "b"), nil, 0644)) },
+			&CountEvent{Dir: dir, Count: 2, Threshold: 2, Above: true},
+		},
+		// i=1
+		{
+			func() { must(os.Mkdir(filepath.Join(dir, "c"), 0755)) },
+			&CountEvent{Dir: dir, Count: 3, Threshold: 3, Above: true},
+		},
+		// i=2
+		{
+			func() { must(ioutil.WriteFile(filepath.Join(dir, "b"), []byte("XD"), 0644)) },
+			nil,
+		},
+		// i=3
+		{
+			func() { must(os.Rename(filepath.Join(dir, "c"), filepath.Join(dir, "d"))) },
+			nil,
+		},
+		// i=4
+		{
+			func() { must(os.Remove(filepath.Join(dir, "a"))) },
+			&CountEvent{Dir: dir, Count: 2, Threshold: 3},
+		},
+	}
+	for i, cas := range cases {
+		cas.fn()
+		if cas.ce == nil {
+			select {
+			case ce := <-c:
+				t.Fatalf("want no event; got %+v (i=%d)", ce, i)
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+		select {
+		case ce := <-c:
+			if ce != *cas.ce {
+				t.Fatalf("want %+v; got %+v (i=%d)", *cas.ce, ce, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "sort"
+
+// Descendants gives the directories, which the underlying watcher watches on
+// behalf of the recursive watchpoints covering root, sorted. It is meant for
+// debugging recursive watchpoints, e.g. to find out why no events were
+// reported for a file deep under the watched directory.
+//
+// Under watchers, which do not watch directory trees natively, e.g. inotify
+// or kqueue, every directory under a recursive watchpoint is watched on its
+// own, so Descendants lists root together with all the directories under it,
+// which are watched at the moment. Watchers, which watch directory trees
+// natively, e.g. FSEvents or ReadDirectoryChangesW, need no watches for
+// the directories under root, so Descendants gives just root for them.
+//
+// Descendants fails with ErrNotWatched, if root is not covered by a recursive
+// watchpoint.
+func Descendants(root string) ([]string, error) {
+	return defaultTree.Descendants(root)
+}
+
+// Descendants gives the directories watched recursively under root.
+func (t *pipeTree) Descendants(root string) ([]string, error) {
+	dir, _, err := cleanpath(root)
+	if err != nil {
+		return nil, err
+	}
+	dirs := descendants(t.underlying(), dir)
+	if len(dirs) == 0 {
+		return nil, &WatchError{Op: "descendants", Path: dir, Err: ErrNotWatched}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// descendants gives the directories of t watched recursively under dir.
+func descendants(t tree, dir string) (dirs []string) {
+	switch t := t.(type) {
+	case *nonrecursiveTree:
+		t.rw.RLock()
+		defer t.rw.RUnlock()
+		nd, err := t.root.Get(dir)
+		if err != nil {
+			return nil
+		}
+		if _, ok := nd.Watch[t.rec]; !ok {
+			return nil
+		}
+		nd.Walk(func(nd node) error {
+			if _, ok := nd.Watch[t.rec]; ok {
+				dirs = append(dirs, nd.Name)
+			}
+			return nil
+		})
+	case *recursiveTree:
+		t.rw.RLock()
+		defer t.rw.RUnlock()
+		t.root.WalkPath(dir, func(nd node, _ bool) error {
+			if watchTotal(nd) != 0 && watchIsRecursive(nd) {
+				dirs = []string{dir}
+				return errSkip
+			}
+			return nil
+		})
+	}
+	return dirs
+}
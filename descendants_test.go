@@ -0,0 +1,41 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDescendants(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	for _, sub := range []string{"a/b", "c"} {
+		must(os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0755))
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	c := make(chan EventInfo, buffer)
+	must(tr.Watch(path("a/..."), c, Create))
+	must(tr.Watch(path("c"), c, Create))
+	want := []string{path("a"), path("a/b")}
+	if _, native := watcherOf(tr.tree).(recursiveWatcher); native {
+		want = []string{path("a")}
+	}
+	got, err := tr.Descendants(path("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want Descendants()=%v; got %v", want, got)
+	}
+	_, err = tr.Descendants(path("c"))
+	if we, ok := err.(*WatchError); !ok || we.Err != ErrNotWatched {
+		t.Fatalf("want err=ErrNotWatched; got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sort"
+	"time"
+)
+
+// diffSettle is the time WatchDiff waits for the changes to settle, before
+// delivering a DirDiff.
+var diffSettle = 100 * time.Millisecond
+
+// DirDiff describes the changes made under a watched directory since
+// the previous DirDiff delivered for it. The paths are absolute and sorted.
+type DirDiff struct {
+	Root     string   // the watched directory
+	Added    []string // entries which did not exist before
+	Removed  []string // entries which no longer exist
+	Modified []string // files which size or modification time changed
+}
+
+// WatchDiff watches the directory dir, recursively if recursive is true, and
+// instead of the individual events delivers to c the differences between
+// the state of the directory at the time the previous DirDiff was delivered,
+// or the watchpoint was set up, and its current state, e.g. for file sync
+// tools. Notify keeps a snapshot of the directory, which it updates by
+// rescanning the paths it got events for, so the differences are net ones:
+// a file created and removed in the meantime is not listed, a renamed file is
+// listed as removed under its old path and as added under the new one.
+//
+// A DirDiff is delivered once the changes settled for a short time. Like
+// events, the diffs are delivered without blocking; if c is not ready,
+// the changes are carried over to the next DirDiff. The events of the
+// watchpoint are never dropped, the watchpoint waits for notify to process
+// them like with WithBackpressure, so the snapshot does not go out of sync.
+// Changes, which the underlying watcher itself does not report, e.g. once
+// its queue overflowed, are not noticed until the path is changed again.
+//
+// Use StopDiff to remove watchpoints set up with WatchDiff.
+func WatchDiff(dir string, c chan<- DirDiff, recursive bool) error {
+	return defaultTree.WatchDiff(dir, c, recursive)
+}
+
+// StopDiff removes all watchpoints set up with WatchDiff for c. When StopDiff
+// returns, no more diffs are delivered to c.
+func StopDiff(c chan<- DirDiff) {
+	defaultTree.StopDiff(c)
+}
+
+// differ delivers the differences of a snapshot updated on the events
+// received from c.
+type differ struct {
+	c    chan EventInfo
+	done chan struct{}
+}
+
+// loop updates the snapshot s with the events from d.c, delivering the net
+// changes to dst once they settled for d, until d.c is closed.
+func (d *differ) loop(s *snapshot, dst chan<- DirDiff, settle time.Duration) {
+	defer close(d.done)
+	fire := make(chan struct{}, 1)
+	signal := func() {
+		select {
+		case fire <- struct{}{}:
+		default:
+		}
+	}
+	var t Timer
+	arm := func() {
+		if t == nil {
+			t = afterFunc(settle, signal)
+		} else {
+			t.Reset(settle)
+		}
+	}
+	defer func() {
+		if t != nil {
+			t.Stop()
+		}
+	}()
+	dirty := make(map[string]struct{})
+	// existed tells for every changed path, whether it existed when
+	// the previous diff was delivered.
+	existed := make(map[string]bool)
+	for {
+		select {
+		case ei, ok := <-d.c:
+			if !ok {
+				return
+			}
+			dirty[ei.Path()] = struct{}{}
+			arm()
+		case <-fire:
+			for path := range dirty {
+				eis, err := s.update(path)
+				if err != nil {
+					dbgprintf("diff: rescanning %q failed: %v", path, err)
+					continue
+				}
+				for _, ei := range eis {
+					if _, ok := existed[ei.Path()]; !ok {
+						existed[ei.Path()] = ei.Event() != Create
+					}
+				}
+			}
+			dirty = make(map[string]struct{})
+			diff := DirDiff{Root: s.root}
+			for path, was := range existed {
+				_, is := s.m[path]
+				switch {
+				case !was && is:
+					diff.Added = append(diff.Added, path)
+				case was && !is:
+					diff.Removed = append(diff.Removed, path)
+				case was && is:
+					diff.Modified = append(diff.Modified, path)
+				}
+			}
+			if diff.Added == nil && diff.Removed == nil && diff.Modified == nil {
+				existed = make(map[string]bool)
+				continue
+			}
+			sort.Strings(diff.Added)
+			sort.Strings(diff.Removed)
+			sort.Strings(diff.Modified)
+			select {
+			case dst <- diff:
+				existed = make(map[string]bool)
+			default: // Carry the changes over if receiver is too slow
+				arm()
+			}
+		}
+	}
+}
+
+// stop closes the channel of d, which must have been already stopped within
+// the tree, and waits for its loop to exit.
+func (d *differ) stop() {
+	close(d.c)
+	<-d.done
+}
+
+// WatchDiff watches dir with a channel of its own, which events update
+// the snapshot of dir, which differences are delivered to c.
+func (t *pipeTree) WatchDiff(dir string, c chan<- DirDiff, recursive bool) error {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	dir, _, err := cleanpath(dir)
+	if err != nil {
+		return err
+	}
+	s, err := newSnapshot(dir, recursive)
+	if err != nil {
+		return err
+	}
+	path := dir
+	if recursive {
+		path = dir + sep + "..."
+	}
+	d := &differ{c: make(chan EventInfo, buffer), done: make(chan struct{})}
+	p, err := t.WatchPipe(path, d.c, nil, All)
+	if err != nil {
+		return err
+	}
+	t.Throttle(p)
+	t.mu.Lock()
+	t.diffs[c] = append(t.diffs[c], d)
+	t.mu.Unlock()
+	go d.loop(s, c, diffSettle)
+	return nil
+}
+
+// StopDiff removes the watchpoints of all the differs of c.
+func (t *pipeTree) StopDiff(c chan<- DirDiff) {
+	t.mu.Lock()
+	ds := t.diffs[c]
+	delete(t.diffs, c)
+	t.mu.Unlock()
+	for _, d := range ds {
+		t.Stop(d.c)
+		d.stop()
+	}
+}
+
+// stopDiffs stops the differs of all channels, once their pipes were stopped.
+// It expects t.mu to be held.
+func (t *pipeTree) stopDiffs() {
+	for c, ds := range t.diffs {
+		for _, d := range ds {
+			d.stop()
+		}
+		delete(t.diffs, c)
+	}
+}
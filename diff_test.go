@@ -0,0 +1,66 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWatchDiff(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	join := func(names ...string) (paths []string) {
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		return paths
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "a"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(dir, "old"), nil, 0644))
+	c := make(chan DirDiff, 1)
+	must(tr.WatchDiff(dir, c, true))
+	must(ioutil.WriteFile(filepath.Join(dir, "a"), []byte("changed"), 0644))
+	must(ioutil.WriteFile(filepath.Join(dir, "b"), nil, 0644))
+	must(os.MkdirAll(filepath.Join(dir, "sub", "x"), 0755))
+	must(ioutil.WriteFile(filepath.Join(dir, "tmp"), nil, 0644))
+	must(os.Remove(filepath.Join(dir, "tmp")))
+	must(os.Rename(filepath.Join(dir, "old"), filepath.Join(dir, "new")))
+	want := DirDiff{
+		Root:     dir,
+		Added:    join("b", "new", "sub", "sub/x"),
+		Removed:  join("old"),
+		Modified: join("a"),
+	}
+	// The changes may be split into several diffs, if they did not settle.
+	got := DirDiff{Root: dir}
+	for !reflect.DeepEqual(got, want) {
+		select {
+		case diff := <-c:
+			if diff.Root != dir {
+				t.Fatalf("want Root=%q; got %q", dir, diff.Root)
+			}
+			got.Added = append(got.Added, diff.Added...)
+			got.Removed = append(got.Removed, diff.Removed...)
+			got.Modified = append(got.Modified, diff.Modified...)
+			sort.Strings(got.Added)
+			sort.Strings(got.Removed)
+			sort.Strings(got.Modified)
+		case <-time.After(timeout()):
+			t.Fatalf("want %+v; got %+v", want, got)
+		}
+	}
+	select {
+	case diff := <-c:
+		t.Fatalf("want no more diffs; got %+v", diff)
+	case <-time.After(2 * diffSettle):
+	}
+	tr.StopDiff(c)
+}
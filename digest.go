@@ -0,0 +1,160 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sort"
+	"time"
+)
+
+// digestPaths is a maximum number of changed paths listed by a Digest.
+const digestPaths = 256
+
+// Digest summarizes the changes made under a watched root within a period.
+type Digest struct {
+	Root      string        // the watched directory
+	Since     time.Time     // the start of the period
+	Counts    map[Event]int // number of events per event, e.g. Create or Write
+	Paths     []string      // changed paths, sorted, at most digestPaths of them
+	Truncated bool          // whether more paths changed than Paths lists
+}
+
+// WatchDigest watches the root recursively and, instead of the individual
+// events, delivers a Digest of the changes made within the root to c every
+// interval, e.g. for a dashboard. The Digest counts the Create, Write, Remove
+// and Rename events, events having no portable value are counted as Unknown,
+// and it lists the changed paths. At most 256 distinct paths are listed; once
+// the limit is reached, Truncated is set and the following paths are only
+// counted. A Digest is delivered every interval, even if nothing changed.
+//
+// Like events, digests are delivered without blocking. If c is not ready when
+// the interval elapses, the changes are carried over to the next Digest, which
+// then covers a longer period, so no change goes uncounted.
+//
+// Use StopDigest to remove watchpoints set up with WatchDigest.
+func WatchDigest(root string, c chan<- Digest, interval time.Duration) error {
+	return defaultTree.WatchDigest(root, c, interval)
+}
+
+// StopDigest removes all watchpoints set up with WatchDigest for c. When
+// StopDigest returns, no more digests are delivered to c.
+func StopDigest(c chan<- Digest) {
+	defaultTree.StopDigest(c)
+}
+
+// newDigest gives an empty Digest for the root, starting at since.
+func newDigest(root string, since time.Time) *Digest {
+	return &Digest{
+		Root:   root,
+		Since:  since,
+		Counts: make(map[Event]int),
+	}
+}
+
+// add accounts ei in the digest, the set of paths remembers the paths listed
+// so far.
+func (d *Digest) add(ei EventInfo, paths map[string]struct{}) {
+	var counted bool
+	for _, e := range []Event{Create, Write, Remove, Rename} {
+		if ei.Event()&e != 0 {
+			d.Counts[e]++
+			counted = true
+		}
+	}
+	if !counted {
+		d.Counts[Unknown]++
+	}
+	path := ei.Path()
+	switch _, ok := paths[path]; {
+	case ok:
+	case len(paths) == digestPaths:
+		d.Truncated = true
+	default:
+		paths[path] = struct{}{}
+		d.Paths = append(d.Paths, path)
+	}
+}
+
+// digester delivers the digests of the events received from c.
+type digester struct {
+	c    chan EventInfo
+	done chan struct{}
+}
+
+// loop accounts the events in the digests for dir, delivering one to dst
+// every interval, until d.c is closed.
+func (d *digester) loop(dir string, dst chan<- Digest, interval time.Duration) {
+	defer close(d.done)
+	tk := newTicker(interval)
+	defer tk.Stop()
+	cur, paths := newDigest(dir, now()), make(map[string]struct{})
+	for {
+		select {
+		case ei, ok := <-d.c:
+			if !ok {
+				return
+			}
+			cur.add(ei, paths)
+		case <-tk.C:
+			sort.Strings(cur.Paths)
+			select {
+			case dst <- *cur:
+				cur, paths = newDigest(dir, now()), make(map[string]struct{})
+			default: // Carry the changes over if receiver is too slow
+			}
+		}
+	}
+}
+
+// stop closes the channel of d, which must have been already stopped within
+// the tree, and waits for its loop to exit.
+func (d *digester) stop() {
+	close(d.c)
+	<-d.done
+}
+
+// WatchDigest watches the root recursively with a channel of its own, which
+// events are summarized in the digests delivered to c.
+func (t *pipeTree) WatchDigest(root string, c chan<- Digest, interval time.Duration) error {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	dir, _, err := cleanpath(root)
+	if err != nil {
+		return err
+	}
+	d := &digester{c: make(chan EventInfo, buffer), done: make(chan struct{})}
+	if _, err := t.WatchPipe(dir+sep+"...", d.c, nil, All); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.digests[c] = append(t.digests[c], d)
+	t.mu.Unlock()
+	go d.loop(dir, c, interval)
+	return nil
+}
+
+// StopDigest removes the watchpoints of all the digesters of c.
+func (t *pipeTree) StopDigest(c chan<- Digest) {
+	t.mu.Lock()
+	ds := t.digests[c]
+	delete(t.digests, c)
+	t.mu.Unlock()
+	for _, d := range ds {
+		t.Stop(d.c)
+		d.stop()
+	}
+}
+
+// stopDigests stops the digesters of all channels, once their pipes were
+// stopped. It expects t.mu to be held.
+func (t *pipeTree) stopDigests() {
+	for c, ds := range t.digests {
+		for _, d := range ds {
+			d.stop()
+		}
+		delete(t.digests, c)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDigestAdd(t *testing.T) {
+	d, paths := newDigest("/", time.Time{}), make(map[string]struct{})
+	d.add(&Call{P: "/a", E: Create}, paths)
+	d.add(&Call{P: "/a", E: Write}, paths)
+	d.add(&Call{P: "/b", E: Remove}, paths)
+	d.add(&Call{P: "/b", E: Unknown}, paths)
+	want := map[Event]int{Create: 1, Write: 1, Remove: 1, Unknown: 1}
+	if !reflect.DeepEqual(d.Counts, want) {
+		t.Errorf("want counts=%v; got %v", want, d.Counts)
+	}
+	if want := []string{"/a", "/b"}; !reflect.DeepEqual(d.Paths, want) {
+		t.Errorf("want paths=%v; got %v", want, d.Paths)
+	}
+	for i := 0; i < digestPaths; i++ {
+		d.add(&Call{P: "/c" + strconv.Itoa(i), E: Write}, paths)
+	}
+	if len(d.Paths) != digestPaths || !d.Truncated {
+		t.Errorf("want %d paths and Truncated=true; got %d, %t", digestPaths, len(d.Paths), d.Truncated)
+	}
+	if d.Counts[Write] != digestPaths+1 {
+		t.Errorf("want %d writes counted; got %d", digestPaths+1, d.Counts[Write])
+	}
+}
+
+func TestPipeTreeWatchDigest(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	must(os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	c := make(chan Digest)
+	must(tr.WatchDigest(dir, c, 50*time.Millisecond))
+	file := filepath.Join(dir, "sub", "file")
+	must(ioutil.WriteFile(file, nil, 0644))
+	deadline := time.After(timeout())
+	for {
+		select {
+		case d := <-c:
+			if d.Root != dir {
+				t.Fatalf("want root=%q; got %q", dir, d.Root)
+			}
+			if d.Counts[Create] == 0 {
+				continue
+			}
+			if len(d.Paths) == 0 || d.Paths[0] != file {
+				t.Fatalf("want paths=[%q]; got %v", file, d.Paths)
+			}
+		case <-deadline:
+			t.Fatal("timed out")
+		}
+		break
+	}
+	tr.StopDigest(c)
+	if _, ok := tr.Events(dir); ok {
+		t.Errorf("want %q not to be watched after StopDigest", dir)
+	}
+	select {
+	case d := <-c:
+		t.Fatalf("want no digests after StopDigest; got %+v", d)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
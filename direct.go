@@ -0,0 +1,176 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stamps records the time of the latest event a tree dispatched to each of
+// the channels added to it. It stands in for the direct pipes, which do not
+// see the events they deliver.
+type stamps struct {
+	n  int32 // number of the channels added, accessed atomically
+	mu sync.RWMutex
+	m  map[chan<- EventInfo]*atomic.Value
+}
+
+func newStamps() *stamps {
+	return &stamps{m: make(map[chan<- EventInfo]*atomic.Value)}
+}
+
+// stampsOf gives the stamps of the tree, nil if it has none.
+func stampsOf(t tree) *stamps {
+	switch t := t.(type) {
+	case *nonrecursiveTree:
+		return t.st
+	case *recursiveTree:
+		return t.st
+	}
+	return nil
+}
+
+// add makes s record the time of the events dispatched to c.
+func (s *stamps) add(c chan<- EventInfo) {
+	s.mu.Lock()
+	if _, ok := s.m[c]; !ok {
+		s.m[c] = new(atomic.Value)
+		atomic.AddInt32(&s.n, 1)
+	}
+	s.mu.Unlock()
+}
+
+// remove stops recording the time of the events dispatched to c. It gives
+// the time of the latest one, the zero time if there was none.
+func (s *stamps) remove(c chan<- EventInfo) time.Time {
+	s.mu.Lock()
+	v, ok := s.m[c]
+	if ok {
+		delete(s.m, c)
+		atomic.AddInt32(&s.n, -1)
+	}
+	s.mu.Unlock()
+	return stamped(v)
+}
+
+// last gives the time of the latest event dispatched to c, the zero time if
+// there was none.
+func (s *stamps) last(c chan<- EventInfo) time.Time {
+	s.mu.RLock()
+	v := s.m[c]
+	s.mu.RUnlock()
+	return stamped(v)
+}
+
+// mark records an event was dispatched to c now, if c was added to s.
+func (s *stamps) mark(c chan<- EventInfo) {
+	if s == nil || atomic.LoadInt32(&s.n) == 0 {
+		return
+	}
+	s.mu.RLock()
+	v := s.m[c]
+	s.mu.RUnlock()
+	if v != nil {
+		v.Store(now())
+	}
+}
+
+func stamped(v *atomic.Value) time.Time {
+	if v != nil {
+		if t, ok := v.Load().(time.Time); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// rekeyer is implemented by the trees, which are able to move watchpoints from
+// one channel to another.
+type rekeyer interface {
+	// rekey makes all the watchpoints of c deliver events to d instead. No
+	// event is dispatched while they are moved.
+	rekey(c, d chan<- EventInfo)
+}
+
+// rekey moves the watchpoints of c found in the nodes under nd, including
+// the inactive ones, to d.
+func rekey(nd node, c, d chan<- EventInfo) {
+	stack := []node{nd}
+	for n := len(stack); n != 0; n = len(stack) {
+		nd, stack = stack[n-1], stack[:n-1]
+		nd.Watch.rekey(c, d)
+		for _, nd := range nd.Child {
+			stack = append(stack, nd)
+		}
+	}
+}
+
+// newDirectPipe creates a plain pipe, which the tree dispatches events to dst
+// for directly. It keeps the pipe's bookkeeping only and has no goroutine of
+// its own, until it is promoted.
+func newDirectPipe(dst chan<- EventInfo, act *activity, st *stamps) *pipe {
+	p := &pipe{
+		dst:   dst,
+		plain: true,
+		last:  now(),
+		act:   act,
+		st:    st,
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	close(p.done)
+	st.add(dst)
+	return p
+}
+
+// direct reports whether a new plain pipe of c may be a direct one. It is
+// not, when c already has one, since the tree is not able to tell apart
+// the watchpoints of two of them, or when c has middlewares, a rate, mutes
+// or generations, which only a pipe of its own applies. It expects t.mu to
+// be held.
+func (t *pipeTree) direct(c chan<- EventInfo) bool {
+	if _, ok := t.tree.(rekeyer); !ok || stampsOf(t.tree) == nil {
+		return false
+	}
+	if _, ok := t.gens[c]; ok || len(t.uses[c]) != 0 || t.rates[c] != nil || t.mutes[c] != nil {
+		return false
+	}
+	for _, pipes := range t.pipes[c] {
+		for _, p := range pipes {
+			if p.st != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// promote turns the direct pipe p into one with a channel of its own, which
+// the tree dispatches the events for p to from now on. It is a nop if p is not
+// direct or was already stopped. It expects t.mu to be held.
+func (t *pipeTree) promote(p *pipe) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.st == nil || p.stopped {
+		return
+	}
+	p.c, p.done = make(chan EventInfo, buffer), make(chan struct{})
+	go p.loop(p.send)
+	t.tree.(rekeyer).rekey(p.dst, p.c)
+	if last := p.st.remove(p.dst); last.After(p.last) {
+		p.last = last
+	}
+	p.st = nil
+}
+
+// ch gives the channel the tree dispatches the events for p to.
+func (p *pipe) ch() chan<- EventInfo {
+	if p.st != nil {
+		return p.dst
+	}
+	return p.c
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EditorProfile describes the files an editor creates while saving a file,
+// e.g. a temporary file renamed over the saved one or a backup of its previous
+// version, see WithEditorProfiles.
+type EditorProfile struct {
+	Name string   // name of the editor, e.g. "vim"
+	Temp []string // patterns of the base names of the files, as for filepath.Match
+}
+
+// Built-in editor profiles.
+var (
+	// EditorVim covers the file vim creates to check whether the directory is
+	// writable, its backup and swap files.
+	EditorVim = EditorProfile{Name: "vim", Temp: []string{"4913", "*~", ".*.sw?"}}
+
+	// EditorEmacs covers the backup, auto-save and lock files of Emacs.
+	EditorEmacs = EditorProfile{Name: "emacs", Temp: []string{"*~", "#*#", ".#*"}}
+
+	// EditorVSCode has no files of its own, since VS Code writes the files in
+	// place; the several changes a save takes are coalesced only.
+	EditorVSCode = EditorProfile{Name: "vscode"}
+
+	// EditorJetBrains covers the files of the "safe write" of the JetBrains
+	// IDEs, which writes a temporary file and renames it over the saved one.
+	EditorJetBrains = EditorProfile{Name: "jetbrains", Temp: []string{"*___jb_tmp___", "*___jb_old___"}}
+)
+
+// editorEvents are the events watched for while a save is recognized.
+const editorEvents = Create | Remove | Write | Rename
+
+// WithEditorProfiles makes notify deliver a single event for every file saved
+// by an editor, instead of the several ones the editor's way of saving takes,
+// e.g. renaming the file to a backup and writing a new one in its place:
+//
+//   opt := notify.WithEditorProfiles(200*time.Millisecond, notify.EditorVim, notify.EditorJetBrains)
+//   if err := notify.WatchWithOptions("./...", c, notify.Write, opt); err != nil {
+//       log.Fatal(err)
+//   }
+//
+// The events for the files described by the profiles are dropped. The events
+// for any other file are held back until no event was reported for it within
+// window, after which a single one is delivered: Create, if the first event
+// held back was a Create, a Write if the file exists, a Remove otherwise.
+// The event implements the CoalescedEventInfo interface, which Count tells how
+// many events it stands for. No event is delivered for a file created and
+// removed within the window. The events for directories are not held back.
+//
+// With no profiles given all the built-in ones are used. The watchpoint
+// watches for Create, Remove, Write and Rename regardless of the events given,
+// but only the events given are delivered. The events still held back, when
+// the watchpoint is removed, are delivered before it is.
+func WithEditorProfiles(window time.Duration, profiles ...EditorProfile) Option {
+	if len(profiles) == 0 {
+		profiles = []EditorProfile{EditorVim, EditorEmacs, EditorVSCode, EditorJetBrains}
+	}
+	return func(o *options) {
+		o.editorWindow = window
+		o.editors = profiles
+	}
+}
+
+// editorTemp reports whether the path is a file of any of the profiles.
+func editorTemp(profiles []EditorProfile, path string) bool {
+	base := filepath.Base(path)
+	for _, p := range profiles {
+		for _, pat := range p.Temp {
+			if ok, _ := filepath.Match(pat, base); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// editorSaves gives a stage, which drops the events for the files of
+// the profiles and passes a single event for the ones held back for a path
+// once there were none for window. Only the events from the given set are
+// passed on. Calling flush passes on the events held back right away.
+func editorSaves(profiles []EditorProfile, window time.Duration, e Event, key keyFunc) (s stage, flush func()) {
+	type held struct {
+		ei    EventInfo // first event held back
+		first Event     // its event, the one it had when it was held back
+		last  EventInfo // last event held back
+		n     int       // number of the events held back
+		t     Timer
+	}
+	// fold gives the event standing for the held back ones, nil if there
+	// should be none.
+	fold := func(h *held) EventInfo {
+		path := h.ei.Path()
+		var ev Event
+		switch _, err := os.Lstat(path); {
+		case err != nil && h.first == Create:
+			return nil
+		case err != nil:
+			ev = Remove
+		case h.first == Create:
+			ev = Create
+		default:
+			ev = Write
+		}
+		if ev&e == 0 {
+			return nil
+		}
+		if h.n == 1 && h.ei.Event() == ev {
+			return h.ei
+		}
+		if h.last.Event() == ev {
+			return &coalesced{EventInfo: h.last, n: h.n}
+		}
+		return &coalesced{EventInfo: &synthetic{path: path, event: ev}, n: h.n}
+	}
+	var fl flushers
+	s = func(next handler) handler {
+		var mu sync.Mutex
+		pending := make(map[string]*held)
+		pass := func(h *held) {
+			if ei := fold(h); ei != nil {
+				next(ei)
+			}
+		}
+		fl.add(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			keys := make([]string, 0, len(pending))
+			for k := range pending {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				h := pending[k]
+				h.t.Stop()
+				delete(pending, k)
+				pass(h)
+			}
+		})
+		// The mutex is held while passing the events on, so that an event
+		// passed by a timer is not reordered with later events for its path.
+		return func(ei EventInfo) {
+			if editorTemp(profiles, ei.Path()) {
+				return
+			}
+			if d, ok := ei.(isDirer); ok {
+				if isdir, err := d.isDir(); err == nil && isdir {
+					if ei.Event()&e != 0 {
+						next(ei)
+					}
+					return
+				}
+			}
+			k := key(ei)
+			mu.Lock()
+			defer mu.Unlock()
+			h, ok := pending[k]
+			if !ok {
+				h = &held{ei: ei, first: ei.Event()}
+				pending[k] = h
+			} else {
+				h.t.Stop()
+			}
+			h.n += count(ei)
+			h.last = ei
+			h.t = afterFunc(window, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if pending[k] == h && h.last == ei {
+					delete(pending, k)
+					pass(h)
+				}
+			})
+		}
+	}
+	return s, fl.flush
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEditorSaves(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	dir, err := ioutil.TempDir("", "notify_editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b", "c", "f"} {
+		must(ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	must(os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	var got []string
+	profiles := []EditorProfile{EditorVim, EditorJetBrains}
+	s, flush := editorSaves(profiles, time.Second, Create|Remove|Write, pathKey)
+	fn := s(func(ei EventInfo) {
+		got = append(got, fmt.Sprintf("%v %s %d", ei.Event(), filepath.Base(ei.Path()), count(ei)))
+	})
+	ev := func(name string, e Event) EventInfo {
+		return &Call{P: filepath.Join(dir, name), E: e, Dir: name == "sub"}
+	}
+	cases := [...]struct {
+		events  []EventInfo
+		advance time.Duration
+		want    []string
+	}{
+		// i=0: the backup dance of vim
+		{
+			[]EventInfo{ev("4913", Create), ev("4913", Remove), ev("a", Rename), ev("a~", Create),
+				ev("a", Create), ev("a", Write), ev("a~", Remove)},
+			time.Second,
+			[]string{"notify.Write a 3"},
+		},
+		// i=1: the safe write of JetBrains IDEs
+		{
+			[]EventInfo{ev("b___jb_tmp___", Create), ev("b___jb_tmp___", Write), ev("b", Rename),
+				ev("b___jb_tmp___", Rename), ev("b", Create), ev("b___jb_old___", Remove)},
+			time.Second,
+			[]string{"notify.Write b 2"},
+		},
+		// i=2: a new file
+		{
+			[]EventInfo{ev("c", Create), ev("c", Write), ev("c", Write)},
+			time.Second,
+			[]string{"notify.Create c 3"},
+		},
+		// i=3: a file created and removed within the window
+		{
+			[]EventInfo{ev("d", Create), ev("d", Write), ev("d", Remove)},
+			time.Second,
+			nil,
+		},
+		// i=4: a removed file, directories are passed right away
+		{
+			[]EventInfo{ev("e", Remove), ev("sub", Create)},
+			time.Second,
+			[]string{"notify.Create sub 1", "notify.Remove e 1"},
+		},
+		// i=5: the window is restarted by every event
+		{
+			[]EventInfo{ev("f", Write)},
+			time.Second / 2,
+			nil,
+		},
+		// i=6
+		{
+			[]EventInfo{ev("f", Write)},
+			time.Second / 2,
+			nil,
+		},
+		// i=7
+		{
+			nil,
+			time.Second / 2,
+			[]string{"notify.Write f 2"},
+		},
+	}
+	for i, cas := range cases {
+		got = nil
+		for _, ei := range cas.events {
+			fn(ei)
+		}
+		clk.Advance(cas.advance)
+		if !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want %v; got %v (i=%d)", cas.want, got, i)
+		}
+	}
+	got = nil
+	fn(ev("b", Write))
+	fn(ev("a", Write))
+	flush()
+	if want := []string{"notify.Write a 1", "notify.Write b 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
@@ -29,6 +29,19 @@ const (
 
 	// All is handful alias for all platform-independent event values.
 	All = Create | Remove | Write | Rename
+
+	// Unknown is reported for native events, which the underlying watcher
+	// recognized, but was not able to map to any of the above. It is never
+	// delivered unless requested explicitly. Currently it is reported under
+	// Linux (inotify), where the raw mask is available via EventInfo.Sys(),
+	// and Windows (ReadDirectoryChangesW) only.
+	Unknown = osSpecificUnknown
+
+	// Structure is handful alias for events describing changes to directory
+	// layout only - files added, removed or renamed. Write events are never
+	// delivered for it, even if the underlying watcher reports them coalesced
+	// with the requested ones.
+	Structure = Create | Remove | Rename
 )
 
 const internal = recursive | omit
@@ -123,11 +136,33 @@ func (e *event) String() string {
 	return e.Event().String() + `: "` + e.Path() + `"`
 }
 
+// synthetic is an event, which was not reported by the underlying watcher,
+// but generated by notify itself. Its Sys() always returns nil.
+type synthetic struct {
+	path  string
+	event Event
+	dir   bool
+}
+
+var _ fmt.Stringer = (*synthetic)(nil)
+var _ isDirer = (*synthetic)(nil)
+
+func (e *synthetic) Event() Event         { return e.event }
+func (e *synthetic) Path() string         { return e.path }
+func (e *synthetic) Sys() interface{}     { return nil }
+func (e *synthetic) isDir() (bool, error) { return e.dir, nil }
+
+// String implements fmt.Stringer interface.
+func (e *synthetic) String() string {
+	return e.Event().String() + `: "` + e.Path() + `"`
+}
+
 var estr = map[Event]string{
-	Create: "notify.Create",
-	Remove: "notify.Remove",
-	Write:  "notify.Write",
-	Rename: "notify.Rename",
+	Create:  "notify.Create",
+	Remove:  "notify.Remove",
+	Write:   "notify.Write",
+	Rename:  "notify.Rename",
+	Unknown: "notify.Unknown",
 	// Display name for recursive event is added only for debugging
 	// purposes. It's an internal event after all and won't be exposed to the
 	// user. Having Recursive event printable is helpful, e.g. for reading
@@ -17,6 +17,8 @@ const (
 	// omit is used for dispatching internal events; only those events are sent
 	// for which both the event and the watchpoint has omit in theirs event sets.
 	omit = Event(0x400000)
+	// unknown is reported for native events with no portable mapping
+	osSpecificUnknown = Event(0x800000)
 )
 
 // FSEvents specific event values.
@@ -40,6 +42,7 @@ const (
 	FSEventsIsFile                = 0x10000
 	FSEventsIsDir                 = 0x20000
 	FSEventsIsSymlink             = 0x40000
+	FSEventsOwnEvent              = 0x80000
 )
 
 var osestr = map[Event]string{
@@ -58,8 +61,17 @@ var osestr = map[Event]string{
 	FSEventsIsFile:          "notify.FSEventsIsFile",
 	FSEventsIsDir:           "notify.FSEventsIsDir",
 	FSEventsIsSymlink:       "notify.FSEventsIsSymlink",
+	FSEventsOwnEvent:        "notify.FSEventsOwnEvent",
 }
 
+// fseventsMeta are the flags of the metadata changes made by the system, e.g.
+// by Spotlight updating its extended attributes, while fseventsContent are
+// the flags of the changes made by users and applications.
+const (
+	fseventsMeta    = FSEventsInodeMetaMod | FSEventsFinderInfoMod | FSEventsXattrMod
+	fseventsContent = FSEventsCreated | FSEventsRemoved | FSEventsRenamed | FSEventsModified | FSEventsChangeOwner
+)
+
 type event struct {
 	fse   FSEvent
 	event Event
@@ -69,3 +81,25 @@ func (ei *event) Event() Event         { return ei.event }
 func (ei *event) Path() string         { return ei.fse.Path }
 func (ei *event) Sys() interface{}     { return &ei.fse }
 func (ei *event) isDir() (bool, error) { return ei.fse.Flags&FSEventsIsDir != 0, nil }
+func (ei *event) Own() bool            { return ei.fse.Flags&FSEventsOwnEvent != 0 }
+func (ei *event) ID() uint64           { return ei.fse.ID }
+
+// Count implements notify.CoalescedEventInfo interface. It gives the number of
+// kinds of changes the flags of the event tell about.
+func (ei *event) Count() int {
+	var n int
+	for f := ei.fse.Flags & (fseventsContent | fseventsMeta); f != 0; f &= f - 1 {
+		n++
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
+
+// isSystem reports whether the event tells only about metadata changes made
+// by another process, which is what indexing and scanning services do.
+func (ei *event) isSystem() bool {
+	f := ei.fse.Flags
+	return f&fseventsMeta != 0 && f&(fseventsContent|FSEventsOwnEvent) == 0
+}
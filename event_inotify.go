@@ -20,6 +20,10 @@ const (
 	// omit is used for dispatching internal events; only those events are sent
 	// for which both the event and the watchpoint has omit in theirs event sets.
 	omit
+	// skip IN_EXCL_UNLINK, so it is not mistaken for unknown
+	_
+	// unknown is reported for native events with no portable mapping
+	osSpecificUnknown
 )
 
 // Inotify specific masks are legal, implemented events that are guaranteed to
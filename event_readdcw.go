@@ -25,6 +25,8 @@ const (
 	omit
 	// dirmarker TODO(pknap)
 	dirmarker
+	// unknown is reported for native events with no portable mapping
+	osSpecificUnknown
 )
 
 // ReadDirectoryChangesW filters
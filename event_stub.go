@@ -19,6 +19,8 @@ const (
 	// omit is used for dispatching internal events; only those events are sent
 	// for which both the event and the watchpoint has omit in theirs event sets.
 	omit
+	// unknown is reported for native events with no portable mapping
+	osSpecificUnknown
 )
 
 var osestr = map[Event]string{}
@@ -0,0 +1,136 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// execDebounce is the default time Exec waits for the changes to settle.
+var execDebounce = 100 * time.Millisecond
+
+var errNoCommand = errors.New("notify: Exec using empty command")
+
+// ExecOption configures Exec.
+type ExecOption func(*execOptions)
+
+type execOptions struct {
+	debounce time.Duration
+	restart  bool
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// ExecDebounce makes Exec run the command once no events were reported for d.
+// It defaults to 100ms.
+func ExecDebounce(d time.Duration) ExecOption {
+	return func(o *execOptions) {
+		o.debounce = d
+	}
+}
+
+// ExecRestart makes Exec kill the command started previously, if it is still
+// running, before starting it again, e.g. for commands serving the build they
+// were given. Without the option every run of the command is started on its
+// own, regardless of the previous ones.
+func ExecRestart() ExecOption {
+	return func(o *execOptions) {
+		o.restart = true
+	}
+}
+
+// ExecOutput makes the command write its standard output and error to stdout
+// and stderr respectively. They default to os.Stdout and os.Stderr.
+func ExecOutput(stdout, stderr io.Writer) ExecOption {
+	return func(o *execOptions) {
+		o.stdout, o.stderr = stdout, stderr
+	}
+}
+
+// Exec watches the path for the given events and runs the command whenever
+// they are reported, e.g. to rebuild a project on every change:
+//
+//   err := notify.Exec(ctx, "./...", notify.Write, []string{"go", "build"})
+//
+// The events are debounced, so that a burst of changes, e.g. saving a bunch of
+// files, runs the command once, after the changes settled; see ExecDebounce.
+// The command is run with the environment of the process, extended with
+// NOTIFY_PATH and NOTIFY_EVENT variables telling the path and the event of
+// the last change.
+//
+// Exec blocks until ctx is done, then it kills the commands still running and
+// returns ctx.Err(). It fails early if the path cannot be watched or
+// the command cannot be started.
+func Exec(ctx context.Context, path string, events Event, command []string, opts ...ExecOption) error {
+	if len(command) == 0 {
+		return errNoCommand
+	}
+	o := execOptions{debounce: execDebounce, stdout: os.Stdout, stderr: os.Stderr}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := make(chan EventInfo, buffer)
+	defer Stop(c)
+	if err := Watch(path, c, events); err != nil {
+		return err
+	}
+	fire := make(chan struct{}, 1)
+	signal := func() {
+		select {
+		case fire <- struct{}{}:
+		default:
+		}
+	}
+	var t Timer
+	var last EventInfo
+	var done chan struct{} // closed once the restartable command exits
+	var running *exec.Cmd
+	for {
+		select {
+		case <-ctx.Done():
+			if t != nil {
+				t.Stop()
+			}
+			if done != nil {
+				<-done
+			}
+			return ctx.Err()
+		case ei := <-c:
+			last = ei
+			if t == nil {
+				t = afterFunc(o.debounce, signal)
+			} else {
+				t.Reset(o.debounce)
+			}
+		case <-fire:
+			if o.restart && done != nil {
+				running.Process.Kill()
+				<-done
+			}
+			cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+			cmd.Env = append(os.Environ(),
+				"NOTIFY_PATH="+last.Path(),
+				"NOTIFY_EVENT="+last.Event().String(),
+			)
+			cmd.Stdout, cmd.Stderr = o.stdout, o.stderr
+			if err := cmd.Start(); err != nil {
+				return err
+			}
+			exited := make(chan struct{})
+			go func() {
+				cmd.Wait()
+				close(exited)
+			}()
+			if o.restart {
+				running, done = cmd, exited
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !windows,!plan9
+
+package notify
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	must(os.Mkdir(src, 0755))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errc := make(chan error, 1)
+	cmd := []string{"sh", "-c", `echo "$NOTIFY_EVENT $NOTIFY_PATH" >> "$0"`, out}
+	go func() {
+		errc <- Exec(ctx, src, Create, cmd, ExecDebounce(50*time.Millisecond))
+	}()
+	file := filepath.Join(src, "file")
+	deadline := time.After(timeout())
+	for {
+		if p, err := ioutil.ReadFile(out); err == nil {
+			want := "notify.Create " + file + "\n"
+			if string(p) != want {
+				t.Fatalf("want out=%q; got %q", want, p)
+			}
+			break
+		}
+		// The watchpoint may have not been set up yet.
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			must(ioutil.WriteFile(file, nil, 0644))
+		} else {
+			must(os.Remove(file))
+		}
+		select {
+		case err := <-errc:
+			t.Fatalf("want Exec to run until ctx is done; got %v", err)
+		case <-deadline:
+			t.Fatal("timed out waiting for the command to run")
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	cancel()
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Fatalf("want err=context.Canceled; got %v", err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out waiting for Exec to return")
+	}
+	if err := Exec(context.Background(), src, Create, nil); err != errNoCommand {
+		t.Fatalf("want err=errNoCommand; got %v", err)
+	}
+	if p, _ := ioutil.ReadFile(out); strings.Count(string(p), "\n") != 1 {
+		t.Fatalf("want the command to run once; got %q", p)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WithExpansionConcurrency makes notify register at most n directories at
+// a time, while setting up a recursive watchpoint under watchers, which do
+// not watch directory trees natively, e.g. inotify or kqueue. By default
+// the directories are listed and watched one after another; for very large
+// trees reading the directories and setting up their watches concurrently
+// arms the watchpoint faster, while n bounds the burst of system calls it
+// takes. The directories created later on are watched one at a time as usual.
+//
+// The option has no effect for non-recursive watchpoints and for watchers,
+// which watch directory trees natively. Combined with WithProgress, the
+// directories are reported in the order their watches were set up.
+func WithExpansionConcurrency(n int) Option {
+	return func(o *options) {
+		o.expansion = n
+	}
+}
+
+// SetExpansion makes the next recursive watch of dir set up by the underlying
+// tree register at most n directories at a time.
+func (t *pipeTree) SetExpansion(dir string, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t, ok := t.tree.(*nonrecursiveTree); ok {
+		t.rw.Lock()
+		if t.expansion == nil {
+			t.expansion = make(map[string]int)
+		}
+		t.expansion[dir] = n
+		t.rw.Unlock()
+	}
+}
+
+// expandFunc gives the traversal of the directories under nd, which calls
+// the walk function for at most n of them at a time, or nd.AddDir if n is not
+// greater than 1. It expects t.rw to be held.
+func (t *nonrecursiveTree) expandFunc(nd node) func(walkFunc) error {
+	n := t.expansion[nd.Name]
+	delete(t.expansion, nd.Name)
+	if n <= 1 {
+		return nd.AddDir
+	}
+	return func(fn walkFunc) error {
+		return expand(nd, fn, n)
+	}
+}
+
+// expand works like nd.AddDir, but it calls fn for and lists the directories
+// of each level of the tree with n goroutines. The function is expected to
+// modify only the node it is called for, the children are added by the calling
+// goroutine only.
+func expand(nd node, fn walkFunc, n int) error {
+	type result struct {
+		walk error // given by fn
+		fis  []os.FileInfo
+		err  error
+	}
+	top := nd.Name
+	sem := make(chan struct{}, n)
+	for level := []node{nd}; len(level) != 0; {
+		res := make([]result, len(level))
+		var wg sync.WaitGroup
+		for i := range level {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer func() { <-sem; wg.Done() }()
+				name := level[i].Name
+				if res[i].walk = fn(level[i]); res[i].walk != nil {
+					return
+				}
+				if name == top {
+					res[i].fis, res[i].err = ioutil.ReadDir(name)
+				} else {
+					res[i].fis, res[i].err = readSubdir(name)
+				}
+			}(i)
+		}
+		wg.Wait()
+		var next []node
+		for i, nd := range level {
+			switch err := res[i].walk; err {
+			case nil:
+			case errSkip:
+				continue
+			default:
+				return &os.PathError{Op: "error while traversing", Path: nd.Name, Err: err}
+			}
+			if err := res[i].err; err != nil {
+				// Unreadable subdirectories are skipped like with AddDir.
+				if nd.Name != top {
+					if !vanished(err) {
+						report(nd.Name, err, nil)
+					}
+					continue
+				}
+				return err
+			}
+			for _, fi := range res[i].fis {
+				if fi.Mode()&(os.ModeSymlink|os.ModeDir) == os.ModeDir {
+					name := filepath.Join(nd.Name, fi.Name())
+					next = append(next, nd.addchild(name, name[len(nd.Name)+1:]))
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithExpansionConcurrency(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	var leaves []string
+	for _, a := range []string{"a", "b", "c"} {
+		for _, b := range []string{"x", "y"} {
+			leaf := filepath.Join(dir, a, b)
+			must(os.MkdirAll(leaf, 0755))
+			leaves = append(leaves, leaf)
+		}
+	}
+	c := make(chan EventInfo, buffer)
+	must(tr.WatchWithOptions(filepath.Join(dir, "..."), c, Create, WithExpansionConcurrency(4)))
+	dirs, err := tr.Descendants(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Watchers watching directory trees natively watch the root only.
+	if len(dirs) != 1 {
+		want := append([]string{dir}, leaves...)
+		for _, a := range []string{"a", "b", "c"} {
+			want = append(want, filepath.Join(dir, a))
+		}
+		sort.Strings(want)
+		if !reflect.DeepEqual(dirs, want) {
+			t.Fatalf("want %v watched; got %v", want, dirs)
+		}
+	}
+	for i, leaf := range leaves {
+		file := filepath.Join(leaf, "file")
+		must(ioutil.WriteFile(file, nil, 0644))
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&Call{P: file, E: Create}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+}
+
+func TestExpandWalkFunc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_expand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, a := range []string{"a", "b", "c"} {
+		must(os.MkdirAll(filepath.Join(dir, a, "x"), 0755))
+	}
+	var mu sync.Mutex
+	var got []string
+	fn := func(nd node) error {
+		mu.Lock()
+		got = append(got, nd.Name)
+		mu.Unlock()
+		if filepath.Base(nd.Name) == "b" {
+			return errSkip
+		}
+		return nil
+	}
+	if err := expand(newnode(dir), fn, 4); err != nil {
+		t.Fatal(err)
+	}
+	// The directories under the skipped one are not walked.
+	want := []string{dir}
+	for _, name := range []string{"a", "a/x", "b", "c", "c/x"} {
+		want = append(want, filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v walked; got %v", want, got)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrTooManyChildren is reported to the OnError hook for a directory, which
+// subdirectories are not watched by a recursive watchpoint set up with
+// WithMaxChildrenPerDir.
+var ErrTooManyChildren = errors.New("too many subdirectories to watch recursively")
+
+// WithMaxChildrenPerDir limits the fan-out of a recursive watchpoint: if
+// a directory under the watched path has more than n immediate
+// subdirectories, the directory is watched non-recursively, i.e. the events
+// for its entries are delivered, but none of its subdirectories is watched.
+// The decision is reported to the OnError hook with ErrTooManyChildren and the
+// directories watched that way are described by Stats, together with their
+// subdirectory counts:
+//
+//   if err := notify.WatchWithOptions("/srv/...", c, notify.All, notify.WithMaxChildrenPerDir(1000)); err != nil {
+//       log.Fatal(err)
+//   }
+//   for dir, n := range notify.Stats().Crowded {
+//       log.Printf("%s has %d subdirectories, they are not watched", dir, n)
+//   }
+//
+// It protects the watchpoint from a pathological directory, which would
+// otherwise take a watch for each of its children, while the rest of the tree
+// is watched as usual. The subdirectories are counted once a directory is
+// listed, when the watchpoint is set up or the directory is created, the
+// subdirectories created later on do not count. The ignored directories, see
+// WithRules, do not count either.
+//
+// The option has no effect for non-recursive watchpoints and for watchers,
+// which watch directory trees natively.
+func WithMaxChildrenPerDir(n int) Option {
+	return func(o *options) {
+		o.maxChildren = n
+	}
+}
+
+// fanout keeps the directories found to have too many subdirectories for
+// a single watchpoint.
+type fanout struct {
+	max     int
+	mu      sync.Mutex
+	crowded map[string]int // subdirectory counts of the crowded directories
+}
+
+// newFanout gives a fanout limiting the subdirectories to max, nil if max is
+// not greater than 0.
+func newFanout(max int) *fanout {
+	if max <= 0 {
+		return nil
+	}
+	return &fanout{max: max, crowded: make(map[string]int)}
+}
+
+// known reports whether dir was found to be crowded already.
+func (f *fanout) known(dir string) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.crowded[dir]
+	return ok
+}
+
+// pruner gives a function reporting whether the subdirectories of dir are not
+// watched, counting the ones the skip function does not report as ignored
+// relative to top. Each directory is counted once per pruner. It gives nil,
+// if f is nil.
+func (f *fanout) pruner(top string, skip skipFunc) func(dir string) bool {
+	if f == nil {
+		return nil
+	}
+	counted := make(map[string]bool)
+	return func(dir string) bool {
+		if crowded, ok := counted[dir]; ok {
+			return crowded
+		}
+		crowded := f.count(top, dir, skip)
+		counted[dir] = crowded
+		return crowded
+	}
+}
+
+// count counts the subdirectories of dir, recording and reporting dir if
+// there are more of them than allowed.
+func (f *fanout) count(top, dir string, skip skipFunc) bool {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	n := 0
+	for _, fi := range fis {
+		if fi.Mode()&(os.ModeSymlink|os.ModeDir) != os.ModeDir {
+			continue
+		}
+		if rel, ok := relpath(top, filepath.Join(dir, fi.Name())); ok && skip(rel, true) {
+			continue
+		}
+		n++
+	}
+	if n <= f.max {
+		return false
+	}
+	f.mu.Lock()
+	_, ok := f.crowded[dir]
+	f.crowded[dir] = n
+	f.mu.Unlock()
+	if !ok {
+		report(dir, &WatchError{Op: "watch", Path: dir, Err: ErrTooManyChildren}, nil)
+	}
+	return true
+}
+
+// TrackFanout makes Stats describe the crowded directories f finds for p.
+func (t *pipeTree) TrackFanout(p *pipe, f *fanout) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !p.closed() {
+		p.fan = f
+	}
+}
+
+// crowdedStats adds the crowded directories of all the pipes to s.
+func (t *pipeTree) crowdedStats(s *TreeStats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, pipes := range t.pipes {
+		for _, ps := range pipes {
+			for _, p := range ps {
+				if p.fan == nil {
+					continue
+				}
+				p.fan.mu.Lock()
+				for dir, n := range p.fan.crowded {
+					if s.Crowded == nil {
+						s.Crowded = make(map[string]int)
+					}
+					s.Crowded[dir] = n
+				}
+				p.fan.mu.Unlock()
+			}
+		}
+	}
+}
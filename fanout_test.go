@@ -0,0 +1,75 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithMaxChildrenPerDir(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	for _, sub := range []string{"a/x", "a/y", "a/z", "b/p"} {
+		must(os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0755))
+	}
+	if _, native := watcherOf(tr.tree).(recursiveWatcher); native {
+		t.Skip("the watcher watches directory trees natively")
+	}
+	var mu sync.Mutex
+	var failed []string
+	SetHooks(Hooks{OnError: func(path string, err error) {
+		if we, ok := err.(*WatchError); ok && we.Err == ErrTooManyChildren {
+			mu.Lock()
+			failed = append(failed, path)
+			mu.Unlock()
+		}
+	}})
+	defer SetHooks(Hooks{})
+	a := filepath.Join(dir, "a")
+	c := make(chan EventInfo, buffer)
+	o := options{maxChildren: 2}
+	must(o.watch(tr.pipeTree, filepath.Join(dir, "..."), c, Create))
+	mu.Lock()
+	if want := []string{a}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("want %v reported; got %v", want, failed)
+	}
+	mu.Unlock()
+	if want := map[string]int{a: 3}; !reflect.DeepEqual(tr.Stats().Crowded, want) {
+		t.Errorf("want Crowded=%v; got %v", want, tr.Stats().Crowded)
+	}
+	files := [...]string{"a/x/f", "a/f", "b/p/f"}
+	for _, file := range files {
+		must(ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(file)), nil, 0644))
+	}
+	var got []string
+	for range files[1:] {
+		select {
+		case ei := <-c:
+			got = append(got, filepath.ToSlash(ei.Path()[len(dir)+1:]))
+		case <-time.After(timeout()):
+			t.Fatalf("timed out waiting for %v; got %v", files[1:], got)
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"a/f", "b/p/f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want events for %v; got %v", want, got)
+	}
+	select {
+	case ei := <-c:
+		t.Errorf("want no event for %q; got %v", files[0], ei)
+	case <-time.After(50 * time.Millisecond):
+	}
+	tr.Stop(c)
+	if s := tr.Stats(); s.Crowded != nil {
+		t.Errorf("want Crowded=nil after Stop; got %v", s.Crowded)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "io"
+
+// WatchFD watches the file or directory referred to by the open file
+// descriptor fd, instead of by its path, so the watch survives renames of
+// the file. The events are reported under the path the descriptor currently
+// resolves to. It makes it possible to watch files with no name at all, e.g.
+// ones opened with O_TMPFILE, which are reported to gain a name, once linked
+// into the filesystem with linkat(2), by the InAttrib event, as the kernel
+// reports the change of their link count so.
+//
+// WatchFD is Linux-specific: the descriptor is watched with inotify via its
+// /proc/self/fd path, using an inotify instance of its own. Everywhere else it
+// fails with a *WatchError wrapping ErrUnsupported.
+//
+// The descriptor remains owned by the caller, who must keep it open for as
+// long as it is watched, as the kernel may reuse a closed descriptor for
+// another file. Use Stop to remove watchpoints set up with WatchFD.
+func WatchFD(fd uintptr, c chan<- EventInfo, events ...Event) error {
+	return defaultTree.WatchFD(fd, c, events...)
+}
+
+// fdPipe is a pipe fed by a watch of a file descriptor.
+type fdPipe struct {
+	p *pipe
+	w io.Closer
+}
+
+// WatchFD sets up a watch of the descriptor delivering events to c through
+// a pipe of its own.
+func (t *pipeTree) WatchFD(fd uintptr, c chan<- EventInfo, events ...Event) error {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	// Expanding with empty event set is a nop.
+	if len(events) == 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.newPipe(c)
+	w, err := watchFD(fd, p, joinevents(events))
+	if err != nil {
+		p.stop()
+		return err
+	}
+	t.fds[c] = append(t.fds[c], fdPipe{p: p, w: w})
+	return nil
+}
+
+// stopFDs stops the watches of descriptors set up for c, all of them if c is
+// nil. It expects t.mu to be held.
+func (t *pipeTree) stopFDs(c chan<- EventInfo) {
+	for dst, fps := range t.fds {
+		if c != nil && dst != c {
+			continue
+		}
+		for _, fp := range fps {
+			fp.p.halt()
+			if err := fp.w.Close(); err != nil {
+				dbgprintf("stopFDs: close error: %v", err)
+			}
+			fp.p.stop()
+		}
+		delete(t.fds, dst)
+	}
+}
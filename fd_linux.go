@@ -0,0 +1,157 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build linux
+
+package notify
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// fdWatch watches a file descriptor with an inotify instance of its own,
+// injecting the events into a pipe.
+type fdWatch struct {
+	fd     int    // inotify file descriptor
+	epfd   int    // epoll file descriptor
+	pipefd []int  // pipe waking up the loop on close
+	path   string // /proc/self/fd path of the watched descriptor
+	mask   Event
+	p      *pipe
+	done   chan struct{}
+}
+
+// watchFD watches the file descriptor fd for the events e, injecting them into
+// p until the returned watch is closed.
+func watchFD(fd uintptr, p *pipe, e Event) (io.Closer, error) {
+	path := "/proc/self/fd/" + strconv.FormatUint(uint64(fd), 10)
+	if e&^(All|Unknown|Event(unix.IN_ALL_EVENTS)) != 0 {
+		return nil, &WatchError{Op: "watchfd", Path: path, Err: ErrInvalidEventSet}
+	}
+	ifd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		if err == unix.EMFILE || err == unix.ENFILE {
+			err = ErrResourceLimit
+		}
+		return nil, &WatchError{Op: "watchfd", Path: path, Err: err}
+	}
+	w := &fdWatch{
+		fd:     ifd,
+		epfd:   invalidDescriptor,
+		pipefd: []int{invalidDescriptor, invalidDescriptor},
+		path:   path,
+		mask:   e,
+		p:      p,
+		done:   make(chan struct{}),
+	}
+	// The kernel follows the magic symlink to the inode of the descriptor,
+	// even if the file has no name.
+	if _, err = unix.InotifyAddWatch(ifd, path, encode(e)); err == nil {
+		err = w.epollinit()
+	}
+	if err != nil {
+		w.release()
+		return nil, &WatchError{Op: "watchfd", Path: path, Err: err}
+	}
+	go w.loop()
+	return w, nil
+}
+
+// epollinit sets up epoll waiting for either inotify or pipe file descriptor.
+func (w *fdWatch) epollinit() (err error) {
+	if w.epfd, err = unix.EpollCreate1(0); err != nil {
+		return
+	}
+	if err = unix.Pipe(w.pipefd); err != nil {
+		return
+	}
+	epes := []unix.EpollEvent{
+		{Events: unix.EPOLLIN, Fd: int32(w.fd)},
+		{Events: unix.EPOLLIN, Fd: int32(w.pipefd[0])},
+	}
+	if err = unix.EpollCtl(w.epfd, unix.EPOLL_CTL_ADD, w.fd, &epes[0]); err != nil {
+		return
+	}
+	return unix.EpollCtl(w.epfd, unix.EPOLL_CTL_ADD, w.pipefd[0], &epes[1])
+}
+
+// loop reads the events until the watch is closed.
+func (w *fdWatch) loop() {
+	defer close(w.done)
+	var buf [unix.SizeofInotifyEvent * 4096]byte
+	epes := make([]unix.EpollEvent, 1)
+	for {
+		switch _, err := unix.EpollWait(w.epfd, epes, -1); {
+		case err == unix.EINTR:
+			continue
+		case err != nil:
+			dbgprintf("watchfd: epoll_wait(2) error: %v", err)
+			return
+		case epes[0].Fd == int32(w.pipefd[0]):
+			return
+		}
+		n, err := unix.Read(w.fd, buf[:])
+		if err != nil || n < unix.SizeofInotifyEvent {
+			continue
+		}
+		es, err := parse(buf[:n])
+		if err != nil {
+			dbgprintf("watchfd: %v", err)
+		}
+		w.dispatch(es)
+	}
+}
+
+// dispatch injects the events into the pipe, under the path the descriptor
+// resolves to at the moment.
+func (w *fdWatch) dispatch(es []*event) {
+	path := w.path
+	if s, err := os.Readlink(w.path); err == nil {
+		path = strings.TrimSuffix(s, " (deleted)")
+	}
+	for _, e := range es {
+		if e.sys.Mask&(unix.IN_IGNORED|unix.IN_Q_OVERFLOW) != 0 {
+			continue
+		}
+		ev := &event{sys: e.sys, path: path}
+		if e.path != "" {
+			ev.path = filepath.Join(path, e.path)
+		}
+		syse := decode(w.mask, ev)
+		if ev.event != 0 {
+			w.p.inject(ev)
+		}
+		if syse != nil {
+			w.p.inject(syse)
+		}
+	}
+}
+
+// Close implements io.Closer interface. It stops the loop and releases
+// the file descriptors of the watch, leaving the watched one open.
+func (w *fdWatch) Close() error {
+	if _, err := unix.Write(w.pipefd[1], []byte{0x00}); err != nil {
+		return err
+	}
+	<-w.done
+	return w.release()
+}
+
+// release closes the file descriptors opened by the watch.
+func (w *fdWatch) release() (err error) {
+	for _, fd := range append([]int{w.fd, w.epfd}, w.pipefd...) {
+		if fd != invalidDescriptor {
+			if e := unix.Close(fd); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
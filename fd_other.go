@@ -0,0 +1,18 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !linux
+
+package notify
+
+import (
+	"io"
+	"strconv"
+)
+
+// watchFD fails with ErrUnsupported, as watching descriptors is supported
+// under Linux only.
+func watchFD(fd uintptr, _ *pipe, _ Event) (io.Closer, error) {
+	return nil, &WatchError{Op: "watchfd", Path: strconv.FormatUint(uint64(fd), 10), Err: ErrUnsupported}
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"container/list"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errExactRecursive = errors.New("notify: WatchExact does not support recursive paths")
+
+// exact gives a stage which passes only events for dir itself and for the
+// entries dir contained at the time the stage was created. Direct children
+// reported with Create event are added to the set, the ones reported with
+// Remove or Rename are dropped from it.
+//
+// The dir is expected to be a clean, absolute path.
+func exact(dir string) (stage, error) {
+	set := map[string]struct{}{dir: {}}
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		// A file has no entries, only events for the file itself are passed.
+		if fi, e := os.Stat(dir); e != nil || fi.IsDir() {
+			return nil, err
+		}
+	}
+	for _, name := range names {
+		set[filepath.Join(dir, name)] = struct{}{}
+	}
+	var mu sync.Mutex
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			path, e := ei.Path(), ei.Event()
+			mu.Lock()
+			_, ok := set[path]
+			switch {
+			case ok && path != dir && e&(Remove|Rename) != 0:
+				delete(set, path)
+			case !ok && e&Create != 0 && filepath.Dir(path) == dir:
+				set[path], ok = struct{}{}, true
+			}
+			mu.Unlock()
+			if ok {
+				next(ei)
+			}
+		}
+	}, nil
+}
+
+// dedupSize is a maximum number of events remembered by dedup stage.
+const dedupSize = 256
+
+type dedupKey struct {
+	key   string
+	event Event
+}
+
+// dedupEntry is an event remembered by dedup stage.
+type dedupEntry struct {
+	k  dedupKey
+	at time.Time // time the event was last passed
+}
+
+// keyFunc gives the key events are grouped by, e.g. by dedup or collapse.
+type keyFunc func(EventInfo) string
+
+// pathKey groups the events by their paths, normalized with the function
+// registered with SetPathNormalizer, so that the different forms of the same
+// path, which the watcher may report, are grouped together.
+func pathKey(ei EventInfo) string {
+	return normalize(ei.Path())
+}
+
+// folded gives a keyFunc, which groups the events by the keys given by key
+// regardless of their case.
+func folded(key keyFunc) keyFunc {
+	return func(ei EventInfo) string {
+		return strings.ToLower(key(ei))
+	}
+}
+
+// dedup gives a stage, which drops an event if the same event for the same
+// key was passed within the last window. At most size recently seen events
+// are remembered, the least recently seen ones are forgotten first, so that
+// an event repeated all the time is not forgotten because of a burst of other
+// ones.
+func dedup(window time.Duration, size int, key keyFunc) stage {
+	var mu sync.Mutex
+	seen := make(map[dedupKey]*list.Element)
+	lru := list.New() // least recently seen first
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			k, at := dedupKey{key(ei), ei.Event()}, now()
+			mu.Lock()
+			el, ok := seen[k]
+			if ok {
+				lru.MoveToBack(el)
+			} else {
+				el = lru.PushBack(&dedupEntry{k: k})
+				seen[k] = el
+				if lru.Len() > size {
+					delete(seen, lru.Remove(lru.Front()).(*dedupEntry).k)
+				}
+			}
+			e := el.Value.(*dedupEntry)
+			dup := ok && at.Sub(e.at) < window
+			if !dup {
+				e.at = at
+			}
+			mu.Unlock()
+			if !dup {
+				next(ei)
+			}
+		}
+	}
+}
+
+// collapse gives a stage, which holds back the Create event of a file for
+// window, dropping the Write events reported for the file in the meantime.
+// If the file is removed or renamed within the window, the Create is dropped
+// as well and only the Remove or Rename is passed. Any other event for the
+// file passes the Create on early. Create events of directories are passed
+// right away. The events are matched with the Create by their keys. Calling
+// flush passes on all the Create events held back.
+func collapse(window time.Duration, key keyFunc) (s stage, flush func()) {
+	type held struct {
+		ei EventInfo
+		t  Timer
+		n  int // number of the Write events dropped
+	}
+	// fold gives the held Create, counting the Write events folded into it.
+	fold := func(h *held) EventInfo {
+		if h.n == 0 {
+			return h.ei
+		}
+		return &coalesced{EventInfo: h.ei, n: count(h.ei) + h.n}
+	}
+	var fl flushers
+	s = func(next handler) handler {
+		var mu sync.Mutex
+		pending := make(map[string]*held)
+		fl.add(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			keys := make([]string, 0, len(pending))
+			for k := range pending {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				h := pending[k]
+				h.t.Stop()
+				delete(pending, k)
+				next(fold(h))
+			}
+		})
+		// The mutex is held while passing the events on, so that a Create
+		// passed by a timer is not reordered with later events for its path.
+		return func(ei EventInfo) {
+			k, e := key(ei), ei.Event()
+			mu.Lock()
+			defer mu.Unlock()
+			h, ok := pending[k]
+			switch {
+			case e == Create:
+				if d, ok := ei.(isDirer); ok {
+					if isdir, err := d.isDir(); err == nil && isdir {
+						break
+					}
+				}
+				if ok {
+					h.t.Stop()
+				}
+				h = &held{ei: ei}
+				h.t = afterFunc(window, func() {
+					mu.Lock()
+					defer mu.Unlock()
+					if pending[k] == h {
+						delete(pending, k)
+						next(fold(h))
+					}
+				})
+				pending[k] = h
+				return
+			case !ok:
+			case e&^Write == 0:
+				h.n += count(ei)
+				return
+			default:
+				h.t.Stop()
+				delete(pending, k)
+				if e&(Remove|Rename) == 0 {
+					next(fold(h))
+				}
+			}
+			next(ei)
+		}
+	}
+	return s, fl.flush
+}
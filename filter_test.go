@@ -0,0 +1,223 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_exact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, p := range []string{"a", "b/c"} {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := exact(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []EventInfo
+	fn := s(func(ei EventInfo) { got = append(got, ei) })
+	cases := [...]struct {
+		call Call
+		pass bool
+	}{
+		{Call{P: dir, E: Write}, true},                            // i=0
+		{Call{P: filepath.Join(dir, "a"), E: Write}, true},        // i=1
+		{Call{P: filepath.Join(dir, "b"), E: Write}, true},        // i=2
+		{Call{P: filepath.Join(dir, "b", "c"), E: Write}, false},  // i=3
+		{Call{P: filepath.Join(dir, "d"), E: Write}, false},       // i=4
+		{Call{P: filepath.Join(dir, "d"), E: Create}, true},       // i=5
+		{Call{P: filepath.Join(dir, "d"), E: Write}, true},        // i=6
+		{Call{P: filepath.Join(dir, "a"), E: Remove}, true},       // i=7
+		{Call{P: filepath.Join(dir, "a"), E: Write}, false},       // i=8
+		{Call{P: filepath.Join(dir, "b", "e"), E: Create}, false}, // i=9
+	}
+	for i, cas := range cases {
+		got = nil
+		fn(&cas.call)
+		if pass := len(got) == 1; pass != cas.pass {
+			t.Errorf("want pass=%t; got %t (i=%d)", cas.pass, pass, i)
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	window := 50 * time.Millisecond
+	var n int
+	fn := dedup(window, 2, pathKey)(func(EventInfo) { n++ })
+	cases := [...]struct {
+		call  Call
+		sleep time.Duration
+		n     int
+	}{
+		{Call{P: "/a", E: Write}, 0, 1},          // i=0
+		{Call{P: "/a", E: Write}, 0, 1},          // i=1
+		{Call{P: "/a", E: Create}, 0, 2},         // i=2
+		{Call{P: "/b", E: Write}, 0, 3},          // i=3
+		{Call{P: "/a", E: Write}, 0, 4},          // i=4: forgotten
+		{Call{P: "/a", E: Write}, 2 * window, 5}, // i=5: window passed
+	}
+	for i, cas := range cases {
+		time.Sleep(cas.sleep)
+		fn(&cas.call)
+		if n != cas.n {
+			t.Fatalf("want n=%d; got %d (i=%d)", cas.n, n, i)
+		}
+	}
+}
+
+func TestDedupLeastRecentlySeen(t *testing.T) {
+	var n int
+	fn := dedup(time.Minute, 2, pathKey)(func(EventInfo) { n++ })
+	cases := [...]struct {
+		call Call
+		n    int
+	}{
+		{Call{P: "/a", E: Write}, 1}, // i=0
+		{Call{P: "/b", E: Write}, 2}, // i=1
+		{Call{P: "/a", E: Write}, 2}, // i=2: /a seen again
+		{Call{P: "/c", E: Write}, 3}, // i=3: /b forgotten
+		{Call{P: "/a", E: Write}, 3}, // i=4
+		{Call{P: "/b", E: Write}, 4}, // i=5
+	}
+	for i, cas := range cases {
+		fn(&cas.call)
+		if n != cas.n {
+			t.Fatalf("want n=%d; got %d (i=%d)", cas.n, n, i)
+		}
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	var n int
+	base := func(ei EventInfo) string {
+		return strings.TrimRight(ei.Path(), ".v0123456789")
+	}
+	fn := dedup(time.Minute, dedupSize, base)(func(EventInfo) { n++ })
+	cases := [...]struct {
+		call Call
+		n    int
+	}{
+		{Call{P: "/a.v1", E: Write}, 1},  // i=0
+		{Call{P: "/a.v2", E: Write}, 1},  // i=1
+		{Call{P: "/b.v2", E: Write}, 2},  // i=2
+		{Call{P: "/a.v3", E: Remove}, 3}, // i=3
+	}
+	for i, cas := range cases {
+		fn(&cas.call)
+		if n != cas.n {
+			t.Fatalf("want n=%d; got %d (i=%d)", cas.n, n, i)
+		}
+	}
+}
+
+func TestDedupCaseFolding(t *testing.T) {
+	SetPathNormalizer(func(path string) string {
+		return strings.Replace(path, "e\u0301", "\u00e9", -1) // NFD to NFC of "é" only
+	})
+	defer SetPathNormalizer(nil)
+	var n int
+	o := &options{}
+	WithCaseFolding()(o)
+	fn := dedup(time.Minute, dedupSize, o.keyFunc())(func(EventInfo) { n++ })
+	cases := [...]struct {
+		call Call
+		n    int
+	}{
+		{Call{P: "/File.TXT", E: Write}, 1},   // i=0
+		{Call{P: "/file.txt", E: Write}, 1},   // i=1
+		{Call{P: "/FILE.txt", E: Create}, 2},  // i=2
+		{Call{P: "/caf\u00e9", E: Write}, 3},  // i=3
+		{Call{P: "/Cafe\u0301", E: Write}, 3}, // i=4
+		{Call{P: "/other.txt", E: Write}, 4},  // i=5
+	}
+	for i, cas := range cases {
+		fn(&cas.call)
+		if n != cas.n {
+			t.Fatalf("want n=%d; got %d (i=%d)", cas.n, n, i)
+		}
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	window := 50 * time.Millisecond
+	var mu sync.Mutex
+	var got []string
+	s, _ := collapse(window, pathKey)
+	fn := s(func(ei EventInfo) {
+		s := ei.Event().String() + " " + ei.Path()
+		if n := count(ei); n > 1 {
+			s += " x" + strconv.Itoa(n)
+		}
+		mu.Lock()
+		got = append(got, s)
+		mu.Unlock()
+	})
+	cases := [...]struct {
+		calls []Call
+		want  []string
+	}{
+		// i=0: writes folded into the create
+		{
+			[]Call{{P: "/a", E: Create}, {P: "/a", E: Write}, {P: "/a", E: Write}},
+			[]string{"notify.Create /a x3"},
+		},
+		// i=1: removed within the window
+		{
+			[]Call{{P: "/a", E: Create}, {P: "/a", E: Write}, {P: "/a", E: Remove}},
+			[]string{"notify.Remove /a"},
+		},
+		// i=2: other event passes the create on early
+		{
+			[]Call{{P: "/a", E: Create}, {P: "/a", E: Unknown}, {P: "/a", E: Write}},
+			[]string{"notify.Create /a", "notify.Unknown /a", "notify.Write /a"},
+		},
+		// i=3: directories are not held back
+		{
+			[]Call{{P: "/d", E: Create, Dir: true}, {P: "/b", E: Write}},
+			[]string{"notify.Create /d", "notify.Write /b"},
+		},
+	}
+	for i, cas := range cases {
+		mu.Lock()
+		got = nil
+		mu.Unlock()
+		for j := range cas.calls {
+			fn(&cas.calls[j])
+		}
+		time.Sleep(2 * window)
+		mu.Lock()
+		if !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want %v; got %v (i=%d)", cas.want, got, i)
+		}
+		mu.Unlock()
+	}
+	fn(&Call{P: "/a", E: Create})
+	time.Sleep(2 * window)
+	fn(&Call{P: "/a", E: Write})
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"notify.Create /a", "notify.Write /a"}; !reflect.DeepEqual(got[len(got)-2:], want) {
+		t.Errorf("want %v after the window; got %v", want, got)
+	}
+}
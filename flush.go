@@ -0,0 +1,123 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pendingDrain is the longest time Stop and StopPath wait for the events in
+// flight of the watchpoints set up with DeliverPending.
+var pendingDrain = time.Second
+
+// PendingPolicy tells what happens to the events in flight, i.e. the ones
+// reported by the OS but not yet delivered, when a watchpoint is removed.
+type PendingPolicy int
+
+const (
+	// DropPending drops the events in flight right away. Once Stop or
+	// StopPath is called, the only events delivered for the watchpoint are
+	// the ones held back by its options, see WithDiscardOnStop.
+	DropPending PendingPolicy = iota
+
+	// DeliverPending makes Stop and StopPath deliver the events in flight
+	// before the watchpoint is removed.
+	DeliverPending
+)
+
+// WithPendingPolicy sets what happens to the events in flight, when
+// the watchpoint is removed with Stop or StopPath. By default they are
+// dropped, like with DropPending.
+//
+// With DeliverPending the events are flushed from the underlying watcher and
+// dispatched first, like with Quiesce, so that every change made before Stop
+// was called is delivered, e.g. in tests removing the watchpoint right after
+// the last change. The watchers, which cannot be flushed, e.g. kqueue or
+// ReadDirectoryChangesW, may still report a change made before Stop was
+// called too late for it to be delivered. Stop waits at most a second for
+// the events, the ones dropped since the receiver was too slow are lost
+// regardless of the policy.
+func WithPendingPolicy(policy PendingPolicy) Option {
+	return func(o *options) {
+		o.pending = policy
+	}
+}
+
+// settle waits until the events in flight are dispatched, if any of the pipes
+// of c on the path, or all of them if the path is empty, was set up with
+// DeliverPending.
+func (t *pipeTree) settle(c chan<- EventInfo, path string) {
+	var deliver bool
+	t.mu.Lock()
+	for key, pipes := range t.pipes[c] {
+		if path != "" && key != path {
+			continue
+		}
+		for _, p := range pipes {
+			deliver = deliver || p.pending
+		}
+	}
+	t.mu.Unlock()
+	if !deliver {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pendingDrain)
+	defer cancel()
+	t.Quiesce(ctx, nil)
+}
+
+// WithDiscardOnStop makes notify drop the events held back by the watchpoint,
+// e.g. with WithCreateCollapse, once it is removed with Stop or StopPath.
+// By default such events are delivered right before the watchpoint is
+// removed, so that the last changes made before stopping are not lost.
+func WithDiscardOnStop() Option {
+	return func(o *options) {
+		o.discard = true
+	}
+}
+
+// OnStop makes Stop and StopPath call the flush functions before p is halted,
+// so that the stages of p deliver the events they hold back.
+func (t *pipeTree) OnStop(p *pipe, flush ...func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !p.closed() {
+		p.flushes = append(p.flushes, flush...)
+	}
+}
+
+// flush makes the stages of p pass on the events they hold back. It expects
+// the pipeTree's mutex to be held.
+func (p *pipe) flush() {
+	for _, fn := range p.flushes {
+		fn()
+	}
+}
+
+// flushers keeps the flush functions of the handlers built by a stage, which
+// holds events back.
+type flushers struct {
+	mu sync.Mutex
+	fn []func()
+}
+
+// add registers the flush function of a handler.
+func (f *flushers) add(fn func()) {
+	f.mu.Lock()
+	f.fn = append(f.fn, fn)
+	f.mu.Unlock()
+}
+
+// flush makes all the handlers pass the events they hold back on.
+func (f *flushers) flush() {
+	f.mu.Lock()
+	fns := f.fn
+	f.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStopFlush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_flush")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	cases := [...]struct {
+		opts []Option
+		want bool
+	}{
+		// i=0
+		{[]Option{WithCreateCollapse(time.Hour)}, true},
+		// i=1
+		{[]Option{WithCreateCollapse(time.Hour), WithDiscardOnStop()}, false},
+	}
+	for i, cas := range cases {
+		tr := newPipeTree(newTree())
+		c := make(chan EventInfo, buffer)
+		must(tr.WatchWithOptions(dir, c, Create, cas.opts...))
+		file := filepath.Join(dir, "file"+strconv.Itoa(i))
+		must(ioutil.WriteFile(file, nil, 0644))
+		time.Sleep(100 * time.Millisecond)
+		if len(c) != 0 {
+			t.Fatalf("want the Create held back; got %v (i=%d)", <-c, i)
+		}
+		tr.Stop(c)
+		select {
+		case ei := <-c:
+			if !cas.want {
+				t.Fatalf("want no events; got %v (i=%d)", ei, i)
+			}
+			if err := EqualEventInfo(&Call{P: file, E: Create}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		default:
+			if cas.want {
+				t.Fatalf("want the Create delivered on Stop (i=%d)", i)
+			}
+		}
+		tr.Close()
+	}
+}
+
+func TestPendingPolicy(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	if _, ok := watcherOf(tr.tree).(flusher); !ok {
+		t.Skip("the watcher cannot be flushed")
+	}
+	for i := 0; i < 5; i++ {
+		c := make(chan EventInfo, buffer)
+		must(tr.WatchWithOptions(dir, c, Create, WithPendingPolicy(DeliverPending)))
+		file := filepath.Join(dir, strconv.Itoa(i))
+		must(ioutil.WriteFile(file, nil, 0644))
+		tr.Stop(c)
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&Call{P: file, E: Create}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		default:
+			t.Fatalf("want the pending event delivered before Stop returned (i=%d)", i)
+		}
+	}
+}
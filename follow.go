@@ -0,0 +1,127 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// RenameSelfEventInfo is delivered for watchpoints set up with WithFollowMoves,
+// when the watched directory itself was renamed and the watchpoint followed
+// it. Its Path is the previous path of the directory, its Event is Rename.
+type RenameSelfEventInfo interface {
+	EventInfo
+	// NewPath gives the path the directory was renamed to, which is watched
+	// from now on.
+	NewPath() string
+}
+
+// renameSelf reports the watched directory was renamed from path to newpath.
+type renameSelf struct {
+	EventInfo
+	path    string
+	newpath string
+}
+
+var _ RenameSelfEventInfo = (*renameSelf)(nil)
+var _ fmt.Stringer = (*renameSelf)(nil)
+
+func (e *renameSelf) Path() string    { return e.path }
+func (e *renameSelf) NewPath() string { return e.newpath }
+
+// String implements fmt.Stringer interface.
+func (e *renameSelf) String() string {
+	return `rename self: "` + e.path + `" -> "` + e.newpath + `"`
+}
+
+// WithFollowMoves makes the watchpoint follow the watched directory, when it
+// is renamed, e.g. when a release directory is atomically swapped during
+// a blue/green deployment. Instead of the Rename event for the directory,
+// a RenameSelfEventInfo telling its new path is delivered, and the events are
+// reported under the new path from then on. For that to work Rename must be
+// requested.
+//
+// Notify finds out the new path using a descriptor of the directory, which it
+// keeps open for as long as the watchpoint exists. Currently it is supported
+// under Linux only, which resolves the descriptor via /proc/self/fd; under
+// the other systems the option has no effect. Events reported between the
+// rename and the watchpoint being moved may still carry the previous path.
+func WithFollowMoves() Option {
+	return func(o *options) {
+		o.follow = true
+	}
+}
+
+// rootHandle refers to a directory regardless of its path.
+type rootHandle interface {
+	path() (string, error) // the current path of the directory
+	close() error
+}
+
+// follow gives a stage, which moves the watchpoint of the pipe given to arm,
+// once dir is reported to be renamed. The directory is followed only if it
+// can be opened by the time arm is called, under a system supporting it.
+func follow(t *pipeTree, dir string, isrec bool, e Event) (s stage, arm func(*pipe)) {
+	var mu sync.Mutex
+	var p *pipe
+	var h rootHandle
+	s = func(next handler) handler {
+		return func(ei EventInfo) {
+			mu.Lock()
+			if ei.Event()&Rename == 0 || normalize(ei.Path()) != dir || h == nil {
+				mu.Unlock()
+				next(ei)
+				return
+			}
+			newdir, err := h.path()
+			if err == nil {
+				newdir, _, err = cleanpath(newdir)
+			}
+			if err != nil || newdir == dir {
+				mu.Unlock()
+				next(ei)
+				return
+			}
+			olddir, pp := dir, p
+			dir = newdir
+			mu.Unlock()
+			watched := newdir
+			if isrec {
+				watched = filepath.Join(newdir, "...")
+			}
+			// Not to block the pipe, the tree may be waiting for it to exit.
+			go func() {
+				if err := t.Retarget(pp, watched, e); err != nil {
+					dbgprintf("follow: moving %q to %q failed: %v", olddir, newdir, err)
+					mu.Lock()
+					if dir == newdir {
+						dir = olddir
+					}
+					mu.Unlock()
+				}
+			}()
+			next(&renameSelf{EventInfo: ei, path: olddir, newpath: newdir})
+		}
+	}
+	arm = func(pp *pipe) {
+		mu.Lock()
+		d := dir
+		mu.Unlock()
+		hh, err := openRoot(d)
+		if hh == nil || err != nil {
+			return
+		}
+		mu.Lock()
+		p, h = pp, hh
+		mu.Unlock()
+		go func() {
+			<-pp.quit
+			hh.close()
+		}()
+	}
+	return s, arm
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build linux
+
+package notify
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errRootDeleted is reported for a followed directory, which was removed.
+var errRootDeleted = errors.New("directory was removed")
+
+// fdRoot is a rootHandle backed by a descriptor of the directory, which is
+// resolved to the current path via procfs.
+type fdRoot struct {
+	f *os.File
+}
+
+// openRoot opens the directory, so that it can be followed.
+func openRoot(dir string) (rootHandle, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	return fdRoot{f: f}, nil
+}
+
+func (r fdRoot) path() (string, error) {
+	path, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(r.f.Fd())))
+	if err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(path, " (deleted)") {
+		return "", errRootDeleted
+	}
+	return path, nil
+}
+
+func (r fdRoot) close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,12 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !linux
+
+package notify
+
+// openRoot gives nil handle, as following directories is not supported.
+func openRoot(string) (rootHandle, error) {
+	return nil, nil
+}
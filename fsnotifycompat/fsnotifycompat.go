@@ -0,0 +1,250 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// Package fsnotifycompat implements the API of the github.com/fsnotify/fsnotify
+// package on top of notify, for easing the migration of code written against
+// fsnotify.
+//
+// The Watcher works like the fsnotify one, with the following differences:
+//
+//   - the paths may use the notify's "/..." suffix for watching a directory
+//     recursively, e.g. w.Add("/path/to/dir/...");
+//   - the names of the events are absolute paths, regardless of the paths
+//     given to Add;
+//   - Chmod events are not reported, since notify has no platform-independent
+//     counterpart for them;
+//   - nothing is ever sent to the Errors channel, as notify does not report
+//     errors once a path is watched.
+package fsnotifycompat
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rjeczalik/notify"
+)
+
+// Op describes a set of file operations.
+type Op uint32
+
+// The operations, which the events are reported for.
+const (
+	Create Op = 1 << iota
+	Write
+	Remove
+	Rename
+	Chmod
+)
+
+var opstr = [...]struct {
+	op  Op
+	str string
+}{
+	{Create, "CREATE"},
+	{Write, "WRITE"},
+	{Remove, "REMOVE"},
+	{Rename, "RENAME"},
+	{Chmod, "CHMOD"},
+}
+
+// String implements fmt.Stringer interface.
+func (op Op) String() string {
+	var s []string
+	for _, o := range opstr {
+		if op&o.op != 0 {
+			s = append(s, o.str)
+		}
+	}
+	return strings.Join(s, "|")
+}
+
+// Has reports whether op has h set.
+func (op Op) Has(h Op) bool { return op&h != 0 }
+
+// Event represents a single file system notification.
+type Event struct {
+	Name string // path to the file or directory
+	Op   Op     // file operation, which triggered the event
+}
+
+// Has reports whether the event has op set.
+func (e Event) Has(op Op) bool { return e.Op.Has(op) }
+
+// String implements fmt.Stringer interface.
+func (e Event) String() string {
+	return e.Op.String() + ": \"" + e.Name + "\""
+}
+
+// The errors returned by Watcher.
+var (
+	ErrNonExistentWatch = errors.New("fsnotify: can't remove non-existent watch")
+	ErrEventOverflow    = errors.New("fsnotify: queue or buffer overflow")
+	ErrClosed           = errors.New("fsnotify: watcher already closed")
+)
+
+// buffer is the size of the channels the events are received from notify with.
+const buffer = 1024
+
+// Watcher watches a set of paths, delivering events on a channel.
+type Watcher struct {
+	// Events sends the filesystem change events.
+	Events chan Event
+
+	// Errors sends any errors.
+	Errors chan error
+
+	mu      sync.Mutex
+	watches map[string]*watch
+	wg      sync.WaitGroup
+	done    chan struct{}
+	closed  bool
+}
+
+// watch is a single path added to the Watcher.
+type watch struct {
+	c    chan notify.EventInfo
+	quit chan struct{}
+}
+
+// NewWatcher creates a new Watcher.
+func NewWatcher() (*Watcher, error) {
+	return &Watcher{
+		Events:  make(chan Event),
+		Errors:  make(chan error),
+		watches: make(map[string]*watch),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Add starts watching the named file or directory. Directories are watched
+// non-recursively, unless the path ends with "/...". Adding a path, which
+// is already watched, is a nop.
+func (w *Watcher) Add(name string) error {
+	key, err := clean(name)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if _, ok := w.watches[key]; ok {
+		return nil
+	}
+	wt := &watch{
+		c:    make(chan notify.EventInfo, buffer),
+		quit: make(chan struct{}),
+	}
+	if err := notify.Watch(name, wt.c, notify.All); err != nil {
+		return err
+	}
+	w.watches[key] = wt
+	w.wg.Add(1)
+	go w.forward(wt)
+	return nil
+}
+
+// Remove stops watching the named file or directory, which was added with Add.
+func (w *Watcher) Remove(name string) error {
+	key, err := clean(name)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	wt, ok := w.watches[key]
+	if !ok {
+		return ErrNonExistentWatch
+	}
+	delete(w.watches, key)
+	notify.Stop(wt.c)
+	close(wt.quit)
+	return nil
+}
+
+// WatchList gives the paths added with Add, which were not removed yet.
+func (w *Watcher) WatchList() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	list := make([]string, 0, len(w.watches))
+	for key := range w.watches {
+		list = append(list, key)
+	}
+	return list
+}
+
+// Close removes all the watches and closes the Events and Errors channels.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	for key, wt := range w.watches {
+		notify.Stop(wt.c)
+		delete(w.watches, key)
+	}
+	close(w.done)
+	w.mu.Unlock()
+	w.wg.Wait()
+	close(w.Events)
+	close(w.Errors)
+	return nil
+}
+
+// forward translates the events of the watch and sends them to w.Events,
+// until the watch is removed or w is closed.
+func (w *Watcher) forward(wt *watch) {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-wt.quit:
+			return
+		case <-w.done:
+			return
+		case ei := <-wt.c:
+			op := translate(ei.Event())
+			if op == 0 {
+				continue
+			}
+			select {
+			case w.Events <- Event{Name: ei.Path(), Op: op}:
+			case <-wt.quit:
+				return
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// translate gives the Op the notify event corresponds to, 0 if none.
+func translate(e notify.Event) (op Op) {
+	if e&notify.Create != 0 {
+		op |= Create
+	}
+	if e&notify.Write != 0 {
+		op |= Write
+	}
+	if e&notify.Remove != 0 {
+		op |= Remove
+	}
+	if e&notify.Rename != 0 {
+		op |= Rename
+	}
+	return op
+}
+
+// clean gives the key the watch of the path is kept under. The "/..." suffix
+// of recursive paths is kept, so that a directory may be watched both ways.
+func clean(name string) (string, error) {
+	return filepath.Abs(name)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package fsnotifycompat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rjeczalik/notify"
+)
+
+func TestTranslate(t *testing.T) {
+	cases := [...]struct {
+		e  notify.Event
+		op Op
+	}{
+		{notify.Create, Create},                          // i=0
+		{notify.Write, Write},                            // i=1
+		{notify.Remove, Remove},                          // i=2
+		{notify.Rename, Rename},                          // i=3
+		{notify.Create | notify.Remove, Create | Remove}, // i=4
+		{notify.All, Create | Write | Remove | Rename},   // i=5
+	}
+	for i, cas := range cases {
+		if op := translate(cas.e); op != cas.op {
+			t.Errorf("want op=%v; got %v (i=%d)", cas.op, op, i)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fsnotifycompat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	if list := w.WatchList(); len(list) != 1 || list[0] != dir {
+		t.Fatalf("want [%s]; got %v", dir, list)
+	}
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-w.Events:
+		if ev.Name != file || !ev.Has(Create) {
+			t.Fatalf("want CREATE: %q; got %v", file, ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	if err := w.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Remove(dir); err != ErrNonExistentWatch {
+		t.Fatalf("want err=%v; got %v", ErrNonExistentWatch, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-w.Events; ok {
+		t.Fatal("want Events closed")
+	}
+	if err := w.Add(dir); err != ErrClosed {
+		t.Fatalf("want err=%v; got %v", ErrClosed, err)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "os"
+
+// GenerationEventInfo is an EventInfo delivered to the channels registered
+// with TrackGenerations. It tells the generation of the watchpoints, which
+// the event was reported for.
+type GenerationEventInfo interface {
+	EventInfo
+	Generation() uint64 // the generation of the watchpoints of the event
+}
+
+// TrackGenerations makes the events delivered to c implement
+// the GenerationEventInfo interface. The generation of c starts at 0 and is
+// incremented by every Reconcile, RewatchAll and successful RewatchBatch call
+// for c, so the events reported for the watchpoints replaced or changed by the
+// call, including the ones already queued in c, are told apart from the ones
+// reported for the new configuration:
+//
+//   notify.TrackGenerations(c)
+//   if err := notify.Reconcile(c, desired); err != nil {
+//       log.Println(err)
+//   }
+//   gen := notify.Generation(c)
+//   for ei := range c {
+//       if ei.(notify.GenerationEventInfo).Generation() < gen {
+//           continue // reported for the previous configuration
+//       }
+//       ...
+//   }
+//
+// An event held back by an option, e.g. by WithScanBatch, carries the generation
+// its watchpoint had at the time it was passed on. The events
+// delivered implement Sequenced, StatefulEventInfo, PairedEventInfo and
+// HistoryDoneEventInfo no longer, if any of the options giving them was used.
+//
+// TrackGenerations affects watchpoints set up for c after the call only and
+// it is undone once Stop is called for c, like Use.
+func TrackGenerations(c chan<- EventInfo) {
+	defaultTree.TrackGenerations(c)
+}
+
+// Generation gives the current generation of c, see TrackGenerations.
+func Generation(c chan<- EventInfo) uint64 {
+	return defaultTree.Generation(c)
+}
+
+// TrackGenerations registers c for tracking the generations.
+func (t *pipeTree) TrackGenerations(c chan<- EventInfo) {
+	t.mu.Lock()
+	if _, ok := t.gens[c]; !ok {
+		t.gens[c] = 0
+	}
+	t.mu.Unlock()
+}
+
+// Generation gives the generation of c.
+func (t *pipeTree) Generation(c chan<- EventInfo) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.gens[c]
+}
+
+// nextGeneration increments the generation of c, if it is tracked, and passes
+// it to all the pipes of c. It expects t.mu to be held.
+func (t *pipeTree) nextGeneration(c chan<- EventInfo) {
+	gen, ok := t.gens[c]
+	if !ok {
+		return
+	}
+	gen++
+	t.gens[c] = gen
+	for _, pipes := range t.pipes[c] {
+		for _, p := range pipes {
+			p.fence(gen)
+		}
+	}
+}
+
+// fence is queued in a pipe to make the events queued after it carry the next
+// generation. It is consumed by the pipe itself.
+type fence struct {
+	synthetic
+	gen uint64
+}
+
+// fence makes the events of p queued from now on carry the generation gen.
+// If the pipe's queue is full, the events already queued carry it as well.
+func (p *pipe) fence(gen uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	select {
+	case p.c <- &fence{gen: gen}:
+	default:
+		p.gen = gen
+	}
+}
+
+// generational is an event carrying the generation of its watchpoint.
+type generational struct {
+	EventInfo
+	gen uint64
+}
+
+var _ GenerationEventInfo = (*generational)(nil)
+var _ isDirer = (*generational)(nil)
+var _ OwnEventInfo = (*generational)(nil)
+var _ systemer = (*generational)(nil)
+var _ CoalescedEventInfo = (*generational)(nil)
+
+func (e *generational) Generation() uint64 { return e.gen }
+func (e *generational) Own() bool          { return isown(e.EventInfo) }
+func (e *generational) isSystem() bool     { return issystem(e.EventInfo) }
+func (e *generational) Count() int         { return count(e.EventInfo) }
+
+func (e *generational) isDir() (bool, error) {
+	if d, ok := e.EventInfo.(isDirer); ok {
+		return d.isDir()
+	}
+	return false, nil
+}
+
+// The generational events keep the optional interfaces of the events they wrap.
+type (
+	generationalStat    struct{ *generational }
+	generationalAck     struct{ *generational }
+	generationalStatAck struct{ *generational }
+)
+
+var _ InodeEventInfo = generationalStat{}
+var _ AckEventInfo = generationalAck{}
+var _ InodeEventInfo = generationalStatAck{}
+var _ AckEventInfo = generationalStatAck{}
+
+func (e generationalStat) FileInfo() os.FileInfo    { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e generationalAck) Done()                     { e.EventInfo.(AckEventInfo).Done() }
+func (e generationalStatAck) FileInfo() os.FileInfo { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e generationalStatAck) Done()                 { e.EventInfo.(AckEventInfo).Done() }
+
+func (e generationalStat) Inode() (dev, ino uint64, ok bool)    { return inodeOf(e.EventInfo) }
+func (e generationalStatAck) Inode() (dev, ino uint64, ok bool) { return inodeOf(e.EventInfo) }
+
+// withGeneration wraps ei with the generation gen.
+func withGeneration(ei EventInfo, gen uint64) EventInfo {
+	_, stat := ei.(StatEventInfo)
+	_, ack := ei.(AckEventInfo)
+	g := &generational{EventInfo: ei, gen: gen}
+	switch {
+	case stat && ack:
+		return generationalStatAck{g}
+	case stat:
+		return generationalStat{g}
+	case ack:
+		return generationalAck{g}
+	default:
+		return g
+	}
+}
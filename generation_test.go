@@ -0,0 +1,58 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrackGenerations(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo, 16)
+	tr.TrackGenerations(c)
+	must(tr.Watch(dir, c, Create))
+	recv := func(name string) uint64 {
+		select {
+		case ei := <-c:
+			if want := filepath.Join(dir, name); ei.Path() != want {
+				t.Fatalf("want event for %q; got %v", want, ei)
+			}
+			g, ok := ei.(GenerationEventInfo)
+			if !ok {
+				t.Fatalf("want GenerationEventInfo; got %T", ei)
+			}
+			return g.Generation()
+		case <-time.After(timeout()):
+			t.Fatalf("timed out waiting for %q", name)
+		}
+		return 0
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "a"), nil, 0644))
+	if gen := recv("a"); gen != 0 {
+		t.Fatalf("want gen=0; got %d", gen)
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "b"), nil, 0644))
+	time.Sleep(50 * time.Millisecond) // leave the event for b queued in c
+	must(tr.RewatchAll(c, Create|Remove))
+	must(tr.Reconcile(c, map[string]Event{dir: Create | Remove}))
+	if gen := tr.Generation(c); gen != 2 {
+		t.Fatalf("want Generation()=2; got %d", gen)
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "c"), nil, 0644))
+	if gen := recv("b"); gen != 0 {
+		t.Errorf("want gen=0 for an event of the previous configuration; got %d", gen)
+	}
+	if gen := recv("c"); gen != 2 {
+		t.Errorf("want gen=2; got %d", gen)
+	}
+	tr.Stop(c)
+	if gen := tr.Generation(c); gen != 0 {
+		t.Errorf("want Generation()=0 after Stop; got %d", gen)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitpattern is a single pattern of a gitignore file.
+type gitpattern struct {
+	segs     []string // pattern split on slashes
+	neg      bool     // pattern starts with "!", re-including matched paths
+	dir      bool     // pattern ends with "/", matching only directories
+	anchored bool     // pattern contains a slash, matching from the top
+}
+
+// match reports whether the pattern matches the path given by its elements.
+func (p gitpattern) match(elems []string, isdir bool) bool {
+	if p.dir && !isdir {
+		return false
+	}
+	if !p.anchored {
+		ok, _ := path.Match(p.segs[0], elems[len(elems)-1])
+		return ok
+	}
+	return matchsegs(p.segs, elems)
+}
+
+// matchsegs matches path elements against pattern segments, where the "**"
+// segment matches zero or more directories, or everything within when it
+// ends the pattern.
+func matchsegs(segs, elems []string) bool {
+	if len(segs) == 0 {
+		return len(elems) == 0
+	}
+	if segs[0] == "**" {
+		if len(segs) == 1 {
+			return len(elems) != 0
+		}
+		for i := range elems {
+			if matchsegs(segs[1:], elems[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(segs[0], elems[0]); !ok {
+		return false
+	}
+	return matchsegs(segs[1:], elems[1:])
+}
+
+// parsegitignore parses patterns from the content of a gitignore file.
+func parsegitignore(p []byte) (pats []gitpattern) {
+	s := bufio.NewScanner(bytes.NewReader(p))
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = line[:len(line)-1]
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var pat gitpattern
+		if line[0] == '!' {
+			pat.neg, line = true, line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			pat.dir, line = true, strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			pat.anchored, line = true, strings.TrimLeft(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		pat.segs = strings.Split(line, "/")
+		pats = append(pats, pat)
+	}
+	return pats
+}
+
+// gitignore holds the patterns of a gitignore file, which are reloaded
+// whenever the file changes. A missing file holds no patterns.
+type gitignore struct {
+	file    string
+	mu      sync.Mutex
+	pats    []gitpattern
+	modtime time.Time
+	size    int64
+}
+
+// load rereads the patterns if the file was modified since it was last read.
+func (g *gitignore) load() {
+	var modtime time.Time
+	var size int64 = -1
+	if fi, err := os.Stat(g.file); err == nil {
+		modtime, size = fi.ModTime(), fi.Size()
+	}
+	if modtime.Equal(g.modtime) && size == g.size {
+		return
+	}
+	g.modtime, g.size, g.pats = modtime, size, nil
+	if size >= 0 {
+		if p, err := ioutil.ReadFile(g.file); err == nil {
+			g.pats = parsegitignore(p)
+		}
+	}
+}
+
+// ignored reports whether the file or directory given by its slash-separated
+// relative path is ignored. As with git, a path is ignored when any of its
+// parent directories is, otherwise the last pattern matching it decides.
+func (g *gitignore) ignored(rel string, isdir bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.load()
+	elems := strings.Split(rel, "/")
+	for i := 1; i <= len(elems); i++ {
+		ignored := false
+		for _, pat := range g.pats {
+			if pat.match(elems[:i], isdir || i < len(elems)) {
+				ignored = !pat.neg
+			}
+		}
+		if ignored {
+			return true
+		}
+	}
+	return false
+}
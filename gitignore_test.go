@@ -0,0 +1,115 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitignore(t *testing.T) {
+	const patterns = `# comment
+*.log
+!keep.log
+build/
+/vendor
+doc/*.txt
+**/tmp
+cache/**
+a/**/z
+\#hash
+trailing   
+`
+	cases := [...]struct {
+		rel   string
+		isdir bool
+		ok    bool
+	}{
+		{"main.go", false, false},          // i=0
+		{"out.log", false, true},           // i=1
+		{"sub/out.log", false, true},       // i=2
+		{"keep.log", false, false},         // i=3
+		{"sub/keep.log", false, false},     // i=4
+		{"build", true, true},              // i=5
+		{"build", false, false},            // i=6
+		{"sub/build", true, true},          // i=7
+		{"sub/build/main.go", false, true}, // i=8
+		{"vendor", true, true},             // i=9
+		{"vendor/pkg/a.go", false, true},   // i=10
+		{"sub/vendor", true, false},        // i=11
+		{"doc/a.txt", false, true},         // i=12
+		{"doc/sub/a.txt", false, false},    // i=13
+		{"sub/doc/a.txt", false, false},    // i=14
+		{"tmp", true, true},                // i=15
+		{"x/y/tmp", false, true},           // i=16
+		{"cache", true, false},             // i=17
+		{"cache/a/b", false, true},         // i=18
+		{"a/z", false, true},               // i=19
+		{"a/b/c/z", false, true},           // i=20
+		{"b/a/z", false, false},            // i=21
+		{"#hash", false, true},             // i=22
+		{"# comment", false, false},        // i=23
+		{"trailing", false, true},          // i=24
+		{"build/keep.log", false, true},    // i=25
+	}
+	dir, err := ioutil.TempDir("", "notify_gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, ".gitignore")
+	must(ioutil.WriteFile(file, []byte(patterns), 0644))
+	g := &gitignore{file: file}
+	for i, cas := range cases {
+		if ok := g.ignored(cas.rel, cas.isdir); ok != cas.ok {
+			t.Errorf("want ok=%t; got %t (i=%d)", cas.ok, ok, i)
+		}
+	}
+}
+
+func TestGitignoreReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	g := &gitignore{file: filepath.Join(dir, ".gitignore")}
+	if g.ignored("a.log", false) {
+		t.Fatal("want missing file to ignore nothing")
+	}
+	must(ioutil.WriteFile(g.file, []byte("*.log\n"), 0644))
+	if !g.ignored("a.log", false) {
+		t.Fatal("want a.log ignored once the file is created")
+	}
+	must(ioutil.WriteFile(g.file, []byte("*.log\n!a.log\n"), 0644))
+	if g.ignored("a.log", false) {
+		t.Fatal("want a.log delivered once the file changes")
+	}
+	must(os.Remove(g.file))
+	if g.ignored("b.log", false) {
+		t.Fatal("want removed file to ignore nothing")
+	}
+}
+
+func TestWithGitignore(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	must(os.MkdirAll(filepath.Join(dir, "build", "obj"), 0755))
+	must(os.MkdirAll(filepath.Join(dir, "src"), 0755))
+	must(ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\n*.log\n"), 0644))
+	o := options{ignore: ".gitignore"}
+	must(o.watch(tr.pipeTree, filepath.Join(dir, "..."), ch[0], Create))
+	touch := func(elem ...string) string {
+		p := filepath.Join(append([]string{dir}, elem...)...)
+		must(ioutil.WriteFile(p, nil, 0644))
+		return p
+	}
+	touch("out.log")
+	touch("build", "obj", "file")
+	tr.Expect(ch[0], &Call{P: touch("src", "file"), E: Create})
+	tr.ExpectDry(ch...)
+}
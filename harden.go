@@ -0,0 +1,125 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithDontFollow makes the watchpoint fail with ErrSymlink, if the last
+// element of the watched path is a symlink, instead of watching its target.
+// Under inotify the watch is additionally set up with IN_DONT_FOLLOW, so that
+// a symlink swapped in for the path after the check is not followed. The other
+// watchers check the path only, which leaves a short window for such a swap.
+//
+// Only the last element of the path is not followed, the symlinks amongst its
+// parent directories are resolved before the path is watched. The flags are
+// enforced for the watch of the resolved path, so the watchpoint fails with
+// ErrSymlink as well if the last element was a symlink at the time the path
+// was resolved, even if it was replaced with a directory before it was
+// checked.
+func WithDontFollow() Option {
+	return func(o *options) {
+		o.flags |= flagDontFollow
+	}
+}
+
+// WithOnlyDir makes the watchpoint fail with ErrNotDir, if the watched path
+// is not a directory. Under inotify the watch is additionally set up with
+// IN_ONLYDIR, so that the check and setting up the watch happen at once.
+// The other watchers check the path only, which leaves a short window for
+// replacing the directory with a file.
+func WithOnlyDir() Option {
+	return func(o *options) {
+		o.flags |= flagOnlyDir
+	}
+}
+
+// watchFlags tighten the requirements the path of a watch must meet.
+type watchFlags uint8
+
+const (
+	flagDontFollow watchFlags = 1 << iota // the path must not be a symlink
+	flagOnlyDir                           // the path must be a directory
+)
+
+// flagWatcher is implemented by the watchers, which can enforce watchFlags
+// when setting up the watches by themselves.
+type flagWatcher interface {
+	// setFlags makes every following watch of the path enforce the flags,
+	// until the path is unwatched.
+	setFlags(path string, f watchFlags)
+}
+
+// check checks whether the path meets the flags, before it is watched.
+func (f watchFlags) check(path string) error {
+	if f == 0 {
+		return nil
+	}
+	path, err := filepath.Abs(strings.TrimSuffix(path, "..."))
+	if err != nil {
+		return err
+	}
+	fi, err := os.Lstat(path)
+	switch {
+	case err != nil:
+		return err
+	case f&flagDontFollow != 0 && fi.Mode()&os.ModeSymlink != 0:
+		return &WatchError{Op: "watch", Path: path, Err: ErrSymlink}
+	case f&flagOnlyDir == 0:
+		return nil
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if fi, err = os.Stat(path); err != nil {
+			return err
+		}
+	}
+	if !fi.IsDir() {
+		return &WatchError{Op: "watch", Path: path, Err: ErrNotDir}
+	}
+	return nil
+}
+
+// checkResolved checks whether dir, which is the path resolved by cleanpath,
+// still names the last element of the path, when the flags tell it must not be
+// a symlink, i.e. whether resolving the path did not follow its last element.
+func (f watchFlags) checkResolved(path, dir string) error {
+	if f&flagDontFollow == 0 {
+		return nil
+	}
+	path, err := filepath.Abs(strings.TrimSuffix(path, "..."))
+	if err != nil {
+		return err
+	}
+	parent, _, err := cleanpath(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if normalize(longpath(filepath.Join(parent, filepath.Base(path)))) != dir {
+		return &WatchError{Op: "watch", Path: path, Err: ErrSymlink}
+	}
+	return nil
+}
+
+// setFlags passes the flags for the path to the watcher w wraps, if it is able
+// to enforce them.
+func (w hookWatcher) setFlags(path string, f watchFlags) {
+	if fw, ok := w.watcher.(flagWatcher); ok {
+		fw.setFlags(kernelPath(path), f)
+	}
+}
+
+// SetFlags makes the underlying watcher enforce the flags for the watches of
+// the path, if it is able to. The path is the resolved one, as given by
+// cleanpath, since that is the one the watcher is called with.
+func (t *pipeTree) SetFlags(path string, f watchFlags) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if fw, ok := watcherOf(t.tree).(flagWatcher); ok {
+		fw.setFlags(path, f)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchFlagsCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_harden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	must(ioutil.WriteFile(file, nil, 0644))
+	cases := [...]struct {
+		path  string
+		flags watchFlags
+		err   error
+	}{
+		{file, 0, nil},                                       // i=0
+		{file, flagDontFollow, nil},                          // i=1
+		{file, flagOnlyDir, ErrNotDir},                       // i=2
+		{dir, flagOnlyDir | flagDontFollow, nil},             // i=3
+		{filepath.Join(dir, "..."), flagOnlyDir, nil},        // i=4
+		{filepath.Join(file, "..."), flagOnlyDir, ErrNotDir}, // i=5
+	}
+	for i, cas := range cases {
+		err := cas.flags.check(cas.path)
+		if we, ok := err.(*WatchError); ok {
+			err = we.Err
+		}
+		if err != cas.err {
+			t.Errorf("want err=%v; got %v (i=%d)", cas.err, err, i)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"bytes"
+	"hash"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+)
+
+// contentHashSize is the default size of the largest file WithContentHash
+// hashes.
+const contentHashSize = 1 << 20
+
+// WithContentHash makes notify drop the Write events for the files, which
+// content did not change since the previous event delivered for them, e.g.
+// for sync tools, which would transfer a file touched or rewritten with
+// the very same bytes for nothing. The content of a file is hashed on each
+// Write event with a hash given by fn, FNV-1a if fn is nil, and compared
+// with the hash of the content the previous event was delivered for.
+//
+// Hashing reads the whole file, so the files larger than maxSize bytes are
+// not hashed and their Write events are always delivered; if maxSize is not
+// positive, it defaults to 1MiB. The first Write event for a file is always
+// delivered, as the content it had before is not known. The events, which
+// carry other events along with Write, are delivered as well.
+func WithContentHash(fn func() hash.Hash, maxSize int64) Option {
+	return func(o *options) {
+		if fn == nil {
+			fn = func() hash.Hash { return fnv.New64a() }
+		}
+		if maxSize <= 0 {
+			maxSize = contentHashSize
+		}
+		o.hash, o.hashSize = fn, maxSize
+	}
+}
+
+// contentHash gives a stage, which drops the Write events for the files,
+// which hash computed with fn did not change. The files are read within
+// the budget b, which may be nil.
+func contentHash(fn func() hash.Hash, maxSize int64, b *budget) stage {
+	var mu sync.Mutex
+	sums := make(map[string][]byte)
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			path := normalize(ei.Path())
+			if ei.Event()&(Remove|Rename) != 0 {
+				mu.Lock()
+				delete(sums, path)
+				mu.Unlock()
+			}
+			if ei.Event()&Write == 0 {
+				next(ei)
+				return
+			}
+			sum := filesum(ei.Path(), fn, maxSize, b)
+			mu.Lock()
+			prev, ok := sums[path]
+			if sum != nil {
+				sums[path] = sum
+			} else {
+				delete(sums, path)
+			}
+			mu.Unlock()
+			if ok && sum != nil && ei.Event() == Write && bytes.Equal(prev, sum) {
+				dbgprintf("dropped %v on %q: content unchanged", ei.Event(), ei.Path())
+				return
+			}
+			next(ei)
+		}
+	}
+}
+
+// filesum gives the hash of the content of the regular file given by
+// the path, nil if it cannot be read or it is larger than maxSize. It takes
+// the size of the file from b for the time it is read.
+func filesum(path string, fn func() hash.Hash, maxSize int64, b *budget) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() > maxSize {
+		return nil
+	}
+	n := b.acquire(fi.Size())
+	defer b.release(n)
+	h := fn()
+	// The file may have grown since it was stat'ed.
+	if n, err := io.Copy(h, io.LimitReader(f, maxSize+1)); err != nil || n > maxSize {
+		return nil
+	}
+	return h.Sum(nil)
+}
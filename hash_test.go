@@ -0,0 +1,55 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"crypto/md5"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContentHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, big := filepath.Join(dir, "file"), filepath.Join(dir, "big")
+	ch := NewChans(1)
+	p := newPipe(ch[0], contentHash(md5.New, 4, nil))
+	defer p.stop()
+	cases := [...]struct {
+		path    string
+		content string
+		e       Event
+		passed  bool
+	}{
+		{file, "abc", Write, true},          // i=0
+		{file, "abc", Write, false},         // i=1
+		{file, "abd", Write, true},          // i=2
+		{file, "abd", Write | Create, true}, // i=3
+		{file, "abd", Remove, true},         // i=4
+		{file, "abd", Write, true},          // i=5
+		{big, "abcde", Write, true},         // i=6
+		{big, "abcde", Write, true},         // i=7
+	}
+	for i, cas := range cases {
+		must(ioutil.WriteFile(cas.path, []byte(cas.content), 0644))
+		p.c <- &Call{P: cas.path, E: cas.e}
+		select {
+		case ei := <-ch[0]:
+			if !cas.passed {
+				t.Fatalf("want %v dropped (i=%d)", ei, i)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if cas.passed {
+				t.Fatalf("want event delivered (i=%d)", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HeartbeatEventInfo is the EventInfo delivered periodically for watchpoints
+// set up with WithHeartbeat. Its Event() is 0 and its Path() is the watched
+// directory.
+type HeartbeatEventInfo interface {
+	EventInfo
+	Heartbeat() time.Time // time the watchpoint was found healthy
+}
+
+// WithHeartbeat makes notify deliver a HeartbeatEventInfo event every interval,
+// as long as the watchpoint is healthy, so that consumers of paths changing
+// rarely can tell a quiet path from a watchpoint, which stopped delivering
+// events, e.g. since its directory was replaced or the volume was unmounted.
+// The watchpoint is checked the same way Verify checks it, a consumer missing
+// the heartbeats is expected to set up the watchpoint again:
+//
+//   for {
+//           select {
+//           case ei := <-c:
+//                   if _, ok := ei.(notify.HeartbeatEventInfo); ok {
+//                           continue
+//                   }
+//                   process(ei)
+//           case <-time.After(3 * interval):
+//                   // Watchpoint is dead, set it up again.
+//           }
+//   }
+//
+// The heartbeats are not passed through the other options, e.g. filters or
+// WithSerialize, and they are dropped like any other event if the receiver is
+// too slow.
+func WithHeartbeat(interval time.Duration) Option {
+	return func(o *options) {
+		o.heartbeat = interval
+	}
+}
+
+// heartbeat is an event telling a watchpoint is healthy.
+type heartbeat struct {
+	path string
+	t    time.Time
+}
+
+var _ HeartbeatEventInfo = (*heartbeat)(nil)
+var _ fmt.Stringer = (*heartbeat)(nil)
+var _ isDirer = (*heartbeat)(nil)
+
+func (e *heartbeat) Event() Event         { return 0 }
+func (e *heartbeat) Path() string         { return e.path }
+func (e *heartbeat) Sys() interface{}     { return nil }
+func (e *heartbeat) Heartbeat() time.Time { return e.t }
+func (e *heartbeat) isDir() (bool, error) { return true, nil }
+
+// String implements fmt.Stringer interface.
+func (e *heartbeat) String() string {
+	return `heartbeat: "` + e.Path() + `"`
+}
+
+// beat gives an arm function, which makes the tree t check the watchpoint of
+// a pipe on dir every d and deliver a heartbeat, if it is healthy.
+func beat(t *pipeTree, dir string, d time.Duration) (arm func(*pipe)) {
+	return func(p *pipe) {
+		var mu sync.Mutex
+		var timer Timer
+		mu.Lock()
+		defer mu.Unlock()
+		timer = afterFunc(d, func() {
+			if !t.Beat(p, dir) {
+				return
+			}
+			mu.Lock()
+			timer.Reset(d)
+			mu.Unlock()
+		})
+	}
+}
+
+// Beat delivers a heartbeat to p, if its watchpoint on dir is healthy. It
+// reports false once p is stopped.
+func (t *pipeTree) Beat(p *pipe, dir string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p.closed() {
+		return false
+	}
+	if t.verify(p, dir) == nil {
+		p.send(&heartbeat{path: dir, t: now()})
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo, buffer)
+	must(tr.WatchWithOptions(dir, c, Create, WithHeartbeat(20*time.Millisecond)))
+	for i := 0; i < 2; i++ {
+		select {
+		case ei := <-c:
+			hb, ok := ei.(HeartbeatEventInfo)
+			if !ok {
+				t.Fatalf("want heartbeat; got %v (i=%d)", ei, i)
+			}
+			if hb.Path() != dir || hb.Event() != 0 || hb.Heartbeat().IsZero() {
+				t.Fatalf("want heartbeat for %q; got %v (i=%d)", dir, ei, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+	must(os.RemoveAll(dir))
+	time.Sleep(50 * time.Millisecond)
+	for len(c) != 0 {
+		<-c
+	}
+	select {
+	case ei := <-c:
+		if _, ok := ei.(HeartbeatEventInfo); ok {
+			t.Fatalf("want no heartbeats for removed %q; got %v", dir, ei)
+		}
+	case <-time.After(100 * time.Millisecond):
+	}
+	tr.Stop(c)
+}
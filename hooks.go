@@ -0,0 +1,150 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "sync/atomic"
+
+// Hooks is a set of callbacks, which are called on watch lifecycle events.
+// Any of them may be nil.
+//
+// The callbacks are called synchronously, while notify holds its internal
+// locks, so they are expected to return quickly and must not call back into
+// notify.
+type Hooks struct {
+	// OnWatch is called after a watch for the path was successfully set up
+	// by the underlying watcher.
+	OnWatch func(path string)
+
+	// OnUnwatch is called after a watch for the path was successfully removed
+	// from the underlying watcher.
+	OnUnwatch func(path string)
+
+	// OnError is called when the underlying watcher failed to set up, modify
+	// or remove a watch for the path.
+	OnError func(path string, err error)
+
+	// OnEventDropped is called when an event for the path was dropped, since
+	// the receiving channel was not ready.
+	OnEventDropped func(path string)
+
+	// OnEventUnmatched is called when the underlying watcher reported an event
+	// for the path, which is not covered by any of the watched paths, so it was
+	// dropped. It is meant for debugging lost events: the path usually differs
+	// from the watched one in the way it was canonicalized, e.g. it was reported
+	// with a symlink resolved or in a different case. It may also be called for
+	// the events reported right after the path was unwatched.
+	OnEventUnmatched func(path string)
+}
+
+var hooks atomic.Value
+
+func init() {
+	hooks.Store(&Hooks{})
+}
+
+// SetHooks registers callbacks for watch lifecycle events, replacing the
+// previously registered ones. Calling SetHooks with zero value Hooks
+// unregisters all of them.
+func SetHooks(h Hooks) {
+	hooks.Store(&h)
+}
+
+func loadHooks() *Hooks {
+	return hooks.Load().(*Hooks)
+}
+
+// dropped reports ei was dropped, since its receiver was too slow.
+func dropped(ei EventInfo) {
+	dbgprintf("dropped %s on %q: receiver too slow", ei.Event(), ei.Path())
+	if a, ok := ei.(AckEventInfo); ok {
+		a.Done()
+	}
+	if fn := loadHooks().OnEventDropped; fn != nil {
+		fn(ei.Path())
+	}
+}
+
+// unmatched reports the event for path was dropped, since it did not match
+// any of the watched paths.
+func unmatched(path string) {
+	dbgprintf("dropped event on %q: path not watched", path)
+	if fn := loadHooks().OnEventUnmatched; fn != nil {
+		fn(path)
+	}
+}
+
+// hookWatcher calls the registered hooks for every watch and unwatch request.
+type hookWatcher struct {
+	watcher
+}
+
+// hookRecursiveWatcher is a hookWatcher for recursive watchers.
+type hookRecursiveWatcher struct {
+	hookWatcher
+	rw recursiveWatcher
+}
+
+// withHooks wraps w with a watcher calling the registered hooks. The wrapper
+// implements recursiveWatcher if w does.
+func withHooks(w watcher) watcher {
+	if rw, ok := w.(recursiveWatcher); ok {
+		return hookRecursiveWatcher{hookWatcher{w}, rw}
+	}
+	return hookWatcher{w}
+}
+
+// report calls the hook for the given request outcome.
+func report(path string, err error, fn func(string)) error {
+	h := loadHooks()
+	switch {
+	case err != nil && h.OnError != nil:
+		h.OnError(path, err)
+	case err == nil && fn != nil:
+		fn(path)
+	}
+	return err
+}
+
+// tracked reports the progress of the watch for path, unless it failed.
+func tracked(path string, err error) error {
+	if err == nil {
+		track(path)
+	}
+	return err
+}
+
+func onWatch() func(string)   { return loadHooks().OnWatch }
+func onUnwatch() func(string) { return loadHooks().OnUnwatch }
+
+// Following methods implement notify.watcher interface.
+func (w hookWatcher) Watch(path string, e Event) error {
+	return tracked(path, report(path, w.watcher.Watch(kernelPath(path), e), onWatch()))
+}
+
+func (w hookWatcher) Unwatch(path string) error {
+	return report(path, w.watcher.Unwatch(kernelPath(path)), onUnwatch())
+}
+
+func (w hookWatcher) Rewatch(path string, olde, newe Event) error {
+	return tracked(path, report(path, w.watcher.Rewatch(kernelPath(path), olde, newe), nil))
+}
+
+// Following methods implement notify.recursiveWatcher interface.
+func (w hookRecursiveWatcher) RecursiveWatch(path string, e Event) error {
+	return tracked(path, report(path, w.rw.RecursiveWatch(kernelPath(path), e), onWatch()))
+}
+
+func (w hookRecursiveWatcher) RecursiveUnwatch(path string) error {
+	return report(path, w.rw.RecursiveUnwatch(kernelPath(path)), onUnwatch())
+}
+
+func (w hookRecursiveWatcher) RecursiveRewatch(oldp, newp string, olde, newe Event) error {
+	err := w.rw.RecursiveRewatch(kernelPath(oldp), kernelPath(newp), olde, newe)
+	if err != nil || oldp == newp {
+		return tracked(newp, report(newp, err, nil))
+	}
+	report(oldp, nil, onUnwatch())
+	return tracked(newp, report(newp, nil, onWatch()))
+}
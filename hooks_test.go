@@ -0,0 +1,58 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var got []string
+	SetHooks(Hooks{
+		OnWatch:        func(p string) { got = append(got, "watch "+p) },
+		OnUnwatch:      func(p string) { got = append(got, "unwatch "+p) },
+		OnError:        func(p string, err error) { got = append(got, "error "+p) },
+		OnEventDropped: func(p string) { got = append(got, "dropped "+p) },
+	})
+	defer SetHooks(Hooks{})
+	w := withHooks(&Spy{}).(recursiveWatcher)
+	w.RecursiveWatch("/a", Create)
+	w.RecursiveRewatch("/a", "/b", Create, Create|Remove)
+	w.RecursiveRewatch("/b", "/b", Create|Remove, Create)
+	w.RecursiveUnwatch("/b")
+	withHooks(watcherStub{errors.New("stub")}).Watch("/c", Create)
+	wp := watchpoint{}
+	wp.Add(make(chan EventInfo), Create)
+	wp.Dispatch(&Call{P: "/d", E: Create}, 0)
+	want := []string{"watch /a", "unwatch /a", "watch /b", "unwatch /b", "error /c", "dropped /d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v; got %v", want, got)
+	}
+}
+
+func TestHooksEventUnmatched(t *testing.T) {
+	var mu sync.Mutex
+	var got []string
+	SetHooks(Hooks{
+		OnEventUnmatched: func(p string) {
+			mu.Lock()
+			got = append(got, p)
+			mu.Unlock()
+		},
+	})
+	defer SetHooks(Hooks{})
+	tr := newNonrecursiveTree(&Spy{}, make(chan EventInfo), nil)
+	if tr.deliver(&Call{P: "/notwatched/file", E: Create}) {
+		t.Fatal("want isrec=false")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"/notwatched/file"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v; got %v", want, got)
+	}
+}
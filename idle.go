@@ -0,0 +1,59 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrIdleTimeout is returned by Sys() of the last event delivered for
+// a watchpoint set up with WithIdleTimeout, which expired due to idleness.
+var ErrIdleTimeout = errors.New("notify: watch expired due to idleness")
+
+// expired is the last event delivered for an idle watchpoint.
+type expired struct {
+	path string
+}
+
+var _ fmt.Stringer = (*expired)(nil)
+var _ isDirer = (*expired)(nil)
+
+func (e *expired) Event() Event         { return 0 }
+func (e *expired) Path() string         { return e.path }
+func (e *expired) Sys() interface{}     { return ErrIdleTimeout }
+func (e *expired) isDir() (bool, error) { return true, nil }
+
+// String implements fmt.Stringer interface.
+func (e *expired) String() string {
+	return `expired: "` + e.Path() + `"`
+}
+
+// idle gives a stage, which once arm was called, removes the watchpoint from
+// the tree t when no events were passed through it within the last d.
+func idle(t *pipeTree, dir string, d time.Duration) (s stage, arm func(*pipe)) {
+	var mu sync.Mutex
+	var timer Timer
+	s = func(next handler) handler {
+		return func(ei EventInfo) {
+			mu.Lock()
+			if timer != nil {
+				timer.Reset(d)
+			}
+			mu.Unlock()
+			next(ei)
+		}
+	}
+	arm = func(p *pipe) {
+		mu.Lock()
+		timer = afterFunc(d, func() {
+			t.Expire(p, &expired{path: dir})
+		})
+		mu.Unlock()
+	}
+	return s, arm
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIdle(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	o := options{idle: 200 * time.Millisecond}
+	must(o.watch(tr.pipeTree, dir, ch[0], Create))
+	file := filepath.Join(dir, "file")
+	must(ioutil.WriteFile(file, nil, 0644))
+	select {
+	case ei := <-ch[0]:
+		if ei.Path() != file {
+			t.Fatalf("want event for %q; got %v", file, ei)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	select {
+	case ei := <-ch[0]:
+		if ei.Path() != dir || ei.Event() != 0 || ei.Sys() != ErrIdleTimeout {
+			t.Fatalf("want expired event for %q; got %v", dir, ei)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out waiting for the watchpoint to expire")
+	}
+	if _, err := tr.Snapshot(dir); err == nil {
+		t.Fatal("want the watchpoint to be removed")
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "other"), nil, 0644))
+	tr.ExpectDry(ch...)
+}
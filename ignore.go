@@ -0,0 +1,144 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// skipFunc reports whether the file or directory given by its slash-separated
+// path relative to the watched directory is ignored.
+type skipFunc func(rel string, isdir bool) bool
+
+// relpath gives the slash-separated path relative to dir, false if the path
+// is not under dir.
+func relpath(dir, path string) (string, bool) {
+	if !strings.HasPrefix(path, dir+sep) {
+		return "", false
+	}
+	return filepath.ToSlash(path[len(dir)+len(sep):]), true
+}
+
+// isdot reports whether any element of the relative path starts with a dot.
+func isdot(rel string, _ bool) bool {
+	for _, name := range strings.Split(rel, "/") {
+		if strings.HasPrefix(name, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// subdirs gives dir together with all directories found under it, skipping
+// the ones, which the skip function reports as ignored relative to top, and
+// the subdirectories of the ones, which fan finds crowded.
+func subdirs(top, dir string, skip skipFunc, fan *fanout) (dirs []string) {
+	prune := fan.pruner(top, skip)
+	fn := func(path string, fi os.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case !fi.IsDir():
+			return nil
+		}
+		if rel, ok := relpath(top, path); ok && skip(rel, true) {
+			return filepath.SkipDir
+		}
+		if prune != nil && path != dir && prune(filepath.Dir(path)) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	}
+	filepath.Walk(dir, fn)
+	return dirs
+}
+
+// listed is a Create event generated for an entry found within a new
+// directory.
+type listed struct {
+	*synthetic
+}
+
+// contents gives Create events for the files and directories found under dir,
+// in parent-before-child order, skipping the ones, which the skip function
+// reports as ignored relative to top, and the contents of the subdirectories
+// of the ones, which fan finds crowded.
+func contents(top, dir string, skip skipFunc, fan *fanout) (created []EventInfo) {
+	prune := fan.pruner(top, skip)
+	fn := func(path string, fi os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return nil
+		}
+		if rel, ok := relpath(top, path); ok && skip(rel, fi.IsDir()) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		created = append(created, &listed{&synthetic{path: path, event: Create, dir: fi.IsDir()}})
+		if prune != nil && fi.IsDir() && prune(filepath.Dir(path)) {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+	filepath.Walk(dir, fn)
+	return created
+}
+
+// ignore gives a stage, which drops events for the files and directories under
+// dir, which the skip function reports as ignored, passing on only the events
+// given by e. If expand is true, the stage emulates a recursive watchpoint on
+// dir, which does not descend into ignored directories: once arm is called,
+// every other directory under dir is watched, and so is every such directory
+// created later on, reporting Create events for its contents as well. The
+// subdirectories of the directories, which fan finds crowded, are not watched.
+func ignore(t *pipeTree, dir string, e Event, expand bool, skip skipFunc, fan *fanout) (s stage, arm func(*pipe)) {
+	var mu sync.Mutex
+	var p *pipe
+	s = func(next handler) handler {
+		return func(ei EventInfo) {
+			path := normalize(ei.Path())
+			var isdir bool
+			if d, ok := ei.(isDirer); ok {
+				isdir, _ = d.isDir()
+			}
+			if rel, ok := relpath(dir, path); ok && skip(rel, isdir) {
+				return
+			}
+			if _, ok := ei.(*listed); !ok && expand && isdir && ei.Event()&Create != 0 && !fan.known(filepath.Dir(path)) {
+				mu.Lock()
+				pp := p
+				mu.Unlock()
+				if pp != nil {
+					// Not to block the pipe, the tree may be waiting
+					// for it to exit. The contents of the directory, e.g.
+					// one moved into dir, are reported once it is watched.
+					go func() {
+						t.WatchLinks(pp, subdirs(dir, path, skip, fan), e|Create)
+						for _, ei := range contents(dir, path, skip, fan) {
+							pp.inject(ei)
+						}
+					}()
+				}
+			}
+			if ei.Event()&e != 0 {
+				next(ei)
+			}
+		}
+	}
+	arm = func(pp *pipe) {
+		mu.Lock()
+		p = pp
+		mu.Unlock()
+		if dirs := subdirs(dir, dir, skip, fan); expand && len(dirs) > 1 {
+			t.WatchLinks(pp, dirs[1:], e|Create)
+		}
+	}
+	return s, arm
+}
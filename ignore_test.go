@@ -0,0 +1,138 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsdot(t *testing.T) {
+	cases := [...]struct {
+		rel string
+		ok  bool
+	}{
+		{"file", false},        // i=0
+		{".swp", true},         // i=1
+		{".git/objects", true}, // i=2
+		{"a/.b/c", true},       // i=3
+		{"a/b.c", false},       // i=4
+	}
+	for i, cas := range cases {
+		if ok := isdot(cas.rel, false); ok != cas.ok {
+			t.Errorf("want ok=%t; got %t (i=%d)", cas.ok, ok, i)
+		}
+	}
+}
+
+func TestRelpath(t *testing.T) {
+	dir := filepath.FromSlash("/home/.config")
+	cases := [...]struct {
+		path string
+		rel  string
+		ok   bool
+	}{
+		{"/home/.config", "", false},           // i=0
+		{"/home/.config/file", "file", true},   // i=1
+		{"/home/.config/a/b.c", "a/b.c", true}, // i=2
+		{"/home/.configs/file", "", false},     // i=3
+		{"/home/other/.swp", "", false},        // i=4
+	}
+	for i, cas := range cases {
+		rel, ok := relpath(dir, filepath.FromSlash(cas.path))
+		if rel != cas.rel || ok != cas.ok {
+			t.Errorf("want rel=%q, ok=%t; got %q, %t (i=%d)", cas.rel, cas.ok, rel, ok, i)
+		}
+	}
+}
+
+func TestNodot(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	must(os.MkdirAll(filepath.Join(dir, ".git", "objects"), 0755))
+	must(os.MkdirAll(filepath.Join(dir, "src", "pkg"), 0755))
+	o := options{nodot: true}
+	must(o.watch(tr.pipeTree, filepath.Join(dir, "..."), ch[0], Create))
+	touch := func(elem ...string) string {
+		p := filepath.Join(append([]string{dir}, elem...)...)
+		must(ioutil.WriteFile(p, nil, 0644))
+		return p
+	}
+	touch(".swp")
+	touch(".git", "objects", "file")
+	tr.Expect(ch[0], &Call{P: touch("src", "pkg", "file"), E: Create})
+	must(os.Mkdir(filepath.Join(dir, "new"), 0755))
+	tr.Expect(ch[0], &Call{P: filepath.Join(dir, "new"), E: Create})
+	deadline := time.After(timeout())
+	for {
+		file := touch("new", "file")
+		select {
+		case ei := <-ch[0]:
+			if ei.Path() != file {
+				t.Fatalf("want event for %q; got %v", file, ei)
+			}
+		case <-time.After(50 * time.Millisecond):
+			must(os.Remove(file))
+			continue
+		case <-deadline:
+			t.Fatal("timed out waiting for the new directory to be watched")
+		}
+		break
+	}
+	tr.ExpectDry(ch...)
+}
+
+func TestNodotMovedDir(t *testing.T) {
+	tmp, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	dir, src := filepath.Join(tmp, "dir"), filepath.Join(tmp, "src")
+	must(os.Mkdir(dir, 0755))
+	must(os.MkdirAll(filepath.Join(src, "b"), 0755))
+	must(os.MkdirAll(filepath.Join(src, ".git"), 0755))
+	must(ioutil.WriteFile(filepath.Join(src, "f"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(src, ".swp"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(src, "b", "g"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(src, ".git", "HEAD"), nil, 0644))
+	c := make(chan EventInfo, buffer)
+	o := options{nodot: true}
+	must(o.watch(tr.pipeTree, filepath.Join(dir, "..."), c, Create))
+	// The tree is moved into place, so its contents exist before any of its
+	// directories is watched.
+	must(os.Rename(src, filepath.Join(dir, "a")))
+	want := []string{
+		filepath.Join(dir, "a"),
+		filepath.Join(dir, "a", "b"),
+		filepath.Join(dir, "a", "b", "g"),
+		filepath.Join(dir, "a", "f"),
+	}
+	seen := make(map[string]int)
+	deadline := time.After(timeout())
+	for len(seen) != len(want) {
+		select {
+		case ei := <-c:
+			if isdot(filepath.Base(ei.Path()), false) {
+				t.Fatalf("want no events for dotfiles; got %v", ei)
+			}
+			if _, ok := seen[ei.Path()]; !ok {
+				seen[ei.Path()] = len(seen)
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for Create events; got %v", seen)
+		}
+	}
+	for _, path := range want {
+		if _, ok := seen[path]; !ok {
+			t.Errorf("want Create for %q (seen=%v)", path, seen)
+		}
+	}
+	for path, n := range seen {
+		if m, ok := seen[filepath.Dir(path)]; ok && m > n {
+			t.Errorf("want %q to be reported before %q (seen=%v)", filepath.Dir(path), path, seen)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+// IndexedEvent is an event delivered by WatchIndexed. It tells which of
+// the watched paths changed by its index instead of the path itself.
+type IndexedEvent struct {
+	Index int   // index of the path within the paths given to WatchIndexed
+	Event Event // the event reported for the path
+}
+
+// WatchIndexed watches each of the paths for the given events like Watch does,
+// but it delivers to c the index of the path an event was reported for
+// together with the event, instead of the EventInfo itself. It is meant for
+// watching a fixed set of paths known up front, e.g. the configuration files
+// of a server, where telling which of them changed is all that is needed:
+//
+//   files := []string{"/etc/app/main.conf", "/etc/app/tls.conf"}
+//   c := make(chan notify.IndexedEvent, 16)
+//   if err := notify.WatchIndexed(files, c, notify.Write); err != nil {
+//       log.Fatal(err)
+//   }
+//   for ev := range c {
+//       reload[ev.Index]()
+//   }
+//
+// The events are values, so delivering them allocates nothing on the way to c.
+// The events reported for the entries of a watched directory are delivered
+// under the index of the directory. Like with Watch, the events are delivered
+// without blocking and the ones not received in time are dropped.
+//
+// If any of the paths cannot be watched, none of them is and the error is
+// returned. The indices are given per call, so watching more sets of paths
+// requires a channel per set. Use StopIndexed to remove watchpoints set up
+// with WatchIndexed.
+func WatchIndexed(paths []string, c chan<- IndexedEvent, events ...Event) error {
+	return defaultTree.WatchIndexed(paths, c, events...)
+}
+
+// StopIndexed removes all watchpoints set up with WatchIndexed for c. When
+// StopIndexed returns, no more events are delivered to c.
+func StopIndexed(c chan<- IndexedEvent) {
+	defaultTree.StopIndexed(c)
+}
+
+// indexed gives a stage, which delivers the events to c under the index i
+// instead of passing them on.
+func indexed(c chan<- IndexedEvent, i int) stage {
+	return func(handler) handler {
+		return func(ei EventInfo) {
+			select {
+			case c <- IndexedEvent{Index: i, Event: ei.Event()}:
+			default: // Drop event if receiver is too slow
+				dropped(ei)
+			}
+		}
+	}
+}
+
+// WatchIndexed watches the paths with the pipes of a channel of its own, which
+// deliver the events to c under the indices of their paths.
+func (t *pipeTree) WatchIndexed(paths []string, c chan<- IndexedEvent, events ...Event) error {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	own := make(chan EventInfo)
+	for i, path := range paths {
+		if _, err := t.WatchPipe(path, own, []stage{indexed(c, i)}, events...); err != nil {
+			t.Stop(own)
+			return err
+		}
+	}
+	t.mu.Lock()
+	t.indexed[c] = append(t.indexed[c], own)
+	t.mu.Unlock()
+	return nil
+}
+
+// StopIndexed removes the watchpoints of all the paths watched for c.
+func (t *pipeTree) StopIndexed(c chan<- IndexedEvent) {
+	t.mu.Lock()
+	owns := t.indexed[c]
+	delete(t.indexed, c)
+	t.mu.Unlock()
+	for _, own := range owns {
+		t.Stop(own)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchIndexed(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	paths := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+	for _, path := range paths {
+		must(ioutil.WriteFile(path, nil, 0644))
+	}
+	c := make(chan IndexedEvent, buffer)
+	if err := tr.WatchIndexed(append(paths, filepath.Join(dir, "missing")), c, Write); err == nil {
+		t.Fatal("want err!=nil for a missing path")
+	}
+	if n := len(tr.pipes); n != 0 {
+		t.Fatalf("want no pipes after a failed WatchIndexed; got %d", n)
+	}
+	must(tr.WatchIndexed(paths, c, Write))
+	must(ioutil.WriteFile(paths[1], []byte("b"), 0644))
+	select {
+	case ev := <-c:
+		if want := (IndexedEvent{Index: 1, Event: Write}); ev != want {
+			t.Fatalf("want %+v; got %+v", want, ev)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	tr.StopIndexed(c)
+	must(ioutil.WriteFile(paths[0], []byte("a"), 0644))
+	select {
+	case ev := <-c:
+		t.Fatalf("want no events after StopIndexed; got %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "os"
+
+// InodeEventInfo is a StatEventInfo, which additionally tells the identity of
+// the file or directory the event concerns, e.g. for keeping state, which
+// survives renames and covers all the hard links of a file. Events delivered
+// for watchpoints set up with WithInode implement it.
+type InodeEventInfo interface {
+	StatEventInfo
+	// Inode gives the device and the inode number of the file, or the volume
+	// serial number and the file index under Windows. The ok is false for
+	// Remove events, if the file could not be stat'ed, or if the platform does
+	// not support file identities.
+	Inode() (dev, ino uint64, ok bool)
+}
+
+// WithInode makes notify stat the path of every event, except Remove ones,
+// right when the event is dispatched, so that the delivered events implement
+// InodeEventInfo. It implies WithStat. Under Windows, which os.FileInfo does
+// not carry the file index, the file is additionally opened to query it.
+func WithInode() Option {
+	return func(o *options) {
+		o.inode = true
+	}
+}
+
+// statInode works like stat, but it also attaches the identity of the file
+// to every event.
+func statInode(next handler) handler {
+	return func(ei EventInfo) {
+		se := &statEvent{EventInfo: ei}
+		if ei.Event()&Remove == 0 {
+			se.fi, _ = os.Lstat(ei.Path())
+		}
+		if se.fi != nil {
+			se.dev, se.ino, se.idok = inode(ei.Path(), se.fi)
+		}
+		next(se)
+	}
+}
+
+// inodeOf gives the identity of the file ei concerns, if ei carries one.
+func inodeOf(ei EventInfo) (dev, ino uint64, ok bool) {
+	if ie, ok := ei.(InodeEventInfo); ok {
+		return ie.Inode()
+	}
+	return 0, 0, false
+}
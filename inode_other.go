@@ -0,0 +1,15 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package notify
+
+import "os"
+
+// inode gives the identity of the file described by fi. It is not supported
+// on this platform.
+func inode(string, os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris windows
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatInode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_inode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, moved := filepath.Join(dir, "file"), filepath.Join(dir, "moved")
+	ch := NewChans(1)
+	p := newPipe(ch[0], statInode, pair(dir))
+	defer p.stop()
+	inode := func(path string, e Event) (dev, ino uint64, ok bool) {
+		p.c <- &Call{P: path, E: e}
+		ie, isinode := (<-ch[0]).(InodeEventInfo)
+		if !isinode {
+			t.Fatalf("want InodeEventInfo for %v on %q", e, path)
+		}
+		return ie.Inode()
+	}
+	must(ioutil.WriteFile(file, nil, 0644))
+	dev, ino, ok := inode(file, Create)
+	if !ok {
+		t.Fatalf("want ok=true for %q", file)
+	}
+	must(os.Rename(file, moved))
+	if d, i, ok := inode(moved, Rename); !ok || d != dev || i != ino {
+		t.Fatalf("want (%d, %d) for %q; got (%d, %d, %t)", dev, ino, moved, d, i, ok)
+	}
+	must(os.Remove(moved))
+	if _, _, ok := inode(moved, Remove); ok {
+		t.Fatalf("want ok=false for removed %q", moved)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+//go:build go1.23
+// +build go1.23
+
+package notify
+
+import (
+	"context"
+	"iter"
+)
+
+// Iterate watches the path for the given events like Watch does, giving
+// the events as an iterator instead of delivering them to a channel:
+//
+//   for ei, err := range notify.Iterate(ctx, "./...", notify.All) {
+//       if err != nil {
+//           log.Fatal(err)
+//       }
+//       log.Println("Got event:", ei)
+//   }
+//
+// The watchpoint is set up when the iteration starts and removed when it ends,
+// either because the loop was exited or ctx was done. If the watchpoint
+// cannot be set up or ctx is done, the last pair yields a nil event together
+// with the error, ctx.Err() in the latter case.
+//
+// Events are received through a channel with the same buffer as the tree uses
+// internally, so the ones the loop body is too slow to receive are dropped,
+// like with Watch.
+func Iterate(ctx context.Context, path string, events ...Event) iter.Seq2[EventInfo, error] {
+	return func(yield func(EventInfo, error) bool) {
+		c := make(chan EventInfo, buffer)
+		if err := Watch(path, c, events...); err != nil {
+			yield(nil, err)
+			return
+		}
+		defer Stop(c)
+		for {
+			select {
+			case <-ctx.Done():
+				yield(nil, ctx.Err())
+				return
+			case ei := <-c:
+				if !yield(ei, nil) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+//go:build go1.23
+// +build go1.23
+
+package notify
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIterate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_iterate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout())
+	defer cancel()
+	file := filepath.Join(dir, "file")
+	go func() {
+		for _, ok := Events(dir); !ok; _, ok = Events(dir) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		must(ioutil.WriteFile(file, nil, 0644))
+	}()
+	var n int
+	for ei, err := range Iterate(ctx, dir, Create) {
+		switch n++; n {
+		case 1:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ei.Path() != file {
+				t.Fatalf("want path=%q; got %q", file, ei.Path())
+			}
+			cancel()
+		case 2:
+			if err != context.Canceled {
+				t.Fatalf("want err=%v; got %v", context.Canceled, err)
+			}
+		}
+	}
+	if n != 2 {
+		t.Fatalf("want 2 iterations; got %d", n)
+	}
+	if _, ok := Events(dir); ok {
+		t.Errorf("want %q not to be watched once the iteration ended", dir)
+	}
+}
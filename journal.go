@@ -0,0 +1,129 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Journal gives the compacted history of the events recently delivered for
+// the watchpoints set up for the path with WithCompactedJournal, the least
+// recently changed paths first. Every path reported by the events appears at
+// most once. It fails with ErrNotWatched if there are no watchpoints keeping
+// a journal registered for the path.
+func Journal(path string) ([]EventInfo, error) {
+	return defaultTree.Journal(path)
+}
+
+// journal keeps the latest state-relevant event for each of at most n
+// recently changed paths.
+type journal struct {
+	mu    sync.Mutex
+	n     int
+	order *list.List               // of EventInfo, least recently changed first
+	m     map[string]*list.Element // event's path to its element in order
+}
+
+func newJournal(n int) *journal {
+	return &journal{
+		n:     n,
+		order: list.New(),
+		m:     make(map[string]*list.Element),
+	}
+}
+
+// compact gives the event, which describes the state of a path after cur
+// followed prev, false if the events cancel each other out. A file which
+// was created stays created after it was written to, and a file created
+// and then removed or renamed never existed as far as the journal is
+// concerned. Otherwise the latest event wins.
+func compact(prev, cur EventInfo) (EventInfo, bool) {
+	if prev.Event()&Create == 0 {
+		return cur, true
+	}
+	switch {
+	case cur.Event()&(Remove|Rename) != 0:
+		return nil, false
+	case cur.Event()&Create == 0:
+		return prev, true
+	}
+	return cur, true
+}
+
+// record adds ei to the journal, evicting the least recently changed path
+// if the journal is full.
+func (j *journal) record(ei EventInfo) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	path := ei.Path()
+	if el, ok := j.m[path]; ok {
+		j.order.Remove(el)
+		delete(j.m, path)
+		var keep bool
+		if ei, keep = compact(el.Value.(EventInfo), ei); !keep {
+			return
+		}
+	}
+	j.m[path] = j.order.PushBack(ei)
+	if j.order.Len() > j.n {
+		el := j.order.Front()
+		j.order.Remove(el)
+		delete(j.m, el.Value.(EventInfo).Path())
+	}
+}
+
+// events gives the recorded events, least recently changed first.
+func (j *journal) events() []EventInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	events := make([]EventInfo, 0, j.order.Len())
+	for el := j.order.Front(); el != nil; el = el.Next() {
+		events = append(events, el.Value.(EventInfo))
+	}
+	return events
+}
+
+// journaled gives a stage, which records every event passing through it in
+// the journal of at most n paths, which is attached to the pipe given to arm.
+func journaled(n int) (s stage, arm func(*pipe)) {
+	j := newJournal(n)
+	s = func(next handler) handler {
+		return func(ei EventInfo) {
+			j.record(ei)
+			next(ei)
+		}
+	}
+	arm = func(p *pipe) {
+		p.mu.Lock()
+		p.jn = j
+		p.mu.Unlock()
+	}
+	return s, arm
+}
+
+// Journal gives the events recorded by the journals of the pipes registered
+// for the path, failing with ErrNotWatched if none of them keeps a journal.
+func (t *pipeTree) Journal(path string) ([]EventInfo, error) {
+	key := pathkey(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var events []EventInfo
+	var ok bool
+	for _, m := range t.pipes {
+		for _, p := range m[key] {
+			p.mu.Lock()
+			j := p.jn
+			p.mu.Unlock()
+			if j != nil {
+				events, ok = append(events, j.events()...), true
+			}
+		}
+	}
+	if !ok {
+		return nil, &WatchError{Op: "journal", Path: path, Err: ErrNotWatched}
+	}
+	return events, nil
+}
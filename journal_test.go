@@ -0,0 +1,127 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	cases := [...]struct {
+		n      int
+		events []EventInfo
+		want   []EventInfo
+	}{
+		// i=0
+		{
+			n: 4,
+			events: []EventInfo{
+				&Call{P: "/a", E: Create},
+				&Call{P: "/a", E: Write},
+				&Call{P: "/b", E: Write},
+			},
+			want: []EventInfo{
+				&Call{P: "/a", E: Create},
+				&Call{P: "/b", E: Write},
+			},
+		},
+		// i=1
+		{
+			n: 4,
+			events: []EventInfo{
+				&Call{P: "/a", E: Create},
+				&Call{P: "/b", E: Write},
+				&Call{P: "/a", E: Write},
+				&Call{P: "/a", E: Remove},
+			},
+			want: []EventInfo{
+				&Call{P: "/b", E: Write},
+			},
+		},
+		// i=2
+		{
+			n: 4,
+			events: []EventInfo{
+				&Call{P: "/a", E: Write},
+				&Call{P: "/b", E: Write},
+				&Call{P: "/a", E: Remove},
+				&Call{P: "/b", E: Rename},
+				&Call{P: "/b", E: Create},
+			},
+			want: []EventInfo{
+				&Call{P: "/a", E: Remove},
+				&Call{P: "/b", E: Create},
+			},
+		},
+		// i=3
+		{
+			n: 2,
+			events: []EventInfo{
+				&Call{P: "/a", E: Write},
+				&Call{P: "/b", E: Write},
+				&Call{P: "/c", E: Write},
+				&Call{P: "/b", E: Remove},
+			},
+			want: []EventInfo{
+				&Call{P: "/c", E: Write},
+				&Call{P: "/b", E: Remove},
+			},
+		},
+	}
+	for i, cas := range cases {
+		j := newJournal(cas.n)
+		for _, ei := range cas.events {
+			j.record(ei)
+		}
+		got := j.events()
+		if len(got) != len(cas.want) {
+			t.Errorf("want %d events; got %v (i=%d)", len(cas.want), got, i)
+			continue
+		}
+		for k := range got {
+			if err := EqualEventInfo(cas.want[k], got[k]); err != nil {
+				t.Errorf("%v (i=%d, k=%d)", err, i, k)
+			}
+		}
+	}
+}
+
+func TestPipeTreeJournal(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	if _, err := tr.Journal(dir); err == nil {
+		t.Fatal("want err!=nil for a path not watched")
+	}
+	o := options{journal: 8}
+	must(o.watch(tr.pipeTree, dir, ch[0], Create|Remove))
+	expect := func() {
+		select {
+		case <-ch[0]:
+		case <-time.After(timeout()):
+			t.Fatal("timed out")
+		}
+	}
+	file := filepath.Join(dir, "file")
+	must(ioutil.WriteFile(file, nil, 0644))
+	expect()
+	must(ioutil.WriteFile(filepath.Join(dir, "swp"), nil, 0644))
+	expect()
+	must(os.Remove(filepath.Join(dir, "swp")))
+	expect()
+	events, err := tr.Journal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("want 1 event; got %v", events)
+	}
+	if err := EqualEventInfo(&Call{P: file, E: Create}, events[0]); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// record is a JSON representation of a single EventInfo value.
+type record struct {
+	Path  string    `json:"path"`
+	Event string    `json:"event"`
+	IsDir bool      `json:"isdir"`
+	Time  time.Time `json:"timestamp"`
+}
+
+// jsonEvent is an event decoded by ReadJSON. Its Sys() returns the time.Time
+// value the event was encoded at.
+type jsonEvent struct {
+	path  string
+	event Event
+	dir   bool
+	time  time.Time
+}
+
+var _ fmt.Stringer = (*jsonEvent)(nil)
+var _ isDirer = (*jsonEvent)(nil)
+
+func (e *jsonEvent) Event() Event         { return e.event }
+func (e *jsonEvent) Path() string         { return e.path }
+func (e *jsonEvent) Sys() interface{}     { return e.time }
+func (e *jsonEvent) isDir() (bool, error) { return e.dir, nil }
+
+// String implements fmt.Stringer interface.
+func (e *jsonEvent) String() string {
+	return e.Event().String() + `: "` + e.Path() + `"`
+}
+
+// StreamJSON writes each event received from c to w as a single line of JSON,
+// until c is closed or a write fails. Every line is an object with the
+// following fields:
+//
+//   {"path":"/tmp/file","event":"notify.Create","isdir":false,"timestamp":"..."}
+//
+// The timestamp is the time the event was encoded at, in RFC 3339 format.
+// The output can be read back with ReadJSON.
+//
+// Every event is written as soon as it is received, so w may be a pipe, a unix
+// socket or the stdin of another process, e.g. for a daemon watching paths
+// with privileges, which streams the events to unprivileged consumers:
+//
+//   conn, err := net.Dial("unix", "/run/notifyd.sock")
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   go notify.StreamJSON(conn, c)
+//
+// with the consumers reading them with ReadJSON from their end of the socket.
+func StreamJSON(w io.Writer, c chan EventInfo) error {
+	enc := json.NewEncoder(w)
+	for ei := range c {
+		if err := encodeEvent(enc, ei); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeEvent writes ei to enc as a single record.
+func encodeEvent(enc *json.Encoder, ei EventInfo) error {
+	rec := record{
+		Path:  ei.Path(),
+		Event: ei.Event().String(),
+		Time:  now(),
+	}
+	if d, ok := ei.(isDirer); ok {
+		rec.IsDir, _ = d.isDir()
+	}
+	return enc.Encode(&rec)
+}
+
+// Record tees the events received from c: it writes each of them to w, in
+// the format of StreamJSON, and sends it on the returned channel, which is
+// closed once c is closed. It is meant for capturing a live event stream, e.g.
+// a hard to reproduce sequence of events from production, for replaying it
+// later with Replay:
+//
+//   f, err := os.Create("session.json")
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   for ei := range notify.Record(c, f) {
+//       handle(ei)
+//   }
+//
+// Writing to w stops after the first write failure, the events are still
+// sent on the returned channel.
+func Record(c chan EventInfo, w io.Writer) chan EventInfo {
+	out := make(chan EventInfo, buffer)
+	go func() {
+		defer close(out)
+		var err error
+		enc := json.NewEncoder(w)
+		for ei := range c {
+			if err == nil {
+				err = encodeEvent(enc, ei)
+			}
+			out <- ei
+		}
+	}()
+	return out
+}
+
+// Replay gives a channel, which the events recorded to r with Record or
+// StreamJSON are sent on, as fast as they are received. The channel is closed
+// once r is exhausted or it fails to decode. The events are the ones given by
+// ReadJSON, so no filesystem is involved in replaying them.
+func Replay(r io.Reader) chan EventInfo {
+	return replay(r, false)
+}
+
+// ReplayTimed works like Replay, but the events are sent with the delays
+// between them, which they were recorded with.
+func ReplayTimed(r io.Reader) chan EventInfo {
+	return replay(r, true)
+}
+
+func replay(r io.Reader, timed bool) chan EventInfo {
+	c, out := make(chan EventInfo), make(chan EventInfo, buffer)
+	go func() {
+		defer close(c)
+		if err := ReadJSON(r, c); err != nil {
+			dbgprintf("replay failed: %v", err)
+		}
+	}()
+	go func() {
+		defer close(out)
+		var last time.Time
+		for ei := range c {
+			t := ei.Sys().(time.Time)
+			if timed && !last.IsZero() && t.After(last) {
+				sleep(t.Sub(last))
+			}
+			last = t
+			out <- ei
+		}
+	}()
+	return out
+}
+
+// ReadJSON decodes the events written by StreamJSON from r and sends them
+// to c, until r is exhausted. Sys() of each event sent returns the time.Time
+// value of its timestamp field. Every event is sent as soon as its line is
+// read, so r may be the reading end of a stream written by another process.
+func ReadJSON(r io.Reader, c chan<- EventInfo) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		e, err := parseEvent(rec.Event)
+		if err != nil {
+			return err
+		}
+		c <- &jsonEvent{path: rec.Path, event: e, dir: rec.IsDir, time: rec.Time}
+	}
+}
+
+// parseEvent is the reverse of Event.String.
+func parseEvent(s string) (e Event, err error) {
+	if s == "" {
+		return 0, nil
+	}
+All:
+	for _, str := range strings.Split(s, "|") {
+		for _, strmap := range []map[Event]string{estr, osestr} {
+			for ev, evstr := range strmap {
+				if evstr == str {
+					e |= ev
+					continue All
+				}
+			}
+		}
+		return 0, fmt.Errorf("notify: unknown event %q", str)
+	}
+	return e, nil
+}
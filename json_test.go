@@ -0,0 +1,154 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamJSON(t *testing.T) {
+	want := []EventInfo{
+		&synthetic{path: "/a/b", event: Create, dir: true},     // i=0
+		&synthetic{path: "/a/b/c.txt", event: Write},           // i=1
+		&synthetic{path: "/a/b/c.txt", event: Remove | Rename}, // i=2
+	}
+	in := make(chan EventInfo, len(want))
+	for _, ei := range want {
+		in <- ei
+	}
+	close(in)
+	var buf bytes.Buffer
+	before := time.Now()
+	if err := StreamJSON(&buf, in); err != nil {
+		t.Fatalf("StreamJSON()=%v", err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != len(want) {
+		t.Fatalf("want %d lines; got %d", len(want), n)
+	}
+	out := make(chan EventInfo, len(want))
+	if err := ReadJSON(&buf, out); err != nil {
+		t.Fatalf("ReadJSON()=%v", err)
+	}
+	close(out)
+	i := 0
+	for ei := range out {
+		if i == len(want) {
+			t.Fatalf("want %d events; got more", len(want))
+		}
+		w := want[i].(*synthetic)
+		if ei.Path() != w.path {
+			t.Errorf("want path=%q; got %q (i=%d)", w.path, ei.Path(), i)
+		}
+		if ei.Event() != w.event {
+			t.Errorf("want event=%v; got %v (i=%d)", w.event, ei.Event(), i)
+		}
+		if dir, _ := ei.(isDirer).isDir(); dir != w.dir {
+			t.Errorf("want isdir=%t; got %t (i=%d)", w.dir, dir, i)
+		}
+		if ts, ok := ei.Sys().(time.Time); !ok || ts.Before(before) {
+			t.Errorf("want timestamp after %v; got %v (i=%d)", before, ei.Sys(), i)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Errorf("want %d events; got %d", len(want), i)
+	}
+}
+
+func TestStreamJSONPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	in, out := make(chan EventInfo), make(chan EventInfo)
+	go func() {
+		StreamJSON(w, in)
+		w.Close()
+	}()
+	go func() {
+		ReadJSON(r, out)
+		close(out)
+	}()
+	// Every event must get through the pipe before the next one is sent.
+	for i, path := range []string{"/a", "/a/b", "/a/b/c"} {
+		in <- &synthetic{path: path, event: Create}
+		select {
+		case ei := <-out:
+			if ei.Path() != path || ei.Event() != Create {
+				t.Fatalf("want %v: %q; got %v (i=%d)", Create, path, ei, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+	close(in)
+	if ei, ok := <-out; ok {
+		t.Errorf("want out to be closed; got %v", ei)
+	}
+}
+
+func TestReadJSONInvalid(t *testing.T) {
+	cases := [...]string{
+		`{"path":"/a","event":"notify.Bogus"}`, // i=0
+		`{"path":`,                             // i=1
+	}
+	for i, cas := range cases {
+		c := make(chan EventInfo, 1)
+		if err := ReadJSON(strings.NewReader(cas), c); err == nil {
+			t.Errorf("want err!=nil (i=%d)", i)
+		}
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	want := []EventInfo{
+		&synthetic{path: "/a/b", event: Create, dir: true}, // i=0
+		&synthetic{path: "/a/b/c.txt", event: Write},       // i=1
+		&synthetic{path: "/a/b/c.txt", event: Remove},      // i=2
+	}
+	in := make(chan EventInfo, len(want))
+	for _, ei := range want {
+		in <- ei
+	}
+	close(in)
+	var buf bytes.Buffer
+	i := 0
+	for ei := range Record(in, &buf) {
+		if ei != want[i] {
+			t.Fatalf("want %v; got %v (i=%d)", want[i], ei, i)
+		}
+		i++
+	}
+	i = 0
+	for ei := range Replay(&buf) {
+		w := want[i].(*synthetic)
+		if ei.Path() != w.path || ei.Event() != w.event {
+			t.Fatalf("want %v; got %v (i=%d)", w, ei, i)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Fatalf("want %d events replayed; got %d", len(want), i)
+	}
+	session := `{"path":"/a","event":"notify.Create","isdir":false,"timestamp":"2018-01-01T00:00:00Z"}
+{"path":"/a","event":"notify.Write","isdir":false,"timestamp":"2018-01-01T00:00:00.1Z"}
+`
+	start := time.Now()
+	i = 0
+	for range ReplayTimed(strings.NewReader(session)) {
+		i++
+	}
+	if i != 2 {
+		t.Fatalf("want 2 events replayed; got %d", i)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Fatalf("want events replayed with 100ms delay; took %v", d)
+	}
+}
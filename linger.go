@@ -0,0 +1,274 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WithLingerUnwatch makes notify keep the watches of the underlying watcher
+// for d after the watchpoint was removed, e.g. with Stop. If the path is
+// watched again within d, the lingering watch is reused instead of being torn
+// down and set up anew. It is meant for applications watching and unwatching
+// the same paths many times per second, e.g. a UI following the directory
+// the user navigates to, for which setting up the watches dominates.
+//
+// The events reported for the path while its watch lingers are dropped, as
+// no watchpoint is registered for it. For recursive watchpoints emulated for
+// watchers like inotify, the watches of all the directories under the path
+// linger.
+func WithLingerUnwatch(d time.Duration) Option {
+	return func(o *options) {
+		o.linger = d
+	}
+}
+
+// lingerWatcher is implemented by the watchers, which are able to defer
+// removing their watches.
+type lingerWatcher interface {
+	// setLinger makes the watches of the path, and of the paths under it if
+	// rec is true, linger for d once they are removed.
+	setLinger(path string, rec bool, d time.Duration)
+}
+
+// SetLinger makes the watches of the path linger for d once removed, if
+// the underlying watcher is able to.
+func (t *pipeTree) SetLinger(path string, rec bool, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if lw, ok := watcherOf(t.tree).(lingerWatcher); ok {
+		lw.setLinger(path, rec, d)
+	}
+}
+
+// lingeringWatcher defers removing the watches of the wrapped watcher, which
+// linger.
+type lingeringWatcher struct {
+	hookWatcher
+	lg *lingerer
+}
+
+// lingeringRecursiveWatcher is a lingeringWatcher for recursive watchers.
+type lingeringRecursiveWatcher struct {
+	lingeringWatcher
+	rw hookRecursiveWatcher
+}
+
+// withLinger wraps w, which is given by withHooks, with a watcher deferring
+// removal of the watches, which linger. The wrapper implements
+// recursiveWatcher if w does.
+func withLinger(w watcher) watcher {
+	switch w := w.(type) {
+	case hookRecursiveWatcher:
+		return lingeringRecursiveWatcher{lingeringWatcher{w.hookWatcher, newLingerer()}, w}
+	case hookWatcher:
+		return lingeringWatcher{w, newLingerer()}
+	}
+	return w
+}
+
+// setLinger implements lingerWatcher interface.
+func (w lingeringWatcher) setLinger(path string, rec bool, d time.Duration) {
+	w.lg.mu.Lock()
+	w.lg.conf[path] = lingerConf{d: d, rec: rec}
+	w.lg.mu.Unlock()
+}
+
+// Following methods implement notify.watcher interface.
+func (w lingeringWatcher) Watch(path string, e Event) error {
+	rewatch := func(olde Event) error { return w.hookWatcher.Rewatch(path, olde, e) }
+	if ok, err := w.lg.revive(path, false, e, rewatch); ok {
+		return tracked(path, err)
+	}
+	return w.lg.watched(path, e, w.hookWatcher.Watch(path, e))
+}
+
+func (w lingeringWatcher) Unwatch(path string) error {
+	remove := func() error { return w.hookWatcher.Unwatch(path) }
+	if w.lg.linger(path, false, remove) {
+		return nil
+	}
+	return remove()
+}
+
+func (w lingeringWatcher) Rewatch(path string, olde, newe Event) error {
+	return w.lg.watched(path, newe, w.hookWatcher.Rewatch(path, olde, newe))
+}
+
+func (w lingeringWatcher) Close() error {
+	w.lg.stop()
+	return w.hookWatcher.Close()
+}
+
+// Following methods implement notify.recursiveWatcher interface.
+func (w lingeringRecursiveWatcher) RecursiveWatch(path string, e Event) error {
+	rewatch := func(olde Event) error { return w.rw.RecursiveRewatch(path, path, olde, e) }
+	if ok, err := w.lg.revive(path, true, e, rewatch); ok {
+		return tracked(path, err)
+	}
+	return w.lg.watched(path, e, w.rw.RecursiveWatch(path, e))
+}
+
+func (w lingeringRecursiveWatcher) RecursiveUnwatch(path string) error {
+	remove := func() error { return w.rw.RecursiveUnwatch(path) }
+	if w.lg.linger(path, true, remove) {
+		return nil
+	}
+	return remove()
+}
+
+func (w lingeringRecursiveWatcher) RecursiveRewatch(oldp, newp string, olde, newe Event) error {
+	return w.lg.watched(newp, newe, w.rw.RecursiveRewatch(oldp, newp, olde, newe))
+}
+
+// lingerConf tells how long the watches of a path linger.
+type lingerConf struct {
+	d   time.Duration
+	rec bool // whether the paths under the path linger as well
+}
+
+// lingering is a watch, which removal was deferred.
+type lingering struct {
+	e      Event // events the watch was set up for
+	rec    bool  // whether the watch is a recursive one
+	t      Timer
+	remove func() error
+}
+
+// lingerer keeps track of the lingering watches of a watcher.
+type lingerer struct {
+	mu      sync.Mutex
+	tree    sync.Locker // lock of the tree, which calls the watcher
+	conf    map[string]lingerConf
+	events  map[string]Event // events the lingering paths are watched for
+	pending map[string]*lingering
+}
+
+func newLingerer() *lingerer {
+	return &lingerer{
+		conf:    make(map[string]lingerConf),
+		events:  make(map[string]Event),
+		pending: make(map[string]*lingering),
+	}
+}
+
+// duration gives the time the watch of the path lingers for. It expects
+// lg.mu to be held.
+func (lg *lingerer) duration(path string) time.Duration {
+	if c, ok := lg.conf[path]; ok {
+		return c.d
+	}
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if c, ok := lg.conf[dir]; ok && c.rec {
+			return c.d
+		}
+		if filepath.Dir(dir) == dir {
+			return 0
+		}
+	}
+}
+
+// watched records the events the path is watched for, if its watch lingers
+// and err, the outcome of the request, is nil. It gives err.
+func (lg *lingerer) watched(path string, e Event, err error) error {
+	if err != nil {
+		return err
+	}
+	lg.mu.Lock()
+	if lg.duration(path) > 0 {
+		lg.events[path] = e
+	}
+	lg.mu.Unlock()
+	return nil
+}
+
+// linger defers removing the watch of the path with the remove function, if
+// the watch lingers. It reports whether it did.
+func (lg *lingerer) linger(path string, rec bool, remove func() error) bool {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	d := lg.duration(path)
+	if d <= 0 {
+		return false
+	}
+	l := &lingering{e: lg.events[path], rec: rec, remove: remove}
+	l.t = afterFunc(d, func() {
+		// The watch is removed while the lock of the tree is held, like
+		// with any other call to the watcher, which may not be safe for
+		// concurrent use, and while lg.mu is held, so that it is not revived
+		// in the meantime. The tree calls the watcher with its lock held,
+		// so it is taken first.
+		lg.mu.Lock()
+		tree := lg.tree
+		lg.mu.Unlock()
+		if tree != nil {
+			tree.Lock()
+			defer tree.Unlock()
+		}
+		lg.mu.Lock()
+		defer lg.mu.Unlock()
+		if lg.pending[path] != l {
+			return
+		}
+		delete(lg.pending, path)
+		delete(lg.events, path)
+		delete(lg.conf, path)
+		l.remove()
+	})
+	lg.pending[path] = l
+	return true
+}
+
+// revive reuses the lingering watch of the path for the events e, calling
+// rewatch if it was set up for other events. It reports whether there was
+// a watch to reuse. A lingering watch of the other kind is removed right away.
+func (lg *lingerer) revive(path string, rec bool, e Event, rewatch func(olde Event) error) (bool, error) {
+	lg.mu.Lock()
+	defer lg.mu.Unlock()
+	l, ok := lg.pending[path]
+	if !ok {
+		return false, nil
+	}
+	delete(lg.pending, path)
+	l.t.Stop()
+	if l.rec != rec {
+		l.remove()
+		return false, nil
+	}
+	lg.events[path] = e
+	if l.e == e {
+		return true, nil
+	}
+	return true, rewatch(l.e)
+}
+
+// lockWith makes the deferred removals of the lingering watches of w hold
+// mu, the lock of the tree w is called by.
+func lockWith(w watcher, mu sync.Locker) {
+	var lg *lingerer
+	switch w := w.(type) {
+	case lingeringWatcher:
+		lg = w.lg
+	case lingeringRecursiveWatcher:
+		lg = w.lg
+	default:
+		return
+	}
+	lg.mu.Lock()
+	lg.tree = mu
+	lg.mu.Unlock()
+}
+
+// stop cancels all the deferred removals, e.g. since the watcher is closed.
+func (lg *lingerer) stop() {
+	lg.mu.Lock()
+	for path, l := range lg.pending {
+		l.t.Stop()
+		delete(lg.pending, path)
+	}
+	lg.mu.Unlock()
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLingerUnwatch(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	var mu sync.Mutex
+	var calls []string
+	record := func(op string) func(string) {
+		return func(path string) {
+			if path == dir {
+				mu.Lock()
+				calls = append(calls, op)
+				mu.Unlock()
+			}
+		}
+	}
+	SetHooks(Hooks{OnWatch: record("watch"), OnUnwatch: record("unwatch")})
+	defer SetHooks(Hooks{})
+	expect := func(want ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(calls) != len(want) {
+			t.Fatalf("want calls=%v; got %v", want, calls)
+		}
+		for i := range want {
+			if calls[i] != want[i] {
+				t.Fatalf("want calls=%v; got %v", want, calls)
+			}
+		}
+	}
+	const d = 200 * time.Millisecond
+	c := make(chan EventInfo, buffer)
+	must(tr.WatchWithOptions(dir, c, Create, WithLingerUnwatch(d)))
+	tr.Stop(c)
+	expect("watch")
+	// The lingering watch is reused, delivering events for the new
+	// registration.
+	must(tr.Watch(dir, c, Create|Remove))
+	expect("watch")
+	file := filepath.Join(dir, "file")
+	for i, e := range []Event{Create, Remove} {
+		if e == Create {
+			must(ioutil.WriteFile(file, nil, 0644))
+		} else {
+			must(os.Remove(file))
+		}
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&Call{P: file, E: e}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+	tr.Stop(c)
+	time.Sleep(2 * d)
+	expect("watch", "unwatch")
+}
+
+func TestLingerHoldsTreeLock(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	var tree sync.Mutex
+	w := withLinger(withHooks(newSimWatcher(nil)))
+	lockWith(w, &tree)
+	lg := w.(lingeringWatcher).lg
+	lg.conf["/a"] = lingerConf{d: time.Second}
+	removed := make(chan struct{})
+	lg.linger("/a", false, func() error { close(removed); return nil })
+	tree.Lock()
+	go clk.Advance(time.Second)
+	select {
+	case <-removed:
+		t.Fatal("want the watch removed with the tree lock held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tree.Unlock()
+	select {
+	case <-removed:
+	case <-time.After(timeout()):
+		t.Fatal("timed out waiting for the watch to be removed")
+	}
+}
+
+func TestLingerFailedWatch(t *testing.T) {
+	w := withLinger(withHooks(watcherStub{errors.New("stub")}))
+	lg := w.(lingeringWatcher).lg
+	lg.conf["/a"] = lingerConf{d: time.Second}
+	if err := w.Watch("/a", Create); err == nil {
+		t.Fatal("want err!=nil")
+	}
+	if e, ok := lg.events["/a"]; ok {
+		t.Fatalf("want failed watch not to be recorded; got %v", e)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// linkWindow is a period within which the same events reported for
+// a hard-linked file and for its directory are deduplicated.
+const linkWindow = 50 * time.Millisecond
+
+// hardlinks gives regular files with more than one hard link, which live under
+// the path. If the path is a file, it is the only candidate.
+func hardlinks(path string, isrec bool) (files []string, err error) {
+	fn := func(p string, fi os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		}
+		if fi.Mode().IsRegular() && nlink(fi) > 1 {
+			files = append(files, p)
+		}
+		return nil
+	}
+	if isrec {
+		if err = filepath.Walk(path, fn); err != nil {
+			return nil, err
+		}
+		return files, nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return files, fn(path, fi, nil)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		fn(filepath.Join(path, fi.Name()), fi, nil)
+	}
+	return files, nil
+}
@@ -0,0 +1,15 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package notify
+
+import "os"
+
+// nlink gives the number of hard links of the file described by fi. It is not
+// supported on this platform, so every file is reported to have a single one.
+func nlink(os.FileInfo) uint64 {
+	return 1
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// pathMapper translates paths between the namespace of the process and
+// the namespace of the underlying watcher.
+type pathMapper struct {
+	toKernel   func(string) string
+	fromKernel func(string) string
+}
+
+var mapper atomic.Value
+
+func init() {
+	mapper.Store(&pathMapper{})
+}
+
+// SetPathMapper registers functions translating paths between the namespace
+// of the process and the namespace the underlying watcher works in, e.g. for
+// processes running in containers or chroots, which see the watched files
+// under other paths than the ones the OS reports in events. The paths given to
+// Watch are passed to the watcher translated with toKernel, the paths of the
+// events reported by the watcher are translated back with fromKernel, so that
+// EventInfo.Path() is always in the namespace of the process. The Hooks are
+// called with the paths of the process as well.
+//
+// Both functions are given absolute, cleaned paths and must not call back into
+// notify. Since watches set up before the call keep their paths, SetPathMapper
+// is meant to be called before any path is watched. Calling it with nil
+// functions unregisters the translation.
+func SetPathMapper(toKernel, fromKernel func(string) string) {
+	mapper.Store(&pathMapper{toKernel: toKernel, fromKernel: fromKernel})
+}
+
+func loadMapper() *pathMapper {
+	return mapper.Load().(*pathMapper)
+}
+
+// kernelPath translates the path to the namespace of the watcher.
+func kernelPath(path string) string {
+	if fn := loadMapper().toKernel; fn != nil {
+		return fn(path)
+	}
+	return path
+}
+
+// userEvent translates the path of ei, reported by the watcher, to
+// the namespace of the process.
+func userEvent(ei EventInfo) EventInfo {
+	fn := loadMapper().fromKernel
+	if fn == nil {
+		return ei
+	}
+	return remap(ei, fn(ei.Path()))
+}
+
+// remap gives ei reported under the path, keeping the optional interfaces
+// ei implements.
+func remap(ei EventInfo, path string) EventInfo {
+	if path == ei.Path() {
+		return ei
+	}
+	m := &mapped{EventInfo: ei, path: path}
+	switch ei.(type) {
+	case SizeEventInfo:
+		return mappedSize{m}
+	case StatEventInfo:
+		return mappedStat{m}
+	case Sequenced:
+		return mappedSeq{m}
+	}
+	return m
+}
+
+// mapped is an event, which path was translated, e.g. to the namespace of
+// the process.
+type mapped struct {
+	EventInfo
+	path string
+}
+
+var _ isDirer = (*mapped)(nil)
+var _ OwnEventInfo = (*mapped)(nil)
+var _ systemer = (*mapped)(nil)
+var _ CoalescedEventInfo = (*mapped)(nil)
+
+func (e *mapped) Path() string   { return e.path }
+func (e *mapped) Own() bool      { return isown(e.EventInfo) }
+func (e *mapped) isSystem() bool { return issystem(e.EventInfo) }
+func (e *mapped) Count() int     { return count(e.EventInfo) }
+
+func (e *mapped) isDir() (bool, error) {
+	if d, ok := e.EventInfo.(isDirer); ok {
+		return d.isDir()
+	}
+	return false, nil
+}
+
+// The mapped events keep the optional interfaces of the events they wrap.
+type (
+	mappedSeq  struct{ *mapped }
+	mappedStat struct{ *mapped }
+	mappedSize struct{ *mapped }
+)
+
+var _ Sequenced = mappedSeq{}
+var _ InodeEventInfo = mappedStat{}
+var _ SizeEventInfo = mappedSize{}
+var _ InodeEventInfo = mappedSize{}
+
+func (e mappedSeq) ID() uint64              { return e.EventInfo.(Sequenced).ID() }
+func (e mappedStat) FileInfo() os.FileInfo  { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e mappedSize) FileInfo() os.FileInfo  { return e.EventInfo.(SizeEventInfo).FileInfo() }
+func (e mappedSize) SizeChange() SizeChange { return e.EventInfo.(SizeEventInfo).SizeChange() }
+
+func (e mappedStat) Inode() (dev, ino uint64, ok bool) { return inodeOf(e.EventInfo) }
+func (e mappedSize) Inode() (dev, ino uint64, ok bool) { return inodeOf(e.EventInfo) }
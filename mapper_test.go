@@ -0,0 +1,42 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetPathMapper(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	// The process sees the files under user, the watcher under kernel.
+	user, kernel := filepath.Join(dir, "user"), filepath.Join(dir, "kernel")
+	must(os.Mkdir(user, 0755))
+	must(os.Mkdir(kernel, 0755))
+	replace := func(from, to string) func(string) string {
+		return func(path string) string {
+			if path == from || strings.HasPrefix(path, from+string(os.PathSeparator)) {
+				return to + path[len(from):]
+			}
+			return path
+		}
+	}
+	SetPathMapper(replace(user, kernel), replace(kernel, user))
+	defer SetPathMapper(nil, nil)
+	var watched []string
+	SetHooks(Hooks{OnWatch: func(path string) { watched = append(watched, path) }})
+	defer SetHooks(Hooks{})
+	c := make(chan EventInfo, buffer)
+	must(tr.Watch(user, c, Create))
+	if len(watched) != 1 || watched[0] != user {
+		t.Fatalf("want OnWatch called for %q; got %v", user, watched)
+	}
+	must(ioutil.WriteFile(filepath.Join(kernel, "file"), nil, 0644))
+	tr.Expect(c, &Call{P: filepath.Join(user, "file"), E: Create})
+}
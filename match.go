@@ -0,0 +1,181 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var errMatchingRecursive = errors.New("notify: WatchMatching does not support recursive paths")
+
+// WatchMatching watches the parent directory and sets up a recursive
+// watchpoint for every its subdirectory, which name satisfies the match
+// function, delivering events for the subdirectory tree to c. The rule is
+// applied to both the existing subdirectories and the ones created later on,
+// e.g. it is possible to watch every /data/shard-* directory with:
+//
+//   notify.WatchMatching("/data", func(name string) bool {
+//           return strings.HasPrefix(name, "shard-")
+//   }, c, notify.All)
+//
+// The watchpoint for a subdirectory is removed once it is removed or renamed.
+// Events for the subdirectories themselves are reported if requested, events
+// for the other children of the parent directory are never reported.
+//
+// Use Stop to remove watchpoints set up with WatchMatching.
+func WatchMatching(parent string, match func(name string) bool, c chan<- EventInfo, events ...Event) error {
+	dir, isrec, err := cleanpath(parent)
+	if err != nil {
+		return err
+	}
+	if isrec {
+		return errMatchingRecursive
+	}
+	return defaultTree.WatchMatching(dir, match, c, joinevents(events))
+}
+
+// matching keeps track of the subdirectories of dir watched by WatchMatching.
+type matching struct {
+	t        *pipeTree
+	dir      string
+	match    func(string) bool
+	c        chan<- EventInfo
+	e        Event
+	mu       sync.Mutex // protects p
+	p        *pipe
+	children map[string]*pipe // protected by t.mu
+}
+
+// WatchMatching sets up a watchpoint on dir, which watches every subdirectory
+// satisfying the match function recursively.
+func (t *pipeTree) WatchMatching(dir string, match func(string) bool, c chan<- EventInfo, e Event) error {
+	m := &matching{
+		t:        t,
+		dir:      dir,
+		match:    match,
+		c:        c,
+		e:        e,
+		children: make(map[string]*pipe),
+	}
+	p, err := t.WatchPipe(dir, c, []stage{m.stage}, e|Create|Remove|Rename)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.p = p
+	m.mu.Unlock()
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		m.stop()
+		return err
+	}
+	for _, fi := range fis {
+		if fi.IsDir() && match(fi.Name()) {
+			if err := m.sync(filepath.Join(dir, fi.Name())); err != nil {
+				m.stop()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stop removes the watchpoints set up for dir and its subdirectories.
+func (m *matching) stop() {
+	m.mu.Lock()
+	p := m.p
+	m.mu.Unlock()
+	t := m.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for child, cp := range m.children {
+		if !cp.closed() {
+			t.remove(cp)
+			t.unwatch(cp)
+			cp.stop()
+		}
+		delete(m.children, child)
+	}
+	if !p.closed() {
+		t.remove(p)
+		t.unwatch(p)
+		p.stop()
+	}
+}
+
+// stage passes on events for the matching subdirectories only. For every event
+// reported for a matching subdirectory itself, its watchpoint is set up or
+// removed, depending on whether the subdirectory exists.
+func (m *matching) stage(next handler) handler {
+	return func(ei EventInfo) {
+		path := normalize(ei.Path())
+		name := strings.TrimPrefix(path, m.dir+string(os.PathSeparator))
+		if name == path || strings.ContainsRune(name, os.PathSeparator) || !m.match(name) {
+			return
+		}
+		// The pipe may be stopped by a call which waits for this handler
+		// to return, thus the tree must not be modified synchronously.
+		go func() {
+			if err := m.sync(path); err != nil {
+				dbgprintf("WatchMatching: watch %q error: %v", path, err)
+			}
+		}()
+		if ei.Event()&m.e != 0 {
+			next(ei)
+		}
+	}
+}
+
+// sync sets up a recursive watchpoint for the child directory if it exists
+// and was not watched already, or removes the watchpoint if the child does not
+// exist anymore.
+func (m *matching) sync(child string) error {
+	m.mu.Lock()
+	p := m.p
+	m.mu.Unlock()
+	t := m.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p == nil || p.closed() {
+		return nil
+	}
+	cp, ok := m.children[child]
+	if ok && cp.closed() {
+		delete(m.children, child)
+		ok = false
+	}
+	switch fi, err := os.Stat(child); {
+	case err == nil && fi.IsDir() && !ok:
+		cp = t.newPipe(m.c, except(child))
+		if err := t.watch(cp, filepath.Join(child, "..."), m.e); err != nil {
+			cp.stop()
+			return err
+		}
+		t.add(m.c, child, cp)
+		m.children[child] = cp
+	case (err != nil || !fi.IsDir()) && ok:
+		t.remove(cp)
+		t.unwatch(cp)
+		cp.stop()
+		delete(m.children, child)
+	}
+	return nil
+}
+
+// except gives a stage, which drops events for the path.
+func except(path string) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			if normalize(ei.Path()) != path {
+				next(ei)
+			}
+		}
+	}
+}
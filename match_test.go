@@ -0,0 +1,104 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipeTreeWatchMatching(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	shard0, shard1 := filepath.Join(dir, "shard-0"), filepath.Join(dir, "shard-1")
+	other := filepath.Join(dir, "other")
+	must(os.MkdirAll(filepath.Join(shard0, "sub"), 0755))
+	must(os.Mkdir(other, 0755))
+	match := func(name string) bool { return strings.HasPrefix(name, "shard-") }
+	must(tr.WatchMatching(dir, match, ch[0], Create))
+	// The existing matching directory is watched recursively.
+	must(ioutil.WriteFile(filepath.Join(other, "file"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(shard0, "sub", "file"), nil, 0644))
+	tr.Expect(ch[0], &Call{P: filepath.Join(shard0, "sub", "file"), E: Create})
+	// So is the one created later on.
+	must(os.Mkdir(shard1, 0755))
+	tr.Expect(ch[0], &Call{P: shard1, E: Create})
+	deadline := time.After(timeout())
+	for i := 0; ; i++ {
+		file := filepath.Join(shard1, "file"+strconv.Itoa(i))
+		must(ioutil.WriteFile(file, nil, 0644))
+		select {
+		case ei := <-ch[0]:
+			if ei.Path() != file {
+				t.Fatalf("want event for %q; got %v", file, ei)
+			}
+		case <-time.After(50 * time.Millisecond):
+			continue
+		case <-deadline:
+			t.Fatalf("timed out waiting for %q to be watched", shard1)
+		}
+		break
+	}
+	// Non-matching directories are not reported at all.
+	must(os.Mkdir(filepath.Join(dir, "tmp"), 0755))
+	must(ioutil.WriteFile(filepath.Join(other, "file2"), nil, 0644))
+	tr.ExpectDry(ch...)
+}
+
+// removingWatcher removes the path right after it was watched.
+type removingWatcher struct {
+	watcher
+	path string
+}
+
+func (w removingWatcher) Watch(p string, e Event) error {
+	err := w.watcher.Watch(p, e)
+	if p == w.path {
+		must(os.RemoveAll(p))
+	}
+	return err
+}
+
+func TestPipeTreeWatchMatchingError(t *testing.T) {
+	for i, name := range []string{"", "shard-1"} {
+		dir, err := ioutil.TempDir("", "notify_matching")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		if dir, _, err = cleanpath(dir); err != nil {
+			t.Fatal(err)
+		}
+		must(os.Mkdir(filepath.Join(dir, "shard-0"), 0755))
+		must(os.Mkdir(filepath.Join(dir, "shard-1"), 0755))
+		c := make(chan EventInfo, buffer)
+		w := newSimWatcher(c)
+		// Either the parent or a matching directory cannot be read.
+		tr := newPipeTree(newNonrecursiveTree(removingWatcher{w, filepath.Join(dir, name)}, c, nil))
+		ch := NewChans(1)
+		match := func(name string) bool { return strings.HasPrefix(name, "shard-") }
+		if err := tr.WatchMatching(dir, match, ch[0], Create); err == nil {
+			t.Fatalf("want err!=nil (i=%d)", i)
+		}
+		tr.mu.Lock()
+		n := len(tr.pipes[ch[0]])
+		tr.mu.Unlock()
+		if n != 0 {
+			t.Fatalf("want no pipes left; got %d (i=%d)", n, i)
+		}
+		w.mu.Lock()
+		watches := len(w.watches)
+		w.mu.Unlock()
+		if watches != 0 {
+			t.Fatalf("want no watches left; got %d (i=%d)", watches, i)
+		}
+		tr.Close()
+	}
+}
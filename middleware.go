@@ -0,0 +1,125 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "time"
+
+// EventHandler processes a single event, e.g. by passing it on to the next
+// handler of a middleware chain.
+type EventHandler func(EventInfo)
+
+// Middleware wraps the next handler with extra logic, e.g. filtering,
+// transforming or coalescing events before they are passed on. A middleware
+// may drop an event by not passing it on, or pass on several events instead
+// of one. It may be called from more than one goroutine, and so may be
+// the handler it gives.
+type Middleware func(next EventHandler) EventHandler
+
+// Use makes the events delivered to c pass through the given middlewares
+// first, in the order they were given, so the first middleware sees the events
+// before the others do. The middlewares are applied after the options given to
+// WatchWithOptions, right before the events are delivered to c. Calling Use
+// again appends the middlewares to the ones registered previously.
+//
+// Use affects watchpoints set up for c after the call only, so it is meant to
+// be called before c is watched, e.g.:
+//
+//   notify.Use(c, notify.Filter(isSource), notify.Dedup(time.Second))
+//
+//   if err := notify.Watch("./...", c, notify.All); err != nil {
+//       log.Fatal(err)
+//   }
+//
+// The middlewares are unregistered once Stop is called for c.
+func Use(c chan<- EventInfo, middlewares ...Middleware) {
+	defaultTree.Use(c, middlewares...)
+}
+
+// Filter gives a middleware, which passes on only the events fn reports true
+// for.
+func Filter(fn func(EventInfo) bool) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ei EventInfo) {
+			if fn(ei) {
+				next(ei)
+			}
+		}
+	}
+}
+
+// Transform gives a middleware, which passes on whatever fn returns for
+// an event, dropping the event if fn returns nil. See WithTransform.
+func Transform(fn func(EventInfo) EventInfo) Middleware {
+	return middleware(transform(fn))
+}
+
+// Dedup gives a middleware, which drops an event if an event with the same
+// path and the same value was passed on within the last window. The events
+// are deduplicated across all the watchpoints of the channel. See WithDedup.
+func Dedup(window time.Duration) Middleware {
+	return middleware(dedup(window, dedupSize, pathKey))
+}
+
+// DedupBy works like Dedup, but it tells events apart by the key fn gives
+// for them instead of by their paths. See WithCoalesceKey.
+func DedupBy(window time.Duration, fn func(EventInfo) string) Middleware {
+	return middleware(dedup(window, dedupSize, fn))
+}
+
+// Collapse gives a middleware, which merges the Create and the following
+// Write events of a new file within window into a single Create. See
+// WithCreateCollapse.
+func Collapse(window time.Duration) Middleware {
+	s, _ := collapse(window, pathKey)
+	return middleware(s)
+}
+
+// CollapseBy works like Collapse, but it matches the Write events with
+// the Create by the key fn gives for them instead of by their paths. See
+// WithCoalesceKey.
+func CollapseBy(window time.Duration, fn func(EventInfo) string) Middleware {
+	s, _ := collapse(window, fn)
+	return middleware(s)
+}
+
+// middleware converts a stage into a Middleware.
+func middleware(s stage) Middleware {
+	return func(next EventHandler) EventHandler {
+		return EventHandler(s(handler(next)))
+	}
+}
+
+// stage converts the middleware into a stage.
+func (m Middleware) stage(next handler) handler {
+	return handler(m(EventHandler(next)))
+}
+
+// Use registers the middlewares for the pipes of c created from now on.
+func (t *pipeTree) Use(c chan<- EventInfo, middlewares ...Middleware) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range middlewares {
+		t.uses[c] = append(t.uses[c], m.stage)
+	}
+}
+
+// newPipe creates a pipe delivering events to c through the given stages,
+// followed by the middlewares registered for c. The pipe is plain if no
+// stages were given, since all pipes of c share the middlewares. It expects
+// t.mu to be held.
+func (t *pipeTree) newPipe(c chan<- EventInfo, stages ...stage) *pipe {
+	var p *pipe
+	if uses := t.uses[c]; len(uses) == 0 {
+		p = newPipeActivity(c, t.act, stages...)
+	} else {
+		all := make([]stage, 0, len(stages)+len(uses))
+		p = newPipeActivity(c, t.act, append(append(all, stages...), uses...)...)
+		p.plain = len(stages) == 0
+	}
+	p.lim = t.rates[c]
+	p.mute = t.mutes[c]
+	p.gen, p.gens = t.gens[c]
+	return p
+}
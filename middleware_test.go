@@ -0,0 +1,93 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var got []EventInfo
+	m := Filter(func(ei EventInfo) bool { return strings.HasSuffix(ei.Path(), ".go") })
+	fn := m(Transform(func(ei EventInfo) EventInfo {
+		if ei.Event() == Unknown {
+			return &Call{P: ei.Path(), E: Write}
+		}
+		return ei
+	})(func(ei EventInfo) { got = append(got, ei) }))
+	cases := [...]struct {
+		ei   EventInfo
+		want EventInfo
+	}{
+		{&Call{P: "/a.go", E: Create}, &Call{P: "/a.go", E: Create}}, // i=0
+		{&Call{P: "/a.txt", E: Create}, nil},                         // i=1
+		{&Call{P: "/b.go", E: Unknown}, &Call{P: "/b.go", E: Write}}, // i=2
+	}
+	for i, cas := range cases {
+		got = nil
+		fn(cas.ei)
+		switch {
+		case cas.want == nil && len(got) != 0:
+			t.Errorf("want event dropped; got %v (i=%d)", got, i)
+		case cas.want == nil:
+		case len(got) != 1:
+			t.Errorf("want one event; got %v (i=%d)", got, i)
+		default:
+			if err := EqualEventInfo(cas.want, got[0]); err != nil {
+				t.Errorf("%v (i=%d)", err, i)
+			}
+		}
+	}
+}
+
+func TestPipeTreeUse(t *testing.T) {
+	tr := newPipeTree(newTree())
+	defer tr.Close()
+	c := make(chan EventInfo, buffer)
+	var mu sync.Mutex
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next EventHandler) EventHandler {
+			return func(ei EventInfo) {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				next(ei)
+			}
+		}
+	}
+	tr.Use(c, mark("first"), Dedup(time.Minute))
+	tr.Use(c, mark("second"))
+	tr.mu.Lock()
+	p := tr.newPipe(c)
+	tr.mu.Unlock()
+	defer p.stop()
+	if !p.plain {
+		t.Error("want pipe with middlewares only to be plain")
+	}
+	p.c <- &Call{P: "/a", E: Create}
+	p.c <- &Call{P: "/a", E: Create}
+	if err := EqualEventInfo(&Call{P: "/a", E: Create}, <-c); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ei := <-c:
+		t.Fatalf("want duplicate dropped; got %v", ei)
+	case <-time.After(50 * time.Millisecond):
+	}
+	mu.Lock()
+	got := strings.Join(order, " ")
+	mu.Unlock()
+	if want := "first second first"; got != want {
+		t.Errorf("want order %q; got %q", want, got)
+	}
+	tr.Stop(c)
+	if _, ok := tr.uses[c]; ok {
+		t.Error("want middlewares unregistered by Stop")
+	}
+}
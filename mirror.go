@@ -0,0 +1,205 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backoff of the rescans an FSMirror makes after the watcher overflowed, see
+// WithOverflowBackoff.
+const (
+	mirrorBackoff    = 100 * time.Millisecond
+	mirrorBackoffMax = 5 * time.Second
+)
+
+// MirrorEntry describes a file or a directory kept by an FSMirror.
+type MirrorEntry struct {
+	Path    string    // path of the file, under the root of the mirror
+	Dir     bool      // whether it is a directory
+	Size    int64     // size of the file, as reported by lstat(2)
+	ModTime time.Time // modification time of the file
+}
+
+// FSMirror is an in-memory view of a directory tree, which is kept in sync
+// with the filesystem by watching it. It is safe for concurrent use.
+type FSMirror struct {
+	sync sync.Mutex   // serializes the updates and the callbacks
+	mu   sync.RWMutex // protects s and fns
+	s    *snapshot
+	fns  []func(EventInfo)
+	stop func()
+}
+
+// Mirror watches root, recursively if told so, and keeps an FSMirror of it:
+// the names, the sizes and the modification times of the files under it.
+//
+//   m, err := notify.Mirror("/srv/www", true)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer m.Close()
+//   m.OnChange(func(ei notify.EventInfo) {
+//       log.Println("changed:", ei)
+//   })
+//   if e, ok := m.Stat("/srv/www/index.html"); ok {
+//       log.Println(e.Size, e.ModTime)
+//   }
+//
+// The tree is scanned once the watchpoint is armed, every path an event is
+// reported for is scanned again and the mirror is updated with the changes
+// found, so renames are handled like the removal of the old path and
+// the creation of the new one. Once the watcher overflowed, the whole tree is
+// scanned again, like with WithOverflowBackoff. For a tree changing while it
+// is scanned, the mirror may lag behind the filesystem until the event for
+// the change is reported.
+//
+// Use Close to stop watching root.
+func Mirror(root string, recursive bool) (*FSMirror, error) {
+	return defaultTree.Mirror(root, recursive)
+}
+
+// OnChange registers fn to be called for every change made to the mirror,
+// with an event describing it: a Create or a Remove for the entries, which
+// were added or removed, and a Write for the files, which size or
+// modification time changed. The calls are made one at a time, in the order
+// the changes were made to the mirror, and they must not block.
+func (m *FSMirror) OnChange(fn func(EventInfo)) {
+	m.mu.Lock()
+	m.fns = append(m.fns, fn)
+	m.mu.Unlock()
+}
+
+// Stat gives the entry kept for the path, false if there is none.
+func (m *FSMirror) Stat(path string) (MirrorEntry, bool) {
+	path = filepath.Clean(path)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.s.m[path]
+	if !ok {
+		return MirrorEntry{}, false
+	}
+	return mirrorEntry(path, e), true
+}
+
+// List gives the entries kept for the direct children of the directory dir,
+// sorted by their paths.
+func (m *FSMirror) List(dir string) []MirrorEntry {
+	dir = filepath.Clean(dir)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []MirrorEntry
+	for path, e := range m.s.m {
+		if parent, _ := split(path); parent == dir && path != dir {
+			list = append(list, mirrorEntry(path, e))
+		}
+	}
+	sort.Sort(byEntryPath(list))
+	return list
+}
+
+// Len gives the number of the entries kept by the mirror, including the root.
+func (m *FSMirror) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.s.m)
+}
+
+// Close stops watching the root of the mirror. The mirror can be queried
+// afterwards, but it is no longer updated.
+func (m *FSMirror) Close() error {
+	m.stop()
+	return nil
+}
+
+// update scans the path again and calls the callbacks for the changes found.
+func (m *FSMirror) update(path string) {
+	m.sync.Lock()
+	defer m.sync.Unlock()
+	m.mu.Lock()
+	if m.s == nil {
+		m.mu.Unlock()
+		return
+	}
+	eis, err := m.s.update(path)
+	fns := m.fns
+	m.mu.Unlock()
+	if err != nil {
+		dbgprintf("mirror: rescanning %q failed: %v", path, err)
+		return
+	}
+	for _, ei := range eis {
+		for _, fn := range fns {
+			fn(ei)
+		}
+	}
+}
+
+func mirrorEntry(path string, e entry) MirrorEntry {
+	return MirrorEntry{Path: path, Dir: e.dir, Size: e.size, ModTime: e.mod}
+}
+
+// byEntryPath implements sort.Interface, sorting entries by their paths.
+type byEntryPath []MirrorEntry
+
+func (b byEntryPath) Len() int           { return len(b) }
+func (b byEntryPath) Less(i, j int) bool { return b[i].Path < b[j].Path }
+func (b byEntryPath) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// Mirror watches root with a pipe of a channel of its own, which keeps
+// the mirror in sync with the events.
+func (t *pipeTree) Mirror(root string, recursive bool) (*FSMirror, error) {
+	dir, _, err := cleanpath(root)
+	if err != nil {
+		return nil, err
+	}
+	m := &FSMirror{}
+	// The updates wait for the initial scan.
+	m.sync.Lock()
+	s := func(handler) handler {
+		return func(ei EventInfo) {
+			path := normalize(ei.Path())
+			if path == dir || strings.HasPrefix(path, dir+sep) {
+				m.update(path)
+			}
+		}
+	}
+	path := dir
+	if recursive {
+		path = filepath.Join(dir, "...")
+	}
+	own := make(chan EventInfo)
+	p, err := t.WatchPipe(path, own, []stage{s}, All)
+	if err != nil {
+		m.sync.Unlock()
+		return nil, err
+	}
+	if ready := t.Ready(dir); ready != nil {
+		<-ready
+	}
+	sn, err := newSnapshot(dir, recursive)
+	m.mu.Lock()
+	m.s = sn
+	m.mu.Unlock()
+	m.sync.Unlock()
+	if err != nil {
+		t.Stop(own)
+		return nil, err
+	}
+	b := &backoff{initial: mirrorBackoff, max: mirrorBackoffMax}
+	b.fn = func() { m.update(dir) }
+	cancel := onOverflow(b.schedule)
+	go func() {
+		<-p.quit
+		cancel()
+		b.stop()
+	}()
+	m.stop = func() { t.Stop(own) }
+	return m, nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	path := func(rel string) string {
+		return filepath.Join(dir, filepath.FromSlash(rel))
+	}
+	must(os.Mkdir(path("sub"), 0755))
+	must(ioutil.WriteFile(path("sub/a"), []byte("a"), 0644))
+	m, err := tr.Mirror(dir, true)
+	if err != nil {
+		t.Fatalf("Mirror()=%v", err)
+	}
+	defer m.Close()
+	if n := m.Len(); n != 3 {
+		t.Fatalf("want 3 entries after the initial scan; got %d", n)
+	}
+	if e, ok := m.Stat(path("sub/a")); !ok || e.Dir || e.Size != 1 {
+		t.Fatalf("want file of size 1; got %+v (ok=%t)", e, ok)
+	}
+	changes := make(chan EventInfo, buffer)
+	m.OnChange(func(ei EventInfo) { changes <- ei })
+	must(os.Rename(path("sub/a"), path("sub/b")))
+	want := map[string]Event{path("sub/a"): Remove, path("sub/b"): Create}
+	got := make(map[string]Event)
+	for len(got) < len(want) {
+		select {
+		case ei := <-changes:
+			got[ei.Path()] |= ei.Event()
+		case <-time.After(timeout()):
+			t.Fatalf("timed out; got %v", got)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v; got %v", want, got)
+	}
+	list := m.List(path("sub"))
+	if len(list) != 1 || list[0].Path != path("sub/b") {
+		t.Fatalf("want %q listed; got %+v", path("sub/b"), list)
+	}
+	must(m.Close())
+	must(ioutil.WriteFile(path("sub/c"), nil, 0644))
+	select {
+	case ei := <-changes:
+		t.Fatalf("want no changes after Close; got %v", ei)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mute makes the events for the path, or for any path under it if it is
+// a directory, not delivered to c, until Unmute is called for it. Unlike Stop
+// or StopPath, Mute does not remove any watchpoint, so no events are missed
+// because of rewatching, e.g. when the application itself rewrites a watched
+// file many times:
+//
+//   if err := notify.Mute(c, "/path/to/file", true); err != nil {
+//       log.Fatal(err)
+//   }
+//   rewrite("/path/to/file")
+//   notify.Unmute(c, "/path/to/file")
+//
+// The events for a muted path are dropped. If resync is true, the changes
+// made while the path was muted are reported with a single event on Unmute:
+// Write if the path exists at that time, Remove otherwise.
+//
+// Mute fails with ErrNotWatched if c has no watchpoints. Muting a path which
+// is already muted changes its resync only.
+func Mute(c chan<- EventInfo, path string, resync bool) error {
+	return defaultTree.Mute(c, path, resync)
+}
+
+// Unmute makes the events for the path muted with Mute delivered to c again.
+// It is a nop if the path is not muted.
+func Unmute(c chan<- EventInfo, path string) {
+	defaultTree.Unmute(c, path)
+}
+
+// muted is the state of a single path muted with Mute.
+type muted struct {
+	resync bool
+	hit    bool // whether an event was dropped
+}
+
+// muter drops the events for the paths muted for a single user channel.
+type muter struct {
+	mu    sync.Mutex
+	paths map[string]*muted
+}
+
+// drop reports whether ei is for any of the muted paths, recording it was
+// dropped if it is.
+func (m *muter) drop(ei EventInfo) bool {
+	path := normalize(ei.Path())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, mt := range m.paths {
+		if path == key || strings.HasPrefix(path, key+sep) {
+			mt.hit = true
+			return true
+		}
+	}
+	return false
+}
+
+// Mute mutes the path for all the pipes of c.
+func (t *pipeTree) Mute(c chan<- EventInfo, path string, resync bool) error {
+	key := pathkey(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pipes[c]) == 0 {
+		return &WatchError{Op: "mute", Path: path, Err: ErrNotWatched}
+	}
+	m, ok := t.mutes[c]
+	if !ok {
+		m = &muter{paths: make(map[string]*muted)}
+		t.mutes[c] = m
+		for _, pipes := range t.pipes[c] {
+			for _, p := range pipes {
+				t.promote(p)
+				p.mu.Lock()
+				p.mute = m
+				p.mu.Unlock()
+			}
+		}
+	}
+	m.mu.Lock()
+	if mt, ok := m.paths[key]; ok {
+		mt.resync = resync
+	} else {
+		m.paths[key] = &muted{resync: resync}
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// Unmute unmutes the path for all the pipes of c, delivering the resync event
+// through the pipe watching the path.
+func (t *pipeTree) Unmute(c chan<- EventInfo, path string) {
+	key := pathkey(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m, ok := t.mutes[c]
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	mt, ok := m.paths[key]
+	delete(m.paths, key)
+	m.mu.Unlock()
+	if !ok || !mt.resync || !mt.hit {
+		return
+	}
+	ei := &synthetic{path: key, event: Write}
+	switch fi, err := os.Lstat(key); {
+	case err == nil:
+		ei.dir = fi.IsDir()
+	case os.IsNotExist(err):
+		ei.event = Remove
+	}
+	if p := t.covering(c, key); p != nil {
+		p.send(ei)
+	}
+}
+
+// covering gives the pipe of c, which watchpoint reports the events for
+// the path, nil if there is none. It expects t.mu to be held.
+func (t *pipeTree) covering(c chan<- EventInfo, path string) *pipe {
+	var rec *pipe
+	for key, pipes := range t.pipes[c] {
+		for _, p := range pipes {
+			switch {
+			case key == path || key == filepath.Dir(path):
+				return p
+			case p.rec && strings.HasPrefix(path, key+sep):
+				rec = p
+			}
+		}
+	}
+	return rec
+}
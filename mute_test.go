@@ -0,0 +1,49 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMute(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	muted, other := filepath.Join(dir, "muted"), filepath.Join(dir, "other")
+	must(ioutil.WriteFile(muted, nil, 0644))
+	c := make(chan EventInfo, buffer)
+	if err := tr.Mute(c, muted, true); err == nil {
+		t.Fatal("want err!=nil for a channel not watching")
+	}
+	must(tr.Watch(dir, c, Create|Write))
+	for _, resync := range []bool{true, false} {
+		must(tr.Mute(c, muted, resync))
+		for i := 0; i < 3; i++ {
+			must(ioutil.WriteFile(muted, []byte{byte(i)}, 0644))
+		}
+		// The events for other paths are still delivered. The events are not
+		// guaranteed to be dispatched in order, so the ones for the muted path
+		// are given some more time to get dispatched.
+		must(ioutil.WriteFile(other, nil, 0644))
+		tr.Expect(c, &Call{P: other, E: Create})
+		time.Sleep(50 * time.Millisecond)
+		tr.Unmute(c, muted)
+		if resync {
+			tr.Expect(c, &Call{P: muted, E: Write})
+		}
+		select {
+		case ei := <-c:
+			t.Fatalf("want no event; got %v (resync=%t)", ei, resync)
+		case <-time.After(50 * time.Millisecond):
+		}
+		must(ioutil.WriteFile(muted, nil, 0644))
+		tr.Expect(c, &Call{P: muted, E: Write})
+		must(os.Remove(other))
+	}
+}
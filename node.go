@@ -61,6 +61,7 @@ func (nd node) Add(name string) node {
 }
 
 func (nd node) AddDir(fn walkFunc) error {
+	top := nd.Name
 	stack := []node{nd}
 Traverse:
 	for n := len(stack); n != 0; n = len(stack) {
@@ -76,10 +77,24 @@ Traverse:
 				Err:  err,
 			}
 		}
-		// TODO(rjeczalik): tolerate open failures - add failed names to
-		// AddDirError and notify users which names are not added to the tree.
-		fi, err := ioutil.ReadDir(nd.Name)
+		var fi []os.FileInfo
+		var err error
+		if nd.Name == top {
+			fi, err = ioutil.ReadDir(nd.Name)
+		} else {
+			fi, err = readSubdir(nd.Name)
+		}
 		if err != nil {
+			// A subdirectory, which cannot be read, is skipped, so that it
+			// does not fail watching the rest of the tree. The failure is
+			// reported to the OnError hook, unless the subdirectory was
+			// removed in the meantime.
+			if nd.Name != top {
+				if !vanished(err) {
+					report(nd.Name, err, nil)
+				}
+				continue
+			}
 			return err
 		}
 		for _, fi := range fi {
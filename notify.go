@@ -19,7 +19,13 @@
 
 package notify
 
-var defaultTree = newTree()
+import (
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+var defaultTree = newPipeTree(newTree())
 
 // Watch sets up a watchpoint on path listening for events given by the events
 // argument.
@@ -60,15 +66,315 @@ var defaultTree = newTree()
 // mind this limitation while setting recursive watchpoints for your application,
 // e.g. use persistent paths like %userprofile% or watch additionally parent
 // directory of a recursive watchpoint in order to receive delete events for it.
+//
+// Linux and bind mounts
+//
+// Inotify keeps watches per inode, so watching the same directory via several
+// bind mounts (or the same file via several hard links) shares a single watch.
+// Notify reports each event under every path that was used to set a watchpoint,
+// so EventInfo.Path() is always rooted at the path passed to Watch. Other
+// watchers report paths as given by the OS.
+//
+// Nested directory creation
+//
+// Under watchers which do not watch directory trees natively, e.g. inotify
+// or kqueue, recursive watchpoints watch every new directory on their own.
+// Directories and files created within a new one before it was watched, e.g.
+// by mkdir -p a/b/c or tar -x, are reported with Create events generated by
+// notify, which are delivered after the Create of the new directory, in
+// parent-before-child order: a, a/b, a/b/c. Consumers building a directory
+// tree incrementally, e.g. to mirror it, do not need to reorder them, nor to
+// list the new directories on their own. Since the watches are set up concurrently with
+// the changes, such a Create may occasionally be reported twice. Watchers
+// which watch directory trees natively, e.g. FSEvents or ReadDirectoryChangesW,
+// report the events in the order given by the OS.
+//
+// Unreadable directories
+//
+// Under the same watchers, the subdirectories of a recursive watchpoint, which
+// cannot be read for lack of permissions, e.g. the home directories of other
+// users under /home, are skipped together with the whole subtrees, so that
+// the rest of the tree is watched anyway. Each skipped directory is reported
+// to the OnError hook, see SetHooks. The same goes for the subdirectories,
+// which fail to be listed or watched with a transient error, e.g. for running
+// out of file descriptors, after a few retries made within tens of
+// milliseconds. The subdirectories removed while the tree is being watched
+// are skipped silently.
 func Watch(path string, c chan<- EventInfo, events ...Event) error {
 	return defaultTree.Watch(path, c, events...)
 }
 
+// WatchReady works like Watch, but it additionally gives a channel, which is
+// closed once the watchpoint is armed, i.e. every change made from then on
+// is going to be reported. It allows for synchronizing with the watchpoint
+// instead of waiting an arbitrary amount of time for it to be set up.
+//
+// For most of the watchers the channel is closed right away, as they are
+// armed once the watch is set up, e.g. after inotify_add_watch(2) succeeds.
+// FSEvents first replays historical events for a newly created stream, so
+// the channel is closed once it reports it was done with them. The channel
+// is closed as well if the watchpoint is removed, e.g. with Stop, before it
+// was armed.
+func WatchReady(path string, c chan<- EventInfo, events ...Event) (<-chan struct{}, error) {
+	return defaultTree.WatchReady(path, c, events...)
+}
+
+// WatchAndRead works like Watch, but once the watchpoint is armed, like with
+// WatchReady, it reads the file given by the path and gives back its contents.
+// Since the file is read only after the watchpoint is armed, every change made
+// to the file after the contents were read is reported, e.g. for loading
+// a configuration file without missing the changes made while loading it:
+//
+//   c := make(chan notify.EventInfo, 1)
+//   p, err := notify.WatchAndRead("/etc/app.conf", c, notify.Write)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   load(p)
+//   for range c {
+//       // reload
+//   }
+//
+// A change made while the file is being read may be reported even though
+// the contents returned already reflect it. If reading the file fails, all
+// the watchpoints of c for the path are removed, like with StopPath. To keep
+// watching a file, which is replaced atomically on save, use WatchConfig for
+// the watchpoint instead.
+func WatchAndRead(path string, c chan<- EventInfo, events ...Event) ([]byte, error) {
+	ready, err := WatchReady(path, c, events...)
+	if err != nil {
+		return nil, err
+	}
+	<-ready
+	p, err := ioutil.ReadFile(path)
+	if err != nil {
+		defaultTree.StopPath(c, path)
+		return nil, err
+	}
+	return p, nil
+}
+
+// WatchExact works like Watch, but it delivers events only for the directory
+// given by the path and for the entries it contains at the time WatchExact is
+// called. Events for the other paths, e.g. the ones a recursive watcher like
+// FSEvents coalesces into events for the directory tree, are dropped. New
+// direct children reported with Create event are included and the removed or
+// renamed ones are excluded from then on.
+//
+// WatchExact does not support recursive paths. Use Stop to remove watchpoints
+// set up with WatchExact.
+func WatchExact(path string, c chan<- EventInfo, events ...Event) error {
+	dir, isrec, err := cleanpath(path)
+	if err != nil {
+		return err
+	}
+	if isrec {
+		return errExactRecursive
+	}
+	s, err := exact(dir)
+	if err != nil {
+		return err
+	}
+	_, err = defaultTree.WatchPipe(dir, c, []stage{s}, events...)
+	return err
+}
+
+// WatchDirPulse watches the directory given by the path and delivers at most
+// one event per interval whenever anything within the directory changed.
+// The event is reported for the directory itself, its value is the union of
+// the values of all the events it stands for, e.g. Create|Write. It is meant
+// for consumers like UIs, which only need to know that the directory needs
+// refreshing. Recursive paths are supported, e.g. "./dir/...", then the changes
+// in any of the subdirectories are reported as well.
+//
+// The first change starts the interval, the event is delivered once the interval
+// elapses, so it accounts for all the changes made in the meantime.
+//
+// Use Stop to remove watchpoints set up with WatchDirPulse. The changes made
+// within the interval, which is still running, are delivered before
+// the watchpoint is removed.
+func WatchDirPulse(path string, c chan<- EventInfo, interval time.Duration) error {
+	dir, _, err := cleanpath(path)
+	if err != nil {
+		return err
+	}
+	s, flush := pulse(dir, interval)
+	p, err := defaultTree.WatchPipe(path, c, []stage{s}, All)
+	if err != nil {
+		return err
+	}
+	defaultTree.OnStop(p, flush)
+	return nil
+}
+
+// WatchUntil watches the path for the given events and calls fn for each of
+// them, one at a time, until fn returns true. Then the watchpoint is removed
+// and WatchUntil returns, no further calls to fn are made. It blocks until
+// then, or fails right away if the watchpoint could not be set up. Calling it
+// with no events fails with a *WatchError wrapping ErrInvalidEventSet. If the
+// watchpoint is removed by Reset or the path itself is removed before fn
+// returns true, WatchUntil fails with a *WatchError wrapping ErrNotWatched.
+//
+// E.g. the following waits until a file named done.txt appears in ./out:
+//
+//   notify.WatchUntil("./out", func(ei notify.EventInfo) bool {
+//           return filepath.Base(ei.Path()) == "done.txt"
+//   }, notify.Create)
+func WatchUntil(path string, fn func(EventInfo) bool, events ...Event) error {
+	return defaultTree.WatchUntil(path, fn, events...)
+}
+
+// WatchWithOptions works like Watch, but the watchpoint is additionally
+// configured with the given options. Unlike Watch, it takes the events as
+// a single value, e.g. Create|Remove.
+//
+// Use Stop to remove watchpoints set up with WatchWithOptions.
+func WatchWithOptions(path string, c chan<- EventInfo, events Event, opts ...Option) error {
+	return defaultTree.WatchWithOptions(path, c, events, opts...)
+}
+
+// WatchReplace replaces the watchpoints c has for oldPath with a new one
+// on newPath listening for the given events. The new watchpoint is set up
+// before the old ones are removed, so there is no window during which changes
+// under newPath could be missed. For the same reason c may receive
+// duplicated events for the paths both of the watchpoints cover.
+//
+// If setting up the new watchpoint fails, the old ones are left intact.
+// WatchReplace fails with ErrNotWatched if c has no watchpoints for oldPath
+// and with ErrInvalidEventSet if no events are given.
+func WatchReplace(c chan<- EventInfo, oldPath, newPath string, events ...Event) error {
+	return defaultTree.Replace(c, oldPath, newPath, events...)
+}
+
+// SetRecursive turns the watchpoints set up for the path into recursive ones
+// or back into non-recursive ones, regardless of the channel they deliver
+// events to. The event sets of the watchpoints are left unchanged. For the
+// watchers which emulate recursive watches, like inotify, the watches for
+// the descendant directories are added or removed accordingly.
+//
+// Demoting a watchpoint is done by replacing it with a new, non-recursive one,
+// events reported while the replacement takes place may be missed.
+//
+// SetRecursive fails with ErrNotWatched if there are no watchpoints for the
+// path.
+func SetRecursive(path string, recursive bool) error {
+	return defaultTree.SetRecursive(path, recursive)
+}
+
+// RewatchAll replaces the event sets of all the watchpoints of c with
+// the given events, all under a single lock, e.g. to listen for Create only
+// while scanning and for all the events afterwards. The underlying watches are
+// modified in place, so the events reported for the paths in the meantime are
+// not missed. Calling RewatchAll with no events is a nop.
+//
+// RewatchAll applies as much of the update as it can. It fails with
+// a ReconcileError listing the paths, which could not be rewatched; such paths
+// keep their previous event sets. The events watched internally by options,
+// e.g. Create for the watchpoints set up with WithRetry, are not preserved, nor
+// are the hard links watched with WithHardLinks.
+func RewatchAll(c chan<- EventInfo, events ...Event) error {
+	return defaultTree.RewatchAll(c, joinevents(events))
+}
+
+// RewatchBatch changes the event sets of the watchpoints of c for many paths
+// at once, all or nothing. Each of the changes maps a path, given like for
+// Watch, to the pair of the event set the watchpoint is expected to have and
+// the one to replace it with:
+//
+//   err := notify.RewatchBatch(c, map[string][2]notify.Event{
+//       "/path/to/dir":       {notify.Create, notify.All},
+//       "/path/to/other/...": {notify.Write, notify.Write | notify.Remove},
+//   })
+//
+// The changes are applied under a single lock. RewatchBatch fails without
+// changing anything if any of the paths is not watched by c, with a *WatchError
+// wrapping ErrNotWatched, or if its watchpoint has other events than expected,
+// with a *WatchError wrapping ErrEventsChanged. An empty event set to replace
+// it with fails with ErrInvalidEventSet. If the underlying watcher fails
+// to apply any of the changes, the ones already applied are reverted and
+// the error is returned. Like with RewatchAll, the events watched internally
+// by options are not preserved.
+func RewatchBatch(c chan<- EventInfo, changes map[string][2]Event) error {
+	return defaultTree.RewatchBatch(c, changes)
+}
+
+// Snapshot gives the current listing of the watched directory given by
+// the path, sorted by name. It is meant for reconciling the state of the
+// directory, e.g. after some of the events were dropped: the listing reflects
+// at least all the changes reported before Snapshot was called, the changes
+// made afterwards are going to be reported as events.
+//
+// Snapshot fails with ErrNotWatched if there are no watchpoints for the path.
+// Recursive paths are not listed recursively, e.g. "./dir/..." lists ./dir
+// only.
+func Snapshot(path string) ([]os.FileInfo, error) {
+	return defaultTree.Snapshot(path)
+}
+
+// Events gives the events the path is currently watched for, which is the union
+// of the event sets of all the watchpoints registered for it by any channel,
+// and whether it is watched at all. Like with Snapshot, recursive paths are
+// looked up by their directory, e.g. "./dir/..." and "./dir" give the same
+// result. The events may include ones watched internally by notify, e.g.
+// Create for watchpoints set up with WithRetry.
+func Events(path string) (Event, bool) {
+	return defaultTree.Events(path)
+}
+
+// LastEvent gives the time the latest event was delivered for the watched path
+// by any of its watchpoints, and whether it is watched at all. It is meant for
+// detecting watches, which are silent for suspiciously long, e.g. since the
+// underlying watcher stopped reporting some of the paths. If no event was
+// delivered yet, the time the latest of the watchpoints was set up is given
+// instead. The events dropped by the stages of the watchpoints, e.g. ones
+// filtered out with options, are not taken into account, while the ones
+// dropped since the receiver was too slow are. Like with Events, recursive
+// paths are looked up by their directory.
+func LastEvent(path string) (time.Time, bool) {
+	return defaultTree.LastEvent(path)
+}
+
 // Stop removes all watchpoints registered for c. All underlying watches are
 // also removed, for which c was the last channel listening for events.
 //
-// Stop does not close c. When Stop returns, it is guaranteed that c will
-// receive no more signals.
+// When c was passed to Watch for several paths, Stop removes the watchpoints
+// for all of them. Use StopPath to remove the watchpoint for one of the paths
+// only.
+//
+// Stop does not close c. Once Stop is called, no more events are delivered
+// to c: the events which are still being dispatched are dropped, and so are
+// the ones caused by removing the watches, e.g. IN_IGNORED reported by inotify.
+// Watchpoints set up with DeliverPending, see WithPendingPolicy, deliver
+// the events which are still being dispatched first.
+// The watches are removed in a defined order, leaf-first - a watch on
+// a directory is removed only after the watches on the paths within it.
+// Stop waits for the dispatching to end, so it is safe to close c right after
+// Stop returns, provided no other goroutine sets up watchpoints for c
+// concurrently.
 func Stop(c chan<- EventInfo) {
 	defaultTree.Stop(c)
 }
+
+// StopPath removes the watchpoint registered for c on the given path, leaving
+// the watchpoints of c for other paths in place, unlike Stop, which removes all
+// of them. Recursive paths are given like for Watch, e.g. "./dir/...". The
+// underlying watch is also removed, if c was the last channel listening for
+// events on the path.
+//
+// StopPath fails with ErrNotWatched if c has no watchpoint for the path.
+// Like with Stop, no more events for the path are delivered to c once StopPath
+// is called.
+func StopPath(c chan<- EventInfo, path string) error {
+	return defaultTree.StopPath(c, path)
+}
+
+// Reset removes all watchpoints registered for every channel, as if Stop was
+// called for each of them, all under a single lock. The underlying watcher is
+// not closed, so new watchpoints can be set up right after Reset returns.
+//
+// Reset does not close any of the channels. Like with Stop, no more events
+// are delivered to any of them once Reset is called, the watches are removed
+// leaf-first and it is safe to close the channels after Reset returns.
+func Reset() {
+	defaultTree.Reset()
+}
@@ -6,7 +6,16 @@
 
 package notify
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestNotifySystemAndGlobalMix(t *testing.T) {
 	n := NewNotifyTest(t, "testdata/vfs.txt")
@@ -35,3 +44,313 @@ func TestUnknownEvent(t *testing.T) {
 
 	n.WatchErr("src/github.com/rjeczalik/fs", ch[0], nil, inExclUnlink)
 }
+
+func TestNotifyHardLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_hardlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	must(os.Mkdir(a, 0755))
+	must(os.Mkdir(b, 0755))
+	must(ioutil.WriteFile(filepath.Join(a, "file"), nil, 0644))
+	must(os.Link(filepath.Join(a, "file"), filepath.Join(b, "link")))
+	c := make(chan EventInfo, buffer)
+	must(WatchWithOptions(a, c, Write, WithHardLinks()))
+	defer Stop(c)
+	f, err := os.OpenFile(filepath.Join(b, "link"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteString("XD")
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ei := <-c:
+		if err := EqualEventInfo(&Call{P: filepath.Join(a, "file"), E: Write}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out waiting for Write event")
+	}
+}
+
+func TestNotifyRetryRearm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_rearm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	must(os.Mkdir(sub, 0755))
+	c := make(chan EventInfo, buffer)
+	must(WatchWithOptions(sub, c, All, WithRetry(5, 20*time.Millisecond)))
+	defer Stop(c)
+	must(os.Remove(sub))
+	must(os.Mkdir(sub, 0755))
+	file := filepath.Join(sub, "file")
+	deadline := time.After(timeout())
+	for {
+		must(ioutil.WriteFile(file, nil, 0644))
+		select {
+		case ei := <-c:
+			if ei.Path() == file && ei.Event() == Create {
+				return
+			}
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("timed out waiting for the watchpoint to be rearmed")
+		}
+		must(os.Remove(file))
+	}
+}
+
+func TestNotifyNestedCreate(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo, buffer)
+	must(tr.Watch(filepath.Join(dir, "..."), c, Create))
+	want := []string{
+		filepath.Join(dir, "a"),
+		filepath.Join(dir, "a", "b"),
+		filepath.Join(dir, "a", "b", "c"),
+		filepath.Join(dir, "a", "b", "c", "d"),
+	}
+	must(os.MkdirAll(want[len(want)-1], 0755))
+	seen := make(map[string]int)
+	deadline := time.After(timeout())
+	for len(seen) != len(want) {
+		select {
+		case ei := <-c:
+			if _, ok := seen[ei.Path()]; !ok {
+				seen[ei.Path()] = len(seen)
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for Create events; got %v", seen)
+		}
+	}
+	for i, path := range want {
+		if n, ok := seen[path]; !ok || n != i {
+			t.Errorf("want %q to be reported as %d; got %d (seen=%v)", path, i, n, seen)
+		}
+	}
+}
+
+func TestNotifyNewTreeContents(t *testing.T) {
+	tmp, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	dir, src := filepath.Join(tmp, "dir"), filepath.Join(tmp, "src")
+	must(os.Mkdir(dir, 0755))
+	must(os.MkdirAll(filepath.Join(src, "b"), 0755))
+	must(ioutil.WriteFile(filepath.Join(src, "f"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(src, "b", "g"), nil, 0644))
+	c := make(chan EventInfo, buffer)
+	must(tr.Watch(filepath.Join(dir, "..."), c, Create))
+	// The tree is moved into place, so its contents exist before any of its
+	// directories is watched.
+	must(os.Rename(src, filepath.Join(dir, "a")))
+	want := map[string]bool{
+		filepath.Join(dir, "a"):           true,
+		filepath.Join(dir, "a", "f"):      false,
+		filepath.Join(dir, "a", "b"):      true,
+		filepath.Join(dir, "a", "b", "g"): false,
+	}
+	seen := make(map[string]int)
+	deadline := time.After(timeout())
+	for len(seen) != len(want) {
+		select {
+		case ei := <-c:
+			if _, ok := want[ei.Path()]; !ok {
+				t.Fatalf("unexpected event: %v", ei)
+			}
+			if _, ok := seen[ei.Path()]; !ok {
+				seen[ei.Path()] = len(seen)
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for Create events; got %v", seen)
+		}
+	}
+	for path := range want {
+		dir := filepath.Dir(path)
+		if n, ok := seen[dir]; ok && n > seen[path] {
+			t.Errorf("want %q to be reported before %q (seen=%v)", dir, path, seen)
+		}
+	}
+}
+
+func TestWatchSinceUnsupported(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo, 1)
+	err := tr.WatchSince(dir, c, 1, Create)
+	if we, ok := err.(*WatchError); !ok || we.Err != ErrUnsupported {
+		t.Fatalf("want err=ErrUnsupported; got %v", err)
+	}
+	if _, ok := tr.Events(dir); ok {
+		t.Fatalf("want %q not to be watched", dir)
+	}
+}
+
+func TestNotifyFollowMoves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_follow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	blue, green := filepath.Join(dir, "blue"), filepath.Join(dir, "green")
+	must(os.Mkdir(blue, 0755))
+	c := make(chan EventInfo, buffer)
+	must(WatchWithOptions(blue, c, All, WithFollowMoves()))
+	defer Stop(c)
+	must(os.Rename(blue, green))
+	deadline := time.After(timeout())
+	for moved := false; !moved; {
+		select {
+		case ei := <-c:
+			if rs, ok := ei.(RenameSelfEventInfo); ok {
+				if rs.Path() != blue || rs.NewPath() != green || rs.Event() != Rename {
+					t.Fatalf("want rename self %q -> %q; got %v", blue, green, rs)
+				}
+				moved = true
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for the rename of the root")
+		}
+	}
+	file := filepath.Join(green, "file")
+	for {
+		must(ioutil.WriteFile(file, nil, 0644))
+		select {
+		case ei := <-c:
+			if ei.Path() == file && ei.Event() == Create {
+				return
+			}
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("timed out waiting for the events under the new path")
+		}
+		must(os.Remove(file))
+	}
+}
+
+func TestNotifyQuiesce(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo, buffer)
+	must(tr.Watch(dir, c, Create))
+	var mu sync.Mutex
+	var n int
+	go func() {
+		for range c {
+			mu.Lock()
+			n++
+			mu.Unlock()
+		}
+	}()
+	const files = 20
+	for i := 0; i < files; i++ {
+		must(ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d", i)), nil, 0644))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout())
+	defer cancel()
+	if err := tr.Quiesce(ctx, c); err != nil {
+		t.Fatalf("Quiesce()=%v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if n != files {
+		t.Fatalf("want %d events delivered; got %d", files, n)
+	}
+}
+
+func TestWatchFD(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	f, err := os.Create(filepath.Join(dir, "old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	c := make(chan EventInfo, buffer)
+	must(tr.WatchFD(f.Fd(), c, Write))
+	defer tr.Stop(c)
+	for i, name := range []string{"old", "new"} {
+		path := filepath.Join(dir, name)
+		if name != "old" {
+			must(os.Rename(filepath.Join(dir, "old"), path))
+		}
+		if _, err := f.Write([]byte("data")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&Call{P: path, E: Write}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out waiting for Write (i=%d)", i)
+		}
+	}
+}
+
+func TestNotifySkipUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	for _, name := range []string{"a/locked", "b/c"} {
+		must(os.MkdirAll(filepath.Join(dir, filepath.FromSlash(name)), 0755))
+	}
+	locked := filepath.Join(dir, "a", "locked")
+	must(os.Chmod(locked, 0))
+	defer os.Chmod(locked, 0755)
+	var mu sync.Mutex
+	var failed []string
+	SetHooks(Hooks{OnError: func(path string, _ error) {
+		mu.Lock()
+		failed = append(failed, path)
+		mu.Unlock()
+	}})
+	defer SetHooks(Hooks{})
+	c := make(chan EventInfo, buffer)
+	if err := tr.Watch(filepath.Join(dir, "..."), c, Create); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	mu.Lock()
+	if len(failed) == 0 || failed[len(failed)-1] != locked {
+		t.Errorf("want %q reported to OnError; got %v", locked, failed)
+	}
+	mu.Unlock()
+	path := filepath.Join(dir, "b", "c", "file")
+	must(ioutil.WriteFile(path, nil, 0644))
+	tr.Expect(c, &Call{P: path, E: Create})
+}
+
+func TestNotifyBackpressureBurst(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo)
+	must(tr.WatchWithOptions(dir, c, Create, WithBackpressure()))
+	// The consumer is stalled while the files are created, so most of their
+	// events are read from the kernel before the pipe blocks.
+	const n = 8 * buffer
+	for i := 0; i < n; i++ {
+		must(ioutil.WriteFile(filepath.Join(dir, fmt.Sprint(i)), nil, 0644))
+	}
+	seen := make(map[string]bool)
+	for len(seen) != n {
+		select {
+		case ei := <-c:
+			seen[filepath.Base(ei.Path())] = true
+		case <-time.After(timeout()):
+			t.Fatalf("want %d events; got %d", n, len(seen))
+		}
+	}
+}
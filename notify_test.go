@@ -6,7 +6,13 @@
 
 package notify
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
 
 func TestNotifyExample(t *testing.T) {
 	n := NewNotifyTest(t, "testdata/vfs.txt")
@@ -98,6 +104,143 @@ func TestNotifyExample(t *testing.T) {
 	n.ExpectNotifyEvents(cases, ch)
 }
 
+func TestNotifyStructure(t *testing.T) {
+	n := NewNotifyTest(t, "testdata/vfs.txt")
+	defer n.Close()
+
+	ch := NewChans(1)
+
+	n.Watch("src/github.com/rjeczalik/fs", ch[0], Structure)
+
+	cases := []NCase{
+		// i=0
+		{
+			Event:    write(n.W(), "src/github.com/rjeczalik/fs/fs.go", []byte("XD")),
+			Receiver: nil,
+		},
+		// i=1
+		{
+			Event:    create(n.W(), "src/github.com/rjeczalik/fs/fs_test.go"),
+			Receiver: Chans{ch[0]},
+		},
+		// i=2
+		{
+			Event:    write(n.W(), "src/github.com/rjeczalik/fs/fs_test.go", []byte("XD")),
+			Receiver: nil,
+		},
+		// i=3
+		{
+			Event:    remove(n.W(), "src/github.com/rjeczalik/fs/fs_test.go"),
+			Receiver: Chans{ch[0]},
+		},
+	}
+
+	n.ExpectNotifyEvents(cases, ch)
+}
+
 func TestStop(t *testing.T) {
 	t.Skip("TODO(rjeczalik)")
 }
+
+func TestStopClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_stop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		file := filepath.Join(dir, "file")
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			ioutil.WriteFile(file, []byte("XD"), 0644)
+			os.Remove(file)
+		}
+	}()
+	// Closing the channel right after Stop must not panic with send on closed
+	// channel, even if events are still being dispatched.
+	for i := 0; i < 20; i++ {
+		c := make(chan EventInfo, 1)
+		if err := WatchWithOptions(dir, c, All, WithDedup(time.Millisecond)); err != nil {
+			t.Fatalf("WatchWithOptions()=%v (i=%d)", err, i)
+		}
+		if err := Watch(dir, c, All); err != nil {
+			t.Fatalf("Watch()=%v (i=%d)", err, i)
+		}
+		time.Sleep(10 * time.Millisecond)
+		Stop(c)
+		close(c)
+	}
+}
+
+func TestWatchAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	must(ioutil.WriteFile(file, []byte("v1"), 0644))
+	c := make(chan EventInfo, 16)
+	p, err := WatchAndRead(file, c, Write)
+	if err != nil {
+		t.Fatalf("WatchAndRead()=%v", err)
+	}
+	defer Stop(c)
+	if string(p) != "v1" {
+		t.Fatalf("want contents=%q; got %q", "v1", p)
+	}
+	// No retries, the very first change after the read must be reported.
+	must(ioutil.WriteFile(file, []byte("v2"), 0644))
+	select {
+	case ei := <-c:
+		if err := EqualEventInfo(&Call{P: file, E: Write}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	if _, err := WatchAndRead(filepath.Join(dir, "missing"), c, Write); err == nil {
+		t.Error("want err!=nil for a missing file")
+	}
+}
+
+func TestWatchReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	c := make(chan EventInfo, 16)
+	ready, err := WatchReady(dir, c, Create)
+	if err != nil {
+		t.Fatalf("WatchReady()=%v", err)
+	}
+	defer Stop(c)
+	select {
+	case <-ready:
+	case <-time.After(timeout()):
+		t.Fatal("timed out waiting for the watchpoint to be armed")
+	}
+	// No retries, once armed the very first change must be reported.
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ei := <-c:
+		if ei.Path() != file {
+			t.Fatalf("want event for %q; got %v", file, ei)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// OnRemove gives a channel, which is closed once the file or directory given
+// by the path is removed or renamed away, or once ctx is done, e.g. to run
+// a cleanup when a lock file disappears:
+//
+//   go func() {
+//       <-notify.OnRemove(ctx, "/var/run/app.lock")
+//       cleanup()
+//   }()
+//
+// The channel is closed right away if the path does not exist. OnRemove
+// watches the parent directory of the path and removes the watchpoint once
+// the channel is closed. If the parent directory cannot be watched, the
+// channel is closed only once ctx is done.
+func OnRemove(ctx context.Context, path string) <-chan struct{} {
+	return defaultTree.OnRemove(ctx, path)
+}
+
+// OnRemove watches the parent directory of the path for the events, which
+// may have removed the path, checking whether it still exists on each of them.
+func (t *pipeTree) OnRemove(ctx context.Context, path string) <-chan struct{} {
+	done := make(chan struct{})
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	gone := func() bool {
+		_, err := os.Lstat(path)
+		return os.IsNotExist(err)
+	}
+	c := make(chan EventInfo, buffer)
+	watched := t.Watch(filepath.Dir(path), c, Remove|Rename) == nil
+	go func() {
+		defer close(done)
+		if watched {
+			defer t.Stop(c)
+		}
+		// The path may have been removed before the watchpoint was set up.
+		if gone() {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ei := <-c:
+				if filepath.Clean(ei.Path()) == path && gone() {
+					return
+				}
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOnRemove(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	file := filepath.Join(dir, "file")
+	cases := [...]struct {
+		setup  func()
+		remove func()
+		closed bool
+	}{
+		// i=0
+		{
+			func() {},
+			func() {},
+			true,
+		},
+		// i=1
+		{
+			func() { must(ioutil.WriteFile(file, nil, 0644)) },
+			func() { must(os.Remove(file)) },
+			true,
+		},
+		// i=2
+		{
+			func() { must(ioutil.WriteFile(file, nil, 0644)) },
+			func() { must(os.Rename(file, filepath.Join(dir, "moved"))) },
+			true,
+		},
+		// i=3
+		{
+			func() { must(ioutil.WriteFile(file, nil, 0644)) },
+			func() { must(ioutil.WriteFile(filepath.Join(dir, "other"), nil, 0644)) },
+			false,
+		},
+	}
+	for i, cas := range cases {
+		cas.setup()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := tr.OnRemove(ctx, file)
+		cas.remove()
+		wait := timeout()
+		if !cas.closed {
+			wait = 100 * time.Millisecond
+		}
+		select {
+		case <-done:
+			if !cas.closed {
+				t.Fatalf("want channel open (i=%d)", i)
+			}
+		case <-time.After(wait):
+			if cas.closed {
+				t.Fatalf("timed out (i=%d)", i)
+			}
+		}
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(timeout()):
+			t.Fatalf("want channel closed once ctx is done (i=%d)", i)
+		}
+	}
+}
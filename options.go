@@ -0,0 +1,739 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"hash"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Option configures a watchpoint set up with WatchWithOptions.
+type Option func(*options)
+
+type options struct {
+	dedup  time.Duration
+	leaves bool
+	grace  time.Duration
+	hard   bool
+	links  []string      // hard-linked files to watch additionally
+	arm    []func(*pipe) // called once the watchpoint is set up
+	flush  []func()      // called before the watchpoint is removed
+
+	attempts int
+	backoff  time.Duration
+	priority int
+	root     bool
+	idle     time.Duration
+	stat     bool
+	nodot    bool
+	ignore   string
+	journal  int
+	noown    bool
+	sizes    bool
+	collapse time.Duration
+	symlinks bool
+	nosys    bool
+	flags    watchFlags
+
+	backpressure bool
+	progress     func(path string, registered, total int)
+	follow       bool
+	maxNodes     int
+	transform    func(EventInfo) EventInfo
+	nooverlap    bool
+	linger       time.Duration
+	key          keyFunc
+	fold         bool
+	order        bool
+	discard      bool
+	serialize    bool
+	heartbeat    time.Duration
+	pair         bool
+	alias        bool
+	expansion    int
+	hash         func() hash.Hash
+	hashSize     int64
+	overflow     time.Duration
+	overflowMax  time.Duration
+	inode        bool
+	rules        []Rule
+	regexp       string // source of the regexp given to WithRegexp
+	history      bool
+	budget       int64
+	state        bool
+	pending      PendingPolicy
+	scanBatch    time.Duration
+	editorWindow time.Duration
+	editors      []EditorProfile
+	maxChildren  int
+	style        PathStyle
+	minAge       time.Duration
+	maxAge       time.Duration
+	confirm      bool
+}
+
+// WithDedup makes notify drop an event, if an event with the same path and
+// the same value was delivered within the last window. It is meant for the
+// cases when the underlying watcher reports the very same event several times
+// in quick succession, e.g. multiple Write events for a single save; unlike
+// debouncing, events differing in value are never merged.
+//
+// Notify remembers a limited number of recently seen events, forgetting the
+// least recently seen ones first, so repeats may be delivered if events for
+// many distinct paths are reported within the window.
+func WithDedup(window time.Duration) Option {
+	return func(o *options) {
+		o.dedup = window
+	}
+}
+
+// WithCreateCollapse makes notify deliver a single Create event for a new
+// file, which is written to right after it was created, e.g. when a file is
+// dropped into a directory. The Create is held back for window and the Write
+// events reported for the file in the meantime are dropped, so consumers
+// process the file once, after it was written. If the file is removed or
+// renamed within the window, the Create is dropped as well and only the Remove
+// or Rename is delivered. Any other event for the file delivers the held back
+// Create right away, followed by the event.
+//
+// Write events reported after the window elapsed are delivered as usual, so
+// the window should cover the time it takes to write a new file. Create events
+// of directories are not held back. The Create events still held back, when
+// the watchpoint is removed, are delivered before it is; see WithDiscardOnStop.
+func WithCreateCollapse(window time.Duration) Option {
+	return func(o *options) {
+		o.collapse = window
+	}
+}
+
+// WithCoalesceKey makes WithDedup, WithCreateCollapse and WithSerialize group
+// the events by the key fn gives for them instead of by their paths, e.g. by
+// the logical name of a file ignoring its version suffix, or by its parent
+// directory:
+//
+//   notify.WithCoalesceKey(func(ei notify.EventInfo) string {
+//           return filepath.Dir(ei.Path())
+//   })
+//
+// The events delivered are the original ones, the key is used only to tell
+// which of them stand for the same entity.
+func WithCoalesceKey(fn func(EventInfo) string) Option {
+	return func(o *options) {
+		o.key = fn
+	}
+}
+
+// WithCaseFolding makes WithDedup, WithCreateCollapse and WithSerialize group
+// the events by their keys regardless of case, e.g. for case-insensitive
+// filesystems like the default ones under macOS and Windows, where
+// "File.TXT" and "file.txt" name the same file. It applies to the keys given
+// by the function passed to WithCoalesceKey as well. The paths are always
+// normalized with the function registered with SetPathNormalizer before they
+// are grouped.
+func WithCaseFolding() Option {
+	return func(o *options) {
+		o.fold = true
+	}
+}
+
+// keyFunc gives the function the events are grouped by.
+func (o *options) keyFunc() keyFunc {
+	key := o.key
+	if key == nil {
+		key = pathKey
+	}
+	if o.fold {
+		key = folded(key)
+	}
+	return key
+}
+
+// WithLeafEvents makes notify find out which files and directories changed,
+// whenever the underlying watcher reports an event for a directory only.
+//
+// Some watchers, e.g. kqueue, report a change deep in the directory tree as
+// a Write to one of its ancestors. With the option in effect, notify keeps
+// a snapshot of the watched tree, and on every such event it walks the reported
+// directory and compares it against the snapshot, delivering Create, Remove and
+// Write events for each changed entry in addition to the original one.
+//
+// Walking the tree is expensive, as is keeping the snapshot for large trees.
+// Since the underlying watcher may report the same changes by itself, the
+// option may cause some events to be delivered twice.
+func WithLeafEvents() Option {
+	return func(o *options) {
+		o.leaves = true
+	}
+}
+
+// WithStartupGrace makes notify drop all events, which are reported during
+// the first d after the watchpoint was set up. It allows for ignoring spurious
+// events, e.g. the ones caused by watched directory settling down, so that
+// only changes made from now on are delivered.
+//
+// FSEvents may additionally replay historical events when a stream is created,
+// those are always dropped by notify until the stream reports the
+// FSEventsHistoryDone marker, regardless of the option, unless the replay was
+// asked for with WatchSince.
+func WithStartupGrace(d time.Duration) Option {
+	return func(o *options) {
+		o.grace = d
+	}
+}
+
+// WithHardLinks makes notify watch additionally every file with more than
+// one hard link found under the path at the time the watchpoint is set up,
+// so that its modifications are reported even if they are made using a name
+// outside of the watched directory. Such events are reported under the name
+// from the watched directory.
+//
+// The support is best-effort: it works for watchers which watch files by their
+// inodes, like inotify, and for hard links existing at the time the watchpoint
+// is set up only. Since the same modification may be reported both for the file
+// and for its directory, events for the same path and with the same value are
+// deduplicated within a short window, like with WithDedup.
+func WithHardLinks() Option {
+	return func(o *options) {
+		o.hard = true
+	}
+}
+
+// WithRetry makes notify retry setting up the watchpoint, if it failed with
+// a transient error, at most the given number of attempts. The first retry is
+// made after backoff, every following one waits twice as long as the previous.
+//
+// The errors considered transient are: running out of file descriptors (EMFILE,
+// ENFILE), interrupted or unavailable system calls (EINTR, EAGAIN), and
+// nonexistent path (ENOENT), which is common while the watched directory is
+// atomically replaced.
+//
+// Once the watchpoint is set up, notify tries to set it up again the same way
+// whenever the watched path is reported to be removed or renamed, so that
+// the watchpoint survives the replacement. For that to work Remove and Rename
+// must be requested.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.attempts = attempts
+		o.backoff = backoff
+	}
+}
+
+// WithRootEvents makes notify report the watched path itself vanishing, with
+// Remove event, even if the underlying watcher does not report it, e.g. under
+// Windows. Combined with WithRetry, Create event is reported as well, once the
+// path is created again and the watchpoint set up anew. The events are reported
+// only if they were requested. They can be told apart from the events for
+// the watched path's children by their Path(), which is equal to the watched
+// path.
+//
+// Notify checks whether the path still exists once per second, so the events
+// missing from the underlying watcher may be delayed.
+func WithRootEvents() Option {
+	return func(o *options) {
+		o.root = true
+	}
+}
+
+// WithIdleTimeout makes notify remove the watchpoint, once no events were
+// delivered for it within the last d. It is meant for watching paths, which
+// are of interest only for as long as some activity takes place, e.g. a build
+// directory.
+//
+// Once the watchpoint expires, a final event is delivered, for the watched
+// path and with no value. Its Sys() returns ErrIdleTimeout.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.idle = d
+	}
+}
+
+// WithStat makes notify describe the state of the file or directory each
+// event was reported for, at the time the event is dispatched. The delivered
+// events implement StatEventInfo, which gives the result of os.Lstat for
+// the event's path, saving consumers from calling it themselves. The value
+// is nil for Remove events and when the path no longer exists.
+func WithStat() Option {
+	return func(o *options) {
+		o.stat = true
+	}
+}
+
+// WithDotfiles controls whether events for dotfiles, i.e. files and
+// directories which name starts with a dot, are delivered. With include
+// set to false, the events for dotfiles under the watched path and for
+// anything within dot-directories are dropped, e.g. for editor swap files or
+// .git directories. By default, events for dotfiles are delivered.
+//
+// For recursive watchpoints notify additionally does not watch dot-directories,
+// unless the underlying watcher watches directory trees natively, e.g. FSEvents
+// or ReadDirectoryChangesW.
+func WithDotfiles(include bool) Option {
+	return func(o *options) {
+		o.nodot = !include
+	}
+}
+
+// WithGitignore drops the events for the files and directories under the
+// watched path, which are ignored by the patterns of the given gitignore file.
+// The usual gitignore syntax is supported, including negated patterns and
+// patterns matching only directories, e.g.:
+//
+//   build/
+//   *.log
+//   !important.log
+//
+// The patterns are relative to the watched directory, and so is the file,
+// unless it is given by an absolute path. The patterns are reloaded once
+// the file changes; a missing file ignores nothing.
+//
+// For recursive watchpoints notify additionally does not watch the ignored
+// directories, unless the underlying watcher watches directory trees natively.
+func WithGitignore(path string) Option {
+	return func(o *options) {
+		o.ignore = path
+	}
+}
+
+// WithCompactedJournal makes the watchpoint keep a history of the events
+// delivered for at most n recently changed paths, which can be read back
+// with Journal, e.g. by an indexer recovering after its restart. Rather than
+// every event, the history keeps only the latest state-relevant one per path:
+// a file which was created and then written to is kept as created, a file
+// which was created and then removed is dropped altogether, otherwise the
+// latest event replaces the previous one. Once the history is full,
+// the least recently changed path is evicted.
+func WithCompactedJournal(n int) Option {
+	return func(o *options) {
+		o.journal = n
+	}
+}
+
+// WithOwnEvents controls whether events caused by the current process, e.g.
+// by its own writes, are delivered. By default they are, and the ones which
+// are told apart implement OwnEventInfo, so consumers can process them
+// differently from external changes. With include set to false, they are
+// dropped instead.
+//
+// Currently only FSEvents reports which events are own ones, under the other
+// watchers the option has no effect.
+func WithOwnEvents(include bool) Option {
+	return func(o *options) {
+		o.noown = !include
+	}
+}
+
+// WithSystemEvents controls whether events, which appear to be caused by
+// the system rather than by users or applications, are delivered. By default
+// they are; with include set to false, they are dropped.
+//
+// Currently only FSEvents reports enough to tell them apart: events telling
+// only about changes of inode metadata, Finder info or extended attributes,
+// which is what Spotlight indexing or antivirus scanning usually cause, are
+// considered system ones, unless they were caused by the current process.
+// Genuine metadata changes, e.g. by the xattr tool, are dropped as well. Under
+// the other watchers the option has no effect.
+func WithSystemEvents(include bool) Option {
+	return func(o *options) {
+		o.nosys = !include
+	}
+}
+
+// WithSizeTracking makes notify tell appends apart from truncations and
+// in-place modifications, e.g. for tailing logs. It caches the size of every
+// file under the watched path and stats the file on each Write event, so the
+// delivered Write events implement SizeEventInfo, which reports SizeAppended
+// when the file grew, SizeTruncated when it shrank and SizeModified otherwise.
+// Files created after the watchpoint was set up, or replaced in the meantime,
+// are assumed to have been empty.
+func WithSizeTracking() Option {
+	return func(o *options) {
+		o.sizes = true
+	}
+}
+
+// WithPriority gives the watchpoint a priority, which takes effect when
+// the receiving channel is not ready. Events of watchpoints with a priority
+// are queued instead of dropped, and once the queue is full, the events of
+// the least important watchpoints are dropped first. It allows for preserving
+// events for a critical path, e.g. a control file, when the same channel
+// receives also a lot of events for a noisy one.
+//
+// The level must be positive, the greater the level the more important
+// the watchpoint. Watchpoints set up with no priority have the level 0,
+// their events are still dropped whenever the channel is not ready.
+func WithPriority(level int) Option {
+	return func(o *options) {
+		o.priority = level
+	}
+}
+
+// WithTransform makes notify pass every event through fn before it is
+// delivered, so that consumers receive whatever fn returns instead, e.g. to
+// report InAttrib as Write or to relabel events based on their path. If fn
+// returns nil, the event is dropped.
+//
+// The transform applies only to the events delivered to the channel. The other
+// options filtering, coalescing or inspecting the events, the routing of events
+// and Journal see the original ones. The options shaping the events for
+// delivery, i.e. WithAliasPaths, WithSerialize, WithPathPair, WithTotalOrder,
+// WithPathState and WithPathStyle, are applied after fn, to the events fn
+// returns.
+func WithTransform(fn func(EventInfo) EventInfo) Option {
+	return func(o *options) {
+		o.transform = fn
+	}
+}
+
+// stages builds a list of stages configured by the options for a watchpoint
+// on dir, listening for the given events.
+func (o *options) stages(dir string, isrec bool, e Event) (stages []stage, err error) {
+	if o.noown {
+		stages = append(stages, noown)
+	}
+	if o.nosys {
+		stages = append(stages, nosystem)
+	}
+	if len(o.rules) != 0 {
+		rs, err := compileRules(o.rules)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, rs.filter(dir))
+	}
+	if o.regexp != "" {
+		re, err := regexp.Compile(o.regexp)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, matchRegexp(re))
+	}
+	if o.scanBatch > 0 {
+		s, flush := batchScans(dir, o.scanBatch)
+		stages = append(stages, s)
+		o.flush = append(o.flush, flush)
+	}
+	if o.root {
+		s, arm := rootEvents(dir, e, o.attempts > 0, pollInterval)
+		stages = append(stages, s)
+		o.arm = append(o.arm, arm)
+	}
+	key := o.keyFunc()
+	switch {
+	case o.dedup > 0:
+		stages = append(stages, dedup(o.dedup, dedupSize, key))
+	case o.hard:
+		stages = append(stages, dedup(linkWindow, dedupSize, key))
+	}
+	if o.collapse > 0 {
+		s, flush := collapse(o.collapse, key)
+		stages = append(stages, s)
+		o.flush = append(o.flush, flush)
+	}
+	if o.editorWindow > 0 {
+		s, flush := editorSaves(o.editors, o.editorWindow, e, key)
+		stages = append(stages, s)
+		o.flush = append(o.flush, flush)
+	}
+	if o.hard {
+		if o.links, err = hardlinks(dir, isrec); err != nil {
+			return nil, err
+		}
+	}
+	if o.leaves {
+		s, err := leaves(dir, isrec, e)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, s)
+	}
+	if o.overflow > 0 {
+		s, arm, err := overflowRescan(dir, isrec, o.overflow, o.overflowMax)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, s)
+		o.arm = append(o.arm, arm)
+	}
+	if o.grace > 0 {
+		s, arm := grace(o.grace)
+		stages = append(stages, s)
+		o.arm = append(o.arm, arm)
+	}
+	if o.confirm {
+		stages = append(stages, confirmWrites())
+	}
+	if o.hash != nil {
+		stages = append(stages, contentHash(o.hash, o.hashSize, newBudget(o.budget)))
+	}
+	switch {
+	case o.inode:
+		stages = append(stages, statInode)
+	case o.stat:
+		stages = append(stages, stat)
+	}
+	if o.minAge > 0 || o.maxAge > 0 {
+		stages = append(stages, aged(o.minAge, o.maxAge))
+	}
+	if o.sizes {
+		stages = append(stages, sizes(dir, isrec))
+	}
+	if o.journal > 0 {
+		s, arm := journaled(o.journal)
+		stages = append(stages, s)
+		o.arm = append(o.arm, arm)
+	}
+	return stages, nil
+}
+
+// skip gives the function reporting the paths under dir, which are ignored
+// by either of WithDotfiles or WithGitignore, nil if neither of them is set.
+func (o options) skip(dir string) skipFunc {
+	var skips []skipFunc
+	if o.nodot {
+		skips = append(skips, isdot)
+	}
+	if o.ignore != "" {
+		file := o.ignore
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		skips = append(skips, (&gitignore{file: file}).ignored)
+	}
+	if rs, err := compileRules(o.rules); err == nil && len(rs) != 0 {
+		skips = append(skips, rs.skip)
+	}
+	switch len(skips) {
+	case 0:
+		return nil
+	case 1:
+		return skips[0]
+	}
+	return func(rel string, isdir bool) bool {
+		for _, skip := range skips {
+			if skip(rel, isdir) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// total estimates the number of directories the underlying watcher registers
+// for a watchpoint on dir.
+func (o options) total(t *pipeTree, dir string, isrec bool) int {
+	if _, native := watcherOf(t.underlying()).(recursiveWatcher); !isrec || native {
+		return 1
+	}
+	skip := o.skip(dir)
+	if skip == nil {
+		skip = func(string, bool) bool { return false }
+	}
+	return len(subdirs(dir, dir, skip, nil))
+}
+
+// WatchWithOptions sets up a watchpoint configured by the options, retrying
+// on transient failures if the options say so.
+func (t *pipeTree) WatchWithOptions(path string, c chan<- EventInfo, events Event, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	err := o.watch(t, path, c, events)
+	for i := 0; err != nil && i < o.attempts && transient(err); i++ {
+		sleep(o.backoff << uint(i))
+		err = o.watch(t, path, c, events)
+	}
+	return err
+}
+
+// watch sets up a watchpoint configured by the options within the tree t.
+func (o options) watch(t *pipeTree, path string, c chan<- EventInfo, e Event) error {
+	orig := path
+	dir, isrec, err := cleanpath(path)
+	if err != nil {
+		return err
+	}
+	stages, err := o.stages(dir, isrec, e)
+	if err != nil {
+		return err
+	}
+	if err := o.flags.check(path); err != nil {
+		return err
+	}
+	if err := o.flags.checkResolved(path, dir); err != nil {
+		return err
+	}
+	if o.nooverlap {
+		if err := checkOverlap(t, c, dir); err != nil {
+			return err
+		}
+	}
+	if o.maxNodes > 0 && isrec {
+		if err := checkNodes(t, dir, o.skip(dir), o.maxNodes); err != nil {
+			return err
+		}
+	}
+	if o.flags != 0 {
+		t.SetFlags(dir, o.flags)
+	}
+	if o.linger > 0 {
+		t.SetLinger(dir, isrec, o.linger)
+	}
+	if o.expansion > 1 && isrec {
+		t.SetExpansion(dir, o.expansion)
+	}
+	link, islink := linkpath(path)
+	we := e
+	if o.editorWindow > 0 {
+		we |= editorEvents
+	}
+	if skip := o.skip(dir); skip != nil || isrec && o.maxChildren > 0 {
+		// Recursive watchpoints are emulated for non-recursive watchers,
+		// so that ignored directories and the subdirectories of crowded
+		// ones are not watched at all.
+		if skip == nil {
+			skip = func(string, bool) bool { return false }
+		}
+		_, native := watcherOf(t.underlying()).(recursiveWatcher)
+		expand := isrec && !native
+		fan := newFanout(o.maxChildren)
+		s, arm := ignore(t, dir, we, expand, skip, fan)
+		if expand {
+			path, we = dir, we|Create
+		}
+		stages = append([]stage{s}, stages...)
+		o.arm = append(o.arm, arm)
+		if expand && fan != nil {
+			o.arm = append(o.arm, func(p *pipe) { t.TrackFanout(p, fan) })
+		}
+	}
+	if o.attempts > 0 {
+		s, arm := rearm(t, path, dir, we, o.attempts, o.backoff)
+		stages = append([]stage{s}, stages...)
+		o.arm = append(o.arm, arm)
+	}
+	if o.follow {
+		s, arm := follow(t, dir, strings.HasSuffix(path, "..."), we)
+		stages = append([]stage{s}, stages...)
+		o.arm = append(o.arm, arm)
+	}
+	if o.idle > 0 {
+		s, arm := idle(t, dir, o.idle)
+		stages = append(stages, s)
+		o.arm = append(o.arm, arm)
+	}
+	if o.heartbeat > 0 {
+		o.arm = append(o.arm, beat(t, dir, o.heartbeat))
+	}
+	if o.history {
+		o.arm = append(o.arm, history(t, dir))
+	}
+	// The stages shaping the events for delivery follow the transform,
+	// see WithTransform.
+	if o.transform != nil {
+		stages = append(stages, transform(o.transform))
+	}
+	root := dir
+	if as, ok := aliaspath(orig, dir); ok && o.alias {
+		stages = append(stages, alias(dir, as))
+		root = as
+	}
+	if o.serialize {
+		stages = append(stages, serialize(o.keyFunc()))
+	}
+	if o.pair {
+		stages = append(stages, pair(root))
+	}
+	if o.order {
+		stages = append(stages, totalOrder)
+	}
+	if o.state {
+		key := keyFunc(pathKey)
+		if o.fold {
+			key = folded(key)
+		}
+		stages = append(stages, pathState(key))
+	}
+	if o.style != 0 {
+		stages = append(stages, pathStyle(o.style))
+	}
+	if o.progress != nil {
+		tr := newTracker(dir, o.total(t, dir, isrec), o.progress)
+		defer tr.done()
+	}
+	p, err := t.WatchPipe(path, c, stages, we)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.spec(p, orig, e, &o)
+	p.pending = o.pending == DeliverPending
+	t.mu.Unlock()
+	if o.priority > 0 {
+		t.Prioritize(p, c, o.priority)
+	}
+	if o.backpressure {
+		t.Throttle(p)
+	}
+	if len(o.flush) != 0 && !o.discard {
+		t.OnStop(p, o.flush...)
+	}
+	if o.symlinks && islink {
+		if err := t.TrackLink(p, link, isrec, we); err != nil {
+			t.mu.Lock()
+			t.remove(p)
+			t.unwatch(p)
+			p.stop()
+			t.mu.Unlock()
+			return err
+		}
+	}
+	if len(o.links) != 0 && e&Write != 0 {
+		t.WatchLinks(p, o.links, Write)
+	}
+	for _, fn := range o.arm {
+		fn(p)
+	}
+	return nil
+}
+
+// grace gives a stage, which drops all events until arm is called and then
+// for the following d.
+func grace(d time.Duration) (s stage, arm func(*pipe)) {
+	var mu sync.Mutex
+	var deadline time.Time
+	s = func(next handler) handler {
+		return func(ei EventInfo) {
+			mu.Lock()
+			drop := deadline.IsZero() || now().Before(deadline)
+			mu.Unlock()
+			if !drop {
+				next(ei)
+			}
+		}
+	}
+	arm = func(*pipe) {
+		mu.Lock()
+		deadline = now().Add(d)
+		mu.Unlock()
+	}
+	return s, arm
+}
+
+// transform gives a stage, which passes on whatever fn returns for an event,
+// unless it is nil.
+func transform(fn func(EventInfo) EventInfo) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			if ei = fn(ei); ei != nil {
+				next(ei)
+			}
+		}
+	}
+}
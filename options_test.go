@@ -0,0 +1,67 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGrace(t *testing.T) {
+	d := 50 * time.Millisecond
+	s, arm := grace(d)
+	var n int
+	fn := s(func(EventInfo) { n++ })
+	fn(&Call{P: "/a", E: Create})
+	if n != 0 {
+		t.Fatalf("want event dropped before arming; got %d", n)
+	}
+	arm(nil)
+	fn(&Call{P: "/a", E: Write})
+	if n != 0 {
+		t.Fatalf("want event dropped during grace period; got %d", n)
+	}
+	time.Sleep(2 * d)
+	fn(&Call{P: "/a", E: Remove})
+	if n != 1 {
+		t.Fatalf("want event passed after grace period; got %d", n)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	var got []EventInfo
+	fn := transform(func(ei EventInfo) EventInfo {
+		switch ei.Path() {
+		case "/drop":
+			return nil
+		case "/attrib":
+			return &Call{P: ei.Path(), E: Write}
+		}
+		return ei
+	})(func(ei EventInfo) { got = append(got, ei) })
+	cases := [...]struct {
+		ei   EventInfo
+		want EventInfo
+	}{
+		{&Call{P: "/a", E: Create}, &Call{P: "/a", E: Create}},           // i=0
+		{&Call{P: "/drop", E: Create}, nil},                              // i=1
+		{&Call{P: "/attrib", E: Unknown}, &Call{P: "/attrib", E: Write}}, // i=2
+	}
+	for i, cas := range cases {
+		got = nil
+		fn(cas.ei)
+		switch {
+		case cas.want == nil && len(got) != 0:
+			t.Errorf("want event dropped; got %v (i=%d)", got, i)
+		case cas.want == nil:
+		case len(got) != 1:
+			t.Errorf("want one event; got %v (i=%d)", got, i)
+		default:
+			if err := EqualEventInfo(cas.want, got[0]); err != nil {
+				t.Errorf("%v (i=%d)", err, i)
+			}
+		}
+	}
+}
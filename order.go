@@ -0,0 +1,111 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"sync"
+)
+
+// WithTotalOrder makes the events of the watchpoint delivered one at a time
+// through a single point shared by all the watchpoints set up with it, which
+// gives every event a sequence number. The events delivered implement
+// the Sequenced interface, which ID grows monotonically across all the paths
+// watched with WithTotalOrder, and the events are delivered to their channels
+// in the order of their IDs, e.g. for building a replicated log:
+//
+//   c := make(chan notify.EventInfo, 64)
+//   opt := notify.WithTotalOrder()
+//   notify.WatchWithOptions("/srv/a/...", c, notify.All, opt)
+//   notify.WatchWithOptions("/srv/b/...", c, notify.All, opt)
+//   for ei := range c {
+//       replica.Append(ei.(notify.Sequenced).ID(), ei)
+//   }
+//
+// The IDs are given out within the process only, they start over once it is
+// restarted. The ID reported by the watcher, e.g. the FSEvents one, is not
+// kept. Since the events are delivered one at a time, a slow receiver of
+// a watchpoint set up with WithBackpressure holds back the events of all
+// the others. The events delivered implement PairedEventInfo no longer, if
+// WithPathPair was given as well.
+func WithTotalOrder() Option {
+	return func(o *options) {
+		o.order = true
+	}
+}
+
+// sequence is the point the events of all the watchpoints set up with
+// WithTotalOrder are delivered through.
+var sequence struct {
+	sync.Mutex
+	seq uint64 // ID of the last event delivered
+}
+
+// ordered is an event given a sequence number.
+type ordered struct {
+	EventInfo
+	seq uint64
+}
+
+var _ Sequenced = (*ordered)(nil)
+var _ isDirer = (*ordered)(nil)
+var _ OwnEventInfo = (*ordered)(nil)
+var _ systemer = (*ordered)(nil)
+var _ CoalescedEventInfo = (*ordered)(nil)
+
+func (e *ordered) ID() uint64     { return e.seq }
+func (e *ordered) Own() bool      { return isown(e.EventInfo) }
+func (e *ordered) isSystem() bool { return issystem(e.EventInfo) }
+func (e *ordered) Count() int     { return count(e.EventInfo) }
+
+func (e *ordered) isDir() (bool, error) {
+	if d, ok := e.EventInfo.(isDirer); ok {
+		return d.isDir()
+	}
+	return false, nil
+}
+
+// The ordered events keep the optional interfaces of the events they wrap.
+type (
+	orderedStat    struct{ *ordered }
+	orderedAck     struct{ *ordered }
+	orderedStatAck struct{ *ordered }
+)
+
+var _ InodeEventInfo = orderedStat{}
+var _ AckEventInfo = orderedAck{}
+var _ InodeEventInfo = orderedStatAck{}
+var _ AckEventInfo = orderedStatAck{}
+
+func (e orderedStat) FileInfo() os.FileInfo    { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e orderedAck) Done()                     { e.EventInfo.(AckEventInfo).Done() }
+func (e orderedStatAck) FileInfo() os.FileInfo { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e orderedStatAck) Done()                 { e.EventInfo.(AckEventInfo).Done() }
+
+func (e orderedStat) Inode() (dev, ino uint64, ok bool)    { return inodeOf(e.EventInfo) }
+func (e orderedStatAck) Inode() (dev, ino uint64, ok bool) { return inodeOf(e.EventInfo) }
+
+// totalOrder is a stage, which numbers the events passed through it and passes
+// them on one at a time, in the order of their numbers.
+func totalOrder(next handler) handler {
+	return func(ei EventInfo) {
+		_, stat := ei.(StatEventInfo)
+		_, ack := ei.(AckEventInfo)
+		sequence.Lock()
+		defer sequence.Unlock()
+		sequence.seq++
+		o := &ordered{EventInfo: ei, seq: sequence.seq}
+		switch {
+		case stat && ack:
+			next(orderedStatAck{o})
+		case stat:
+			next(orderedStat{o})
+		case ack:
+			next(orderedAck{o})
+		default:
+			next(o)
+		}
+	}
+}
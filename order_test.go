@@ -0,0 +1,75 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTotalOrder(t *testing.T) {
+	var ids []uint64
+	fn := totalOrder(func(ei EventInfo) {
+		ids = append(ids, ei.(Sequenced).ID())
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				fn(&synthetic{path: "/a", event: Write})
+			}
+		}()
+	}
+	wg.Wait()
+	if len(ids) != 800 {
+		t.Fatalf("want 800 events; got %d", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] != ids[i-1]+1 {
+			t.Fatalf("want ID=%d; got %d (i=%d)", ids[i-1]+1, ids[i], i)
+		}
+	}
+	var got EventInfo
+	totalOrder(func(ei EventInfo) { got = ei })(&acked{EventInfo: &synthetic{path: "/a"}, done: func() {}})
+	if _, ok := got.(AckEventInfo); !ok {
+		t.Errorf("want AckEventInfo; got %T", got)
+	}
+}
+
+func TestWithTotalOrder(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	dirs := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+	c := make(chan EventInfo, buffer)
+	for _, d := range dirs {
+		must(os.Mkdir(d, 0755))
+		must(tr.WatchWithOptions(d, c, Create, WithTotalOrder()))
+	}
+	for i := 0; i < 10; i++ {
+		must(ioutil.WriteFile(filepath.Join(dirs[i%2], string('a'+rune(i))), nil, 0644))
+	}
+	var last uint64
+	for i := 0; i < 10; i++ {
+		select {
+		case ei := <-c:
+			s, ok := ei.(Sequenced)
+			if !ok {
+				t.Fatalf("want Sequenced; got %T (i=%d)", ei, i)
+			}
+			if s.ID() <= last {
+				t.Fatalf("want ID>%d; got %d (i=%d)", last, s.ID(), i)
+			}
+			last = s.ID()
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithOverflowBackoff makes notify rescan the watched path once the underlying
+// watcher reported it dropped events, e.g. since the inotify queue overflowed
+// or FSEvents told the history has to be rescanned, and deliver the changes
+// found as events. Notify keeps a snapshot of the path, which it updates on
+// every event, so that the rescan reports only the changes, which were lost.
+//
+// The rescans are coalesced and backed off, so that a path busy enough to make
+// the watcher overflow over and over does not make things worse by rescanning
+// it all the time: a rescan is made initial after an overflow, with all the
+// overflows in the meantime handled by it. If the watcher overflows again
+// within that delay after the rescan, the delay doubles, up to max; otherwise
+// it starts over at initial.
+//
+// The overflows are reported by inotify and FSEvents. They are reported for
+// all the paths at once, so every watchpoint set up with WithOverflowBackoff
+// is rescanned.
+func WithOverflowBackoff(initial, max time.Duration) Option {
+	return func(o *options) {
+		if max < initial {
+			max = initial
+		}
+		o.overflow, o.overflowMax = initial, max
+	}
+}
+
+// overflows keeps the functions called once a watcher reported it dropped
+// events.
+var overflows = struct {
+	sync.Mutex
+	n   int
+	fns map[int]func()
+}{fns: make(map[int]func())}
+
+// onOverflow registers fn to be called on every overflow, until the returned
+// function is called.
+func onOverflow(fn func()) (cancel func()) {
+	overflows.Lock()
+	overflows.n++
+	id := overflows.n
+	overflows.fns[id] = fn
+	overflows.Unlock()
+	return func() {
+		overflows.Lock()
+		delete(overflows.fns, id)
+		overflows.Unlock()
+	}
+}
+
+// overflowed is called by the watchers, which dropped events, e.g. since their
+// queue overflowed.
+func overflowed() {
+	dbgprint("watcher overflowed")
+	overflows.Lock()
+	fns := make([]func(), 0, len(overflows.fns))
+	for _, fn := range overflows.fns {
+		fns = append(fns, fn)
+	}
+	overflows.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// backoff schedules coalesced calls of fn, doubling the delay of the calls
+// requested within the delay since the previous one.
+type backoff struct {
+	mu           sync.Mutex
+	initial, max time.Duration
+	d            time.Duration // delay of the next call
+	t            Timer         // non-nil while a call is scheduled
+	last         time.Time     // time of the previous call
+	fn           func()
+	stopped      bool
+}
+
+// schedule requests a call of fn, unless one is already scheduled.
+func (b *backoff) schedule() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.t != nil || b.stopped {
+		return
+	}
+	switch {
+	case b.d == 0 || b.last.IsZero() || now().Sub(b.last) >= b.d:
+		b.d = b.initial
+	case b.d*2 > b.max:
+		b.d = b.max
+	default:
+		b.d *= 2
+	}
+	b.t = afterFunc(b.d, func() {
+		b.mu.Lock()
+		b.t = nil
+		b.last = now()
+		stopped := b.stopped
+		b.mu.Unlock()
+		if !stopped {
+			b.fn()
+		}
+	})
+}
+
+// stop cancels the scheduled call, if any, and all future ones.
+func (b *backoff) stop() {
+	b.mu.Lock()
+	b.stopped = true
+	if b.t != nil {
+		b.t.Stop()
+		b.t = nil
+	}
+	b.mu.Unlock()
+}
+
+// overflowRescan gives a stage, which keeps a snapshot of dir up to date with
+// the events, and an arm function, which makes the pipe rescan dir once
+// a watcher overflowed, passing the changes found through the pipe.
+func overflowRescan(dir string, isrec bool, initial, max time.Duration) (stage, func(*pipe), error) {
+	s, err := newSnapshot(dir, isrec)
+	if err != nil {
+		return nil, nil, err
+	}
+	var mu sync.Mutex // protects s
+	st := func(next handler) handler {
+		return func(ei EventInfo) {
+			if path := normalize(ei.Path()); path == dir || strings.HasPrefix(path, dir+sep) {
+				mu.Lock()
+				_, err := s.update(path)
+				mu.Unlock()
+				if err != nil {
+					dbgprintf("overflow: rescanning %q failed: %v", path, err)
+				}
+			}
+			next(ei)
+		}
+	}
+	arm := func(p *pipe) {
+		b := &backoff{initial: initial, max: max}
+		b.fn = func() {
+			mu.Lock()
+			eis, err := s.update(dir)
+			mu.Unlock()
+			if err != nil {
+				dbgprintf("overflow: rescanning %q failed: %v", dir, err)
+				return
+			}
+			for _, ei := range eis {
+				p.inject(ei)
+			}
+		}
+		cancel := onOverflow(b.schedule)
+		go func() {
+			<-p.quit
+			cancel()
+			b.stop()
+		}()
+	}
+	return st, arm, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	calls := 0
+	b := &backoff{initial: time.Second, max: 3 * time.Second, fn: func() { calls++ }}
+	cases := [...]struct {
+		overflows int
+		advance   time.Duration
+		d         time.Duration
+		calls     int
+	}{
+		{3, time.Second, time.Second, 1},         // i=0
+		{1, 2 * time.Second, 2 * time.Second, 2}, // i=1
+		{1, 3 * time.Second, 3 * time.Second, 3}, // i=2
+		{0, 5 * time.Second, 3 * time.Second, 3}, // i=3
+		{2, time.Second, time.Second, 4},         // i=4
+	}
+	for i, cas := range cases {
+		for j := 0; j < cas.overflows; j++ {
+			b.schedule()
+		}
+		clk.Advance(cas.advance)
+		b.mu.Lock()
+		d := b.d
+		b.mu.Unlock()
+		if d != cas.d {
+			t.Errorf("want d=%v; got %v (i=%d)", cas.d, d, i)
+		}
+		if calls != cas.calls {
+			t.Errorf("want calls=%d; got %d (i=%d)", cas.calls, calls, i)
+		}
+	}
+	b.schedule()
+	b.stop()
+	clk.Advance(time.Minute)
+	if calls != 4 {
+		t.Errorf("want no calls once stopped; got %d", calls-4)
+	}
+}
+
+func TestOverflowRescan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_overflow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	seen, lost := filepath.Join(dir, "seen"), filepath.Join(dir, "lost")
+	s, arm, err := overflowRescan(dir, false, 10*time.Millisecond, 40*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := NewChans(1)
+	p := newPipe(ch[0], s)
+	defer p.stop()
+	arm(p)
+	must(ioutil.WriteFile(seen, nil, 0644))
+	p.c <- &Call{P: seen, E: Create}
+	if err := EqualEventInfo(&Call{P: seen, E: Create}, <-ch[0]); err != nil {
+		t.Fatal(err)
+	}
+	must(ioutil.WriteFile(lost, nil, 0644))
+	overflowed()
+	select {
+	case ei := <-ch[0]:
+		if err := EqualEventInfo(&Call{P: lost, E: Create}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	select {
+	case ei := <-ch[0]:
+		t.Fatalf("want no more events; got %v", ei)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrOverlap is reported by a watchpoint set up with WithNoOverlap, which
+// path is already covered by a recursive watchpoint of the same channel.
+var ErrOverlap = errors.New("path is already watched recursively")
+
+// Overlaps reports the pairs of watchpoints, which deliver events for the same
+// paths to the same channel, so the channel may receive an event twice. The
+// first path of a pair is the one covering the other, it ends with "..." if
+// it is recursive, e.g. watching "/a/..." and "/a/b" with a single channel is
+// reported as:
+//
+//   [][2]string{{"/a/...", "/a/b"}}
+//
+// Only the watchpoints described by Export are considered. The pairs are
+// sorted by the paths.
+func Overlaps() [][2]string {
+	return defaultTree.Overlaps()
+}
+
+// WithNoOverlap makes setting up the watchpoint fail with ErrOverlap, if its
+// path is already covered by a recursive watchpoint of the same channel,
+// instead of delivering the events for the path twice.
+func WithNoOverlap() Option {
+	return func(o *options) {
+		o.nooverlap = true
+	}
+}
+
+// Covered reports whether events for the path are delivered by any of
+// the watchpoints described by Export, giving the watched path of the closest
+// one covering it: the path itself, its parent, which reports events for its
+// direct children, or an ancestor watched recursively. The paths excluded
+// from a watchpoint, e.g. with WithDotfiles or WithGitignore, are not covered
+// by it. The path does not need to exist, e.g. to check whether the events
+// for a file are delivered once it is created.
+func Covered(path string) (root string, ok bool) {
+	return defaultTree.Covered(path)
+}
+
+// Covered gives the closest watchpoint of the tree covering the path.
+func (t *pipeTree) Covered(path string) (root string, ok bool) {
+	path = pathkey(path)
+	var isdir bool
+	if fi, err := os.Stat(path); err == nil {
+		isdir = fi.IsDir()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.pipes {
+		for key, pipes := range m {
+			if ok && len(key) <= len(root) {
+				continue
+			}
+			for _, p := range pipes {
+				if p.spec != nil && !p.closed() && p.covers(key, path, isdir) {
+					root, ok = key, true
+					break
+				}
+			}
+		}
+	}
+	return root, ok
+}
+
+// covers reports whether the watchpoint of p, registered under the key,
+// delivers events for the path.
+func (p *pipe) covers(key, path string, isdir bool) bool {
+	if key == path {
+		return true
+	}
+	rel, under := relpath(key, path)
+	if !under {
+		return false
+	}
+	rec := p.rec || strings.HasSuffix(p.spec.path, "...")
+	if !rec && strings.Contains(rel, "/") {
+		return false
+	}
+	if o := p.spec.o; o != nil {
+		if skip := o.skip(key); skip != nil && skip(rel, isdir) {
+			return false
+		}
+	}
+	return true
+}
+
+// Overlaps gives the overlapping watchpoints of the tree.
+func (t *pipeTree) Overlaps() (pairs [][2]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	type watch struct {
+		key string
+		rec bool
+	}
+	name := func(w watch) string {
+		if w.rec {
+			return filepath.Join(w.key, "...")
+		}
+		return w.key
+	}
+	seen := make(map[[2]string]struct{})
+	for _, m := range t.pipes {
+		var ws []watch
+		for key, pipes := range m {
+			for _, p := range pipes {
+				if p.spec != nil && !p.closed() {
+					ws = append(ws, watch{key, p.rec})
+				}
+			}
+		}
+		for i, a := range ws {
+			for j, b := range ws {
+				if i == j {
+					continue
+				}
+				_, under := relpath(a.key, b.key)
+				if a.key == b.key {
+					under = a.rec && !b.rec || a.rec == b.rec && i < j
+				} else {
+					under = under && a.rec
+				}
+				if !under {
+					continue
+				}
+				pair := [2]string{name(a), name(b)}
+				if _, ok := seen[pair]; !ok {
+					seen[pair] = struct{}{}
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+	sort.Sort(byPair(pairs))
+	return pairs
+}
+
+// covered reports whether c has a recursive watchpoint covering dir. It expects
+// t.mu to be held.
+func (t *pipeTree) covered(c chan<- EventInfo, dir string) bool {
+	for key, pipes := range t.pipes[c] {
+		if _, under := relpath(key, dir); !under && key != dir {
+			continue
+		}
+		for _, p := range pipes {
+			if p.spec != nil && p.rec && !p.closed() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkOverlap fails with ErrOverlap, if dir is covered by a recursive
+// watchpoint of c.
+func checkOverlap(t *pipeTree, c chan<- EventInfo, dir string) error {
+	t.mu.Lock()
+	ok := t.covered(c, dir)
+	t.mu.Unlock()
+	if ok {
+		return &WatchError{Op: "watch", Path: dir, Err: ErrOverlap}
+	}
+	return nil
+}
+
+type byPair [][2]string
+
+func (p byPair) Len() int { return len(p) }
+func (p byPair) Less(i, j int) bool {
+	if p[i][0] != p[j][0] {
+		return p[i][0] < p[j][0]
+	}
+	return p[i][1] < p[j][1]
+}
+func (p byPair) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
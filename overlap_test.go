@@ -0,0 +1,88 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOverlaps(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 2)
+	defer tr.Close()
+	for _, name := range []string{"a/b/c", "d"} {
+		must(os.MkdirAll(filepath.Join(dir, filepath.FromSlash(name)), 0755))
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	for _, w := range []struct {
+		path string
+		c    chan<- EventInfo
+	}{
+		{path("a/..."), ch[0]},
+		{path("a/b/c"), ch[0]},
+		{path("a/b"), ch[1]},
+		{path("d"), ch[0]},
+	} {
+		if err := tr.Watch(w.path, w.c, Create); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tr.WatchWithOptions(path("d"), ch[0], Remove, WithDedup(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{
+		{path("a/..."), path("a/b/c")},
+		{path("d"), path("d")},
+	}
+	if got := tr.Overlaps(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want Overlaps()=%v; got %v", want, got)
+	}
+	err := tr.WatchWithOptions(path("a/b"), ch[0], Write, WithNoOverlap())
+	if we, ok := err.(*WatchError); !ok || we.Err != ErrOverlap {
+		t.Fatalf("want err=ErrOverlap; got %v", err)
+	}
+	if err := tr.WatchWithOptions(path("a/b"), ch[1], Write, WithNoOverlap()); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+}
+
+func TestCovered(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 2)
+	defer tr.Close()
+	for _, name := range []string{"a/b/c", "d/e/f", "g/.h"} {
+		must(os.MkdirAll(filepath.Join(dir, filepath.FromSlash(name)), 0755))
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	must(tr.Watch(path("a/..."), ch[0], Create))
+	must(tr.Watch(path("a/b"), ch[1], Create))
+	must(tr.Watch(path("d"), ch[0], Create))
+	must(tr.WatchWithOptions(path("g/..."), ch[1], Create, WithDotfiles(false)))
+	cases := [...]struct {
+		path string
+		root string
+	}{
+		{"a", "a"},         // i=0
+		{"a/b/new", "a/b"}, // i=1
+		{"a/x/y", "a"},     // i=2
+		{"d/e", "d"},       // i=3
+		{"d/e/f", ""},      // i=4
+		{"g/.h", ""},       // i=5
+		{"g/i/j", "g"},     // i=6
+		{"k", ""},          // i=7
+	}
+	for i, cas := range cases {
+		root, ok := tr.Covered(path(cas.path))
+		if want := cas.root != ""; ok != want || (ok && root != path(cas.root)) {
+			t.Errorf("want Covered(%q)=(%q, %t); got (%q, %t) (i=%d)", cas.path, cas.root, want, root, ok, i)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+// OwnEventInfo is an EventInfo, which tells whether the event was caused by
+// the current process. It is implemented by the events reported by FSEvents,
+// which marks the events of the process itself with FSEventsOwnEvent flag.
+// Other watchers are not able to tell where a change came from, so their
+// events never appear to be own ones.
+type OwnEventInfo interface {
+	EventInfo
+	Own() bool // whether the event was caused by the current process
+}
+
+// isown reports whether ei is an event caused by the current process.
+func isown(ei EventInfo) bool {
+	o, ok := ei.(OwnEventInfo)
+	return ok && o.Own()
+}
+
+// noown is a stage, which drops the events caused by the current process.
+func noown(next handler) handler {
+	return func(ei EventInfo) {
+		if !isown(ei) {
+			next(ei)
+		}
+	}
+}
+
+// systemer is implemented by the events, which can tell whether the change
+// appears to come from the system, e.g. from Spotlight indexing or antivirus
+// scanning, rather than from a user or an application.
+type systemer interface {
+	isSystem() bool
+}
+
+// issystem reports whether ei appears to be an event caused by the system.
+func issystem(ei EventInfo) bool {
+	s, ok := ei.(systemer)
+	return ok && s.isSystem()
+}
+
+// nosystem is a stage, which drops the events caused by the system.
+func nosystem(next handler) handler {
+	return func(ei EventInfo) {
+		if !issystem(ei) {
+			next(ei)
+		}
+	}
+}
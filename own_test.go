@@ -0,0 +1,50 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "testing"
+
+// ownCall is a Call caused by the current process.
+type ownCall struct {
+	Call
+}
+
+func (*ownCall) Own() bool { return true }
+
+func TestNoown(t *testing.T) {
+	ch := NewChans(1)
+	p := newPipe(ch[0], noown, stat)
+	defer p.stop()
+	p.c <- &ownCall{Call{P: "/own", E: Write}}
+	p.c <- &Call{P: "/external", E: Remove}
+	ei := <-ch[0]
+	if err := EqualEventInfo(&Call{P: "/external", E: Remove}, ei); err != nil {
+		t.Fatal(err)
+	}
+	if o, ok := ei.(OwnEventInfo); !ok || o.Own() {
+		t.Fatalf("want external event not to be an own one; got %v", ei)
+	}
+	if !isown(&statEvent{EventInfo: &ownCall{Call{P: "/own", E: Write}}}) {
+		t.Fatal("want own event to stay own once stat is attached")
+	}
+}
+
+// systemCall is a Call caused by the system.
+type systemCall struct {
+	Call
+}
+
+func (*systemCall) isSystem() bool { return true }
+
+func TestNosystem(t *testing.T) {
+	ch := NewChans(1)
+	p := newPipe(ch[0], stat, nosystem)
+	defer p.stop()
+	p.c <- &systemCall{Call{P: "/indexed", E: Write}}
+	p.c <- &Call{P: "/edited", E: Write}
+	if err := EqualEventInfo(&Call{P: "/edited", E: Write}, <-ch[0]); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PairedEventInfo is an EventInfo delivered for a watchpoint set up with
+// WithPathPair. PathPair gives the absolute path of the event, the same as
+// Path(), together with the path relative to the watched directory, e.g. to
+// open the file with the former and key the state of the application with
+// the latter. The relative path of the watched directory itself is ".".
+type PairedEventInfo interface {
+	EventInfo
+	PathPair() (abs, rel string)
+}
+
+// WithPathPair makes the events delivered for the watchpoint implement
+// PairedEventInfo. The relative paths are computed on demand.
+func WithPathPair() Option {
+	return func(o *options) {
+		o.pair = true
+	}
+}
+
+// paired is an event, which knows the directory it was reported under.
+type paired struct {
+	EventInfo
+	root string
+}
+
+var _ PairedEventInfo = (*paired)(nil)
+var _ isDirer = (*paired)(nil)
+var _ OwnEventInfo = (*paired)(nil)
+var _ systemer = (*paired)(nil)
+var _ CoalescedEventInfo = (*paired)(nil)
+
+func (e *paired) Own() bool      { return isown(e.EventInfo) }
+func (e *paired) isSystem() bool { return issystem(e.EventInfo) }
+func (e *paired) Count() int     { return count(e.EventInfo) }
+
+func (e *paired) isDir() (bool, error) {
+	if d, ok := e.EventInfo.(isDirer); ok {
+		return d.isDir()
+	}
+	return false, nil
+}
+
+// PathPair implements PairedEventInfo interface. The relative path is equal
+// to the absolute one, if the event was reported outside of the watched
+// directory, e.g. for a hard link watched with WithHardLinks.
+func (e *paired) PathPair() (abs, rel string) {
+	abs = e.Path()
+	rel, err := filepath.Rel(e.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return abs, abs
+	}
+	return abs, rel
+}
+
+// The paired events keep the optional interfaces of the events they wrap,
+// which are delivered, when the options are combined.
+type (
+	pairedStat    struct{ *paired }
+	pairedAck     struct{ *paired }
+	pairedStatAck struct{ *paired }
+)
+
+var _ InodeEventInfo = pairedStat{}
+var _ AckEventInfo = pairedAck{}
+var _ InodeEventInfo = pairedStatAck{}
+var _ AckEventInfo = pairedStatAck{}
+
+func (e pairedStat) FileInfo() os.FileInfo    { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e pairedAck) Done()                     { e.EventInfo.(AckEventInfo).Done() }
+func (e pairedStatAck) FileInfo() os.FileInfo { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e pairedStatAck) Done()                 { e.EventInfo.(AckEventInfo).Done() }
+
+func (e pairedStat) Inode() (dev, ino uint64, ok bool)    { return inodeOf(e.EventInfo) }
+func (e pairedStatAck) Inode() (dev, ino uint64, ok bool) { return inodeOf(e.EventInfo) }
+
+// pair gives a stage, which makes the events passed through it tell their
+// path relative to root.
+func pair(root string) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			p := &paired{EventInfo: ei, root: root}
+			_, stat := ei.(StatEventInfo)
+			_, ack := ei.(AckEventInfo)
+			switch {
+			case stat && ack:
+				next(pairedStatAck{p})
+			case stat:
+				next(pairedStat{p})
+			case ack:
+				next(pairedAck{p})
+			default:
+				next(p)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPair(t *testing.T) {
+	root := filepath.FromSlash("/a/b")
+	var got EventInfo
+	fn := pair(root)(func(ei EventInfo) { got = ei })
+	cases := [...]struct {
+		path string
+		rel  string
+	}{
+		// i=0
+		{"/a/b/c", "c"},
+		// i=1
+		{"/a/b/c/d.txt", "c/d.txt"},
+		// i=2
+		{"/a/b", "."},
+		// i=3: outside of the root
+		{"/a/bc", "/a/bc"},
+		// i=4
+		{"/x/y", "/x/y"},
+	}
+	for i, cas := range cases {
+		path := filepath.FromSlash(cas.path)
+		fn(&Call{P: path, E: Write})
+		p, ok := got.(PairedEventInfo)
+		if !ok {
+			t.Fatalf("want %T to implement PairedEventInfo (i=%d)", got, i)
+		}
+		abs, rel := p.PathPair()
+		if abs != path {
+			t.Errorf("want abs=%q; got %q (i=%d)", path, abs, i)
+		}
+		if want := filepath.FromSlash(cas.rel); rel != want {
+			t.Errorf("want rel=%q; got %q (i=%d)", want, rel, i)
+		}
+	}
+	fn(&statEvent{EventInfo: &Call{P: root, E: Write}})
+	if _, ok := got.(StatEventInfo); !ok {
+		t.Errorf("want %T to implement StatEventInfo", got)
+	}
+	if _, ok := got.(AckEventInfo); ok {
+		t.Errorf("want %T not to implement AckEventInfo", got)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// PathStyle tells the form of the paths of the events delivered, see
+// WithPathStyle.
+type PathStyle int
+
+// Path styles. The zero PathStyle delivers the paths as they were reported.
+const (
+	// OSNative paths are absolute and clean, they are separated with
+	// the separator of the operating system and have no trailing separator,
+	// e.g. C:\Users\gopher\file.txt or /home/gopher/file.txt.
+	OSNative PathStyle = iota + 1
+
+	// ForwardSlash paths are OSNative ones separated with forward slashes,
+	// e.g. C:/Users/gopher/file.txt.
+	ForwardSlash
+
+	// Canonical paths are OSNative ones with the drive letters upper-cased
+	// and the short 8.3 names expanded under Windows, which the function
+	// registered with SetPathNormalizer, if any, is applied to.
+	Canonical
+)
+
+// WithPathStyle makes notify deliver the paths of the events in the given
+// style, regardless of the form the underlying watcher reported them in:
+//
+//   notify.WatchWithOptions(`c:\data\...`, c, notify.All, notify.WithPathStyle(notify.ForwardSlash))
+//
+// Whatever the style, the paths delivered are absolute and clean, they have
+// no trailing separators. Without the option the paths are delivered in the
+// form the underlying watcher reported them in, which differs between
+// the platforms, e.g. in the case of the drive letters under Windows or in
+// the Unicode normalization form of the names under macOS, so the paths
+// reported for different watchpoints are best compared in the Canonical style.
+//
+// The style is applied after all other options saw the events, e.g. the
+// function given to WithTransform gets the paths as they were reported. Only
+// the paths given by the Path method are affected.
+func WithPathStyle(style PathStyle) Option {
+	return func(o *options) {
+		o.style = style
+	}
+}
+
+// stylepath gives the path in the given style.
+func stylepath(style PathStyle, path string) string {
+	path = filepath.Clean(filepath.FromSlash(path))
+	switch style {
+	case ForwardSlash:
+		path = filepath.ToSlash(path)
+	case Canonical:
+		path = longpath(path)
+		if vol := filepath.VolumeName(path); vol != "" {
+			path = strings.ToUpper(vol) + path[len(vol):]
+		}
+		path = normalize(path)
+	}
+	return path
+}
+
+// pathStyle gives a stage, which delivers the paths in the given style.
+func pathStyle(style PathStyle) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			next(remap(ei, stylepath(style, ei.Path())))
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+//go:build darwin
+// +build darwin
+
+package notify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStylepathDarwin(t *testing.T) {
+	// Stands for norm.NFC.String, composing the decomposed names HFS+
+	// reports.
+	SetPathNormalizer(strings.NewReplacer("e\u0301", "\u00e9").Replace)
+	defer SetPathNormalizer(nil)
+	cases := [...]caseStylepath{
+		{Canonical, "/private/tmp/cafe\u0301/", "/private/tmp/caf\u00e9"},   // i=0
+		{OSNative, "/private/tmp/cafe\u0301", "/private/tmp/cafe\u0301"},    // i=1
+		{ForwardSlash, "/private/tmp/caf\u00e9/", "/private/tmp/caf\u00e9"}, // i=2
+	}
+	testStylepath(t, cases[:])
+}
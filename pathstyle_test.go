@@ -0,0 +1,58 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type caseStylepath struct {
+	style PathStyle
+	path  string
+	want  string
+}
+
+func testStylepath(t *testing.T, cases []caseStylepath) {
+	for i, cas := range cases {
+		if got := stylepath(cas.style, cas.path); got != cas.want {
+			t.Errorf("want stylepath(%v, %q)=%q; got %q (i=%d)", cas.style, cas.path, cas.want, got, i)
+		}
+	}
+}
+
+func TestStylepath(t *testing.T) {
+	SetPathNormalizer(strings.ToLower)
+	defer SetPathNormalizer(nil)
+	native := filepath.FromSlash
+	cases := [...]caseStylepath{
+		{OSNative, "/tmp/a/", native("/tmp/a")},       // i=0
+		{OSNative, "/tmp//a/./b", native("/tmp/a/b")}, // i=1
+		{ForwardSlash, native("/tmp/a/"), "/tmp/a"},   // i=2
+		{Canonical, "/tmp/A/", native("/tmp/a")},      // i=3
+		{ForwardSlash, native("/tmp/A"), "/tmp/A"},    // i=4: not normalized
+	}
+	testStylepath(t, cases[:])
+}
+
+func TestWithPathStyle(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo, 16)
+	o := options{style: ForwardSlash}
+	must(o.watch(tr.pipeTree, dir, c, Create))
+	must(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644))
+	select {
+	case ei := <-c:
+		if want := filepath.ToSlash(filepath.Join(dir, "file")); ei.Path() != want {
+			t.Errorf("want path=%q; got %q", want, ei.Path())
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out waiting for the event")
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+//go:build windows
+// +build windows
+
+package notify
+
+import "testing"
+
+func TestStylepathWindows(t *testing.T) {
+	cases := [...]caseStylepath{
+		{OSNative, `c:\Users\gopher\`, `c:\Users\gopher`},          // i=0
+		{OSNative, `c:/Users\gopher/file`, `c:\Users\gopher\file`}, // i=1
+		{ForwardSlash, `c:\Users\gopher\`, `c:/Users/gopher`},      // i=2
+		{Canonical, `c:/Users/gopher/`, `C:\Users\gopher`},         // i=3
+		{Canonical, `D:\data`, `D:\data`},                          // i=4
+	}
+	testStylepath(t, cases[:])
+}
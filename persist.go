@@ -0,0 +1,358 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// persistSize is the maximum number of the events not yet acknowledged, which
+// a PersistedQueue keeps. Once it is exceeded, the oldest of them are dropped
+// to make room for the new ones.
+var persistSize = 1 << 16
+
+// The files a PersistedQueue keeps in its directory.
+const (
+	persistLog = "events.log" // the events, one JSON object per line
+	persistAck = "events.ack" // the ID of the last acknowledged event
+)
+
+var errQueueClosed = errors.New("notify: PersistedQueue is closed")
+
+// persistRecord is a single line of the events log.
+type persistRecord struct {
+	Seq uint64 `json:"seq"`
+	record
+}
+
+// persisted is an event read from a PersistedQueue. Its ID is the position of
+// the event in the queue.
+type persisted struct {
+	*jsonEvent
+	seq uint64
+}
+
+var _ Sequenced = (*persisted)(nil)
+
+func (e *persisted) ID() uint64 { return e.seq }
+
+// PersistedQueue is a queue of events backed by files on disk, so that
+// the events not yet processed survive restarts of the process. It is set up
+// with WatchPersisted.
+//
+// The queue keeps at most 65536 of the events, which were not acknowledged
+// with Ack, dropping the oldest ones once more of them were reported. The space
+// taken on disk by the acknowledged events is reclaimed periodically.
+type PersistedQueue struct {
+	t       *pipeTree
+	c       chan EventInfo
+	dir     string
+	more    chan struct{} // signalled once an event was queued
+	quit    chan struct{}
+	done    chan struct{}
+	mu      sync.Mutex // protects the fields below
+	log     *os.File
+	pending []*persisted // the events not acknowledged, oldest first
+	logged  int          // number of the events written to log
+	seq     uint64       // ID of the last event queued
+	acked   uint64       // ID of the last event acknowledged
+	read    uint64       // ID of the last event given by Next
+	err     error        // first failure of writing to the log
+}
+
+// WatchPersisted watches the path for the given events, like Watch does, and
+// writes every event reported to a log in dir, creating the directory if
+// needed. The events are read from the returned queue with Next and
+// acknowledged with Ack once processed:
+//
+//   q, err := notify.WatchPersisted("/path/to/dir/...", "/var/lib/app/queue", notify.All)
+//   if err != nil {
+//       log.Fatal(err)
+//   }
+//   defer q.Close()
+//   for {
+//       ei, err := q.Next(ctx)
+//       if err != nil {
+//           break
+//       }
+//       // process ei
+//       q.Ack(ei)
+//   }
+//
+// If dir holds the log of a previous queue, e.g. one of a process which has
+// crashed, the events it did not acknowledge are read again, starting with
+// the oldest one, before the events reported by the new watchpoint.
+func WatchPersisted(path, dir string, events ...Event) (*PersistedQueue, error) {
+	return defaultTree.WatchPersisted(path, dir, events...)
+}
+
+// WatchPersisted sets up a queue logging the events of the path to dir.
+func (t *pipeTree) WatchPersisted(path, dir string, events ...Event) (*PersistedQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &PersistedQueue{
+		t:    t,
+		c:    make(chan EventInfo, buffer),
+		dir:  dir,
+		more: make(chan struct{}, 1),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := t.Watch(path, q.c, events...); err != nil {
+		q.log.Close()
+		return nil, err
+	}
+	go q.loop()
+	return q, nil
+}
+
+// load reads the events not acknowledged from the log of a previous queue,
+// if any, and rewrites the log with them.
+func (q *PersistedQueue) load() error {
+	p, err := ioutil.ReadFile(filepath.Join(q.dir, persistAck))
+	switch {
+	case err == nil:
+		if q.acked, err = strconv.ParseUint(strings.TrimSpace(string(p)), 10, 64); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	q.seq, q.read = q.acked, q.acked
+	f, err := os.Open(filepath.Join(q.dir, persistLog))
+	switch {
+	case err == nil:
+		// A failure to decode a line means the process crashed while writing
+		// it, so the events starting with the line were never queued.
+		dec := json.NewDecoder(f)
+		for {
+			var rec persistRecord
+			if dec.Decode(&rec) != nil {
+				break
+			}
+			e, err := parseEvent(rec.Event)
+			if err != nil || rec.Seq <= q.acked {
+				continue
+			}
+			q.pending = append(q.pending, &persisted{
+				jsonEvent: &jsonEvent{path: rec.Path, event: e, dir: rec.IsDir, time: rec.Time},
+				seq:       rec.Seq,
+			})
+			q.seq = rec.Seq
+		}
+		f.Close()
+		if n := len(q.pending) - persistSize; n > 0 {
+			q.pending = q.pending[n:]
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	return q.compact()
+}
+
+// compact rewrites the log with the events not acknowledged. It expects q.mu
+// to be held or q not to be shared yet.
+func (q *PersistedQueue) compact() error {
+	name := filepath.Join(q.dir, persistLog)
+	f, err := os.Create(name + ".tmp")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, ev := range q.pending {
+		if err = ev.encode(enc); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(name+".tmp", name)
+	}
+	if err != nil {
+		os.Remove(name + ".tmp")
+		return err
+	}
+	if q.log != nil {
+		q.log.Close()
+	}
+	if q.log, err = os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return err
+	}
+	q.logged = len(q.pending)
+	return nil
+}
+
+// encode writes the event as a single line of the log.
+func (e *persisted) encode(enc *json.Encoder) error {
+	return enc.Encode(&persistRecord{
+		Seq: e.seq,
+		record: record{
+			Path:  e.path,
+			Event: e.event.String(),
+			IsDir: e.dir,
+			Time:  e.time,
+		},
+	})
+}
+
+// loop writes the events reported for the watchpoint to the log, until q is
+// closed.
+func (q *PersistedQueue) loop() {
+	defer close(q.done)
+	for {
+		select {
+		case <-q.quit:
+			return
+		case ei := <-q.c:
+			q.mu.Lock()
+			q.push(ei)
+			// Write the events, which are already waiting, with a single sync.
+		batch:
+			for {
+				select {
+				case ei = <-q.c:
+					q.push(ei)
+				default:
+					break batch
+				}
+			}
+			if q.err == nil {
+				q.err = q.log.Sync()
+			}
+			if q.err == nil && q.logged >= 2*persistSize {
+				q.err = q.compact()
+			}
+			q.mu.Unlock()
+			select {
+			case q.more <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// push writes ei to the log and queues it. It expects q.mu to be held.
+func (q *PersistedQueue) push(ei EventInfo) {
+	q.seq++
+	ev := &persisted{
+		jsonEvent: &jsonEvent{path: ei.Path(), event: ei.Event(), time: now()},
+		seq:       q.seq,
+	}
+	if d, ok := ei.(isDirer); ok {
+		ev.dir, _ = d.isDir()
+	}
+	if q.err == nil {
+		q.err = ev.encode(json.NewEncoder(q.log))
+	}
+	q.logged++
+	q.pending = append(q.pending, ev)
+	if len(q.pending) > persistSize {
+		dropped(q.pending[0])
+		q.pending = q.pending[1:]
+	}
+}
+
+// Next gives the oldest event of the queue, which was neither given by Next
+// nor acknowledged, waiting for one if there is none. The events given
+// implement the Sequenced interface, which ID tells the position of the event
+// in the queue.
+//
+// Next fails with ctx.Err() if ctx is done before an event is queued. It also
+// fails if the queue was closed or it failed to write to its log. Next is not
+// meant to be called from multiple goroutines at once.
+func (q *PersistedQueue) Next(ctx context.Context) (EventInfo, error) {
+	for {
+		q.mu.Lock()
+		err := q.err
+		for _, ev := range q.pending {
+			if ev.seq > q.read {
+				q.read = ev.seq
+				q.mu.Unlock()
+				return ev, nil
+			}
+		}
+		q.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.done:
+			return nil, errQueueClosed
+		case <-q.more:
+		}
+	}
+}
+
+// Ack acknowledges the event given by Next and all the events queued before
+// it, so they are not read again by the queues reading the same directory
+// later on. Ack of an event, which was already acknowledged, is a nop.
+func (q *PersistedQueue) Ack(ei EventInfo) error {
+	s, ok := ei.(Sequenced)
+	if !ok {
+		return errors.New("notify: Ack of an event not given by PersistedQueue")
+	}
+	id := s.ID()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if id <= q.acked {
+		return nil
+	}
+	name := filepath.Join(q.dir, persistAck)
+	if err := ioutil.WriteFile(name+".tmp", []byte(strconv.FormatUint(id, 10)), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	q.acked = id
+	if q.read < id {
+		q.read = id
+	}
+	n := 0
+	for n < len(q.pending) && q.pending[n].seq <= id {
+		n++
+	}
+	q.pending = q.pending[n:]
+	return nil
+}
+
+// Close removes the watchpoint of the queue and closes its log. The events
+// not acknowledged are kept in the log, to be read by the next queue set up
+// for the same directory.
+func (q *PersistedQueue) Close() error {
+	q.t.Stop(q.c)
+	select {
+	case <-q.quit:
+		return errQueueClosed
+	default:
+		close(q.quit)
+	}
+	<-q.done
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.log.Close(); q.err == nil {
+		return err
+	}
+	return q.err
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchPersisted(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	watched, queue := filepath.Join(dir, "watched"), filepath.Join(dir, "queue")
+	must(os.Mkdir(watched, 0755))
+	next := func(q *PersistedQueue, want string) EventInfo {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout())
+		defer cancel()
+		ei, err := q.Next(ctx)
+		if err != nil {
+			t.Fatalf("want err=nil; got %v (want %s)", err, want)
+		}
+		if err := EqualEventInfo(&Call{P: filepath.Join(watched, want), E: Create}, ei); err != nil {
+			t.Fatal(err)
+		}
+		return ei
+	}
+	// The events are written one by one, as the order they are reported in
+	// is not guaranteed otherwise.
+	write := func(q *PersistedQueue, name string) {
+		q.mu.Lock()
+		seq := q.seq
+		q.mu.Unlock()
+		must(ioutil.WriteFile(filepath.Join(watched, name), nil, 0644))
+		waitSeq(t, q, seq+1)
+	}
+	q, err := tr.WatchPersisted(watched, queue, Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		write(q, name)
+	}
+	must(q.Ack(next(q, "a")))
+	next(q, "b")
+	must(q.Close())
+	// The events not acknowledged are read again.
+	if q, err = tr.WatchPersisted(watched, queue, Create); err != nil {
+		t.Fatal(err)
+	}
+	next(q, "b")
+	must(q.Ack(next(q, "c")))
+	write(q, "d")
+	next(q, "d")
+	must(q.Close())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := q.Next(ctx); err != errQueueClosed {
+		t.Fatalf("want err=%v; got %v", errQueueClosed, err)
+	}
+}
+
+func TestPersistedQueueOverflow(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	defer func(n int) { persistSize = n }(persistSize)
+	persistSize = 2
+	q, err := tr.WatchPersisted(dir, filepath.Join(dir, "queue"), Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		q.c <- &Call{P: path, E: Write}
+	}
+	waitSeq(t, q, 5)
+	q.mu.Lock()
+	logged := q.logged
+	q.mu.Unlock()
+	if logged >= 2*persistSize {
+		t.Fatalf("want log compacted; got %d events logged", logged)
+	}
+	for i, want := range []string{"/d", "/e"} {
+		ei, err := q.Next(context.Background())
+		if err != nil {
+			t.Fatalf("want err=nil; got %v (i=%d)", err, i)
+		}
+		if err := EqualEventInfo(&Call{P: want, E: Write}, ei); err != nil {
+			t.Fatalf("%v (i=%d)", err, i)
+		}
+	}
+}
+
+// waitSeq waits until the event with the given ID was queued by q.
+func waitSeq(t *testing.T, q *PersistedQueue, seq uint64) {
+	deadline := time.Now().Add(timeout())
+	for {
+		q.mu.Lock()
+		cur := q.seq
+		q.mu.Unlock()
+		if cur >= seq {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for event %d", seq)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,919 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// handler processes a single event.
+type handler func(EventInfo)
+
+// stage wraps next handler with extra logic, e.g. filtering or transforming
+// events before they are passed on.
+type stage func(next handler) handler
+
+// pipe connects a tree with a user channel. The tree dispatches events to the
+// pipe's own channel, pipe passes them through its stages and delivers the
+// result to the user channel. A direct pipe has no channel of its own, the tree
+// dispatches events to the user channel itself, see newDirectPipe.
+type pipe struct {
+	mu      sync.Mutex     // protects stopped, out, level, jn, bp, last, mute and gen
+	c       chan EventInfo // nil if the pipe is direct
+	dst     chan<- EventInfo
+	plain   bool        // the pipe has no stages
+	poll    *poller     // non-nil if the pipe's path is polled
+	events  Event       // events the pipe's watchpoint listens for
+	rec     bool        // whether the pipe's watchpoint is recursive
+	fi      os.FileInfo // the pipe's path at the time it was watched
+	gone    bool        // whether the filesystem of the pipe's path was unmounted
+	pending bool        // whether Stop delivers the events in flight first
+	out     *outbox     // non-nil if the pipe was given a priority
+	level   int
+	jn      *journal   // non-nil if the pipe keeps a journal
+	bp      bool       // whether the pipe waits for a slow receiver
+	th      *throttle  // non-nil if the tree queues the events p is not ready for
+	spec    *watchSpec // non-nil if the pipe's watchpoint was set up by the user
+	flushes []func()   // called by Stop before the pipe is halted
+	lim     *limiter   // non-nil if the rate of the channel is capped
+	last    time.Time  // time of the last event delivered, or of setting up
+	mute    *muter     // non-nil if any of the channel's paths was muted
+	gens    bool       // whether the events delivered carry the generation
+	gen     uint64     // generation of the events delivered
+	fan     *fanout    // non-nil if the fan-out of the pipe's watchpoint is limited
+	act     *activity  // counts the events being passed through the stages
+	st      *stamps    // non-nil if the pipe is direct
+	stopped bool
+	quit    chan struct{} // closed once the pipe is stopped
+	done    chan struct{}
+}
+
+// newPipe creates a pipe delivering events to dst. Stages are applied in
+// the order they were given.
+func newPipe(dst chan<- EventInfo, stages ...stage) *pipe {
+	return newPipeActivity(dst, nil, stages...)
+}
+
+// newPipeActivity works like newPipe, but the events the pipe processes are
+// counted with act.
+func newPipeActivity(dst chan<- EventInfo, act *activity, stages ...stage) *pipe {
+	p := &pipe{
+		c:     make(chan EventInfo, buffer),
+		dst:   dst,
+		plain: len(stages) == 0,
+		last:  now(),
+		act:   act,
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	fn := handler(p.send)
+	for i := len(stages) - 1; i >= 0; i-- {
+		fn = stages[i](fn)
+	}
+	go p.loop(fn)
+	return p
+}
+
+func (p *pipe) loop(fn handler) {
+	for ei := range p.c {
+		if f, ok := ei.(*fence); ok {
+			p.mu.Lock()
+			p.gen = f.gen
+			p.mu.Unlock()
+			continue
+		}
+		p.act.enter()
+		fn(ei)
+		p.act.leave()
+	}
+	close(p.done)
+}
+
+// send delivers ei to the user channel, unless the pipe was already stopped.
+// Like watchpoint's Dispatch it does not block, unless the pipe was set up
+// with backpressure. Events of prioritized pipes are queued in the channel's
+// outbox instead.
+func (p *pipe) send(ei EventInfo) {
+	p.mu.Lock()
+	if !p.stopped {
+		p.last = now()
+	}
+	if p.gens {
+		ei = withGeneration(ei, p.gen)
+	}
+	switch {
+	case p.stopped:
+	case p.mute != nil && p.mute.drop(ei):
+	case p.lim != nil:
+		p.lim.push(ei)
+	case p.out != nil:
+		p.out.push(ei, p.level)
+	case p.bp:
+		p.mu.Unlock()
+		p.block(ei)
+		return
+	default:
+		select {
+		case p.dst <- ei:
+		default: // Drop event if receiver is too slow
+			dropped(ei)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// inject passes ei through the pipe as if it was dispatched by the tree,
+// unless the pipe was already stopped. It does not block.
+func (p *pipe) inject(ei EventInfo) {
+	p.mu.Lock()
+	if p.st != nil {
+		p.mu.Unlock()
+		p.send(ei)
+		return
+	}
+	if !p.stopped {
+		select {
+		case p.c <- ei:
+		default: // Drop event if the pipe is too slow
+			dropped(ei)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// stop shuts the pipe down. It expects p.c to be already stopped within
+// the tree. When stop returns, no more events are delivered to the user channel.
+func (p *pipe) stop() {
+	p.halt()
+	if p.th != nil {
+		p.th.remove(p.c)
+	}
+	if p.st != nil {
+		p.st.remove(p.dst)
+	} else {
+		close(p.c)
+	}
+	<-p.done
+}
+
+// halt makes the pipe drop all events from now on, without shutting it down.
+func (p *pipe) halt() {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.quit)
+	}
+	p.mu.Unlock()
+}
+
+// lastEvent gives the time of the latest event delivered by the pipe, or of
+// setting it up if there was none.
+func (p *pipe) lastEvent() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.st != nil {
+		if last := p.st.last(p.dst); last.After(p.last) {
+			return last
+		}
+	}
+	return p.last
+}
+
+// closed reports whether the pipe was stopped.
+func (p *pipe) closed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped
+}
+
+// pipeTree is a tree, which delivers events to user channels through pipes.
+// Every path watched by a channel gets its own pipe, which makes it possible
+// to remove watchpoints per path. The first path a channel watches with no
+// stages or options gets a direct pipe, which the other features promote once
+// they need the events to pass through it.
+type pipeTree struct {
+	tree
+	mu      sync.Mutex // protects pipes, outs, digests, diffs, counts, uses, rates, mutes, fds, indexed and gens
+	pipes   map[chan<- EventInfo]map[string][]*pipe
+	outs    map[chan<- EventInfo]*outbox
+	digests map[chan<- Digest][]*digester
+	diffs   map[chan<- DirDiff][]*differ
+	counts  map[chan<- CountEvent][]*counter
+	uses    map[chan<- EventInfo][]stage // middlewares registered with Use
+	rates   map[chan<- EventInfo]*limiter
+	mutes   map[chan<- EventInfo]*muter
+	fds     map[chan<- EventInfo][]fdPipe
+	indexed map[chan<- IndexedEvent][]chan<- EventInfo
+	gens    map[chan<- EventInfo]uint64
+	act     *activity // shared with the underlying tree, if it has one
+	unmount func()    // cancels the registration for the unmounts
+}
+
+func newPipeTree(t tree) *pipeTree {
+	pt := &pipeTree{
+		tree:    t,
+		pipes:   make(map[chan<- EventInfo]map[string][]*pipe),
+		outs:    make(map[chan<- EventInfo]*outbox),
+		digests: make(map[chan<- Digest][]*digester),
+		diffs:   make(map[chan<- DirDiff][]*differ),
+		counts:  make(map[chan<- CountEvent][]*counter),
+		uses:    make(map[chan<- EventInfo][]stage),
+		rates:   make(map[chan<- EventInfo]*limiter),
+		mutes:   make(map[chan<- EventInfo]*muter),
+		fds:     make(map[chan<- EventInfo][]fdPipe),
+		indexed: make(map[chan<- IndexedEvent][]chan<- EventInfo),
+		gens:    make(map[chan<- EventInfo]uint64),
+		act:     activityOf(t),
+	}
+	if pt.act == nil {
+		pt.act = new(activity)
+	}
+	pt.unmount = onUnmount(pt.unmounted)
+	return pt
+}
+
+// Watch works like tree's Watch, events are delivered to c through a pipe
+// with no stages, shared by all the watchpoints c has for the path. The pipe
+// is a direct one, if c may have it.
+func (t *pipeTree) Watch(path string, c chan<- EventInfo, events ...Event) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, err := t.watchPipe(path, c, nil, t.direct(c), events...)
+	if err == nil {
+		t.spec(p, path, 0, nil)
+	}
+	return err
+}
+
+// WatchPipe works like Watch, but events are passed through the given stages
+// before they are delivered to c. Each call with non-empty stages sets up
+// a new pipe. It gives the pipe the watchpoint was set up for.
+func (t *pipeTree) WatchPipe(path string, c chan<- EventInfo, stages []stage, events ...Event) (*pipe, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.watchPipe(path, c, stages, false, events...)
+}
+
+// watchPipe works like WatchPipe, but a new plain pipe is a direct one if so
+// requested. It expects t.mu to be held.
+func (t *pipeTree) watchPipe(path string, c chan<- EventInfo, stages []stage, direct bool, events ...Event) (*pipe, error) {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	// Expanding with empty event set is a nop.
+	if len(events) == 0 {
+		return nil, nil
+	}
+	key, _, err := cleanpath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(stages) == 0 {
+		for _, p := range t.pipes[c][key] {
+			if p.plain {
+				return p, t.watch(p, path, events...)
+			}
+		}
+	}
+	var p *pipe
+	if direct && len(stages) == 0 {
+		p = newDirectPipe(c, t.act, stampsOf(t.tree))
+	} else {
+		p = t.newPipe(c, stages...)
+	}
+	if err := t.watch(p, path, events...); err != nil {
+		p.stop()
+		return nil, err
+	}
+	t.add(c, key, p)
+	return p, nil
+}
+
+// WatchLinks sets up additional watchpoints for the given files or directories
+// delivering events to p, unless p was already stopped. Failures are not fatal,
+// since the files may be removed in the meantime.
+func (t *pipeTree) WatchLinks(p *pipe, files []string, e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p.closed() || p.poll != nil {
+		return
+	}
+	for _, file := range files {
+		if err := t.tree.Watch(file, p.ch(), e); err != nil {
+			dbgprintf("WatchLinks: watch %q error: %v", file, err)
+		}
+	}
+}
+
+// Prioritize makes p deliver events to c with the given priority. Events
+// of all prioritized pipes for c are queued together, so that the less
+// important ones are dropped first when c is not ready.
+func (t *pipeTree) Prioritize(p *pipe, c chan<- EventInfo, level int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p.closed() {
+		return
+	}
+	t.promote(p)
+	out, ok := t.outs[c]
+	if !ok {
+		out = newOutbox(c, buffer, t.act)
+		t.outs[c] = out
+	}
+	p.mu.Lock()
+	p.out, p.level = out, level
+	p.mu.Unlock()
+	p.plain = false // keep plain watchpoints from sharing the priority
+}
+
+// Replace sets up a new watchpoint for c on newpath and, once it succeeded,
+// removes all the watchpoints c has for oldpath. If setting up the new
+// watchpoint fails, or no events are given, the old ones are left intact.
+func (t *pipeTree) Replace(c chan<- EventInfo, oldpath, newpath string, events ...Event) error {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	if len(events) == 0 {
+		return &WatchError{Op: "replace", Path: newpath, Err: ErrInvalidEventSet}
+	}
+	oldkey := pathkey(oldpath)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	old := t.pipes[c][oldkey]
+	if len(old) == 0 {
+		return &WatchError{Op: "replace", Path: oldpath, Err: ErrNotWatched}
+	}
+	key, _, err := cleanpath(newpath)
+	if err != nil {
+		return err
+	}
+	p := t.newPipe(c)
+	if err := t.watch(p, newpath, events...); err != nil {
+		p.stop()
+		return err
+	}
+	t.spec(p, newpath, 0, nil)
+	t.del(c, oldkey)
+	t.add(c, key, p)
+	for _, p := range old {
+		t.unwatch(p)
+		p.stop()
+	}
+	return nil
+}
+
+// WatchUntil watches the path with its own channel, passing the events to fn
+// until it returns true. The wait ends early once the pipe is stopped, e.g. by
+// Reset, or the path is removed, as no more events would be delivered then.
+func (t *pipeTree) WatchUntil(path string, fn func(EventInfo) bool, events ...Event) error {
+	// Unlike for Watch, an empty event set is not a nop, as nothing would
+	// ever end the wait.
+	if len(events) == 0 {
+		return &WatchError{Op: "watchuntil", Path: path, Err: ErrInvalidEventSet}
+	}
+	c := make(chan EventInfo, buffer)
+	p, err := t.WatchPipe(path, c, nil, events...)
+	if err != nil {
+		return err
+	}
+	defer t.Stop(c)
+	dir, _, _ := cleanpath(path)
+	ctx, cancel := context.WithCancel(context.Background())
+	gone := t.OnRemove(ctx, dir)
+	defer func() {
+		// The channel is closed once the watchpoint of OnRemove is removed.
+		cancel()
+		<-gone
+	}()
+	for {
+		select {
+		case ei := <-c:
+			if fn(ei) {
+				return nil
+			}
+		case <-p.quit:
+			return &WatchError{Op: "watchuntil", Path: path, Err: ErrNotWatched}
+		case <-gone:
+			return &WatchError{Op: "watchuntil", Path: path, Err: ErrNotWatched}
+		}
+	}
+}
+
+// Stop removes all watchpoints registered for c. Every pipe of c is flushed and
+// halted before any watchpoint is removed, then the watchpoints are removed
+// leaf-first.
+func (t *pipeTree) Stop(c chan<- EventInfo) {
+	t.settle(c, "")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]string, 0, len(t.pipes[c]))
+	for key := range t.pipes[c] {
+		keys = append(keys, key)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	// All the pipes are halted before any watch is removed, so that events
+	// caused by the teardown itself are not delivered. The events held back
+	// by the pipes are delivered first.
+	for _, key := range keys {
+		for _, p := range t.pipes[c][key] {
+			p.flush()
+		}
+	}
+	for _, key := range keys {
+		for _, p := range t.pipes[c][key] {
+			p.halt()
+		}
+	}
+	for _, key := range keys {
+		t.unwatchDirect(t.pipes[c][key])
+	}
+	if out, ok := t.outs[c]; ok {
+		out.close()
+		delete(t.outs, c)
+	}
+	delete(t.uses, c)
+	if lim, ok := t.rates[c]; ok {
+		lim.close()
+		delete(t.rates, c)
+	}
+	delete(t.mutes, c)
+	delete(t.gens, c)
+	t.stopFDs(c)
+	for _, key := range keys {
+		for _, p := range t.del(c, key) {
+			t.unwatch(p)
+			p.stop()
+		}
+	}
+}
+
+// StopPath removes the watchpoints registered for c on the path, failing with
+// ErrNotWatched if there are none.
+func (t *pipeTree) StopPath(c chan<- EventInfo, path string) error {
+	key := pathkey(path)
+	t.settle(c, key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pipes := t.pipes[c][key]
+	if len(pipes) == 0 {
+		return &WatchError{Op: "stoppath", Path: path, Err: ErrNotWatched}
+	}
+	for _, p := range pipes {
+		p.flush()
+	}
+	for _, p := range pipes {
+		p.halt()
+	}
+	t.unwatchDirect(pipes)
+	for _, p := range t.del(c, key) {
+		t.unwatch(p)
+		p.stop()
+	}
+	return nil
+}
+
+// Reset resets the underlying tree and stops all the pipes.
+func (t *pipeTree) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pipes := t.drain()
+	t.tree.Reset()
+	t.uses = make(map[chan<- EventInfo][]stage)
+	for _, p := range pipes {
+		if p.poll != nil {
+			p.poll.Close()
+		}
+		p.stop()
+	}
+	t.stopFDs(nil)
+	t.stopDigests()
+	t.stopDiffs()
+	t.stopCounts()
+}
+
+// Close stops all the pipes and closes the underlying tree.
+func (t *pipeTree) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range t.drain() {
+		t.unwatch(p)
+		p.stop()
+	}
+	t.stopFDs(nil)
+	t.stopDigests()
+	t.stopDiffs()
+	t.stopCounts()
+	t.unmount()
+	return t.tree.Close()
+}
+
+// watch sets up a watchpoint on path delivering events to p. If the path
+// cannot be watched by the underlying watcher, it is polled instead. So it is
+// when the watcher ran out of resources and SetLimitFallback was enabled.
+func (t *pipeTree) watch(p *pipe, path string, events ...Event) error {
+	dir, isrec, err := cleanpath(path)
+	if err != nil {
+		return err
+	}
+	e := joinevents(events)
+	switch {
+	case p.poll != nil:
+		if isrec && !p.rec {
+			p.events |= e
+			return t.setrec(p, dir, true)
+		}
+		p.poll.Add(e)
+	case p.events == 0 && atomic.LoadInt32(&pollFallback) == 1 && needsPoll(dir):
+		t.promote(p)
+		if p.poll, err = newPoller(dir, isrec, e, p.c, pollInterval); err != nil {
+			return err
+		}
+	default:
+		err := t.tree.Watch(path, p.ch(), events...)
+		switch {
+		case err == nil:
+		case p.events == 0 && atomic.LoadInt32(&limitFallback) == 1 && limited(err):
+			dbgprintf("watcher out of resources, polling %q instead", dir)
+			t.promote(p)
+			if p.poll, err = newPoller(dir, isrec, e, p.c, pollInterval); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+	if p.fi == nil {
+		p.fi, _ = os.Stat(dir)
+	}
+	p.events |= e
+	p.rec = p.rec || isrec
+	return nil
+}
+
+// Rearm sets up the watchpoint of p on path again, making at most the given
+// number of attempts, the first one after backoff, each following one waiting
+// twice as long. It gives up if p was stopped in the meantime or the failure
+// was not a transient one.
+func (t *pipeTree) Rearm(p *pipe, path string, e Event, attempts int, backoff time.Duration) {
+	for i := 0; i < attempts; i++ {
+		sleep(backoff << uint(i))
+		t.mu.Lock()
+		if p.closed() {
+			t.mu.Unlock()
+			return
+		}
+		t.unwatch(p)
+		p.poll, p.events, p.rec, p.fi, p.gone = nil, 0, false, nil, false
+		err := t.watch(p, path, e)
+		t.mu.Unlock()
+		if err == nil || !transient(err) {
+			return
+		}
+	}
+}
+
+// SetRecursive turns watchpoints registered for the path into recursive or
+// non-recursive ones.
+func (t *pipeTree) SetRecursive(path string, recursive bool) error {
+	key := pathkey(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var pipes []*pipe
+	for _, m := range t.pipes {
+		pipes = append(pipes, m[key]...)
+	}
+	if len(pipes) == 0 {
+		return &WatchError{Op: "setrecursive", Path: path, Err: ErrNotWatched}
+	}
+	for _, p := range pipes {
+		if p.rec == recursive {
+			continue
+		}
+		if err := t.setrec(p, key, recursive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setrec turns watchpoint of p registered for dir into recursive or
+// non-recursive one.
+func (t *pipeTree) setrec(p *pipe, dir string, rec bool) error {
+	switch {
+	case p.poll != nil:
+		poll, err := newPoller(dir, rec, p.events, p.c, pollInterval)
+		if err != nil {
+			return err
+		}
+		p.poll.Close()
+		p.poll = poll
+	case rec:
+		if err := t.tree.Watch(filepath.Join(dir, "..."), p.ch(), p.events); err != nil {
+			return err
+		}
+	default:
+		// The tree is not able to strip the recursive bit from a watchpoint,
+		// so it gets replaced by a non-recursive one. The dir is kept watched
+		// with a temporary channel meanwhile, so that the underlying watch is
+		// not recreated.
+		tmp := make(chan EventInfo, buffer)
+		if err := t.tree.Watch(dir, tmp, p.events); err != nil {
+			return err
+		}
+		t.tree.Stop(p.ch())
+		err := t.tree.Watch(dir, p.ch(), p.events)
+		t.tree.Stop(tmp)
+		if err != nil {
+			return err
+		}
+	}
+	p.rec = rec
+	return nil
+}
+
+// RewatchAll replaces the event sets of all the pipes of c with e.
+func (t *pipeTree) RewatchAll(c chan<- EventInfo, e Event) error {
+	if e == 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.nextGeneration(c)
+	var errs ReconcileError
+	for key, pipes := range t.pipes[c] {
+		for _, p := range pipes {
+			if err := t.rewatch(p, key, e); err != nil {
+				we, ok := err.(*WatchError)
+				if !ok {
+					we = &WatchError{Op: "rewatchall", Path: key, Err: err}
+				}
+				errs = append(errs, we)
+			}
+		}
+	}
+	if len(errs) != 0 {
+		sort.Sort(errs)
+		return errs
+	}
+	return nil
+}
+
+// ErrEventsChanged is reported by RewatchBatch for the watchpoints, which
+// event set differs from the expected one, e.g. since it was changed in
+// the meantime.
+var ErrEventsChanged = errors.New("event set of the watchpoint has changed")
+
+// RewatchBatch applies the changes to the pipes of c, reverting the ones
+// already applied if any of them fails.
+func (t *pipeTree) RewatchBatch(c chan<- EventInfo, changes map[string][2]Event) error {
+	type change struct {
+		p          *pipe
+		key        string
+		olde, newe Event
+	}
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var batch []change
+	for _, path := range paths {
+		key := pathkey(path)
+		pipes := t.pipes[c][key]
+		if len(pipes) == 0 {
+			return &WatchError{Op: "rewatchbatch", Path: path, Err: ErrNotWatched}
+		}
+		ch := changes[path]
+		if ch[1] == 0 {
+			return &WatchError{Op: "rewatchbatch", Path: path, Err: ErrInvalidEventSet}
+		}
+		for _, p := range pipes {
+			if p.events != ch[0] {
+				return &WatchError{Op: "rewatchbatch", Path: path, Err: ErrEventsChanged}
+			}
+			if ch[1] != ch[0] {
+				batch = append(batch, change{p: p, key: key, olde: ch[0], newe: ch[1]})
+			}
+		}
+	}
+	for i, ch := range batch {
+		if err := t.rewatch(ch.p, ch.key, ch.newe); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				t.rewatch(batch[j].p, batch[j].key, batch[j].olde)
+			}
+			if _, ok := err.(*WatchError); !ok {
+				err = &WatchError{Op: "rewatchbatch", Path: ch.key, Err: err}
+			}
+			return err
+		}
+	}
+	if len(batch) != 0 {
+		t.nextGeneration(c)
+	}
+	return nil
+}
+
+// rewatch replaces the event set of the watchpoint of p registered for dir
+// with e. Like setrec, it keeps dir watched with a temporary channel
+// meanwhile, so that the underlying watch is not recreated.
+func (t *pipeTree) rewatch(p *pipe, dir string, e Event) error {
+	if p.poll != nil {
+		p.poll.Set(e)
+		p.events = e
+		return nil
+	}
+	path := dir
+	if p.rec {
+		path = filepath.Join(dir, "...")
+	}
+	tmp := make(chan EventInfo, buffer)
+	if err := t.tree.Watch(path, tmp, p.events); err != nil {
+		return err
+	}
+	defer t.tree.Stop(tmp)
+	t.tree.Stop(p.ch())
+	if err := t.tree.Watch(path, p.ch(), e); err != nil {
+		t.tree.Watch(path, p.ch(), p.events)
+		return err
+	}
+	p.events = e
+	return nil
+}
+
+// Snapshot lists the directory given by the path, failing with ErrNotWatched
+// if there are no watchpoints registered for it.
+func (t *pipeTree) Snapshot(path string) ([]os.FileInfo, error) {
+	key := pathkey(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.pipes {
+		if len(m[key]) != 0 {
+			return ioutil.ReadDir(key)
+		}
+	}
+	return nil, &WatchError{Op: "snapshot", Path: path, Err: ErrNotWatched}
+}
+
+// Events gives the union of the events all the watchpoints registered for
+// the path are watched for, false if there are none.
+func (t *pipeTree) Events(path string) (e Event, ok bool) {
+	key := pathkey(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.pipes {
+		for _, p := range m[key] {
+			e, ok = e|p.events, true
+		}
+	}
+	return e, ok
+}
+
+// LastEvent gives the time of the latest event delivered by any of the pipes
+// registered for the path, false if there are none.
+func (t *pipeTree) LastEvent(path string) (last time.Time, ok bool) {
+	key := pathkey(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.pipes {
+		for _, p := range m[key] {
+			if l := p.lastEvent(); l.After(last) {
+				last = l
+			}
+			ok = true
+		}
+	}
+	return last, ok
+}
+
+// Expire removes the watchpoint of p and stops the pipe, delivering ei
+// as the last event. It is a nop if p was already stopped.
+func (t *pipeTree) Expire(p *pipe, ei EventInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p.closed() {
+		return
+	}
+	t.remove(p)
+	t.unwatch(p)
+	p.send(ei)
+	p.stop()
+}
+
+// remove unregisters pipe p. It expects t.mu to be held.
+func (t *pipeTree) remove(p *pipe) {
+	for key, pipes := range t.pipes[p.dst] {
+		for i := range pipes {
+			if pipes[i] != p {
+				continue
+			}
+			if rest := append(pipes[:i:i], pipes[i+1:]...); len(rest) != 0 {
+				t.pipes[p.dst][key] = rest
+			} else {
+				t.del(p.dst, key)
+			}
+			return
+		}
+	}
+}
+
+// unwatch removes the watchpoint delivering events to p.
+func (t *pipeTree) unwatch(p *pipe) {
+	if p.poll != nil {
+		p.poll.Close()
+		return
+	}
+	t.tree.Stop(p.ch())
+}
+
+// unwatchDirect removes the watchpoints of the direct ones of the halted
+// pipes, as halting does not stop the tree from dispatching to them.
+func (t *pipeTree) unwatchDirect(pipes []*pipe) {
+	for _, p := range pipes {
+		if p.st != nil {
+			t.unwatch(p)
+		}
+	}
+}
+
+// add registers pipe p for c on the given path. It expects t.mu to be held.
+func (t *pipeTree) add(c chan<- EventInfo, key string, p *pipe) {
+	m, ok := t.pipes[c]
+	if !ok {
+		m = make(map[string][]*pipe)
+		t.pipes[c] = m
+	}
+	m[key] = append(m[key], p)
+}
+
+// del unregisters and gives back all pipes for c on the given path. It expects
+// t.mu to be held.
+func (t *pipeTree) del(c chan<- EventInfo, key string) []*pipe {
+	m := t.pipes[c]
+	pipes := m[key]
+	if delete(m, key); len(m) == 0 {
+		delete(t.pipes, c)
+		if out, ok := t.outs[c]; ok {
+			out.close()
+			delete(t.outs, c)
+		}
+	}
+	return pipes
+}
+
+// drain unregisters and gives back all the pipes, halted, leaf-first for every
+// channel. It expects t.mu to be held.
+func (t *pipeTree) drain() (pipes []*pipe) {
+	for _, m := range t.pipes {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+		for _, key := range keys {
+			for _, p := range m[key] {
+				p.halt()
+				pipes = append(pipes, p)
+			}
+		}
+	}
+	for _, out := range t.outs {
+		out.close()
+	}
+	for _, lim := range t.rates {
+		lim.close()
+	}
+	t.pipes = make(map[chan<- EventInfo]map[string][]*pipe)
+	t.outs = make(map[chan<- EventInfo]*outbox)
+	t.rates = make(map[chan<- EventInfo]*limiter)
+	t.mutes = make(map[chan<- EventInfo]*muter)
+	t.indexed = make(map[chan<- IndexedEvent][]chan<- EventInfo)
+	t.gens = make(map[chan<- EventInfo]uint64)
+	return pipes
+}
+
+// pathkey gives a key under which pipes for the given path are registered.
+// If the path no longer exists, e.g. it was removed in the meantime, the key
+// is built from its absolute form.
+func pathkey(path string) string {
+	key, _, err := cleanpath(path)
+	if err != nil {
+		path = strings.TrimSuffix(path, "...")
+		if key, err = filepath.Abs(path); err != nil {
+			return path
+		}
+	}
+	return key
+}
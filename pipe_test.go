@@ -0,0 +1,559 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipe(t *testing.T) {
+	ch := NewChans(1)
+	only := func(e Event) stage {
+		return func(next handler) handler {
+			return func(ei EventInfo) {
+				if ei.Event() == e {
+					next(ei)
+				}
+			}
+		}
+	}
+	p := newPipe(ch[0], only(Create))
+	p.c <- &Call{P: "/a", E: Write}
+	p.c <- &Call{P: "/b", E: Create}
+	select {
+	case ei := <-ch[0]:
+		if err := EqualEventInfo(&Call{P: "/b", E: Create}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	p.stop()
+	p.send(&Call{P: "/c", E: Create})
+	if ei := ch.Drain(); len(ei) != 0 {
+		t.Fatalf("want no events after stop; got %v", ei)
+	}
+}
+
+func TestPipeBackpressure(t *testing.T) {
+	ch := make(chan EventInfo)
+	p := newPipe(ch)
+	p.bp = true
+	p.th = &throttle{g: newGate(), backlog: make(map[chan<- EventInfo]*backlog)}
+	wait := func(held bool) {
+		deadline := time.After(timeout())
+		for p.th.g.held() != held {
+			select {
+			case <-deadline:
+				t.Fatalf("want the gate held=%t", held)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+	p.c <- &Call{P: "/a", E: Create}
+	wait(true)
+	select {
+	case ei := <-ch:
+		if err := EqualEventInfo(&Call{P: "/a", E: Create}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	wait(false)
+	p.c <- &Call{P: "/b", E: Create}
+	wait(true)
+	p.stop()
+	if p.th.g.held() {
+		t.Fatal("want the gate released once the pipe is stopped")
+	}
+}
+
+func TestThrottleBacklog(t *testing.T) {
+	th := &throttle{g: newGate(), backlog: make(map[chan<- EventInfo]*backlog)}
+	c, other := make(chan EventInfo), make(chan EventInfo)
+	if th.send(other, &Call{P: "/a", E: Create}) {
+		t.Fatal("want the events for channels not throttled left to the caller")
+	}
+	quit := make(chan struct{})
+	th.add(c, quit)
+	const n = 4 * buffer
+	for i := 0; i < n; i++ {
+		if !th.send(c, &Call{P: "/" + strconv.Itoa(i), E: Create}) {
+			t.Fatalf("want the event queued (i=%d)", i)
+		}
+	}
+	if !th.g.held() {
+		t.Fatal("want the gate held while the events are queued")
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&Call{P: "/" + strconv.Itoa(i), E: Create}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+	deadline := time.After(timeout())
+	for th.g.held() {
+		select {
+		case <-deadline:
+			t.Fatal("want the gate released once the queue was drained")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	th.send(c, &Call{P: "/b", E: Create})
+	close(quit)
+	th.remove(c)
+	if th.g.held() {
+		t.Fatal("want the gate released once the channel was removed")
+	}
+}
+
+func TestPipeTreeReplace(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	must(os.Mkdir(a, 0755))
+	must(os.Mkdir(b, 0755))
+	must(tr.Watch(a, ch[0], Create))
+	if err, ok := tr.Replace(ch[0], b, a, Create).(*WatchError); !ok || err.Err != ErrNotWatched {
+		t.Fatalf("want err=ErrNotWatched; got %v", err)
+	}
+	if err := tr.Replace(ch[0], a, filepath.Join(dir, "c"), Create); err == nil {
+		t.Fatal("want err!=nil for nonexistent path")
+	}
+	if err, ok := tr.Replace(ch[0], a, b).(*WatchError); !ok || err.Err != ErrInvalidEventSet {
+		t.Fatalf("want err=ErrInvalidEventSet; got %v", err)
+	}
+	w := tr.W()
+	w.ExpectAny([]WCase{create(w, "a/1")})
+	must(tr.Replace(ch[0], a, b, Create))
+	w.ExpectAny([]WCase{
+		{Action: create(w, "a/2").Action},
+		create(w, "b/3"),
+	})
+}
+
+func TestPipeTreeSetRecursive(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	sub := filepath.Join(dir, "sub")
+	must(os.Mkdir(sub, 0755))
+	expect := func(p string) {
+		for {
+			select {
+			case ei := <-ch[0]:
+				if ei.Path() == p {
+					return
+				}
+				if filepath.Dir(ei.Path()) == sub {
+					t.Errorf("want no events under %q; got %v", sub, ei)
+				}
+			case <-time.After(timeout()):
+				t.Fatalf("timed out waiting for Create on %q", p)
+			}
+		}
+	}
+	if err, ok := tr.SetRecursive(dir, true).(*WatchError); !ok || err.Err != ErrNotWatched {
+		t.Fatalf("want err=ErrNotWatched; got %v", err)
+	}
+	must(tr.Watch(dir, ch[0], Create))
+	must(ioutil.WriteFile(filepath.Join(sub, "1"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(dir, "2"), nil, 0644))
+	expect(filepath.Join(dir, "2"))
+	must(tr.SetRecursive(dir, true))
+	must(ioutil.WriteFile(filepath.Join(sub, "3"), nil, 0644))
+	expect(filepath.Join(sub, "3"))
+	must(tr.SetRecursive(dir, false))
+	must(ioutil.WriteFile(filepath.Join(sub, "4"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(dir, "5"), nil, 0644))
+	expect(filepath.Join(dir, "5"))
+	for _, ei := range ch.Drain() {
+		if filepath.Dir(ei.Path()) == sub {
+			t.Errorf("want no events under %q; got %v", sub, ei)
+		}
+	}
+}
+
+func TestPipeTreeRewatchAll(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	must(os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	c := make(chan EventInfo, buffer)
+	must(tr.Watch(dir, c, Create))
+	must(tr.Watch(filepath.Join(dir, "sub", "..."), c, Create))
+	if err := tr.RewatchAll(c, Remove); err != nil {
+		t.Fatalf("RewatchAll()=%v", err)
+	}
+	for _, path := range []string{dir, filepath.Join(dir, "sub")} {
+		if e, ok := tr.Events(path); !ok || e&Create != 0 || e&Remove == 0 {
+			t.Errorf("want %q to be watched for Remove only; got %v", path, e)
+		}
+	}
+	for _, dir := range []string{dir, filepath.Join(dir, "sub")} {
+		file := filepath.Join(dir, "file")
+		must(ioutil.WriteFile(file, nil, 0644))
+		must(os.Remove(file))
+		tr.Expect(c, &Call{P: file, E: Remove})
+	}
+}
+
+func TestPipeTreeRewatchBatch(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	sub := filepath.Join(dir, "sub")
+	must(os.Mkdir(sub, 0755))
+	c := make(chan EventInfo, buffer)
+	must(tr.Watch(dir, c, Create))
+	must(tr.Watch(sub, c, Write))
+	cases := [...]struct {
+		changes map[string][2]Event
+		err     error
+		dir     Event
+		sub     Event
+	}{
+		// i=0
+		{
+			map[string][2]Event{dir: {Create, Remove}, sub: {Create, Remove}},
+			ErrEventsChanged,
+			Create, Write,
+		},
+		// i=1
+		{
+			map[string][2]Event{dir: {Create, Remove}, filepath.Join(dir, "nope"): {Create, Remove}},
+			ErrNotWatched,
+			Create, Write,
+		},
+		// i=2
+		{
+			map[string][2]Event{dir: {Create, Remove}, sub: {Write, 0}},
+			ErrInvalidEventSet,
+			Create, Write,
+		},
+		// i=3
+		{
+			map[string][2]Event{dir: {Create, Remove}, sub: {Write, Create | Write}},
+			nil,
+			Remove, Create | Write,
+		},
+	}
+	for i, cas := range cases {
+		err := tr.RewatchBatch(c, cas.changes)
+		if we, ok := err.(*WatchError); ok {
+			err = we.Err
+		}
+		if err != cas.err {
+			t.Fatalf("want err=%v; got %v (i=%d)", cas.err, err, i)
+		}
+		if e, _ := tr.Events(dir); e != cas.dir {
+			t.Errorf("want %q watched for %v; got %v (i=%d)", dir, cas.dir, e, i)
+		}
+		if e, _ := tr.Events(sub); e != cas.sub {
+			t.Errorf("want %q watched for %v; got %v (i=%d)", sub, cas.sub, e, i)
+		}
+	}
+}
+
+func TestPipeTreeSnapshot(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	for _, name := range []string{"c", "a", "b"} {
+		must(ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	if _, err := tr.Snapshot(dir); err == nil {
+		t.Fatal("want err!=nil for a path not watched")
+	}
+	must(tr.Watch(filepath.Join(dir, "..."), ch[0], Create))
+	fis, err := tr.Snapshot(dir)
+	if err != nil {
+		t.Fatalf("Snapshot()=%v", err)
+	}
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("want names=%v; got %v", want, names)
+	}
+}
+
+func TestPipeTreeEvents(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 2)
+	defer tr.Close()
+	if e, ok := tr.Events(dir); ok || e != 0 {
+		t.Fatalf("want e=0, ok=false for a path not watched; got %v, %t", e, ok)
+	}
+	must(tr.Watch(filepath.Join(dir, "..."), ch[0], Create))
+	must(tr.Watch(dir, ch[1], Remove))
+	must(tr.Watch(dir, ch[1], Write))
+	cases := [...]struct {
+		stop chan EventInfo
+		e    Event
+		ok   bool
+	}{
+		{nil, Create | Remove | Write, true}, // i=0
+		{ch[0], Remove | Write, true},        // i=1
+		{ch[1], 0, false},                    // i=2
+	}
+	for i, cas := range cases {
+		if cas.stop != nil {
+			tr.Stop(cas.stop)
+		}
+		if e, ok := tr.Events(filepath.Join(dir, "...")); e != cas.e || ok != cas.ok {
+			t.Errorf("want e=%v, ok=%t; got %v, %t (i=%d)", cas.e, cas.ok, e, ok, i)
+		}
+	}
+}
+
+func TestPipeTreeLastEvent(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	if _, ok := tr.LastEvent(dir); ok {
+		t.Fatal("want ok=false for a path not watched")
+	}
+	must(tr.Watch(filepath.Join(dir, "..."), ch[0], Create))
+	start := clk.Now()
+	if last, ok := tr.LastEvent(dir); !ok || !last.Equal(start) {
+		t.Fatalf("want last=%v, ok=true; got %v, %t", start, last, ok)
+	}
+	clk.Advance(time.Hour)
+	must(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644))
+	select {
+	case <-ch[0]:
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	if last, ok := tr.LastEvent(filepath.Join(dir, "...")); !ok || !last.Equal(start.Add(time.Hour)) {
+		t.Errorf("want last=%v, ok=true; got %v, %t", start.Add(time.Hour), last, ok)
+	}
+}
+
+func TestPipeTreeDirect(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	must(os.Mkdir(a, 0755))
+	must(os.Mkdir(b, 0755))
+	must(tr.Watch(a, ch[0], Create))
+	must(tr.Watch(b, ch[0], Create))
+	pa, pb := tr.pipes[ch[0]][a][0], tr.pipes[ch[0]][b][0]
+	if pa.c != nil || pa.st == nil {
+		t.Fatal("want the pipe of a direct")
+	}
+	if pb.c == nil || pb.st != nil {
+		t.Fatal("want the pipe of b not direct, as ch already has a direct one")
+	}
+	w := tr.W()
+	w.ExpectAny([]WCase{
+		create(w, "a/1"),
+		create(w, "b/1"),
+	})
+	last, _ := tr.LastEvent(a)
+	tr.Throttle(pa)
+	if pa.c == nil || pa.st != nil {
+		t.Fatal("want the pipe of a promoted")
+	}
+	if l, _ := tr.LastEvent(a); !l.Equal(last) {
+		t.Errorf("want last=%v kept by the promotion; got %v", last, l)
+	}
+	w.ExpectAny([]WCase{create(w, "a/2")})
+	must(tr.StopPath(ch[0], a))
+	w.ExpectAny([]WCase{
+		{Action: create(w, "a/3").Action},
+		create(w, "b/3"),
+	})
+}
+
+func TestPipeTreeStopOrder(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	must(os.MkdirAll(filepath.Join(dir, "a", "b", "c"), 0755))
+	must(os.MkdirAll(filepath.Join(dir, "a", "d"), 0755))
+	p, err := tr.WatchPipe(filepath.Join(dir, "..."), ch[0], nil, Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unwatched []string
+	SetHooks(Hooks{
+		OnUnwatch: func(path string) {
+			unwatched = append(unwatched, path)
+			// Stands for an event caused by the teardown itself.
+			p.inject(&Call{P: path, E: Create})
+		},
+	})
+	defer SetHooks(Hooks{})
+	tr.Stop(ch[0])
+	if len(unwatched) == 0 {
+		t.Fatal("want at least one path unwatched")
+	}
+	for i := range unwatched {
+		for j := i + 1; j < len(unwatched); j++ {
+			if strings.HasPrefix(unwatched[j], unwatched[i]+sep) {
+				t.Errorf("want %q unwatched before %q; got %v", unwatched[j], unwatched[i], unwatched)
+			}
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	tr.ExpectDry(ch...)
+}
+
+func TestPipeTreeWatchUntil(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	var calls int32
+	fn := func(EventInfo) bool {
+		return atomic.AddInt32(&calls, 1) == 2
+	}
+	if err, ok := tr.WatchUntil(dir, fn).(*WatchError); !ok || err.Err != ErrInvalidEventSet {
+		t.Fatalf("want ErrInvalidEventSet for no events; got %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- tr.WatchUntil(dir, fn, Create)
+	}()
+	deadline := time.After(timeout())
+	for i := 0; ; i++ {
+		must(ioutil.WriteFile(filepath.Join(dir, strconv.Itoa(i)), nil, 0644))
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(10 * time.Millisecond):
+			continue
+		case <-deadline:
+			t.Fatal("timed out")
+		}
+		break
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("want calls=2; got %d", n)
+	}
+	tr.mu.Lock()
+	n := len(tr.pipes)
+	tr.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("want no pipes left; got %d", n)
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "after"), nil, 0644))
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("want no calls after WatchUntil returned; got %d", n)
+	}
+}
+
+func TestPipeTreeWatchUntilRemoved(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	never := func(EventInfo) bool { return false }
+	watched := func() bool {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		return len(tr.pipes) != 0
+	}
+	cases := [...]struct {
+		name string
+		fn   func(path string)
+	}{
+		{"remove", func(path string) { must(os.RemoveAll(path)) }}, // i=0
+		{"reset", func(string) { tr.Reset() }},                     // i=1
+	}
+	for i, cas := range cases {
+		path := filepath.Join(dir, cas.name)
+		must(os.Mkdir(path, 0755))
+		done := make(chan error, 1)
+		go func() {
+			done <- tr.WatchUntil(path, never, Create)
+		}()
+		deadline := time.Now().Add(timeout())
+		for !watched() {
+			if time.Now().After(deadline) {
+				t.Fatalf("want the watchpoint to be set up (i=%d)", i)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		cas.fn(path)
+		select {
+		case err := <-done:
+			if we, ok := err.(*WatchError); !ok || we.Err != ErrNotWatched {
+				t.Fatalf("want err=%v; got %v (i=%d)", ErrNotWatched, err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+}
+
+// unarmedWatcher is a simWatcher, which watches are never armed.
+type unarmedWatcher struct {
+	*simWatcher
+}
+
+func (unarmedWatcher) ready(string) <-chan struct{} {
+	return make(chan struct{})
+}
+
+func TestPipeTreeWatchReadyStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_readystop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := make(chan EventInfo, buffer)
+	tr := newPipeTree(newNonrecursiveTree(unarmedWatcher{newSimWatcher(c)}, c, nil))
+	defer tr.Close()
+	ch := NewChans(1)
+	ready, err := tr.WatchReady(dir, ch[0], Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ready:
+		t.Fatal("want the watchpoint not armed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tr.Stop(ch[0])
+	select {
+	case <-ready:
+	case <-time.After(timeout()):
+		t.Fatal("want the channel closed once the watchpoint was removed")
+	}
+}
+
+func TestPipeTreeStopPath(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	must(os.Mkdir(a, 0755))
+	must(os.Mkdir(b, 0755))
+	must(tr.Watch(filepath.Join(a, "..."), ch[0], Create))
+	must(tr.Watch(b, ch[0], Create))
+	if err := tr.StopPath(ch[0], dir); err == nil {
+		t.Fatal("want err!=nil for a path not watched")
+	}
+	must(tr.StopPath(ch[0], filepath.Join(a, "...")))
+	if _, ok := tr.Events(a); ok {
+		t.Fatal("want a no longer watched")
+	}
+	w := tr.W()
+	w.ExpectAny([]WCase{
+		{Action: create(w, "a/file").Action},
+		create(w, "b/file"),
+	})
+}
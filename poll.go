@@ -0,0 +1,150 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pollInterval is a period between two consecutive scans of a polled path.
+var pollInterval = time.Second
+
+var pollFallback int32 = 1
+
+// SetPollFallback enables or disables falling back to polling for paths which
+// live on filesystems known not to be supported by the underlying watcher.
+// The fallback is enabled by default and affects watchpoints set up after
+// the call only.
+//
+// Currently the fallback is used under Linux only, for paths on procfs, sysfs
+// and debugfs, which do not generate inotify events, and for paths on SMB
+// network shares mounted with cifs or smb3, for which inotify reports only
+// the changes made by the local machine, while the changes made by other
+// clients of the share go unnoticed. Notify detects them by the filesystem
+// magic number reported by statfs(2) for the watched path; filesystems
+// mounted below it are not detected. Other network filesystems, e.g. NFS,
+// are watched with inotify as usual.
+//
+// Polled paths are scanned every second and compared against the previous
+// state. Since the pseudo-files usually report neither size nor modification
+// time, mostly Create and Remove events are delivered for them; Rename is
+// never reported. Scanning a large directory tree on a network share is
+// costly, so it is better to watch the directories of interest only.
+func SetPollFallback(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&pollFallback, v)
+}
+
+var limitFallback int32
+
+// SetLimitFallback enables or disables falling back to polling for paths which
+// cannot be watched, because the underlying watcher ran out of resources, e.g.
+// when inotify_init(2) fails with EMFILE once the process is out of file
+// descriptors or the user is out of inotify instances. The fallback is disabled
+// by default, in which case such watchpoints fail with a *WatchError wrapping
+// ErrResourceLimit. It affects watchpoints set up after the call only.
+func SetLimitFallback(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&limitFallback, v)
+}
+
+// limited reports whether err tells the watcher ran out of resources.
+func limited(err error) bool {
+	if e, ok := err.(*WatchError); ok {
+		err = e.Err
+	}
+	return err == ErrResourceLimit
+}
+
+// poller periodically rescans a path and reports changes it finds.
+type poller struct {
+	mu   sync.Mutex // protects e
+	e    Event
+	s    *snapshot
+	c    chan<- EventInfo
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once // guards closing stop
+}
+
+// newPoller starts polling the given path every d, reporting changes to c.
+func newPoller(path string, isrec bool, e Event, c chan<- EventInfo, d time.Duration) (*poller, error) {
+	s, err := newSnapshot(path, isrec)
+	if err != nil {
+		return nil, err
+	}
+	p := &poller{
+		e:    e,
+		s:    s,
+		c:    c,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	// The ticker is created before the loop starts, so that the polling
+	// starts right away from the time newPoller was called at.
+	go p.loop(newTicker(d))
+	return p, nil
+}
+
+func (p *poller) loop(t *ticker) {
+	defer func() {
+		t.Stop()
+		close(p.done)
+	}()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+		}
+		diff, err := p.s.update(p.s.root)
+		if err != nil {
+			dbgprintf("poller: rescanning %q failed: %v", p.s.root, err)
+			continue
+		}
+		p.mu.Lock()
+		e := p.e
+		p.mu.Unlock()
+		for _, ei := range diff {
+			if ei.Event()&e == 0 {
+				continue
+			}
+			select {
+			case p.c <- ei:
+			default: // Drop event if receiver is too slow
+				dropped(ei)
+			}
+		}
+	}
+}
+
+// Add expands the set of events reported by p.
+func (p *poller) Add(e Event) {
+	p.mu.Lock()
+	p.e |= e
+	p.mu.Unlock()
+}
+
+// Set replaces the set of events reported by p.
+func (p *poller) Set(e Event) {
+	p.mu.Lock()
+	p.e = e
+	p.mu.Unlock()
+}
+
+// Close stops polling. When Close returns, no more events are reported.
+// It is safe to call it more than once.
+func (p *poller) Close() {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+}
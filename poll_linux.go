@@ -0,0 +1,39 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build linux
+
+package notify
+
+import "golang.org/x/sys/unix"
+
+// Magic numbers of the SMB filesystems, as found in linux/magic.h. They are
+// not defined by all versions of golang.org/x/sys/unix.
+const (
+	cifsMagic = 0xff534d42 // CIFS_SUPER_MAGIC
+	smb2Magic = 0xfe534d42 // SMB2_SUPER_MAGIC
+	smbMagic  = 0x517b     // SMB_SUPER_MAGIC
+)
+
+// nopoll lists magic numbers of filesystems, which do not generate inotify
+// events, or generate them for the local changes only.
+var nopoll = map[int64]struct{}{
+	unix.PROC_SUPER_MAGIC: {},
+	unix.SYSFS_MAGIC:      {},
+	unix.DEBUGFS_MAGIC:    {},
+	cifsMagic:             {},
+	smb2Magic:             {},
+	smbMagic:              {},
+}
+
+// needsPoll reports whether path lives on a filesystem, which changes can be
+// observed by polling only.
+func needsPoll(path string) bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return false
+	}
+	_, ok := nopoll[int64(st.Type)]
+	return ok
+}
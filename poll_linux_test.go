@@ -0,0 +1,24 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build linux
+
+package notify
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNeedsPoll(t *testing.T) {
+	if _, err := os.Stat("/proc/self"); err != nil {
+		t.Skip("procfs is not mounted")
+	}
+	if !needsPoll("/proc/self") {
+		t.Error("want needsPoll=true for /proc/self")
+	}
+	if needsPoll(os.TempDir()) {
+		t.Errorf("want needsPoll=false for %q", os.TempDir())
+	}
+}
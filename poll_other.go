@@ -0,0 +1,13 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !linux
+
+package notify
+
+// needsPoll reports whether path lives on a filesystem, which changes can be
+// observed by polling only.
+func needsPoll(string) bool {
+	return false
+}
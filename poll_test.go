@@ -0,0 +1,114 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPoller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_poller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := make(chan EventInfo, buffer)
+	p, err := newPoller(dir, false, Create, c, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "a"), nil, 0644))
+	select {
+	case ei := <-c:
+		if err := EqualEventInfo(&Call{P: filepath.Join(dir, "a"), E: Create}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	p.Close()
+	p.Close()
+	must(ioutil.WriteFile(filepath.Join(dir, "b"), nil, 0644))
+	time.Sleep(50 * time.Millisecond)
+	if len(c) != 0 {
+		t.Fatalf("want no events after Close; got %d", len(c))
+	}
+}
+
+func TestPollerClock(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	dir, err := ioutil.TempDir("", "notify_poller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := make(chan EventInfo, buffer)
+	p, err := newPoller(dir, false, Create, c, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	must(ioutil.WriteFile(filepath.Join(dir, "a"), nil, 0644))
+	time.Sleep(50 * time.Millisecond)
+	if len(c) != 0 {
+		t.Fatalf("want no events before the clock ticked; got %d", len(c))
+	}
+	clk.Advance(time.Hour)
+	select {
+	case ei := <-c:
+		if err := EqualEventInfo(&Call{P: filepath.Join(dir, "a"), E: Create}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+}
+
+// limitTree is a tree, which is always out of resources.
+type limitTree struct{}
+
+func (limitTree) Watch(path string, _ chan<- EventInfo, _ ...Event) error {
+	return &WatchError{Op: "watch", Path: path, Err: ErrResourceLimit}
+}
+
+func (limitTree) Stop(chan<- EventInfo) {}
+func (limitTree) Reset()                {}
+func (limitTree) Close() error          { return nil }
+
+func TestLimitFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tr := newPipeTree(limitTree{})
+	defer tr.Close()
+	c := make(chan EventInfo, buffer)
+	if err := tr.Watch(dir, c, Create); !limited(err) {
+		t.Fatalf("want err=%v; got %v", ErrResourceLimit, err)
+	}
+	SetLimitFallback(true)
+	defer SetLimitFallback(false)
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+	if err := tr.Watch(dir, c, Create); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "a"), nil, 0644))
+	select {
+	case ei := <-c:
+		if err := EqualEventInfo(&Call{P: filepath.Join(dir, "a"), E: Create}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+}
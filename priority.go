@@ -0,0 +1,109 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "sync"
+
+// queued is an event waiting in an outbox together with the priority of
+// the watchpoint it was reported for.
+type queued struct {
+	ei    EventInfo
+	level int
+}
+
+// outbox is a bounded queue of events for a single user channel, shared by
+// all its prioritized pipes. Events are delivered in the order they were
+// queued. When the queue is full, the oldest of the events with the lowest
+// priority is dropped to make room for a more important one.
+type outbox struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	q      []queued
+	size   int
+	closed bool
+	dst    chan<- EventInfo
+	act    *activity // counts the events being delivered
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// newOutbox creates an outbox queueing at most size events for dst. The
+// events being delivered are counted with act.
+func newOutbox(dst chan<- EventInfo, size int, act *activity) *outbox {
+	o := &outbox{
+		size: size,
+		dst:  dst,
+		act:  act,
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	o.cond = sync.NewCond(&o.mu)
+	go o.loop()
+	return o
+}
+
+func (o *outbox) loop() {
+	defer close(o.done)
+	for {
+		o.mu.Lock()
+		for len(o.q) == 0 && !o.closed {
+			o.cond.Wait()
+		}
+		if o.closed {
+			o.mu.Unlock()
+			return
+		}
+		ei := o.q[0].ei
+		o.q = o.q[1:]
+		o.act.enter()
+		o.mu.Unlock()
+		select {
+		case o.dst <- ei:
+			o.act.leave()
+		case <-o.quit:
+			o.act.leave()
+			return
+		}
+	}
+}
+
+// push queues ei reported for a watchpoint with the given priority. If the
+// queue is full, either the oldest of the less important events or ei itself
+// is dropped.
+func (o *outbox) push(ei EventInfo, level int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+	if len(o.q) == o.size {
+		j := -1
+		for i := range o.q {
+			if o.q[i].level < level && (j == -1 || o.q[i].level < o.q[j].level) {
+				j = i
+			}
+		}
+		if j == -1 {
+			dropped(ei)
+			return
+		}
+		dropped(o.q[j].ei)
+		o.q = append(o.q[:j], o.q[j+1:]...)
+	}
+	o.q = append(o.q, queued{ei: ei, level: level})
+	o.cond.Signal()
+}
+
+// close discards all queued events and stops the outbox. When close returns,
+// no more events are delivered to dst.
+func (o *outbox) close() {
+	o.mu.Lock()
+	o.closed = true
+	o.q = nil
+	o.cond.Signal()
+	o.mu.Unlock()
+	close(o.quit)
+	<-o.done
+}
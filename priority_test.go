@@ -0,0 +1,59 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutbox(t *testing.T) {
+	c := make(chan EventInfo)
+	out := newOutbox(c, 2, nil)
+	defer out.close()
+	for _, path := range []string{"/log/1", "/log/2", "/log/3", "/log/4", "/log/5"} {
+		out.push(&synthetic{path: path, event: Write}, 1)
+	}
+	out.push(&synthetic{path: "/ctl/1", event: Write}, 2)
+	out.push(&synthetic{path: "/ctl/2", event: Write}, 2)
+	// The outbox may have taken the very first event off the queue before
+	// the others were pushed, so at most one low priority event is delivered.
+	var got []string
+Loop:
+	for len(got) < 4 {
+		select {
+		case ei := <-c:
+			got = append(got, ei.Path())
+		case <-time.After(100 * time.Millisecond):
+			break Loop
+		}
+	}
+	switch len(got) {
+	case 2:
+		if got[0] != "/ctl/1" || got[1] != "/ctl/2" {
+			t.Errorf("want [/ctl/1 /ctl/2]; got %v", got)
+		}
+	case 3:
+		if got[0] != "/log/1" || got[1] != "/ctl/1" || got[2] != "/ctl/2" {
+			t.Errorf("want [/log/1 /ctl/1 /ctl/2]; got %v", got)
+		}
+	default:
+		t.Errorf("want 2 or 3 events; got %v", got)
+	}
+}
+
+func TestOutboxClose(t *testing.T) {
+	c := make(chan EventInfo)
+	out := newOutbox(c, 2, nil)
+	out.push(&synthetic{path: "/a", event: Write}, 1)
+	out.push(&synthetic{path: "/b", event: Write}, 1)
+	out.close()
+	out.push(&synthetic{path: "/c", event: Write}, 1)
+	select {
+	case ei := <-c:
+		t.Fatalf("want no events after close; got %v", ei)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "sync"
+
+// WithProgress makes notify report the progress of setting up the watchpoint,
+// which for a recursive watchpoint on a large tree may take a while, e.g. to
+// draw a progress bar. The fn is called every time a watch for the path or
+// one of the directories under it was set up by the underlying watcher, with
+// the number of the directories registered so far and the total number of
+// the directories to register.
+//
+// The total is estimated by walking the tree before the watchpoint is set up,
+// so it grows when new directories are found in the meantime. Directories,
+// which are already watched by other watchpoints, are not registered again;
+// once the watchpoint is set up, the last call reports registered equal to
+// total. Recursive watchers register the whole tree at once, so under them fn
+// is called once.
+//
+// Like Hooks, fn is called synchronously while notify holds its internal
+// locks, so it is expected to return quickly and must not call back into
+// notify.
+func WithProgress(fn func(path string, registered, total int)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// tracker counts the directories under dir registered while a watchpoint is
+// set up.
+type tracker struct {
+	dir   string
+	fn    func(path string, registered, total int)
+	total int
+	seen  map[string]struct{}
+}
+
+var trackers struct {
+	sync.Mutex
+	m map[*tracker]struct{}
+}
+
+// newTracker starts tracking the registrations under dir, expecting total of
+// them.
+func newTracker(dir string, total int, fn func(string, int, int)) *tracker {
+	tr := &tracker{
+		dir:   dir,
+		fn:    fn,
+		total: total,
+		seen:  make(map[string]struct{}),
+	}
+	trackers.Lock()
+	if trackers.m == nil {
+		trackers.m = make(map[*tracker]struct{})
+	}
+	trackers.m[tr] = struct{}{}
+	trackers.Unlock()
+	return tr
+}
+
+// done stops tracking and reports the final progress, unless the last
+// registration already did.
+func (tr *tracker) done() {
+	trackers.Lock()
+	defer trackers.Unlock()
+	delete(trackers.m, tr)
+	if n := len(tr.seen); n != tr.total || n == 0 {
+		tr.fn(tr.dir, n, n)
+	}
+}
+
+// track reports the watch for path was set up to every tracker of a directory
+// containing the path.
+func track(path string) {
+	trackers.Lock()
+	defer trackers.Unlock()
+	for tr := range trackers.m {
+		if _, ok := relpath(tr.dir, path); !ok && path != tr.dir {
+			continue
+		}
+		if _, ok := tr.seen[path]; ok {
+			continue
+		}
+		tr.seen[path] = struct{}{}
+		if n := len(tr.seen); n > tr.total {
+			tr.total = n
+		}
+		tr.fn(path, len(tr.seen), tr.total)
+	}
+}
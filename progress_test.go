@@ -0,0 +1,65 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithProgress(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	must(os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+	must(os.MkdirAll(filepath.Join(dir, "c"), 0755))
+	type call struct {
+		path              string
+		registered, total int
+	}
+	var calls []call
+	var o options
+	WithProgress(func(path string, registered, total int) {
+		calls = append(calls, call{path, registered, total})
+	})(&o)
+	if err := o.watch(tr.pipeTree, filepath.Join(dir, "..."), ch[0], Create); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) == 0 {
+		t.Fatal("want progress to be reported")
+	}
+	last := calls[len(calls)-1]
+	if last.registered != last.total {
+		t.Errorf("want registered=total for the last call; got %+v", last)
+	}
+	seen := make(map[string]bool)
+	for i, c := range calls {
+		if c.registered != i+1 && i != len(calls)-1 {
+			t.Errorf("want registered=%d; got %+v (i=%d)", i+1, c, i)
+		}
+		if c.registered > c.total {
+			t.Errorf("want registered<=total; got %+v (i=%d)", c, i)
+		}
+		seen[c.path] = true
+	}
+	if _, native := watcherOf(tr.tree).(recursiveWatcher); !native {
+		if last.total != 4 {
+			t.Errorf("want total=4; got %d", last.total)
+		}
+		for _, path := range []string{dir, filepath.Join(dir, "a", "b"), filepath.Join(dir, "c")} {
+			if !seen[path] {
+				t.Errorf("want progress reported for %q", path)
+			}
+		}
+	}
+	// Directories already watched are not registered again.
+	calls = nil
+	if err := o.watch(tr.pipeTree, filepath.Join(dir, "a", "..."), ch[0], Create); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].registered != calls[0].total {
+		t.Errorf("want single final call; got %+v", calls)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// pulse gives a stage, which coalesces all events reported within interval
+// into a single event for dir. The event is delivered once the interval since
+// the first of the coalesced events elapses, its value is the union of their
+// values. Calling flush delivers the coalesced events right away.
+func pulse(dir string, interval time.Duration) (s stage, flush func()) {
+	var fl flushers
+	s = func(next handler) handler {
+		var mu sync.Mutex
+		var pending Event
+		var t Timer
+		fire := func() {
+			mu.Lock()
+			e := pending
+			pending, t = 0, nil
+			mu.Unlock()
+			if e != 0 {
+				next(&synthetic{path: dir, event: e, dir: true})
+			}
+		}
+		fl.add(func() {
+			mu.Lock()
+			if t != nil {
+				t.Stop()
+			}
+			mu.Unlock()
+			fire()
+		})
+		return func(ei EventInfo) {
+			mu.Lock()
+			if t == nil {
+				t = afterFunc(interval, fire)
+			}
+			pending |= ei.Event()
+			mu.Unlock()
+		}
+	}
+	return s, fl.flush
+}
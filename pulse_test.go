@@ -0,0 +1,45 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPulse(t *testing.T) {
+	ch := NewChans(1)
+	s, _ := pulse("/a", 50*time.Millisecond)
+	p := newPipe(ch[0], s)
+	defer p.stop()
+	p.c <- &Call{P: "/a/b", E: Create}
+	p.c <- &Call{P: "/a/b", E: Write}
+	p.c <- &Call{P: "/a/c", E: Remove}
+	select {
+	case ei := <-ch[0]:
+		if err := EqualEventInfo(&Call{P: "/a", E: Create | Write | Remove}, ei); err != nil {
+			t.Fatal(err)
+		}
+		if dir, _ := ei.(isDirer).isDir(); !dir {
+			t.Error("want pulse event for a directory")
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	select {
+	case ei := <-ch[0]:
+		t.Fatalf("want single event per interval; got %v", ei)
+	case <-time.After(100 * time.Millisecond):
+	}
+	p.c <- &Call{P: "/a/d", E: Create}
+	select {
+	case ei := <-ch[0]:
+		if err := EqualEventInfo(&Call{P: "/a", E: Create}, ei); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+}
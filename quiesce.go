@@ -0,0 +1,175 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// quiesceInterval is the time between the checks made by Quiesce.
+var quiesceInterval = 10 * time.Millisecond
+
+// activity counts the events being processed by a tree, its watcher and its
+// pipes, and the events processed so far. A nil activity counts nothing.
+type activity struct {
+	inflight, processed int64
+}
+
+// enter accounts an event being picked up for processing.
+func (a *activity) enter() {
+	if a != nil {
+		atomic.AddInt64(&a.inflight, 1)
+		atomic.AddInt64(&a.processed, 1)
+	}
+}
+
+// leave accounts an event being done with.
+func (a *activity) leave() {
+	if a != nil {
+		atomic.AddInt64(&a.inflight, -1)
+	}
+}
+
+// busy reports whether any event is being processed.
+func (a *activity) busy() bool {
+	return atomic.LoadInt64(&a.inflight) != 0
+}
+
+// total gives the number of the events processed so far.
+func (a *activity) total() int64 {
+	return atomic.LoadInt64(&a.processed)
+}
+
+// activityOf gives the activity of the tree, nil if t is not backed by
+// a watcher.
+func activityOf(t tree) *activity {
+	switch t := t.(type) {
+	case *nonrecursiveTree:
+		return t.act
+	case *recursiveTree:
+		return t.act
+	}
+	return nil
+}
+
+// accounter is implemented by the watchers, which process the events they
+// read before they report them.
+type accounter interface {
+	// account makes the watcher count the events it processes with a. It is
+	// called before any watch is set up.
+	account(a *activity)
+}
+
+// accountWith makes w count the events it processes with a, if it is able to.
+func accountWith(w watcher, a *activity) {
+	if ac, ok := w.(accounter); ok {
+		ac.account(a)
+	}
+}
+
+// account forwards the call to the watcher w wraps, if it is an accounter.
+func (w hookWatcher) account(a *activity) {
+	if ac, ok := w.watcher.(accounter); ok {
+		ac.account(a)
+	}
+}
+
+// flusher is implemented by the watchers, which are able to tell whether
+// the changes made so far are all reported.
+type flusher interface {
+	// flush makes the watcher report the changes it queued, it reports
+	// whether some of them may be still pending.
+	flush() bool
+}
+
+// flush forwards the call to the watcher w wraps, if it is a flusher.
+func (w hookWatcher) flush() bool {
+	f, ok := w.watcher.(flusher)
+	return ok && f.flush()
+}
+
+// Quiesce blocks until notify is done with all the changes made before
+// the call, and c was drained by its receiver, or until ctx is done, whatever
+// happens first. It allows for processing all pending changes before going on,
+// e.g. in integration tests, instead of sleeping for an arbitrary time after
+// making changes to the filesystem.
+//
+// Quiesce flushes the events queued by the underlying watcher, like FSEvents
+// streams or the inotify queue, and then waits until no events are buffered
+// nor being processed by any of the watchpoints and c is empty. The watchers,
+// which cannot be flushed, e.g. kqueue or ReadDirectoryChangesW, may still
+// report a change made before the call after Quiesce returned. Since c needs
+// to be drained, Quiesce must be called from other goroutine than the one
+// receiving from it.
+//
+// Quiesce returns ctx.Err() if ctx is done first.
+func Quiesce(ctx context.Context, c chan<- EventInfo) error {
+	return defaultTree.Quiesce(ctx, c)
+}
+
+// Quiesce waits until t and c are quiescent, as found by two checks made
+// quiesceInterval apart, with no events processed in between.
+func (t *pipeTree) Quiesce(ctx context.Context, c chan<- EventInfo) error {
+	tk := newTicker(quiesceInterval)
+	defer tk.Stop()
+	var last int64 = -1
+	for {
+		n := t.act.total()
+		if t.quiet(c) {
+			if n == last {
+				return nil
+			}
+			last = n
+		} else {
+			last = -1
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tk.C:
+		}
+	}
+}
+
+// quiet reports whether there are no events pending for delivery to c.
+func (t *pipeTree) quiet(c chan<- EventInfo) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if f, ok := watcherOf(t.tree).(flusher); ok && f.flush() {
+		return false
+	}
+	if q := queueOf(t.tree); q != nil && q.len() != 0 {
+		return false
+	}
+	if t, ok := t.tree.(*nonrecursiveTree); ok && len(t.rec) != 0 {
+		return false
+	}
+	if t.act.busy() || len(c) != 0 {
+		return false
+	}
+	for _, m := range t.pipes {
+		for _, pipes := range m {
+			for _, p := range pipes {
+				if len(p.c) != 0 {
+					return false
+				}
+			}
+		}
+	}
+	if lim := t.rates[c]; lim != nil && lim.queued() != 0 {
+		return false
+	}
+	if o := t.outs[c]; o != nil {
+		o.mu.Lock()
+		n := len(o.q)
+		o.mu.Unlock()
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
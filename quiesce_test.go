@@ -0,0 +1,50 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQuiesceUndrained(t *testing.T) {
+	tr := newPipeTree(newTree())
+	defer tr.Close()
+	c := make(chan EventInfo, 1)
+	c <- &Call{P: "/a", E: Create}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*quiesceInterval)
+	defer cancel()
+	if err := tr.Quiesce(ctx, c); err != context.DeadlineExceeded {
+		t.Fatalf("want err=%v; got %v", context.DeadlineExceeded, err)
+	}
+	<-c
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tr.Quiesce(ctx, c); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+}
+
+func TestQuiesceOtherTree(t *testing.T) {
+	busy := newPipeTree(newTree())
+	defer busy.Close()
+	tr := newPipeTree(newTree())
+	defer tr.Close()
+	// An event being processed by the other tree does not keep tr busy.
+	busy.act.enter()
+	defer busy.act.leave()
+	c := make(chan EventInfo, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tr.Quiesce(ctx, c); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 5*quiesceInterval)
+	defer cancel()
+	if err := busy.Quiesce(ctx, c); err != context.DeadlineExceeded {
+		t.Fatalf("want err=%v; got %v", context.DeadlineExceeded, err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// rateSize is the maximum number of distinct paths a limiter keeps events for.
+var rateSize = 4096
+
+// SetGlobalRate caps the number of events delivered to c per second at
+// perSecond, regardless of how many watchpoints deliver events to c, e.g. to
+// protect a fragile consumer from event storms. The events reported faster are
+// queued and delivered one at a time at the capped rate, the queued events for
+// the same path are coalesced, so that only the latest of them is delivered.
+// The queue keeps events for a limited number of distinct paths, the events
+// for paths not fitting into it are dropped.
+//
+// SetGlobalRate affects both the watchpoints c already has and the ones set
+// up later, until Stop is called for c. Calling it again changes the rate,
+// while perSecond equal to 0 removes the cap, dropping the events still
+// queued. Events of watchpoints set up with WithPriority are capped as well,
+// but they are delivered in the order they were queued.
+func SetGlobalRate(c chan<- EventInfo, perSecond int) {
+	defaultTree.SetGlobalRate(c, perSecond)
+}
+
+// SetGlobalRate caps the rate of events delivered to c by all its pipes.
+func (t *pipeTree) SetGlobalRate(c chan<- EventInfo, perSecond int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lim, ok := t.rates[c]
+	switch {
+	case perSecond <= 0 && !ok:
+		return
+	case perSecond <= 0:
+		delete(t.rates, c)
+		lim.close()
+		lim = nil
+	case ok:
+		lim.setRate(perSecond)
+		return
+	default:
+		lim = newLimiter(c, perSecond, t.act)
+		t.rates[c] = lim
+	}
+	for _, pipes := range t.pipes[c] {
+		for _, p := range pipes {
+			if lim != nil {
+				t.promote(p)
+			}
+			p.mu.Lock()
+			p.lim = lim
+			p.mu.Unlock()
+		}
+	}
+}
+
+// limiter delivers events to a single user channel at a capped rate. Queued
+// events are coalesced by their paths.
+type limiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	interval time.Duration
+	order    []string             // paths in the order they were queued
+	pending  map[string]EventInfo // latest event queued for a path
+	closed   bool
+	dst      chan<- EventInfo
+	act      *activity // counts the events being delivered
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// newLimiter creates a limiter delivering at most perSecond events to dst
+// every second. The events being delivered are counted with act.
+func newLimiter(dst chan<- EventInfo, perSecond int, act *activity) *limiter {
+	lim := &limiter{
+		interval: time.Second / time.Duration(perSecond),
+		pending:  make(map[string]EventInfo),
+		dst:      dst,
+		act:      act,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	lim.cond = sync.NewCond(&lim.mu)
+	go lim.loop()
+	return lim
+}
+
+func (lim *limiter) loop() {
+	defer close(lim.done)
+	for {
+		lim.mu.Lock()
+		for len(lim.order) == 0 && !lim.closed {
+			lim.cond.Wait()
+		}
+		if lim.closed {
+			lim.mu.Unlock()
+			return
+		}
+		path := lim.order[0]
+		ei := lim.pending[path]
+		lim.order = lim.order[1:]
+		delete(lim.pending, path)
+		d := lim.interval
+		lim.act.enter()
+		lim.mu.Unlock()
+		select {
+		case lim.dst <- ei:
+			lim.act.leave()
+		case <-lim.quit:
+			lim.act.leave()
+			return
+		}
+		next := make(chan struct{})
+		t := afterFunc(d, func() { close(next) })
+		select {
+		case <-next:
+		case <-lim.quit:
+			t.Stop()
+			return
+		}
+	}
+}
+
+// push queues ei, replacing the event queued for its path.
+func (lim *limiter) push(ei EventInfo) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	if lim.closed {
+		return
+	}
+	path := ei.Path()
+	if _, ok := lim.pending[path]; !ok {
+		if len(lim.order) == rateSize {
+			dropped(ei)
+			return
+		}
+		lim.order = append(lim.order, path)
+	}
+	lim.pending[path] = ei
+	lim.cond.Signal()
+}
+
+// queued gives the number of events waiting to be delivered.
+func (lim *limiter) queued() int {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return len(lim.order)
+}
+
+// setRate changes the rate of lim, starting with the next event.
+func (lim *limiter) setRate(perSecond int) {
+	lim.mu.Lock()
+	lim.interval = time.Second / time.Duration(perSecond)
+	lim.mu.Unlock()
+}
+
+// close discards all queued events and stops the limiter. When close returns,
+// no more events are delivered to dst.
+func (lim *limiter) close() {
+	lim.mu.Lock()
+	lim.closed = true
+	lim.order, lim.pending = nil, nil
+	lim.cond.Signal()
+	lim.mu.Unlock()
+	close(lim.quit)
+	<-lim.done
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	c := make(chan EventInfo)
+	lim := newLimiter(c, 20, nil)
+	defer lim.close()
+	lim.push(&Call{P: "/a", E: Create})
+	// Wait for the limiter to block on c, so that all the other events are
+	// queued.
+	for lim.queued() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	lim.push(&Call{P: "/b", E: Create})
+	lim.push(&Call{P: "/c", E: Create})
+	lim.push(&Call{P: "/b", E: Write})
+	want := []Call{
+		{P: "/a", E: Create},
+		{P: "/b", E: Write},
+		{P: "/c", E: Create},
+	}
+	var last time.Time
+	for i := range want {
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&want[i], ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+		if d := time.Since(last); i != 0 && d < 40*time.Millisecond {
+			t.Errorf("want at most 20 events per second; got %v between (i=%d)", d, i)
+		}
+		last = time.Now()
+	}
+	if n := lim.queued(); n != 0 {
+		t.Errorf("want no events queued; got %d", n)
+	}
+}
+
+func TestPipeTreeSetGlobalRate(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo, buffer)
+	tr.SetGlobalRate(c, 10)
+	must(tr.Watch(dir, c, Create))
+	for _, name := range []string{"a", "b"} {
+		must(ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-c:
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Errorf("want the events delivered at most 10 per second; got both within %v", d)
+	}
+	tr.Stop(c)
+	tr.mu.Lock()
+	n := len(tr.rates)
+	tr.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("want the rate unregistered by Stop; got %d limiters", n)
+	}
+}
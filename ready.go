@@ -0,0 +1,72 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+// readier is implemented by watchers, which start delivering events some time
+// after a watch was set up, e.g. FSEvents, which replays historical events
+// first.
+type readier interface {
+	// ready gives a channel, which is closed once the watch for the path
+	// delivers events for new changes. It gives nil if the watch is ready.
+	ready(path string) <-chan struct{}
+}
+
+// ready implements readier interface for the wrapped watcher, it gives nil
+// if the watcher does not implement it.
+func (w hookWatcher) ready(path string) <-chan struct{} {
+	if r, ok := w.watcher.(readier); ok {
+		return r.ready(kernelPath(path))
+	}
+	return nil
+}
+
+// Ready gives a channel, which is closed once the underlying watch for
+// the path delivers events for new changes, nil if it already does.
+func (t *pipeTree) Ready(path string) <-chan struct{} {
+	key := pathkey(path)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.pipes {
+		for _, p := range m[key] {
+			if p.poll != nil {
+				return nil
+			}
+		}
+	}
+	if r, ok := watcherOf(t.tree).(readier); ok {
+		return r.ready(key)
+	}
+	return nil
+}
+
+// WatchReady watches the path like Watch does and gives a channel, which is
+// closed once the watchpoint is armed or its pipe is stopped, whichever comes
+// first.
+func (t *pipeTree) WatchReady(path string, c chan<- EventInfo, events ...Event) (<-chan struct{}, error) {
+	p, err := t.WatchPipe(path, c, nil, events...)
+	if err != nil {
+		return nil, err
+	}
+	ready := make(chan struct{})
+	if p == nil {
+		close(ready)
+		return ready, nil
+	}
+	t.mu.Lock()
+	t.spec(p, path, 0, nil)
+	t.mu.Unlock()
+	if armed := t.Ready(path); armed != nil {
+		go func() {
+			select {
+			case <-armed:
+			case <-p.quit:
+			}
+			close(ready)
+		}()
+	} else {
+		close(ready)
+	}
+	return ready, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sort"
+	"strings"
+)
+
+// ReconcileError aggregates the errors Reconcile or RewatchAll failed with,
+// one per path, sorted by the path.
+type ReconcileError []*WatchError
+
+// Error implements error interface.
+func (e ReconcileError) Error() string {
+	s := make([]string, 0, len(e))
+	for _, err := range e {
+		s = append(s, err.Error())
+	}
+	return strings.Join(s, "; ")
+}
+
+func (e ReconcileError) Len() int           { return len(e) }
+func (e ReconcileError) Less(i, j int) bool { return e[i].Path < e[j].Path }
+func (e ReconcileError) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// Reconcile makes the paths watched by c match the desired ones, which map
+// every path to the events it should be watched for. Recursive paths are given
+// with the "..." suffix, like for Watch. Watchpoints of c for the paths which
+// are no longer desired are removed, the ones watched for different events
+// are replaced and the missing ones are set up, all under a single lock, so
+// that neither Watch nor Stop calls for c interleave with the update. A path
+// watched for the same events is left untouched, together with the options it
+// was set up with, replaced watchpoints are set up without any options.
+//
+// Reconcile applies as much of the update as it can. It fails with
+// a ReconcileError listing the paths, which could not be set up; such paths
+// keep their previous watchpoints, if any. Calling Reconcile with an empty map
+// is equivalent to calling Stop.
+func Reconcile(c chan<- EventInfo, desired map[string]Event) error {
+	return defaultTree.Reconcile(c, desired)
+}
+
+// Reconcile implements Reconcile for the pipe tree.
+func (t *pipeTree) Reconcile(c chan<- EventInfo, desired map[string]Event) error {
+	return t.reconcile(c, desired, nil, nil)
+}
+
+// reconcile works like Reconcile, but it leaves the keep pipe in place and
+// reports the given errors together with its own ones.
+func (t *pipeTree) reconcile(c chan<- EventInfo, desired map[string]Event, keep *pipe, errs ReconcileError) error {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	fail := func(path string, err error) {
+		we, ok := err.(*WatchError)
+		if !ok {
+			we = &WatchError{Op: "reconcile", Path: path, Err: err}
+		}
+		errs = append(errs, we)
+	}
+	type want struct {
+		path string
+		e    Event
+		rec  bool
+	}
+	wants := make(map[string]want, len(desired))
+	for path, e := range desired {
+		key, isrec, err := cleanpath(path)
+		if err != nil {
+			fail(path, err)
+			continue
+		}
+		w := wants[key]
+		w.e |= e
+		w.rec = w.rec || isrec
+		w.path = key
+		if w.rec {
+			w.path = key + sep + "..."
+		}
+		wants[key] = w
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	defer t.nextGeneration(c)
+	var stale []string
+	for key, pipes := range t.pipes[c] {
+		pipes = without(pipes, keep)
+		w, ok := wants[key]
+		switch {
+		case !ok && len(pipes) == 0:
+			continue
+		case !ok:
+			stale = append(stale, key)
+			continue
+		}
+		var e Event
+		var rec bool
+		for _, p := range pipes {
+			e, rec = e|p.events, rec || p.rec
+		}
+		if e == w.e && rec == w.rec {
+			delete(wants, key)
+		}
+	}
+	// New watchpoints are set up before the stale ones are removed, so that
+	// a failure leaves the previous watchpoint of a path in place.
+	var replaced [][]*pipe
+	for key, w := range wants {
+		p := t.newPipe(c)
+		if err := t.watch(p, w.path, w.e); err != nil {
+			p.stop()
+			fail(w.path, err)
+			continue
+		}
+		t.spec(p, w.path, 0, nil)
+		if old := without(t.pipes[c][key], keep); len(old) != 0 {
+			replaced = append(replaced, old)
+			if len(old) == len(t.pipes[c][key]) {
+				delete(t.pipes[c], key)
+			} else {
+				t.pipes[c][key] = []*pipe{keep}
+			}
+		}
+		t.add(c, key, p)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(stale)))
+	for _, key := range stale {
+		old := without(t.pipes[c][key], keep)
+		if len(old) == len(t.pipes[c][key]) {
+			t.del(c, key)
+		} else {
+			t.pipes[c][key] = []*pipe{keep}
+		}
+		replaced = append(replaced, old)
+	}
+	for _, pipes := range replaced {
+		for _, p := range pipes {
+			p.halt()
+		}
+	}
+	for _, pipes := range replaced {
+		for _, p := range pipes {
+			t.unwatch(p)
+			p.stop()
+		}
+	}
+	if len(errs) != 0 {
+		sort.Sort(errs)
+		return errs
+	}
+	return nil
+}
+
+// without gives the pipes other than p.
+func without(pipes []*pipe, p *pipe) []*pipe {
+	for i := range pipes {
+		if pipes[i] == p {
+			return append(append([]*pipe(nil), pipes[:i]...), pipes[i+1:]...)
+		}
+	}
+	return pipes
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		must(os.Mkdir(filepath.Join(dir, name), 0755))
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+	cases := [...]struct {
+		desired map[string]Event
+		want    map[string]Event
+		failed  []string
+	}{
+		// i=0
+		{
+			desired: map[string]Event{path("a"): Create, path("b/..."): Remove},
+			want:    map[string]Event{path("a"): Create, path("b"): Remove},
+		},
+		// i=1
+		{
+			desired: map[string]Event{path("a"): Create | Write, path("c"): Rename},
+			want:    map[string]Event{path("a"): Create | Write, path("c"): Rename},
+		},
+		// i=2
+		{
+			desired: map[string]Event{path("a"): Create | Write, path("d"): Create},
+			want:    map[string]Event{path("a"): Create | Write},
+			failed:  []string{path("d")},
+		},
+		// i=3
+		{
+			desired: map[string]Event{},
+			want:    map[string]Event{},
+		},
+	}
+	for i, cas := range cases {
+		err := tr.Reconcile(ch[0], cas.desired)
+		var failed []string
+		if errs, ok := err.(ReconcileError); ok {
+			for _, err := range errs {
+				failed = append(failed, err.Path)
+			}
+		} else if err != nil {
+			t.Fatalf("want err to be ReconcileError; got %v (i=%d)", err, i)
+		}
+		if !reflect.DeepEqual(failed, cas.failed) {
+			t.Errorf("want failed=%v; got %v (i=%d)", cas.failed, failed, i)
+		}
+		got := make(map[string]Event)
+		for _, name := range []string{"a", "b", "c", "d"} {
+			if e, ok := tr.Events(path(name)); ok {
+				got[path(name)] = e
+			}
+		}
+		if !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want events=%v; got %v (i=%d)", cas.want, got, i)
+		}
+	}
+	if n := len(tr.pipes); n != 0 {
+		t.Fatalf("want no pipes left; got %d", n)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "regexp"
+
+// WithRegexp makes the watchpoint deliver only the events, which full paths
+// match re, e.g. the SQL migrations anywhere under the watched tree:
+//
+//   re := regexp.MustCompile(`.*/migrations/\d+_.*\.sql$`)
+//   err := notify.WatchWithOptions("/path/to/repo/...", c, notify.All, notify.WithRegexp(re))
+//
+// The paths are matched the way they are delivered, so the pattern has to
+// use the separator of the platform, e.g. a backslash under Windows. Unlike
+// with WithGitignore, all the directories of a recursive watchpoint are still
+// watched, since whether anything under a directory can match a regular
+// expression cannot be told in general; the events are filtered once they are
+// reported. The events for the directories are delivered only if their paths
+// match as well.
+func WithRegexp(re *regexp.Regexp) Option {
+	return func(o *options) {
+		o.regexp = re.String()
+	}
+}
+
+// matchRegexp gives a stage, which passes on the events which paths match re.
+func matchRegexp(re *regexp.Regexp) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			if re.MatchString(ei.Path()) {
+				next(ei)
+			}
+		}
+	}
+}
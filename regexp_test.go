@@ -0,0 +1,43 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatchRegexp(t *testing.T) {
+	var got []string
+	fn := matchRegexp(regexp.MustCompile(`.*/migrations/\d+_.*\.sql$`))(func(ei EventInfo) {
+		got = append(got, ei.Path())
+	})
+	cases := [...]struct {
+		path string
+		ok   bool
+	}{
+		{"/repo/db/migrations/0001_init.sql", true},   // i=0
+		{"/repo/migrations/2_users.sql", true},        // i=1
+		{"/repo/db/migrations/init.sql", false},       // i=2
+		{"/repo/db/migrations/0001_init.sql~", false}, // i=3
+		{"/repo/db/migrations", false},                // i=4
+	}
+	for i, cas := range cases {
+		got = nil
+		fn(&Call{P: cas.path, E: Write})
+		if ok := len(got) == 1; ok != cas.ok {
+			t.Errorf("want ok=%t; got %t (i=%d)", cas.ok, ok, i)
+		}
+	}
+	o := &options{}
+	WithRegexp(regexp.MustCompile(`\.go$`))(o)
+	if _, err := o.stages("/repo", false, All); err != nil {
+		t.Errorf("stages()=%v", err)
+	}
+	o.regexp = `(`
+	if _, err := o.stages("/repo", false, All); err == nil {
+		t.Error("want err!=nil for a malformed regexp")
+	}
+}
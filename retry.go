@@ -0,0 +1,119 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// underlying gives the error wrapped by err, if any.
+func underlying(err error) error {
+	for {
+		switch e := err.(type) {
+		case *WatchError:
+			err = e.Err
+		case *os.PathError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		default:
+			return err
+		}
+	}
+}
+
+// transient reports whether err is worth retrying the operation that caused it.
+func transient(err error) bool {
+	err = underlying(err)
+	for _, e := range transientErrors {
+		if err == e {
+			return true
+		}
+	}
+	return os.IsNotExist(err)
+}
+
+// vanished reports whether err tells the path no longer exists.
+func vanished(err error) bool {
+	return os.IsNotExist(underlying(err))
+}
+
+// subdirAttempts is the number of times registering a subdirectory of
+// a recursively watched tree is retried, if it failed with a transient error,
+// other than the subdirectory being removed in the meantime. The first retry
+// is made after subdirBackoff, every following one waits twice as long.
+var (
+	subdirAttempts = 3
+	subdirBackoff  = 10 * time.Millisecond
+)
+
+// retrySubdir calls fn, retrying it as told by subdirAttempts.
+func retrySubdir(fn func() error) error {
+	err := fn()
+	for i := 0; err != nil && i < subdirAttempts && transient(err) && !vanished(err); i++ {
+		sleep(subdirBackoff << uint(i))
+		err = fn()
+	}
+	return err
+}
+
+// readSubdir lists the subdirectory of a recursively watched tree, retrying
+// the transient failures.
+func readSubdir(name string) (fis []os.FileInfo, err error) {
+	err = retrySubdir(func() error {
+		var err error
+		fis, err = ioutil.ReadDir(name)
+		return err
+	})
+	return fis, err
+}
+
+// rearm gives a stage, which sets up the watchpoint of the pipe given to arm
+// again, once dir is reported to be removed or renamed, or once it is mounted
+// again after it was reported to be unmounted.
+func rearm(t *pipeTree, path, dir string, e Event, attempts int, backoff time.Duration) (s stage, arm func(*pipe)) {
+	var mu sync.Mutex
+	var p *pipe
+	var busy bool
+	s = func(next handler) handler {
+		return func(ei EventInfo) {
+			next(ei)
+			if ei.Event()&(Remove|Rename) == 0 || normalize(ei.Path()) != dir {
+				return
+			}
+			if _, ok := ei.(RenameSelfEventInfo); ok {
+				return // the watchpoint was moved along with dir
+			}
+			_, remount := ei.(UnmountEventInfo)
+			mu.Lock()
+			if p == nil || busy {
+				mu.Unlock()
+				return
+			}
+			busy = true
+			pp := p
+			mu.Unlock()
+			go func() {
+				if remount {
+					t.Remount(pp, path, dir, e, attempts, backoff)
+				} else {
+					t.Rearm(pp, path, e, attempts, backoff)
+				}
+				mu.Lock()
+				busy = false
+				mu.Unlock()
+			}()
+		}
+	}
+	arm = func(pp *pipe) {
+		mu.Lock()
+		p = pp
+		mu.Unlock()
+	}
+	return s, arm
+}
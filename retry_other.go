@@ -0,0 +1,18 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !plan9
+
+package notify
+
+import "syscall"
+
+// transientErrors lists errors, which are worth retrying the operation that
+// caused them.
+var transientErrors = []error{
+	syscall.EMFILE,
+	syscall.ENFILE,
+	syscall.EINTR,
+	syscall.EAGAIN,
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTransient(t *testing.T) {
+	cases := [...]struct {
+		err error
+		ok  bool
+	}{
+		{syscall.EMFILE, true}, // i=0
+		{&WatchError{Op: "watch", Path: "/a", Err: &os.PathError{Op: "open", Path: "/a", Err: syscall.ENOENT}}, true}, // i=1
+		{os.NewSyscallError("inotify_add_watch", syscall.EINTR), true},                                                // i=2
+		{&WatchError{Op: "watch", Path: "/a", Err: ErrInvalidEventSet}, false},                                        // i=3
+		{errors.New("notify: fatal"), false},                                                                          // i=4
+	}
+	for i, cas := range cases {
+		if ok := transient(cas.err); ok != cas.ok {
+			t.Errorf("want ok=%t; got %t (i=%d)", cas.ok, ok, i)
+		}
+	}
+}
+
+func TestAddDirVanished(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_vanished")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	must(os.MkdirAll(filepath.Join(a, "x"), 0755))
+	must(os.MkdirAll(filepath.Join(b, "x"), 0755))
+	var failed []string
+	SetHooks(Hooks{OnError: func(path string, _ error) { failed = append(failed, path) }})
+	defer SetHooks(Hooks{})
+	var got []string
+	err = newnode(dir).AddDir(func(nd node) error {
+		got = append(got, nd.Name)
+		if nd.Name == b {
+			must(os.RemoveAll(b)) // removed before it is listed
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("want a removed subdirectory skipped; got %v", err)
+	}
+	sort.Strings(got)
+	if want := []string{dir, a, filepath.Join(a, "x"), b}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v visited; got %v", want, got)
+	}
+	if len(failed) != 0 {
+		t.Errorf("want no errors reported; got %v", failed)
+	}
+}
+
+// flakyWatcher fails watching the path with EMFILE the given number of times.
+type flakyWatcher struct {
+	watcher
+	path  string
+	fails int
+}
+
+func (w *flakyWatcher) Watch(p string, e Event) error {
+	if p == w.path && w.fails > 0 {
+		w.fails--
+		return &WatchError{Op: "watch", Path: p, Err: syscall.EMFILE}
+	}
+	return w.watcher.Watch(p, e)
+}
+
+func TestNonrecursiveTreeRetrySubdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	must(os.Mkdir(a, 0755))
+	must(os.Mkdir(b, 0755))
+	defer func(n int, d time.Duration) { subdirAttempts, subdirBackoff = n, d }(subdirAttempts, subdirBackoff)
+	subdirBackoff = time.Millisecond
+	cases := [...]struct {
+		fails int
+		want  []string
+	}{
+		{2, []string{dir, a, b}}, // i=0
+		{4, []string{dir, b}},    // i=1
+	}
+	for i, cas := range cases {
+		c := make(chan EventInfo, buffer)
+		sw := newSimWatcher(c)
+		tr := newNonrecursiveTree(&flakyWatcher{watcher: sw, path: a, fails: cas.fails}, c, nil)
+		if err := tr.Watch(filepath.Join(dir, "..."), NewChans(1)[0], Create); err != nil {
+			t.Fatalf("want the rest of the tree watched; got %v (i=%d)", err, i)
+		}
+		if got := sw.paths(); !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want %v watched; got %v (i=%d)", cas.want, got, i)
+		}
+		tr.Close()
+	}
+}
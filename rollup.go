@@ -0,0 +1,129 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchSubtreeRollup watches the directory tree under root and, instead of
+// the individual events, delivers to c a single event per immediate child of
+// root, which anything under was changed within window, e.g. for telling
+// which top-level packages of a monorepo changed:
+//
+//   c := make(chan notify.EventInfo, 16)
+//   if err := notify.WatchSubtreeRollup("/path/to/repo", c, time.Second); err != nil {
+//       log.Fatal(err)
+//   }
+//   for ei := range c {
+//       log.Println("changed:", filepath.Base(ei.Path()))
+//   }
+//
+// The window starts with the first event under a child, after which the event
+// for the child is delivered. Its path is the path of the child, its Event is
+// the union of the events rolled up into it, and it implements
+// the CoalescedEventInfo interface, which Count tells how many of them there
+// were. The events for root itself are rolled up under root.
+//
+// Use Stop to remove watchpoints set up with WatchSubtreeRollup; the events
+// not yet delivered are delivered before the watchpoint is removed.
+func WatchSubtreeRollup(root string, c chan<- EventInfo, window time.Duration) error {
+	return defaultTree.WatchSubtreeRollup(root, c, window)
+}
+
+// WatchSubtreeRollup watches root recursively with a pipe of c, which rolls
+// the events up per child of root.
+func (t *pipeTree) WatchSubtreeRollup(root string, c chan<- EventInfo, window time.Duration) error {
+	dir, _, err := cleanpath(root)
+	if err != nil {
+		return err
+	}
+	s, flush := rollup(dir, window)
+	p, err := t.WatchPipe(filepath.Join(dir, "..."), c, []stage{s}, All)
+	if err != nil {
+		return err
+	}
+	t.OnStop(p, flush)
+	return nil
+}
+
+// topchild gives the immediate child of dir, which the path is under, dir
+// itself if the path is not under dir.
+func topchild(dir, path string) string {
+	rel, ok := relpath(dir, path)
+	if !ok {
+		return dir
+	}
+	if i := strings.IndexByte(rel, '/'); i != -1 {
+		rel = rel[:i]
+	}
+	return filepath.Join(dir, rel)
+}
+
+// rollup gives a stage, which holds back the events under every child of dir
+// for window since the first of them, and then passes a single event for
+// the child instead. Calling flush passes on the events for all the children
+// right away.
+func rollup(dir string, window time.Duration) (s stage, flush func()) {
+	type held struct {
+		e Event
+		n int // number of the events rolled up
+		t Timer
+	}
+	// fold gives the event for the child rolling up the held ones.
+	fold := func(path string, h *held) EventInfo {
+		ei := &synthetic{path: path, event: h.e}
+		if fi, err := os.Lstat(path); err == nil {
+			ei.dir = fi.IsDir()
+		}
+		return &coalesced{EventInfo: ei, n: h.n}
+	}
+	var fl flushers
+	s = func(next handler) handler {
+		var mu sync.Mutex
+		pending := make(map[string]*held)
+		fl.add(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				h := pending[path]
+				h.t.Stop()
+				delete(pending, path)
+				next(fold(path, h))
+			}
+		})
+		return func(ei EventInfo) {
+			path := topchild(dir, normalize(ei.Path()))
+			mu.Lock()
+			defer mu.Unlock()
+			h, ok := pending[path]
+			if !ok {
+				h = &held{}
+				h.t = afterFunc(window, func() {
+					mu.Lock()
+					defer mu.Unlock()
+					if pending[path] == h {
+						delete(pending, path)
+						next(fold(path, h))
+					}
+				})
+				pending[path] = h
+			}
+			h.e |= ei.Event()
+			h.n += count(ei)
+		}
+	}
+	return s, fl.flush
+}
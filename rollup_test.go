@@ -0,0 +1,76 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRollup(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	root := filepath.Join(string(filepath.Separator), "repo")
+	path := func(rel string) string {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+	type rolled struct {
+		path string
+		e    Event
+		n    int
+	}
+	var got []rolled
+	s, flush := rollup(root, time.Second)
+	fn := s(func(ei EventInfo) {
+		got = append(got, rolled{filepath.ToSlash(ei.Path()), ei.Event(), count(ei)})
+	})
+	cases := [...]struct {
+		calls   []Call
+		advance time.Duration
+		want    []rolled
+	}{
+		// i=0: deep events rolled up into their top-level children
+		{
+			[]Call{
+				{P: path("a/b/c.go"), E: Write},
+				{P: path("a/d.go"), E: Create},
+				{P: path("x/y/z"), E: Remove},
+				{P: path("a/b/c.go"), E: Write},
+			},
+			time.Second,
+			[]rolled{{"/repo/a", Create | Write, 3}, {"/repo/x", Remove, 1}},
+		},
+		// i=1: the window has not elapsed yet
+		{
+			[]Call{{P: path("a/b"), E: Create}},
+			time.Second / 2,
+			nil,
+		},
+		// i=2: the window starts with the first event
+		{
+			[]Call{{P: path("a/c"), E: Create}, {P: path("f"), E: Write}, {P: root, E: Rename}},
+			time.Second / 2,
+			[]rolled{{"/repo/a", Create, 2}},
+		},
+	}
+	for i, cas := range cases {
+		got = nil
+		for j := range cas.calls {
+			fn(&cas.calls[j])
+		}
+		clk.Advance(cas.advance)
+		if !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want %v; got %v (i=%d)", cas.want, got, i)
+		}
+	}
+	got = nil
+	flush()
+	if want := []rolled{{"/repo", Rename, 1}, {"/repo/f", Write, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// rootEvents gives a stage, which keeps track of whether dir exists, dropping
+// repeated Remove, Rename and Create events for dir itself. Once arm is
+// called, dir is checked every interval and the missing Remove event is
+// reported, in case the underlying watcher did not report it. If rearm is
+// true, Create event is reported as well, once dir is created again.
+func rootEvents(dir string, e Event, rearm bool, interval time.Duration) (s stage, arm func(*pipe)) {
+	var mu sync.Mutex
+	exists := true
+	s = func(next handler) handler {
+		return func(ei EventInfo) {
+			if normalize(ei.Path()) == dir {
+				var dup bool
+				mu.Lock()
+				switch {
+				case ei.Event()&(Remove|Rename) != 0:
+					dup, exists = !exists, false
+				case ei.Event()&Create != 0:
+					dup, exists = exists, true
+				}
+				mu.Unlock()
+				if dup {
+					return
+				}
+			}
+			next(ei)
+		}
+	}
+	arm = func(p *pipe) {
+		go func() {
+			t := newTicker(interval)
+			defer t.Stop()
+			for range t.C {
+				if p.closed() {
+					return
+				}
+				_, err := os.Stat(dir)
+				mu.Lock()
+				was := exists
+				mu.Unlock()
+				switch {
+				case os.IsNotExist(err) && was && e&Remove != 0:
+					p.inject(&synthetic{path: dir, event: Remove, dir: true})
+				case err == nil && !was && rearm && e&Create != 0:
+					p.inject(&synthetic{path: dir, event: Create, dir: true})
+				}
+			}
+		}()
+	}
+	return s, arm
+}
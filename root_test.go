@@ -0,0 +1,59 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRootEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	must(os.Mkdir(sub, 0755))
+	ch := NewChans(1)
+	s, arm := rootEvents(sub, Create|Remove, true, 10*time.Millisecond)
+	p := newPipe(ch[0], s)
+	defer p.stop()
+	arm(p)
+	expect := func(e Event) {
+		select {
+		case ei := <-ch[0]:
+			if ei.Path() != sub || ei.Event() != e {
+				t.Fatalf("want %v on %q; got %v", e, sub, ei)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out waiting for %v on %q", e, sub)
+		}
+	}
+	p.c <- &Call{P: filepath.Join(sub, "file"), E: Create}
+	select {
+	case ei := <-ch[0]:
+		if ei.Path() != filepath.Join(sub, "file") {
+			t.Fatalf("want child event passed through; got %v", ei)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	// Remove reported by the watcher is delivered once, the one found
+	// by checking the path is dropped.
+	must(os.Remove(sub))
+	p.c <- &Call{P: sub, E: Remove}
+	expect(Remove)
+	must(os.Mkdir(sub, 0755))
+	expect(Create)
+	select {
+	case ei := <-ch[0]:
+		t.Fatalf("want no more events; got %v", ei)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+// WatchRouted works like Watch, but it delivers every event to one of
+// the channels, the one at the index fn gives for the event, e.g. to deliver
+// Create and Remove events on a low-latency channel and Write events, which
+// are expensive to process, on one drained by a pool of workers:
+//
+//   fast, slow := make(chan notify.EventInfo, 1), make(chan notify.EventInfo, 1024)
+//   route := func(ei notify.EventInfo) int {
+//       if ei.Event() == notify.Write {
+//           return 1
+//       }
+//       return 0
+//   }
+//   err := notify.WatchRouted("/path/to/dir/...", route, []chan<- notify.EventInfo{fast, slow}, notify.All)
+//
+// Like with Watch, the events are delivered without blocking, each channel
+// drops the events it is not ready for on its own, so that a backlog on one of
+// them does not delay the others. The events, which fn gives an index out of
+// range of cs for, are delivered to the first channel.
+//
+// The watchpoint is registered for the first of the channels, so it is removed
+// with Stop or StopPath called with cs[0].
+func WatchRouted(path string, fn func(EventInfo) int, cs []chan<- EventInfo, events ...Event) error {
+	return defaultTree.WatchRouted(path, fn, cs, events...)
+}
+
+// WatchRouted sets up a pipe of cs[0], which routes the events to cs.
+func (t *pipeTree) WatchRouted(path string, fn func(EventInfo) int, cs []chan<- EventInfo, events ...Event) error {
+	if len(cs) == 0 {
+		panic("notify: WatchRouted using no channels")
+	}
+	for _, c := range cs {
+		if c == nil {
+			panic("notify: Watch using nil channel")
+		}
+	}
+	_, err := t.WatchPipe(path, cs[0], []stage{route(fn, cs)}, events...)
+	return err
+}
+
+// route gives a stage, which sends events to the channel of cs at the index
+// given by fn, without blocking. The events routed to the first channel, or out
+// of range of cs, are passed on to be delivered like the other events of
+// the pipe.
+func route(fn func(EventInfo) int, cs []chan<- EventInfo) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			i := fn(ei)
+			if i <= 0 || i >= len(cs) {
+				next(ei)
+				return
+			}
+			select {
+			case cs[i] <- ei:
+			default: // Drop event if receiver is too slow
+				dropped(ei)
+			}
+		}
+	}
+}
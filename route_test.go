@@ -0,0 +1,47 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRoute(t *testing.T) {
+	ch := NewChans(3)
+	fn := func(ei EventInfo) int {
+		switch ei.Event() {
+		case Write:
+			return 1
+		case Remove:
+			return 2
+		case Rename:
+			return 3
+		}
+		return 0
+	}
+	p := newPipe(ch[0], route(fn, []chan<- EventInfo{ch[0], ch[1], ch[2]}))
+	defer p.stop()
+	cases := [...]struct {
+		e Event
+		c int
+	}{
+		{Create, 0}, // i=0
+		{Write, 1},  // i=1
+		{Remove, 2}, // i=2
+		{Rename, 0}, // i=3
+	}
+	for i, cas := range cases {
+		p.c <- &Call{P: "/a", E: cas.e}
+		select {
+		case ei := <-ch[cas.c]:
+			if ei.Event() != cas.e {
+				t.Fatalf("want %v; got %v (i=%d)", cas.e, ei.Event(), i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("want event delivered to channel %d (i=%d)", cas.c, i)
+		}
+	}
+}
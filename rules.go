@@ -0,0 +1,181 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Rule decides whether and for which events the paths matching its pattern
+// are watched by WatchRules.
+type Rule struct {
+	// Pattern is a slash-separated pattern of the paths relative to the root,
+	// in the syntax of path.Match, where the "**" element matches zero or more
+	// directories, or everything within when it ends the pattern, e.g.
+	// "src/**/*.go" or "tmp/**".
+	Pattern string
+
+	// Events are the events delivered for the paths matching the pattern,
+	// unless they are excluded.
+	Events Event
+
+	// Exclude makes the paths matching the pattern not watched.
+	Exclude bool
+}
+
+// WatchRules watches the directory tree under root, deciding for every path
+// under it with the first of the rules, which pattern matches it, whether and
+// for which events it is watched, like a firewall ruleset does. The paths
+// matching none of the rules are not watched. E.g. the following rules watch
+// the whole tree except for the tmp directory, within which only the contents
+// of the keep directory are watched:
+//
+//   err := notify.WatchRules("/path/to/dir", []notify.Rule{
+//       {Pattern: "tmp/keep/**", Events: notify.All},
+//       {Pattern: "tmp/**", Exclude: true},
+//       {Pattern: "**", Events: notify.All},
+//   }, c)
+//
+// The directories, which all the paths within are excluded, are not watched at
+// all by watchers, which do not watch directory trees natively, e.g. inotify
+// or kqueue, like the ones ignored with WithGitignore. The events for root
+// itself are delivered, if any of the rules includes them. WatchRules fails
+// with ErrInvalidEventSet, if none of the rules includes any events, and with
+// path.ErrBadPattern, if any of the patterns is malformed.
+func WatchRules(root string, rules []Rule, c chan<- EventInfo) error {
+	return defaultTree.WatchRules(root, rules, c)
+}
+
+// WatchRules sets up a recursive watchpoint of root filtered by the rules.
+func (t *pipeTree) WatchRules(root string, rules []Rule, c chan<- EventInfo) error {
+	rs, err := compileRules(rules)
+	if err != nil {
+		return err
+	}
+	if rs.events() == 0 {
+		return &WatchError{Op: "watchrules", Path: root, Err: ErrInvalidEventSet}
+	}
+	rec := filepath.Join(strings.TrimSuffix(root, "..."), "...")
+	return t.WatchWithOptions(rec, c, rs.events(), withRules(rules))
+}
+
+// withRules makes the watchpoint filter its paths with the rules.
+func withRules(rules []Rule) Option {
+	return func(o *options) {
+		o.rules = rules
+	}
+}
+
+// rule is a Rule with its pattern split on slashes.
+type rule struct {
+	segs    []string
+	events  Event
+	exclude bool
+}
+
+// ruleset is a list of rules evaluated in order.
+type ruleset []rule
+
+// compileRules splits the patterns of the rules, validating them.
+func compileRules(rules []Rule) (ruleset, error) {
+	rs := make(ruleset, 0, len(rules))
+	for _, r := range rules {
+		segs := strings.Split(strings.Trim(r.Pattern, "/"), "/")
+		for _, seg := range segs {
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, err
+			}
+		}
+		rs = append(rs, rule{segs: segs, events: r.Events, exclude: r.Exclude})
+	}
+	return rs, nil
+}
+
+// events gives the events included by any of the rules.
+func (rs ruleset) events() (e Event) {
+	for _, r := range rs {
+		if !r.exclude {
+			e |= r.events
+		}
+	}
+	return e
+}
+
+// first gives the first rule matching the path given by its elements, nil if
+// there is none.
+func (rs ruleset) first(elems []string) *rule {
+	for i := range rs {
+		if matchsegs(rs[i].segs, elems) {
+			return &rs[i]
+		}
+	}
+	return nil
+}
+
+// skip implements skipFunc. A file is skipped if it is excluded, a directory
+// only if all the paths within it are excluded as well, so that it is not
+// watched at all.
+func (rs ruleset) skip(rel string, isdir bool) bool {
+	elems := strings.Split(rel, "/")
+	if !isdir {
+		r := rs.first(elems)
+		return r == nil || r.exclude
+	}
+	for _, r := range rs {
+		if covers(r.segs, elems) {
+			return r.exclude
+		}
+		if matchprefix(r.segs, elems) {
+			return false
+		}
+	}
+	return true
+}
+
+// covers reports whether the pattern, which ends with "**", matches every
+// path under the directory given by its elements.
+func covers(segs, elems []string) bool {
+	n := len(segs)
+	if segs[n-1] != "**" {
+		return false
+	}
+	return matchsegs(segs[:n-1], elems) || matchsegs(segs, elems)
+}
+
+// matchprefix reports whether the pattern may match the directory given by its
+// elements or any path under it.
+func matchprefix(segs, elems []string) bool {
+	switch {
+	case len(elems) == 0:
+		return true
+	case len(segs) == 0:
+		return false
+	case segs[0] == "**":
+		return true
+	}
+	if ok, _ := path.Match(segs[0], elems[0]); !ok {
+		return false
+	}
+	return matchprefix(segs[1:], elems[1:])
+}
+
+// filter gives a stage, which passes on the events of the paths under dir
+// only if the first rule matching them includes them.
+func (rs ruleset) filter(dir string) stage {
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			rel, ok := relpath(dir, normalize(ei.Path()))
+			if !ok {
+				next(ei)
+				return
+			}
+			if r := rs.first(strings.Split(rel, "/")); r != nil && !r.exclude && ei.Event()&r.events != 0 {
+				next(ei)
+			}
+		}
+	}
+}
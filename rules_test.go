@@ -0,0 +1,95 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testRules = []Rule{
+	{Pattern: "tmp/keep/**", Events: All},
+	{Pattern: "tmp/**", Exclude: true},
+	{Pattern: "**/*.go", Events: Write},
+	{Pattern: "src/**", Events: Create},
+}
+
+func TestRulesetSkip(t *testing.T) {
+	rs, err := compileRules(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := [...]struct {
+		rel   string
+		isdir bool
+		skip  bool
+	}{
+		{"tmp", true, false},            // i=0
+		{"tmp/junk", true, true},        // i=1
+		{"tmp/keep", true, false},       // i=2
+		{"tmp/keep/file", false, false}, // i=3
+		{"tmp/file", false, true},       // i=4
+		{"src", true, false},            // i=5
+		{"src/a/b", true, false},        // i=6
+		{"src/a/b.txt", false, false},   // i=7
+		{"doc", true, false},            // i=8
+		{"doc/README", false, true},     // i=9
+		{"doc/main.go", false, false},   // i=10
+	}
+	for i, cas := range cases {
+		if skip := rs.skip(cas.rel, cas.isdir); skip != cas.skip {
+			t.Errorf("want skip=%t; got %t (i=%d)", cas.skip, skip, i)
+		}
+	}
+	if _, err := compileRules([]Rule{{Pattern: "a/[", Events: All}}); err == nil {
+		t.Error("want err!=nil for malformed pattern")
+	}
+}
+
+func TestWatchRules(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	for _, sub := range []string{"tmp/keep", "tmp/junk", "src"} {
+		must(os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0755))
+	}
+	c := make(chan EventInfo, buffer)
+	if err := tr.WatchRules(dir, []Rule{{Pattern: "tmp/**", Exclude: true}}, c); err == nil {
+		t.Fatal("want err!=nil for rules including no events")
+	}
+	must(tr.WatchRules(dir, testRules, c))
+	cases := [...]struct {
+		path string
+		e    Event
+	}{
+		{"tmp/junk/file", 0},      // i=0
+		{"tmp/keep/file", Create}, // i=1
+		{"src/file", Create},      // i=2
+		{"main.go", 0},            // i=3
+		{"tmp/junk/main.go", 0},   // i=4
+	}
+	for i, cas := range cases {
+		path := filepath.Join(dir, filepath.FromSlash(cas.path))
+		must(ioutil.WriteFile(path, nil, 0644))
+		if cas.e == 0 {
+			select {
+			case ei := <-c:
+				t.Fatalf("want no event; got %v (i=%d)", ei, i)
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&Call{P: path, E: cas.e}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+}
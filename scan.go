@@ -0,0 +1,158 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// entry describes the state of a file or a directory in a snapshot.
+type entry struct {
+	dir  bool
+	size int64
+	mod  time.Time
+}
+
+func newEntry(fi os.FileInfo) entry {
+	return entry{dir: fi.IsDir(), size: fi.Size(), mod: fi.ModTime()}
+}
+
+// snapshot keeps the state of the entries under root. For non-recursive
+// snapshots only root and its direct children are kept.
+type snapshot struct {
+	root string
+	rec  bool
+	m    map[string]entry
+}
+
+func newSnapshot(root string, rec bool) (*snapshot, error) {
+	s := &snapshot{root: root, rec: rec}
+	m, err := s.scan(root)
+	if err != nil {
+		return nil, err
+	}
+	s.m = m
+	return s, nil
+}
+
+// scan gives the current state of the entries under the given path, including
+// the path itself. Nonexistent path gives empty result.
+func (s *snapshot) scan(path string) (map[string]entry, error) {
+	m := make(map[string]entry)
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m[path] = newEntry(fi)
+	if !fi.IsDir() {
+		return m, nil
+	}
+	if !s.rec {
+		if path != s.root {
+			return m, nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		fis, err := f.Readdir(-1)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range fis {
+			m[filepath.Join(path, fi.Name())] = newEntry(fi)
+		}
+		return m, nil
+	}
+	err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		switch {
+		case os.IsNotExist(err):
+			return nil
+		case err != nil:
+			return err
+		}
+		m[p] = newEntry(fi)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// update rescans the given path and replaces the state kept for it. It gives
+// events describing the differences found, sorted by path.
+func (s *snapshot) update(path string) ([]EventInfo, error) {
+	fresh, err := s.scan(path)
+	if err != nil {
+		return nil, err
+	}
+	var ei []EventInfo
+	for p, old := range s.m {
+		if p != path && !strings.HasPrefix(p, path+sep) {
+			continue
+		}
+		cur, ok := fresh[p]
+		switch {
+		case !ok:
+			delete(s.m, p)
+			ei = append(ei, &synthetic{path: p, event: Remove, dir: old.dir})
+		case !cur.dir && (cur.size != old.size || !cur.mod.Equal(old.mod)):
+			ei = append(ei, &synthetic{path: p, event: Write})
+		}
+	}
+	for p, cur := range fresh {
+		if _, ok := s.m[p]; !ok {
+			ei = append(ei, &synthetic{path: p, event: Create, dir: cur.dir})
+		}
+		s.m[p] = cur
+	}
+	sort.Sort(byPath(ei))
+	return ei, nil
+}
+
+// byPath implements sort.Interface, sorting events by their paths.
+type byPath []EventInfo
+
+func (b byPath) Len() int           { return len(b) }
+func (b byPath) Less(i, j int) bool { return b[i].Path() < b[j].Path() }
+func (b byPath) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// leaves gives a stage, which after passing each event rescans its path and
+// passes events for the entries that changed under it. The reported path
+// itself is skipped, as the original event already covers it.
+func leaves(dir string, isrec bool, e Event) (stage, error) {
+	s, err := newSnapshot(dir, isrec)
+	if err != nil {
+		return nil, err
+	}
+	var mu sync.Mutex
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			next(ei)
+			mu.Lock()
+			diff, err := s.update(ei.Path())
+			mu.Unlock()
+			if err != nil {
+				dbgprintf("leaves: rescanning %q failed: %v", ei.Path(), err)
+				return
+			}
+			for _, d := range diff {
+				if d.Path() != ei.Path() && d.Event()&e != 0 {
+					next(d)
+				}
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLeaves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_leaves")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	join := func(p string) string { return filepath.Join(dir, filepath.FromSlash(p)) }
+	for _, p := range []string{"a/b", "a/c", "d"} {
+		if err := os.MkdirAll(filepath.Dir(join(p)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(join(p), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := leaves(dir, true, Create|Remove|Write)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []EventInfo
+	fn := s(func(ei EventInfo) { got = append(got, ei) })
+	must(os.MkdirAll(join("a/e"), 0755))
+	must(ioutil.WriteFile(join("a/e/f"), []byte("XD"), 0644))
+	must(ioutil.WriteFile(join("a/b"), []byte("XD"), 0644))
+	must(os.Remove(join("a/c")))
+	must(ioutil.WriteFile(join("d"), []byte("XD"), 0644))
+	fn(&Call{P: join("a"), E: Write})
+	want := []Call{
+		{P: join("a"), E: Write},
+		{P: join("a/b"), E: Write},
+		{P: join("a/c"), E: Remove},
+		{P: join("a/e"), E: Create},
+		{P: join("a/e/f"), E: Create},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %v; got %v", want, got)
+	}
+	for i := range want {
+		if err := EqualEventInfo(&want[i], got[i]); err != nil {
+			t.Errorf("%v (i=%d)", err, i)
+		}
+	}
+}
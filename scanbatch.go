@@ -0,0 +1,125 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// scanBatchSize is the largest number of the events delivered in a single
+// ScanBatchEventInfo.
+var scanBatchSize = 4096
+
+// ScanBatchEventInfo is the EventInfo delivered for watchpoints set up with
+// WithScanBatch instead of the Create events notify made up by scanning. Its
+// Path() is the watched directory and its Event() is Create.
+type ScanBatchEventInfo interface {
+	CoalescedEventInfo
+	Entries() []EventInfo // the events batched, sorted by their paths
+}
+
+// WithScanBatch makes notify deliver the Create events, which it makes up by
+// scanning the watched tree instead of getting them from the OS, in batches
+// rather than one by one, so that a consumer is able to tell the inventory of
+// the directories from the live changes and process it at once. Such events
+// are delivered e.g. for the content of a directory moved into a recursively
+// watched tree under inotify or kqueue, or for the files found by rescanning
+// after an overflow, see WithOverflowBackoff.
+//
+// The events are held back, until none was made up for window, and delivered
+// as a single ScanBatchEventInfo event, which Entries list them. A batch holds
+// at most 4096 events, a larger inventory is delivered in more batches. The
+// live events are delivered right away, but a live event for a path held back
+// makes the batch delivered first, so that the events of a path are never
+// reordered. The batch held back is delivered before the watchpoint is removed,
+// unless WithDiscardOnStop was given.
+func WithScanBatch(window time.Duration) Option {
+	return func(o *options) {
+		o.scanBatch = window
+	}
+}
+
+// scanBatch is a batch of the made up events.
+type scanBatch struct {
+	synthetic
+	entries []EventInfo
+}
+
+var _ ScanBatchEventInfo = (*scanBatch)(nil)
+
+func (e *scanBatch) Entries() []EventInfo { return e.entries }
+func (e *scanBatch) Count() int           { return len(e.entries) }
+
+// isScan reports whether ei is a Create event made up by scanning.
+func isScan(ei EventInfo) bool {
+	s, ok := ei.(*synthetic)
+	return ok && s.event == Create
+}
+
+// batchScans gives a stage, which passes the Create events made up by scanning
+// dir on in batches, once there were none for window. Calling flush passes
+// on the batch held back right away.
+func batchScans(dir string, window time.Duration) (s stage, flush func()) {
+	var fl flushers
+	s = func(next handler) handler {
+		var mu sync.Mutex
+		var t Timer
+		var gen int // bumped on every event held back, invalidates older timers
+		var held []EventInfo
+		paths := make(map[string]struct{})
+		// fire passes on the batch held back. It expects mu to be held.
+		fire := func() {
+			if t != nil {
+				t.Stop()
+				t = nil
+			}
+			if len(held) == 0 {
+				return
+			}
+			sort.Sort(byPath(held))
+			next(&scanBatch{synthetic: synthetic{path: dir, event: Create, dir: true}, entries: held})
+			held, paths = nil, make(map[string]struct{})
+		}
+		fl.add(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			fire()
+		})
+		return func(ei EventInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			path := normalize(ei.Path())
+			if !isScan(ei) {
+				if _, ok := paths[path]; ok {
+					fire()
+				}
+				next(ei)
+				return
+			}
+			held = append(held, ei)
+			paths[path] = struct{}{}
+			if len(held) == scanBatchSize {
+				fire()
+				return
+			}
+			gen++
+			g := gen
+			if t != nil {
+				t.Stop()
+			}
+			t = afterFunc(window, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if gen == g {
+					t = nil
+					fire()
+				}
+			})
+		}
+	}
+	return s, fl.flush
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBatchScans(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	root := filepath.Join(string(filepath.Separator), "tree")
+	path := func(rel string) string {
+		return filepath.Join(root, filepath.FromSlash(rel))
+	}
+	var got [][]string
+	s, flush := batchScans(root, time.Second)
+	fn := s(func(ei EventInfo) {
+		var paths []string
+		if b, ok := ei.(ScanBatchEventInfo); ok {
+			for _, ei := range b.Entries() {
+				paths = append(paths, "+"+filepath.ToSlash(ei.Path()))
+			}
+		} else {
+			paths = append(paths, filepath.ToSlash(ei.Path()))
+		}
+		got = append(got, paths)
+	})
+	scan := func(rel string) EventInfo { return &synthetic{path: path(rel), event: Create} }
+	live := func(rel string) EventInfo { return &Call{P: path(rel), E: Write} }
+	cases := [...]struct {
+		events  []EventInfo
+		advance time.Duration
+		want    [][]string
+	}{
+		// i=0: live events are passed right away, the made up ones batched
+		{
+			[]EventInfo{scan("b"), live("x"), scan("a")},
+			time.Second,
+			[][]string{{"/tree/x"}, {"+/tree/a", "+/tree/b"}},
+		},
+		// i=1: the window has not elapsed yet
+		{
+			[]EventInfo{scan("c")},
+			time.Second / 2,
+			nil,
+		},
+		// i=2: a live event for a path held back delivers the batch first
+		{
+			[]EventInfo{live("c")},
+			0,
+			[][]string{{"+/tree/c"}, {"/tree/c"}},
+		},
+	}
+	for i, cas := range cases {
+		got = nil
+		for _, ei := range cas.events {
+			fn(ei)
+		}
+		clk.Advance(cas.advance)
+		if !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want %v; got %v (i=%d)", cas.want, got, i)
+		}
+	}
+	got = nil
+	fn(scan("d"))
+	flush()
+	if want := [][]string{{"+/tree/d"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
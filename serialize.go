@@ -0,0 +1,128 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"sync"
+)
+
+// AckEventInfo is an EventInfo delivered for a watchpoint set up with
+// WithSerialize. Done tells notify the consumer finished processing the event,
+// so that the next event for the same path may be delivered. Calling Done more
+// than once has no effect.
+type AckEventInfo interface {
+	EventInfo
+	Done()
+}
+
+// WithSerialize makes notify deliver at most one event per path at a time:
+// an event for a path is held back until Done was called for the previous
+// event delivered for it, e.g. for consumers running an expensive job for
+// every change, which must not overlap with the next job for the same file.
+// The events for distinct paths are delivered as usual, so the receiving
+// channel works like a work queue serialized per path:
+//
+//   for ei := range c {
+//           go func(ei notify.EventInfo) {
+//                   defer ei.(notify.AckEventInfo).Done()
+//                   process(ei.Path())
+//           }(ei)
+//   }
+//
+// Every event delivered implements AckEventInfo, and the consumer must call
+// Done for each of them, otherwise no further events are delivered for its
+// path. The events held back are queued in the order they were reported, with
+// no limit on their number. An event dropped on the way to a slow receiver
+// counts as done. Use WithCoalesceKey to serialize the events by other keys
+// than their paths.
+func WithSerialize() Option {
+	return func(o *options) {
+		o.serialize = true
+	}
+}
+
+// acked is an event, which next event for its key is held back until Done is
+// called.
+type acked struct {
+	EventInfo
+	once sync.Once
+	done func()
+}
+
+var _ AckEventInfo = (*acked)(nil)
+var _ isDirer = (*acked)(nil)
+var _ OwnEventInfo = (*acked)(nil)
+var _ systemer = (*acked)(nil)
+var _ CoalescedEventInfo = (*acked)(nil)
+
+func (e *acked) Done()          { e.once.Do(e.done) }
+func (e *acked) Own() bool      { return isown(e.EventInfo) }
+func (e *acked) isSystem() bool { return issystem(e.EventInfo) }
+func (e *acked) Count() int     { return count(e.EventInfo) }
+
+func (e *acked) isDir() (bool, error) {
+	if d, ok := e.EventInfo.(isDirer); ok {
+		return d.isDir()
+	}
+	return false, nil
+}
+
+// ackedStat is an acked event, which keeps the os.FileInfo attached to it.
+type ackedStat struct {
+	*acked
+}
+
+var _ InodeEventInfo = ackedStat{}
+
+func (e ackedStat) FileInfo() os.FileInfo { return e.EventInfo.(StatEventInfo).FileInfo() }
+
+// Inode implements InodeEventInfo interface.
+func (e ackedStat) Inode() (dev, ino uint64, ok bool) { return inodeOf(e.EventInfo) }
+
+// serialize gives a stage, which passes at most one event per key at a time.
+// The events passed are acked ones; the next event for their key is held back
+// until they are done.
+func serialize(key keyFunc) stage {
+	return func(next handler) handler {
+		var mu sync.Mutex
+		held := make(map[string][]EventInfo) // keys with an event in flight
+		var pass func(k string, ei EventInfo)
+		release := func(k string) {
+			mu.Lock()
+			q := held[k]
+			if len(q) == 0 {
+				delete(held, k)
+				mu.Unlock()
+				return
+			}
+			held[k] = q[1:]
+			mu.Unlock()
+			// The event is passed on asynchronously, since Done may be called
+			// by the receiver while the pipe waits for it.
+			go pass(k, q[0])
+		}
+		pass = func(k string, ei EventInfo) {
+			a := &acked{EventInfo: ei, done: func() { release(k) }}
+			if _, ok := ei.(StatEventInfo); ok {
+				next(ackedStat{a})
+				return
+			}
+			next(a)
+		}
+		return func(ei EventInfo) {
+			k := key(ei)
+			mu.Lock()
+			if q, ok := held[k]; ok {
+				held[k] = append(q, ei)
+				mu.Unlock()
+				return
+			}
+			held[k] = nil
+			mu.Unlock()
+			pass(k, ei)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSerialize(t *testing.T) {
+	c := make(chan EventInfo, 10)
+	fn := serialize(pathKey)(func(ei EventInfo) { c <- ei })
+	recv := func(want Call) AckEventInfo {
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&want, ei); err != nil {
+				t.Fatal(err)
+			}
+			a, ok := ei.(AckEventInfo)
+			if !ok {
+				t.Fatalf("want %T to implement AckEventInfo", ei)
+			}
+			return a
+		case <-time.After(timeout()):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+		return nil
+	}
+	none := func() {
+		select {
+		case ei := <-c:
+			t.Fatalf("want no events; got %v", ei)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	fn(&Call{P: "/a", E: Create})
+	fn(&Call{P: "/a", E: Write})
+	fn(&Call{P: "/b", E: Write})
+	fn(&Call{P: "/a", E: Remove})
+	a := recv(Call{P: "/a", E: Create})
+	b := recv(Call{P: "/b", E: Write})
+	none()
+	b.Done()
+	none()
+	a.Done()
+	a.Done()
+	a = recv(Call{P: "/a", E: Write})
+	none()
+	a.Done()
+	a = recv(Call{P: "/a", E: Remove})
+	a.Done()
+	fn(&Call{P: "/a", E: Create})
+	recv(Call{P: "/a", E: Create})
+}
@@ -0,0 +1,604 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simWatcher is a fake watcher, which reports the changes made to the model of
+// a filesystem the way inotify does: the events are reported for the entries
+// of a watched directory and for the watched directory itself, the watch of
+// a removed or renamed directory is dropped and the path is watched again by
+// Rewatch. Like inotify_add_watch(2), both Watch and Rewatch fail for
+// nonexistent paths; the filesystem is kept on disk along with the model.
+//
+// The watcher created by newSimRecursiveWatcher works the way FSEvents does
+// instead: the recursive watches report the events of whole subtrees and, as
+// the watches follow paths, the watch of a removed directory is kept, so it
+// reports the directory again once it is recreated.
+type simWatcher struct {
+	mu      sync.Mutex
+	c       chan<- EventInfo
+	follow  bool             // whether the watches follow paths, see above
+	watches map[string]Event // the recursive ones hold the recursive bit
+	errs    []error          // calls, which are not consistent with the watches
+}
+
+func newSimWatcher(c chan<- EventInfo) *simWatcher {
+	return &simWatcher{c: c, watches: make(map[string]Event)}
+}
+
+func (w *simWatcher) Watch(p string, e Event) error {
+	if _, err := os.Stat(p); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.watches[p]; ok {
+		w.errs = append(w.errs, fmt.Errorf("Watch(%q, %v): already watched", p, e))
+	}
+	w.watches[p] = e
+	return nil
+}
+
+func (w *simWatcher) Unwatch(p string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.watches[p]; !ok {
+		// Unless the watches follow paths, the watch may have been dropped
+		// by a removal.
+		if w.follow {
+			w.errs = append(w.errs, fmt.Errorf("Unwatch(%q): not watched", p))
+		}
+		return &WatchError{Op: "unwatch", Path: p, Err: ErrNotWatched}
+	}
+	delete(w.watches, p)
+	return nil
+}
+
+func (w *simWatcher) Rewatch(p string, olde, newe Event) error {
+	return w.rewatch(p, olde, newe)
+}
+
+// rewatch works like Rewatch, but newe may hold the recursive bit.
+func (w *simWatcher) rewatch(p string, olde, newe Event) error {
+	if !w.follow {
+		if _, err := os.Stat(p); err != nil {
+			return err
+		}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch e, ok := w.watches[p]; {
+	case !ok && w.follow:
+		w.errs = append(w.errs, fmt.Errorf("Rewatch(%q, %v, %v): not watched", p, olde, newe))
+		return &WatchError{Op: "rewatch", Path: p, Err: ErrNotWatched}
+	case ok && e&^recursive != olde:
+		// Like inotify_add_watch(2), it watches the path again, if its watch
+		// was dropped by a removal.
+		w.errs = append(w.errs, fmt.Errorf("Rewatch(%q, %v, %v): watched for %v", p, olde, newe, e))
+	}
+	w.watches[p] = newe
+	return nil
+}
+
+func (w *simWatcher) Close() error { return nil }
+
+// emit reports the event for the path, if it is watched for it by itself,
+// by its parent directory or recursively by a directory above. Unless the
+// watches follow paths, a Remove or a Rename drops the watch of the path.
+func (w *simWatcher) emit(p string, e Event) {
+	w.mu.Lock()
+	dir, _ := split(p)
+	watched := false
+	for root, set := range w.watches {
+		if set&e != 0 && (root == p || root == dir || (set&recursive != 0 && strings.HasPrefix(p, root+sep))) {
+			watched = true
+		}
+	}
+	if !w.follow && (e == Remove || e == Rename) {
+		delete(w.watches, p)
+	}
+	w.mu.Unlock()
+	if watched {
+		w.c <- &Call{P: p, E: e, Dir: true}
+	}
+}
+
+// paths gives the watched paths, sorted.
+func (w *simWatcher) paths() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	paths := make([]string, 0, len(w.watches))
+	for p := range w.watches {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// simRecursiveWatcher is a simWatcher, which watches the paths recursively
+// the way FSEvents does.
+type simRecursiveWatcher struct {
+	*simWatcher
+}
+
+func newSimRecursiveWatcher(c chan<- EventInfo) simRecursiveWatcher {
+	return simRecursiveWatcher{&simWatcher{c: c, follow: true, watches: make(map[string]Event)}}
+}
+
+func (w simRecursiveWatcher) RecursiveWatch(p string, e Event) error {
+	return w.Watch(p, e|recursive)
+}
+
+func (w simRecursiveWatcher) RecursiveUnwatch(p string) error {
+	return w.Unwatch(p)
+}
+
+func (w simRecursiveWatcher) RecursiveRewatch(oldp, newp string, olde, newe Event) error {
+	if oldp == newp {
+		return w.rewatch(newp, olde, newe|recursive)
+	}
+	if err := w.Unwatch(oldp); err != nil {
+		return err
+	}
+	return w.Watch(newp, newe|recursive)
+}
+
+// simWatchpoint is a watchpoint set up by the simulation.
+type simWatchpoint struct {
+	events Event
+	rec    bool
+}
+
+// sim drives a tree backed by simWatcher with random filesystem operations
+// and watchpoint changes, checking after each of them the watches the tree set
+// up and the events it delivered against the model.
+//
+// The nonrecursive tree is not told about the watches dropped along with their
+// directories, so the model follows them: the watchpoints of the path are left
+// in the tree and it is watched again, once the tree changes the events it
+// watches the path for or once it creates the path anew within a recursive
+// watchpoint. For the same reason the model follows the events the tree
+// watches the directories of the recursive watchpoints for, as they are set by
+// the watchpoint, which walked them last. The recursive tree, whose watches
+// follow paths, is expected to watch only the topmost paths of the watchpoints
+// and to deliver all of the events.
+type sim struct {
+	t       *testing.T
+	r       *rand.Rand
+	name    string
+	root    string
+	w       *simWatcher
+	tree    tree
+	idle    func() bool      // reports whether the tree is done with the events
+	rec     bool             // whether the tree is the recursive one
+	dirs    map[string]bool  // the model of the filesystem, directories only
+	watches map[string]bool  // paths expected to be watched by the watcher
+	nodes   map[string]bool  // paths the nonrecursive tree is expected to have nodes for
+	recs    map[string]Event // events of the nonrecursive tree's t.rec by the paths
+	chs     []chan EventInfo
+	wps     []map[string]simWatchpoint // watchpoints of the channels by their paths
+	want    []map[string]int           // events expected for the channels
+	log     []string
+}
+
+func newSim(t *testing.T, seed int64, rec bool) *sim {
+	root, err := ioutil.TempDir("", "notify_sim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, _, err = cleanpath(root); err != nil {
+		t.Fatal(err)
+	}
+	c := make(chan EventInfo, buffer)
+	s := &sim{
+		t:       t,
+		r:       rand.New(rand.NewSource(seed)),
+		name:    fmt.Sprintf("seed=%d recursive=%t", seed, rec),
+		root:    root,
+		rec:     rec,
+		dirs:    map[string]bool{root: true},
+		watches: make(map[string]bool),
+		nodes:   make(map[string]bool),
+		recs:    make(map[string]Event),
+	}
+	if rec {
+		w := newSimRecursiveWatcher(c)
+		t := newRecursiveTree(w, c)
+		s.w, s.tree = w.simWatcher, t
+		s.idle = func() bool { return t.q.len() == 0 && !t.act.busy() }
+	} else {
+		s.w = newSimWatcher(c)
+		t := newNonrecursiveTree(s.w, c, nil)
+		s.tree = t
+		s.idle = func() bool { return t.q.len() == 0 && len(t.rec) == 0 && !t.act.busy() }
+	}
+	for i := 0; i < 3; i++ {
+		s.chs = append(s.chs, make(chan EventInfo, buffer))
+		s.wps = append(s.wps, make(map[string]simWatchpoint))
+		s.want = append(s.want, make(map[string]int))
+	}
+	return s
+}
+
+func (s *sim) close() {
+	s.tree.Close()
+	os.RemoveAll(s.root)
+}
+
+func (s *sim) fatalf(format string, v ...interface{}) {
+	s.t.Fatalf("%s (%s)\nafter:\n\t%s", fmt.Sprintf(format, v...), s.name, strings.Join(s.log, "\n\t"))
+}
+
+// pick gives a random directory of the model.
+func (s *sim) pick() string {
+	dirs := make([]string, 0, len(s.dirs))
+	for dir := range s.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs[s.r.Intn(len(dirs))]
+}
+
+// child gives a random path of an entry of the directory, unless it would be
+// too deep.
+func (s *sim) child(dir string) (string, bool) {
+	if strings.Count(dir[len(s.root):], sep) == 3 {
+		return "", false
+	}
+	return filepath.Join(dir, string('a'+rune(s.r.Intn(3)))), true
+}
+
+// leaf reports whether the directory has no subdirectories and it can be
+// removed.
+func (s *sim) leaf(path string) bool {
+	for dir := range s.dirs {
+		if strings.HasPrefix(dir, path+sep) {
+			return false
+		}
+	}
+	return path != s.root
+}
+
+// recset gives the events of t.rec of the path and its parents, which
+// the nonrecursive tree watches a directory created on the path for.
+func (s *sim) recset(path string) (e Event) {
+	for p := path; ; p, _ = split(p) {
+		e |= s.recs[p]
+		if p == s.root {
+			return e
+		}
+	}
+}
+
+// holds reports whether any channel has a watchpoint on the path or below it.
+func (s *sim) holds(path string) bool {
+	for _, wps := range s.wps {
+		for root := range wps {
+			if root == path || strings.HasPrefix(root, path+sep) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// total gives the events the nonrecursive tree is expected to watch the path
+// for: the ones of its watchpoints and of its t.rec.
+func (s *sim) total(path string) Event {
+	e := s.recs[path]
+	for _, wps := range s.wps {
+		e |= wps[path].events
+	}
+	return e
+}
+
+// totals gives the total of every path known to the model.
+func (s *sim) totals() map[string]Event {
+	m := make(map[string]Event)
+	for path := range s.nodes {
+		m[path] = s.total(path)
+	}
+	return m
+}
+
+// retotal updates the watch of the path, after its total changed from old:
+// the path is unwatched, when nothing is left to watch it for, and watched
+// again otherwise, unless it does not exist.
+func (s *sim) retotal(path string, old Event) {
+	if e := s.total(path); e != old {
+		if e != 0 && s.dirs[path] {
+			s.watches[path] = true
+		} else {
+			delete(s.watches, path)
+		}
+	}
+}
+
+// step makes a single random operation.
+func (s *sim) step() {
+	switch n := s.r.Intn(12); {
+	case n < 4:
+		if path, ok := s.child(s.pick()); ok {
+			s.mkdir(path)
+		}
+	case n < 6:
+		s.rmdir(s.pick())
+	case n < 7:
+		if path, ok := s.child(s.pick()); ok {
+			s.rename(s.pick(), path)
+		}
+	case n < 10:
+		events := []Event{Create, Remove, Rename, Create | Remove | Rename}[s.r.Intn(4)]
+		s.watch(s.r.Intn(len(s.chs)), s.pick(), s.r.Intn(2) == 0, events)
+	default:
+		s.stop(s.r.Intn(len(s.chs)))
+	}
+}
+
+func (s *sim) mkdir(path string) {
+	if s.dirs[path] {
+		return
+	}
+	s.log = append(s.log, "mkdir "+path)
+	must(os.Mkdir(path, 0755))
+	s.created(path)
+	s.w.emit(path, Create)
+}
+
+func (s *sim) rmdir(path string) {
+	if !s.leaf(path) {
+		return
+	}
+	s.log = append(s.log, "rmdir "+path)
+	must(os.Remove(path))
+	s.removed(path, Remove)
+	s.w.emit(path, Remove)
+}
+
+func (s *sim) rename(oldpath, newpath string) {
+	if !s.leaf(oldpath) || s.dirs[newpath] || strings.HasPrefix(newpath, oldpath+sep) {
+		return
+	}
+	s.log = append(s.log, "rename "+oldpath+" "+newpath)
+	must(os.Rename(oldpath, newpath))
+	s.removed(oldpath, Rename)
+	s.w.emit(oldpath, Rename)
+	s.created(newpath)
+	s.w.emit(newpath, Create)
+}
+
+// created updates the model with the directory, which was just created.
+func (s *sim) created(path string) {
+	s.dirs[path] = true
+	if !s.report(path, Create) || s.rec {
+		return
+	}
+	if e := s.recset(path); e != 0 {
+		// The directory created within a recursive watchpoint is watched,
+		// even if its previous one held watchpoints, whose watch was dropped.
+		s.nodes[path], s.recs[path], s.watches[path] = true, e, true
+	}
+}
+
+// removed updates the model with the directory, which was just removed or
+// renamed.
+func (s *sim) removed(path string, e Event) {
+	delete(s.dirs, path)
+	s.report(path, e)
+	if !s.rec {
+		delete(s.watches, path)
+	}
+}
+
+func (s *sim) watch(i int, path string, rec bool, e Event) {
+	s.log = append(s.log, fmt.Sprintf("watch %d %s rec=%t %v", i, path, rec, e))
+	p := path
+	if rec {
+		p = filepath.Join(path, "...")
+	}
+	if err := s.tree.Watch(p, s.chs[i], e); err != nil {
+		s.fatalf("Watch(%q, %d, %v)=%v", p, i, e, err)
+	}
+	old := s.totals()
+	wp := s.wps[i][path]
+	wp.events |= e
+	wp.rec = wp.rec || rec
+	s.wps[i][path] = wp
+	for p := path; !s.nodes[p]; p, _ = split(p) {
+		if s.nodes[p] = true; p == s.root {
+			break
+		}
+	}
+	if s.rec {
+		for root := range s.watches {
+			if root == path || strings.HasPrefix(path, root+sep) {
+				return
+			}
+		}
+		for root := range s.watches {
+			if strings.HasPrefix(root, path+sep) {
+				delete(s.watches, root)
+			}
+		}
+		s.watches[path] = true
+		return
+	}
+	touched := []string{path}
+	switch e := wp.events | Create; {
+	case !wp.rec:
+	case s.recs[path]&e == e:
+	case s.recs[path] == 0:
+		// The directories are read from the filesystem.
+		s.recs[path] = e
+		for dir := range s.dirs {
+			if strings.HasPrefix(dir, path+sep) {
+				s.nodes[dir], s.recs[dir] = true, s.recs[dir]|e
+				touched = append(touched, dir)
+			}
+		}
+	default:
+		// The nodes of the tree are walked.
+		s.recs[path] |= e
+		for p := range s.nodes {
+			if strings.HasPrefix(p, path+sep) {
+				s.recs[p] |= e
+				touched = append(touched, p)
+			}
+		}
+	}
+	for _, path := range touched {
+		s.retotal(path, old[path])
+	}
+}
+
+func (s *sim) stop(i int) {
+	s.log = append(s.log, fmt.Sprintf("stop %d", i))
+	s.tree.Stop(s.chs[i])
+	old := s.totals()
+	s.wps[i] = make(map[string]simWatchpoint)
+	if s.rec {
+		for root := range s.watches {
+			if !s.holds(root) {
+				delete(s.watches, root)
+			}
+		}
+		return
+	}
+	// The t.rec of a path is set anew to the one of its parent along with
+	// the events of the recursive watchpoints left on the path.
+	paths := make([]string, 0, len(s.nodes))
+	for p := range s.nodes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if s.recs[p] == 0 {
+			continue
+		}
+		dir, _ := split(p)
+		e := s.recs[dir]
+		for _, wps := range s.wps {
+			if wp := wps[p]; wp.rec {
+				e |= wp.events
+			}
+		}
+		if e != 0 {
+			s.recs[p] = e | Create
+		} else {
+			delete(s.recs, p)
+		}
+	}
+	for path, e := range old {
+		s.retotal(path, e)
+	}
+}
+
+// report accounts the event for the path to the channels, if the watcher is
+// expected to report it. It reports whether it is.
+func (s *sim) report(path string, e Event) bool {
+	if !s.rec {
+		dir, _ := split(path)
+		if !(s.watches[dir] && s.total(dir)&e != 0) && !(s.watches[path] && s.total(path)&e != 0) {
+			return false
+		}
+	}
+	s.expect(path, e)
+	return true
+}
+
+// expect accounts the event for the path to the channels, which watchpoints
+// are notified of it: the ones on the path itself and on its parent, and
+// the recursive ones on the directories above.
+func (s *sim) expect(path string, e Event) {
+	dir, _ := split(path)
+	for i, wps := range s.wps {
+		for root, wp := range wps {
+			if wp.events&e == 0 {
+				continue
+			}
+			if root == path || root == dir || (wp.rec && strings.HasPrefix(dir, root+sep)) {
+				s.want[i][fmt.Sprintf("%v %s", e, path)]++
+			}
+		}
+	}
+}
+
+// settle waits until the tree is done with the events reported so far.
+func (s *sim) settle() {
+	deadline := time.Now().Add(timeout())
+	for quiet := 0; quiet < 2; {
+		if s.idle() {
+			quiet++
+		} else {
+			quiet = 0
+		}
+		if time.Now().After(deadline) {
+			s.fatalf("timed out waiting for the tree to settle")
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+// check compares the watches and the events delivered with the model.
+func (s *sim) check() {
+	s.settle()
+	var paths []string
+	for p := range s.watches {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	if got := s.w.paths(); strings.Join(got, ",") != strings.Join(paths, ",") {
+		s.fatalf("want watched %v; got %v", paths, got)
+	}
+	if len(s.w.errs) != 0 {
+		s.fatalf("inconsistent watcher calls: %v", s.w.errs)
+	}
+	for i, c := range s.chs {
+		got := make(map[string]int)
+		for len(c) != 0 {
+			ei := <-c
+			got[fmt.Sprintf("%v %s", ei.Event(), ei.Path())]++
+		}
+		for k, n := range s.want[i] {
+			if got[k] != n {
+				s.fatalf("want %d of %q for channel %d; got %d (all: %v)", n, k, i, got[k], got)
+			}
+			delete(got, k)
+		}
+		if len(got) != 0 {
+			s.fatalf("want no other events for channel %d; got %v", i, got)
+		}
+		s.want[i] = make(map[string]int)
+	}
+}
+
+func TestTreeSimulation(t *testing.T) {
+	seeds, steps := 20, 60
+	if testing.Short() {
+		seeds = 5
+	}
+	for _, rec := range []bool{false, true} {
+		for seed := int64(1); seed <= int64(seeds); seed++ {
+			s := newSim(t, seed, rec)
+			for i := 0; i < steps; i++ {
+				s.step()
+				s.check()
+			}
+			s.close()
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// historyDrain is the longest time the HistoryDoneEventInfo event waits for
+// the replayed events to be dispatched.
+var historyDrain = time.Second
+
+// Sequenced is an EventInfo, which carries the identifier the watcher gave
+// the change. It is implemented by the events reported by FSEvents, which IDs
+// grow monotonically across the whole system and survive reboots. Persisting
+// the ID of the last processed event allows for resuming with WatchSince after
+// a restart, without missing the changes made in between.
+type Sequenced interface {
+	EventInfo
+	ID() uint64 // the event ID, e.g. FSEventStreamEventId for FSEvents
+}
+
+// HistoryDoneEventInfo is the EventInfo delivered for watchpoints set up with
+// WatchSince or WithHistoryDone once the historical events are all replayed,
+// i.e. the events following it are the changes made from now on. Its Event()
+// is 0 and its Path() is the watched directory.
+type HistoryDoneEventInfo interface {
+	EventInfo
+	HistoryDone() // marks the end of the replay
+}
+
+// WatchSince works like Watch, but the watchpoint first replays the changes
+// made since the event with the given ID, e.g. the last one processed before
+// the process exited, and then goes on reporting the new ones. The IDs are
+// given by the events which implement the Sequenced interface. Once the changes
+// are all replayed, a HistoryDoneEventInfo event is delivered, like with
+// WithHistoryDone.
+//
+// It is supported by FSEvents only, which passes the ID to FSEventStreamCreate.
+// The replay happens only if the watchpoint sets up a new stream, i.e. when the
+// path is not already covered by an existing recursive watchpoint. For other
+// watchers WatchSince fails with a *WatchError wrapping ErrUnsupported.
+//
+//   var id uint64 // loaded from a checkpoint
+//
+//   if err := notify.WatchSince("/path/to/dir/...", c, id, notify.All); err != nil {
+//       log.Fatal(err)
+//   }
+//   for ei := range c {
+//       if _, ok := ei.(notify.HistoryDoneEventInfo); ok {
+//           // caught up, switch to the live mode
+//           continue
+//       }
+//       // process ei, then checkpoint ei.(notify.Sequenced).ID()
+//   }
+func WatchSince(path string, c chan<- EventInfo, sinceID uint64, events ...Event) error {
+	return defaultTree.WatchSince(path, c, sinceID, events...)
+}
+
+// sinceWatcher is implemented by the watchers, which are able to replay the
+// changes made since a historical event ID.
+type sinceWatcher interface {
+	// setSince makes the following watch of the path start at the event with
+	// the given ID, zero ID clears it. It reports whether replaying is
+	// supported.
+	setSince(path string, id uint64) bool
+}
+
+// setSince passes the ID for the path to the watcher w wraps, if it is able
+// to replay the changes.
+func (w hookWatcher) setSince(path string, id uint64) bool {
+	sw, ok := w.watcher.(sinceWatcher)
+	return ok && sw.setSince(kernelPath(path), id)
+}
+
+// WatchSince sets up a watchpoint, which watch starts at the event with the
+// given ID, if the underlying watcher supports replaying the changes.
+func (t *pipeTree) WatchSince(path string, c chan<- EventInfo, id uint64, events ...Event) error {
+	dir, _, err := cleanpath(path)
+	if err != nil {
+		return err
+	}
+	sw, ok := watcherOf(t.underlying()).(sinceWatcher)
+	if !ok || !sw.setSince(dir, id) {
+		return &WatchError{Op: "watch", Path: dir, Err: ErrUnsupported}
+	}
+	// Clear the ID, if the watch was not set up by the call, e.g. it was
+	// already covered by another one.
+	defer sw.setSince(dir, 0)
+	return t.WatchWithOptions(path, c, joinevents(events), WithHistoryDone())
+}
+
+// WithHistoryDone makes notify deliver a HistoryDoneEventInfo event once
+// the watchpoint is done with replaying the historical events, so that
+// a consumer catching up with the changes knows when it is done with them.
+// FSEvents replays historical events for a newly created stream, the event is
+// delivered once it reports the FSEventsHistoryDone marker. Other watchers
+// replay nothing, for them the event is delivered right after the watchpoint
+// was set up, i.e. after the tree was scanned for a recursive one.
+//
+// The event is delivered once the replayed events were dispatched, but it is
+// not passed through the other options, e.g. filters or WithSerialize, so
+// the events held back by them, e.g. by WithCreateCollapse, may be delivered
+// after it. It waits for the dispatch to settle at most a second, so it may be
+// delivered before the last replayed events, if the path changes constantly.
+// Like any other event it is dropped if the receiver is too slow.
+func WithHistoryDone() Option {
+	return func(o *options) {
+		o.history = true
+	}
+}
+
+// historyDone is an event telling the historical events were all replayed.
+type historyDone struct {
+	path string
+}
+
+var _ HistoryDoneEventInfo = (*historyDone)(nil)
+var _ fmt.Stringer = (*historyDone)(nil)
+var _ isDirer = (*historyDone)(nil)
+
+func (e *historyDone) Event() Event         { return 0 }
+func (e *historyDone) Path() string         { return e.path }
+func (e *historyDone) Sys() interface{}     { return nil }
+func (e *historyDone) HistoryDone()         {}
+func (e *historyDone) isDir() (bool, error) { return true, nil }
+
+// String implements fmt.Stringer interface.
+func (e *historyDone) String() string {
+	return `history done: "` + e.Path() + `"`
+}
+
+// history gives an arm function, which makes the tree t deliver a historyDone
+// event to a pipe on dir once its watch is ready and the events replayed
+// before are dispatched.
+func history(t *pipeTree, dir string) (arm func(*pipe)) {
+	return func(p *pipe) {
+		ready := t.Ready(dir)
+		go func() {
+			if ready != nil {
+				<-ready
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), historyDrain)
+			t.Quiesce(ctx, nil)
+			cancel()
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if !p.closed() {
+				p.send(&historyDone{path: dir})
+			}
+		}()
+	}
+}
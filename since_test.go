@@ -0,0 +1,35 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryDone(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	c := make(chan EventInfo, buffer)
+	must(tr.WatchWithOptions(dir, c, Create, WithHistoryDone()))
+	defer tr.Stop(c)
+	select {
+	case ei := <-c:
+		if _, ok := ei.(HistoryDoneEventInfo); !ok {
+			t.Fatalf("want HistoryDoneEventInfo; got %v", ei)
+		}
+		if ei.Path() != dir || ei.Event() != 0 {
+			t.Fatalf("want history done for %q; got %v", dir, ei)
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	// The marker is delivered once, the live events follow.
+	file := filepath.Join(dir, "file")
+	must(ioutil.WriteFile(file, nil, 0644))
+	tr.Expect(c, &Call{P: file, E: Create})
+}
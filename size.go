@@ -0,0 +1,126 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SizeChange tells how the size of a file changed with a Write event.
+type SizeChange uint8
+
+// Size changes reported by SizeEventInfo.
+const (
+	SizeModified  SizeChange = iota // size stayed the same, e.g. in-place modification
+	SizeAppended                    // size increased
+	SizeTruncated                   // size decreased
+)
+
+var szstr = map[SizeChange]string{
+	SizeModified:  "notify.SizeModified",
+	SizeAppended:  "notify.SizeAppended",
+	SizeTruncated: "notify.SizeTruncated",
+}
+
+// String implements fmt.Stringer interface.
+func (c SizeChange) String() string {
+	if s, ok := szstr[c]; ok {
+		return s
+	}
+	return "<unknown size change>"
+}
+
+// SizeEventInfo is a StatEventInfo, which additionally tells how the size of
+// the file changed since the previous event for it. Write events delivered for
+// watchpoints set up with WithSizeTracking implement it.
+type SizeEventInfo interface {
+	StatEventInfo
+	SizeChange() SizeChange
+}
+
+// sizeEvent attaches a size change to an event.
+type sizeEvent struct {
+	*statEvent
+	change SizeChange
+}
+
+var _ SizeEventInfo = (*sizeEvent)(nil)
+
+func (e *sizeEvent) SizeChange() SizeChange { return e.change }
+
+// sizes gives a stage, which tracks the sizes of the files under dir and
+// tells for every Write event how the size of its file changed. The cache is
+// seeded with the files found under dir, recursively if isrec is true; files
+// which are not known yet or which were replaced in the meantime are assumed
+// to have been empty.
+func sizes(dir string, isrec bool) stage {
+	var mu sync.Mutex
+	cache := make(map[string]os.FileInfo)
+	seed := func(path string, fi os.FileInfo) {
+		if fi.Mode().IsRegular() {
+			cache[filepath.Join(dir, path)] = fi
+		}
+	}
+	switch fi, err := os.Lstat(dir); {
+	case err != nil:
+	case !fi.IsDir():
+		cache[dir] = fi
+	case isrec:
+		filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err == nil {
+				seed(path[len(dir):], fi)
+			}
+			return nil
+		})
+	default:
+		fis, _ := ioutil.ReadDir(dir)
+		for _, fi := range fis {
+			seed(fi.Name(), fi)
+		}
+	}
+	return func(next handler) handler {
+		return func(ei EventInfo) {
+			path := normalize(ei.Path())
+			if ei.Event()&Write == 0 {
+				if ei.Event()&(Remove|Rename) != 0 {
+					mu.Lock()
+					delete(cache, path)
+					mu.Unlock()
+				}
+				next(ei)
+				return
+			}
+			se, ok := ei.(*statEvent)
+			if !ok {
+				fi, _ := os.Lstat(ei.Path())
+				se = &statEvent{EventInfo: ei, fi: fi}
+			}
+			var prev int64
+			mu.Lock()
+			if old, ok := cache[path]; ok && se.fi != nil && os.SameFile(old, se.fi) {
+				prev = old.Size()
+			}
+			if se.fi != nil {
+				cache[path] = se.fi
+			} else {
+				delete(cache, path)
+			}
+			mu.Unlock()
+			change := SizeModified
+			if se.fi != nil {
+				switch size := se.fi.Size(); {
+				case size > prev:
+					change = SizeAppended
+				case size < prev:
+					change = SizeTruncated
+				}
+			}
+			next(&sizeEvent{statEvent: se, change: change})
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSizes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_sizes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	log := filepath.Join(dir, "log")
+	must(ioutil.WriteFile(log, []byte("abc"), 0644))
+	ch := NewChans(1)
+	p := newPipe(ch[0], stat, sizes(dir, false))
+	defer p.stop()
+	write := func(path string, content string, flag int) {
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteString(content)
+		must(nonil(err, f.Close()))
+	}
+	cases := [...]struct {
+		path    string
+		content string
+		flag    int
+		change  SizeChange
+	}{
+		{log, "def", os.O_APPEND, SizeAppended},                      // i=0
+		{log, "xyz", 0, SizeModified},                                // i=1
+		{log, "a", os.O_TRUNC, SizeTruncated},                        // i=2
+		{filepath.Join(dir, "new"), "abc", 0, SizeAppended},          // i=3
+		{filepath.Join(dir, "new"), "ab", os.O_TRUNC, SizeTruncated}, // i=4
+	}
+	for i, cas := range cases {
+		write(cas.path, cas.content, cas.flag)
+		p.c <- &Call{P: cas.path, E: Write}
+		ei := <-ch[0]
+		se, ok := ei.(SizeEventInfo)
+		if !ok {
+			t.Fatalf("want SizeEventInfo; got %T (i=%d)", ei, i)
+		}
+		if se.SizeChange() != cas.change {
+			t.Errorf("want change=%v; got %v (i=%d)", cas.change, se.SizeChange(), i)
+		}
+		if se.FileInfo() == nil {
+			t.Errorf("want FileInfo()!=nil (i=%d)", i)
+		}
+	}
+	must(os.Remove(log))
+	p.c <- &Call{P: log, E: Remove}
+	if ei := <-ch[0]; ei.Event() != Remove {
+		t.Fatalf("want Remove; got %v", ei)
+	}
+	write(log, "abcdef", 0)
+	p.c <- &Call{P: log, E: Write}
+	if se := (<-ch[0]).(SizeEventInfo); se.SizeChange() != SizeAppended {
+		t.Fatalf("want the recreated file to be appended to; got %v", se.SizeChange())
+	}
+}
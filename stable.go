@@ -0,0 +1,132 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"sync"
+	"time"
+)
+
+// WatchStable watches the path like Watch does, but it delivers an event for
+// a file only once the file became stable, i.e. no Create or Write event was
+// reported for it within quiet. It is the portable way of telling when a file,
+// e.g. an upload, was written completely without relying on notify.InCloseWrite,
+// which is not available on every platform:
+//
+//   c := make(chan notify.EventInfo, 16)
+//   if err := notify.WatchStable("/srv/uploads", c, 5*time.Second, notify.Create); err != nil {
+//       log.Fatal(err)
+//   }
+//   for ei := range c {
+//       process(ei.Path()) // the file was not written to for 5 seconds
+//   }
+//
+// The Create and Write events reported for a file are held back, every one of
+// them restarts the quiet period of the file, and once it elapsed a single
+// event is delivered, the first of them, e.g. the Create of a new file. It
+// implements the CoalescedEventInfo interface, which Count tells how many
+// events it stands for. It is delivered if any of Create and Write is amongst
+// the events given, regardless of which of them it is. If the file is removed
+// or renamed before it became stable, the events held back are dropped and
+// only the Remove or Rename is delivered, if it was asked for. The other
+// events, and the events for directories, are delivered right away.
+//
+// Use Stop to remove watchpoints set up with WatchStable; the files, which did
+// not become stable yet, are not reported.
+func WatchStable(path string, c chan<- EventInfo, quiet time.Duration, events ...Event) error {
+	return defaultTree.WatchStable(path, c, quiet, joinevents(events))
+}
+
+// WatchStable watches the path with a pipe of c, which holds the events back
+// until their files become stable.
+func (t *pipeTree) WatchStable(path string, c chan<- EventInfo, quiet time.Duration, e Event) error {
+	s, discard := stable(quiet, e, pathKey)
+	p, err := t.WatchPipe(path, c, []stage{s}, e|Create|Write|Remove|Rename)
+	if err != nil {
+		return err
+	}
+	t.OnStop(p, discard)
+	return nil
+}
+
+// stable gives a stage, which holds back the Create and Write events of
+// a file and passes the first of them on once there were none for the file
+// within quiet. Only the events from the given set are passed on, the held
+// back ones if it has any of Create and Write. The events are matched by their
+// keys. Calling discard drops the events held back.
+func stable(quiet time.Duration, e Event, key keyFunc) (s stage, discard func()) {
+	type held struct {
+		ei  EventInfo // first event held back
+		n   int       // number of the events held back
+		gen int       // bumped on every event, invalidates older timers
+		t   Timer
+	}
+	var fl flushers
+	s = func(next handler) handler {
+		var mu sync.Mutex
+		pending := make(map[string]*held)
+		fl.add(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			for k, h := range pending {
+				h.t.Stop()
+				delete(pending, k)
+			}
+		})
+		// The mutex is held while passing the events on, so that an event
+		// passed by a timer is not reordered with later events for its file.
+		return func(ei EventInfo) {
+			ev := ei.Event()
+			if d, ok := ei.(isDirer); ok {
+				if isdir, err := d.isDir(); err == nil && isdir {
+					if ev&e != 0 {
+						next(ei)
+					}
+					return
+				}
+			}
+			k := key(ei)
+			mu.Lock()
+			defer mu.Unlock()
+			h, ok := pending[k]
+			switch {
+			case ev&(Create|Write) != 0:
+				if !ok {
+					h = &held{ei: ei}
+					pending[k] = h
+				} else {
+					h.t.Stop()
+				}
+				h.n += count(ei)
+				h.gen++
+				hh, g := h, h.gen
+				h.t = afterFunc(quiet, func() {
+					mu.Lock()
+					defer mu.Unlock()
+					if pending[k] != hh || hh.gen != g {
+						return
+					}
+					delete(pending, k)
+					if e&(Create|Write) == 0 {
+						return
+					}
+					if hh.n == count(hh.ei) {
+						next(hh.ei)
+					} else {
+						next(&coalesced{EventInfo: hh.ei, n: hh.n})
+					}
+				})
+				return
+			case ok && ev&(Remove|Rename) != 0:
+				h.t.Stop()
+				delete(pending, k)
+			}
+			if ev&e != 0 {
+				next(ei)
+			}
+		}
+	}
+	return s, fl.flush
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStable(t *testing.T) {
+	clk := newFakeClock()
+	SetClock(clk)
+	defer SetClock(nil)
+	var got []string
+	s, discard := stable(time.Second, Create|Remove, pathKey)
+	fn := s(func(ei EventInfo) {
+		got = append(got, fmt.Sprintf("%v %s %d", ei.Event(), ei.Path(), count(ei)))
+	})
+	ev := func(path string, e Event) EventInfo { return &Call{P: path, E: e, Dir: path == "/sub"} }
+	cases := [...]struct {
+		events  []EventInfo
+		advance time.Duration
+		want    []string
+	}{
+		// i=0: the file is still written to
+		{
+			[]EventInfo{ev("/a", Create), ev("/a", Write)},
+			time.Second / 2,
+			nil,
+		},
+		// i=1: every event restarts the quiet period
+		{
+			[]EventInfo{ev("/a", Write)},
+			time.Second / 2,
+			nil,
+		},
+		// i=2
+		{
+			nil,
+			time.Second / 2,
+			[]string{"notify.Create /a 3"},
+		},
+		// i=3: a file removed before it became stable
+		{
+			[]EventInfo{ev("/b", Write), ev("/b", Remove)},
+			time.Second,
+			[]string{"notify.Remove /b 1"},
+		},
+		// i=4: directories are passed right away
+		{
+			[]EventInfo{ev("/c", Write), ev("/sub", Create), ev("/sub", Write)},
+			time.Second,
+			[]string{"notify.Create /sub 1", "notify.Write /c 1"},
+		},
+	}
+	for i, cas := range cases {
+		got = nil
+		for _, ei := range cas.events {
+			fn(ei)
+		}
+		clk.Advance(cas.advance)
+		if !reflect.DeepEqual(got, cas.want) {
+			t.Errorf("want %v; got %v (i=%d)", cas.want, got, i)
+		}
+	}
+	got = nil
+	fn(ev("/d", Create))
+	discard()
+	clk.Advance(time.Second)
+	if len(got) != 0 {
+		t.Errorf("want no events after discard; got %v", got)
+	}
+}
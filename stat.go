@@ -0,0 +1,70 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"fmt"
+	"os"
+)
+
+// StatEventInfo is an EventInfo, which additionally describes the state of
+// the file or directory at the time the event was dispatched. Events delivered
+// for watchpoints set up with WithStat implement it.
+type StatEventInfo interface {
+	EventInfo
+	FileInfo() os.FileInfo // result of os.Lstat, nil if it failed or for Remove
+}
+
+// statEvent attaches os.FileInfo to an event.
+type statEvent struct {
+	EventInfo
+	fi       os.FileInfo
+	dev, ino uint64 // identity of the file, set by statInode
+	idok     bool
+}
+
+var _ InodeEventInfo = (*statEvent)(nil)
+var _ isDirer = (*statEvent)(nil)
+var _ OwnEventInfo = (*statEvent)(nil)
+var _ systemer = (*statEvent)(nil)
+var _ CoalescedEventInfo = (*statEvent)(nil)
+
+func (e *statEvent) FileInfo() os.FileInfo { return e.fi }
+func (e *statEvent) Own() bool             { return isown(e.EventInfo) }
+func (e *statEvent) isSystem() bool        { return issystem(e.EventInfo) }
+func (e *statEvent) Count() int            { return count(e.EventInfo) }
+
+// Inode implements InodeEventInfo interface.
+func (e *statEvent) Inode() (dev, ino uint64, ok bool) { return e.dev, e.ino, e.idok }
+
+func (e *statEvent) isDir() (bool, error) {
+	if e.fi != nil {
+		return e.fi.IsDir(), nil
+	}
+	if d, ok := e.EventInfo.(isDirer); ok {
+		return d.isDir()
+	}
+	return false, nil
+}
+
+// String implements fmt.Stringer interface.
+func (e *statEvent) String() string {
+	if s, ok := e.EventInfo.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return e.Event().String() + `: "` + e.Path() + `"`
+}
+
+// stat gives a stage, which attaches to every event the os.FileInfo of its
+// path, except Remove events.
+func stat(next handler) handler {
+	return func(ei EventInfo) {
+		var fi os.FileInfo
+		if ei.Event()&Remove == 0 {
+			fi, _ = os.Lstat(ei.Path())
+		}
+		next(&statEvent{EventInfo: ei, fi: fi})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "file")
+	must(ioutil.WriteFile(file, []byte("XD"), 0644))
+	ch := NewChans(1)
+	p := newPipe(ch[0], stat)
+	defer p.stop()
+	cases := [...]struct {
+		ei   EventInfo
+		size int64 // -1 if no FileInfo is expected
+		dir  bool
+	}{
+		{&Call{P: file, E: Write}, 2, false},                                // i=0
+		{&Call{P: dir, E: Create}, 0, true},                                 // i=1
+		{&Call{P: file, E: Remove}, -1, false},                              // i=2
+		{&Call{P: filepath.Join(dir, "nonexistent"), E: Create}, -1, false}, // i=3
+	}
+	for i, cas := range cases {
+		p.c <- cas.ei
+		select {
+		case ei := <-ch[0]:
+			sei, ok := ei.(StatEventInfo)
+			if !ok {
+				t.Fatalf("want StatEventInfo; got %T (i=%d)", ei, i)
+			}
+			if err := EqualEventInfo(cas.ei, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+			fi := sei.FileInfo()
+			switch {
+			case cas.size == -1 && fi != nil:
+				t.Errorf("want FileInfo=nil; got %v (i=%d)", fi, i)
+			case cas.size == -1:
+			case fi == nil:
+				t.Errorf("want FileInfo!=nil (i=%d)", i)
+			case fi.IsDir() != cas.dir:
+				t.Errorf("want IsDir()=%t; got %t (i=%d)", cas.dir, fi.IsDir(), i)
+			case !cas.dir && fi.Size() != cas.size:
+				t.Errorf("want Size()=%d; got %d (i=%d)", cas.size, fi.Size(), i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"sync"
+)
+
+// PathState is the state of a single path, which the handlers of its events
+// keep across them, see WithPathState. It is safe for concurrent use.
+type PathState struct {
+	mu sync.Mutex
+	m  map[interface{}]interface{}
+}
+
+// Load gives the value stored for the key, false if there is none.
+func (s *PathState) Load(key interface{}) (value interface{}, ok bool) {
+	s.mu.Lock()
+	value, ok = s.m[key]
+	s.mu.Unlock()
+	return value, ok
+}
+
+// Store sets the value for the key.
+func (s *PathState) Store(key, value interface{}) {
+	s.mu.Lock()
+	if s.m == nil {
+		s.m = make(map[interface{}]interface{})
+	}
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+// Delete removes the value stored for the key.
+func (s *PathState) Delete(key interface{}) {
+	s.mu.Lock()
+	delete(s.m, key)
+	s.mu.Unlock()
+}
+
+// Update sets the value for the key to the one given by fn for the value
+// currently stored, ok is false if there is none. No other call is made for
+// the state while fn runs, so fn must not use the state itself. It gives
+// the value set.
+func (s *PathState) Update(key interface{}, fn func(old interface{}, ok bool) interface{}) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.m[key]
+	if s.m == nil {
+		s.m = make(map[interface{}]interface{})
+	}
+	v := fn(old, ok)
+	s.m[key] = v
+	return v
+}
+
+// StatefulEventInfo is an EventInfo delivered for watchpoints set up with
+// WithPathState. It carries the state of the path it was reported for.
+type StatefulEventInfo interface {
+	EventInfo
+	State() *PathState // the state of the path of the event
+}
+
+// WithPathState makes notify keep a PathState for every path the watchpoint
+// delivers events for, which the events delivered carry implementing
+// the StatefulEventInfo interface, so that the handlers of the events are
+// able to keep state scoped to the path without a map of their own:
+//
+//   for ei := range c {
+//       st := ei.(notify.StatefulEventInfo).State()
+//       n := st.Update("writes", func(old interface{}, ok bool) interface{} {
+//           if !ok {
+//               return 1
+//           }
+//           return old.(int) + 1
+//       })
+//       log.Println(ei.Path(), "written", n, "times")
+//   }
+//
+// The state of a path is dropped once a Remove or a Rename event is delivered
+// for it, the event still carries it; a later event for the path starts with
+// an empty state. All the states are dropped when the watchpoint is removed.
+// The paths are compared like with WithDedup, see WithCaseFolding. The events
+// delivered implement Sequenced and PairedEventInfo no longer, if either
+// WithTotalOrder or WithPathPair was given as well.
+func WithPathState() Option {
+	return func(o *options) {
+		o.state = true
+	}
+}
+
+// stateful is an event carrying the state of its path.
+type stateful struct {
+	EventInfo
+	st *PathState
+}
+
+var _ StatefulEventInfo = (*stateful)(nil)
+var _ isDirer = (*stateful)(nil)
+var _ OwnEventInfo = (*stateful)(nil)
+var _ systemer = (*stateful)(nil)
+var _ CoalescedEventInfo = (*stateful)(nil)
+
+func (e *stateful) State() *PathState { return e.st }
+func (e *stateful) Own() bool         { return isown(e.EventInfo) }
+func (e *stateful) isSystem() bool    { return issystem(e.EventInfo) }
+func (e *stateful) Count() int        { return count(e.EventInfo) }
+
+func (e *stateful) isDir() (bool, error) {
+	if d, ok := e.EventInfo.(isDirer); ok {
+		return d.isDir()
+	}
+	return false, nil
+}
+
+// The stateful events keep the optional interfaces of the events they wrap.
+type (
+	statefulStat    struct{ *stateful }
+	statefulAck     struct{ *stateful }
+	statefulStatAck struct{ *stateful }
+)
+
+var _ InodeEventInfo = statefulStat{}
+var _ AckEventInfo = statefulAck{}
+var _ InodeEventInfo = statefulStatAck{}
+var _ AckEventInfo = statefulStatAck{}
+
+func (e statefulStat) FileInfo() os.FileInfo    { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e statefulAck) Done()                     { e.EventInfo.(AckEventInfo).Done() }
+func (e statefulStatAck) FileInfo() os.FileInfo { return e.EventInfo.(StatEventInfo).FileInfo() }
+func (e statefulStatAck) Done()                 { e.EventInfo.(AckEventInfo).Done() }
+
+func (e statefulStat) Inode() (dev, ino uint64, ok bool)    { return inodeOf(e.EventInfo) }
+func (e statefulStatAck) Inode() (dev, ino uint64, ok bool) { return inodeOf(e.EventInfo) }
+
+// pathState gives a stage, which passes the events on together with the state
+// of the path given by key.
+func pathState(key keyFunc) stage {
+	return func(next handler) handler {
+		var mu sync.Mutex
+		states := make(map[string]*PathState)
+		return func(ei EventInfo) {
+			k := key(ei)
+			mu.Lock()
+			st, ok := states[k]
+			if !ok {
+				st = &PathState{}
+				states[k] = st
+			}
+			if ei.Event()&(Remove|Rename) != 0 {
+				delete(states, k)
+			}
+			mu.Unlock()
+			_, stat := ei.(StatEventInfo)
+			_, ack := ei.(AckEventInfo)
+			s := &stateful{EventInfo: ei, st: st}
+			switch {
+			case stat && ack:
+				next(statefulStatAck{s})
+			case stat:
+				next(statefulStat{s})
+			case ack:
+				next(statefulAck{s})
+			default:
+				next(s)
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import "testing"
+
+func TestPathState(t *testing.T) {
+	var got []*PathState
+	fn := pathState(pathKey)(func(ei EventInfo) {
+		got = append(got, ei.(StatefulEventInfo).State())
+	})
+	calls := []Call{
+		{P: "/a", E: Create}, // i=0
+		{P: "/b", E: Create}, // i=1
+		{P: "/a", E: Write},  // i=2
+		{P: "/a", E: Remove}, // i=3
+		{P: "/a", E: Create}, // i=4
+	}
+	for i := range calls {
+		fn(&calls[i])
+	}
+	got[0].Store("n", 1)
+	if got[0] != got[2] || got[0] != got[3] {
+		t.Fatal("want the same state for the events of the same path")
+	}
+	if got[0] == got[1] {
+		t.Fatal("want distinct states for distinct paths")
+	}
+	if got[4] == got[0] {
+		t.Fatal("want the state dropped after Remove")
+	}
+	if v := got[2].Update("n", func(old interface{}, ok bool) interface{} {
+		if !ok {
+			return 0
+		}
+		return old.(int) + 1
+	}); v != 2 {
+		t.Errorf("want v=2; got %v", v)
+	}
+	if _, ok := got[4].Load("n"); ok {
+		t.Error("want empty state for a path created again")
+	}
+	got[2].Delete("n")
+	if _, ok := got[0].Load("n"); ok {
+		t.Error("want the value deleted")
+	}
+}
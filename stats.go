@@ -0,0 +1,142 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrTooManyNodes is reported by a watchpoint set up with WithMaxNodes, which
+// would make the tree hold more nodes than allowed.
+var ErrTooManyNodes = errors.New("too many directories to watch")
+
+// Approximate sizes of a node and of a single watchpoint entry, including
+// the overhead of the maps holding them.
+const (
+	nodeBytes  = 160
+	entryBytes = 48
+)
+
+// TreeStats describes the in-memory tree of the watched paths.
+type TreeStats struct {
+	Nodes       int // number of nodes, one per watched directory and its parents
+	Watchpoints int // number of channels registered on the nodes
+	Bytes       int // approximate memory held by the nodes, in bytes
+
+	// Expanded is the number of directories, which recursive watchpoints
+	// emulated with a watch per directory started watching once they were
+	// created. It grows with the churn of the watched trees, it is always
+	// zero for watchers, which watch directory trees natively.
+	Expanded int
+
+	// MaxChildren is the largest number of subdirectories watched within
+	// a single directory.
+	MaxChildren int
+
+	// Crowded gives the subdirectory counts of the directories, which
+	// recursive watchpoints set up with WithMaxChildrenPerDir watch
+	// non-recursively, by their paths.
+	Crowded map[string]int
+}
+
+// Stats gives the statistics of the tree notify keeps for the watched paths.
+// Recursive watchpoints are emulated with a node per directory for watchers,
+// which are not able to watch directory trees natively, e.g. inotify, so
+// the tree grows with the size of the watched trees.
+func Stats() TreeStats {
+	return defaultTree.Stats()
+}
+
+// WithMaxNodes limits the size of the tree notify keeps for the watched paths:
+// setting up a recursive watchpoint fails with ErrTooManyNodes, if the tree
+// together with a node for every directory under the watched path would hold
+// more than n nodes. It protects processes from exhausting their memory by
+// watching an enormous directory tree by accident.
+//
+// The directories are counted before the watchpoint is set up, directories
+// created later on are watched regardless of the limit. The option has no
+// effect for watchers, which watch directory trees natively.
+func WithMaxNodes(n int) Option {
+	return func(o *options) {
+		o.maxNodes = n
+	}
+}
+
+// Stats gives the statistics of the underlying tree.
+func (t *pipeTree) Stats() TreeStats {
+	s := treeStats(t.underlying())
+	t.crowdedStats(&s)
+	return s
+}
+
+// treeStats walks the nodes of t, counting them.
+func treeStats(t tree) (s TreeStats) {
+	fn := func(nd node) error {
+		s.Nodes++
+		s.Bytes += nodeBytes + 2*len(nd.Name)
+		if len(nd.Child) > s.MaxChildren {
+			s.MaxChildren = len(nd.Child)
+		}
+		for c := range nd.Watch {
+			if c != nil {
+				s.Watchpoints++
+			}
+			s.Bytes += entryBytes
+		}
+		return nil
+	}
+	switch t := t.(type) {
+	case *nonrecursiveTree:
+		t.rw.RLock()
+		t.root.nd.Walk(fn)
+		s.Expanded = t.expanded
+		t.rw.RUnlock()
+	case *recursiveTree:
+		t.rw.RLock()
+		t.root.nd.Walk(fn)
+		t.rw.RUnlock()
+	}
+	return s
+}
+
+var errLimit = errors.New("limit reached")
+
+// countdirs counts the directories under dir, including dir itself, which
+// the skip function does not report as ignored. It stops counting once
+// the count exceeds max.
+func countdirs(dir string, skip skipFunc, max int) (n int) {
+	fn := func(path string, fi os.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case !fi.IsDir():
+			return nil
+		}
+		if rel, ok := relpath(dir, path); ok && skip != nil && skip(rel, true) {
+			return filepath.SkipDir
+		}
+		if n++; n > max {
+			return errLimit
+		}
+		return nil
+	}
+	filepath.Walk(dir, fn)
+	return n
+}
+
+// checkNodes fails with ErrTooManyNodes, if watching dir recursively would
+// make the tree t hold more than max nodes.
+func checkNodes(t *pipeTree, dir string, skip skipFunc, max int) error {
+	if _, native := watcherOf(t.underlying()).(recursiveWatcher); native {
+		return nil
+	}
+	left := max - t.Stats().Nodes
+	if left < 0 || countdirs(dir, skip, left) > left {
+		return &WatchError{Op: "watch", Path: dir, Err: ErrTooManyNodes}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCountdirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, sub := range []string{"a", "b/c", ".d"} {
+		must(os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0755))
+	}
+	must(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644))
+	cases := [...]struct {
+		skip skipFunc
+		max  int
+		n    int
+	}{
+		{nil, 10, 5},   // i=0
+		{isdot, 10, 4}, // i=1
+		{nil, 2, 3},    // i=2: stops once max is exceeded
+	}
+	for i, cas := range cases {
+		if n := countdirs(dir, cas.skip, cas.max); n != cas.n {
+			t.Errorf("want n=%d; got %d (i=%d)", cas.n, n, i)
+		}
+	}
+}
+
+func TestWithMaxNodes(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	for _, sub := range []string{"a", "b", "c"} {
+		must(os.Mkdir(filepath.Join(dir, sub), 0755))
+	}
+	if _, native := watcherOf(tr.tree).(recursiveWatcher); native {
+		t.Skip("the watcher watches directory trees natively")
+	}
+	c := make(chan EventInfo, buffer)
+	o := options{maxNodes: tr.Stats().Nodes + 3}
+	err := o.watch(tr.pipeTree, filepath.Join(dir, "..."), c, Create)
+	if we, ok := err.(*WatchError); !ok || we.Err != ErrTooManyNodes {
+		t.Fatalf("want err=ErrTooManyNodes; got %v", err)
+	}
+	before := tr.Stats()
+	o.maxNodes = before.Nodes + 100
+	must(o.watch(tr.pipeTree, filepath.Join(dir, "..."), c, Create))
+	after := tr.Stats()
+	if after.Nodes < before.Nodes+4 {
+		t.Errorf("want at least %d nodes; got %d", before.Nodes+4, after.Nodes)
+	}
+	if after.Watchpoints <= before.Watchpoints || after.Bytes <= before.Bytes {
+		t.Errorf("want stats to grow; got %+v -> %+v", before, after)
+	}
+}
+
+func TestStatsExpanded(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	if _, native := watcherOf(tr.tree).(recursiveWatcher); native {
+		t.Skip("the watcher watches directory trees natively")
+	}
+	c := make(chan EventInfo, buffer)
+	must(tr.Watch(filepath.Join(dir, "..."), c, Create))
+	if n := tr.Stats().Expanded; n != 0 {
+		t.Fatalf("want Expanded=0 for the directories watched up front; got %d", n)
+	}
+	must(os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+	deadline := time.Now().Add(timeout())
+	for tr.Stats().Expanded < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want Expanded>=2; got %d", tr.Stats().Expanded)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LinkEventInfo is delivered for watchpoints set up with WithSymlinkTracking,
+// when the watched symlink was re-pointed, removed or created again. Its Path
+// is the path of the symlink, its Event the one reported for the symlink by
+// the underlying watcher.
+type LinkEventInfo interface {
+	EventInfo
+	// Target gives the directory or file the symlink points to, which is
+	// watched from now on, or an empty string if the symlink is gone or
+	// dangling, in which case the previous target is still watched.
+	Target() string
+}
+
+// linkEvent reports the symlink at path points to target now.
+type linkEvent struct {
+	EventInfo
+	path   string
+	target string
+}
+
+var _ LinkEventInfo = (*linkEvent)(nil)
+var _ fmt.Stringer = (*linkEvent)(nil)
+
+func (e *linkEvent) Path() string   { return e.path }
+func (e *linkEvent) Target() string { return e.target }
+
+// String implements fmt.Stringer interface.
+func (e *linkEvent) String() string {
+	return `link: "` + e.path + `" -> "` + e.target + `"`
+}
+
+// WithSymlinkTracking makes the watchpoint follow the symlink it was set up
+// for, e.g. a "current" symlink of a deployment layout. Like with Watch, the
+// target of the symlink is watched, but the symlink itself is watched as well:
+// once it is re-pointed, the watchpoint is moved to the new target and
+// a LinkEventInfo is delivered. If the symlink is removed, or points to
+// nowhere, the previous target is still watched until the symlink is fixed.
+//
+// Only the last element of the watched path is tracked. The option has no
+// effect if it is not a symlink. Use Stop to remove watchpoints set up with
+// the option; StopPath called for the target leaves the symlink watched.
+func WithSymlinkTracking() Option {
+	return func(o *options) {
+		o.symlinks = true
+	}
+}
+
+// linkpath gives the absolute path of the symlink named by the watched path,
+// false if the path does not name a symlink.
+func linkpath(path string) (string, bool) {
+	link, err := filepath.Abs(strings.TrimSuffix(path, "..."))
+	if err != nil {
+		return "", false
+	}
+	fi, err := os.Lstat(link)
+	if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		return "", false
+	}
+	return link, true
+}
+
+// TrackLink watches the symlink, which p watches the target of, with
+// a pipe of the same channel, which moves the watchpoint of p to the new
+// target, whenever the symlink is re-pointed. It expects p to be watching
+// the current target of the symlink.
+func (t *pipeTree) TrackLink(p *pipe, link string, isrec bool, e Event) error {
+	parent, _, err := cleanpath(filepath.Dir(link))
+	if err != nil {
+		return err
+	}
+	path := normalize(filepath.Join(parent, filepath.Base(link)))
+	target, _, err := cleanpath(link)
+	if err != nil {
+		return err
+	}
+	var mu sync.Mutex // serializes updates, protects target and lp
+	var lp *pipe
+	relink := func(ei EventInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		dir, _, err := cleanpath(link)
+		switch {
+		case err != nil:
+			dir = ""
+		case dir == target:
+			return
+		default:
+			watched := dir
+			if isrec {
+				watched = filepath.Join(dir, "...")
+			}
+			if err := t.Retarget(p, watched, e); err != nil {
+				report(watched, err, nil)
+				return
+			}
+			target = dir
+		}
+		lp.send(&linkEvent{EventInfo: ei, path: link, target: dir})
+	}
+	s := func(handler) handler {
+		return func(ei EventInfo) {
+			if normalize(ei.Path()) == path {
+				// Not to block the pipe, the tree may be waiting for it to exit.
+				go relink(ei)
+			}
+		}
+	}
+	mu.Lock()
+	lp, err = t.WatchPipe(parent, p.dst, []stage{s}, Create|Remove|Rename)
+	mu.Unlock()
+	return err
+}
+
+// Retarget moves the watchpoint of p to the given path, unless p was already
+// stopped. If the path cannot be watched, p is set up on its previous path
+// again.
+func (t *pipeTree) Retarget(p *pipe, path string, e Event) error {
+	key, _, err := cleanpath(path)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p.closed() {
+		return nil
+	}
+	var oldkey string
+	for k, pipes := range t.pipes[p.dst] {
+		for _, pp := range pipes {
+			if pp == p {
+				oldkey = k
+			}
+		}
+	}
+	oldpath := oldkey
+	if p.rec {
+		oldpath = filepath.Join(oldkey, "...")
+	}
+	t.unwatch(p)
+	p.poll, p.events, p.rec, p.fi, p.gone = nil, 0, false, nil, false
+	if err := t.watch(p, path, e); err != nil {
+		t.watch(p, oldpath, e)
+		return err
+	}
+	// The pipe is added for the new path first, so that the channel
+	// keeps its outbox.
+	t.add(p.dst, key, p)
+	if rest := without(t.pipes[p.dst][oldkey], p); len(rest) != 0 {
+		t.pipes[p.dst][oldkey] = rest
+	} else {
+		delete(t.pipes[p.dst], oldkey)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !windows
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithSymlinkTracking(t *testing.T) {
+	tmp, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	v1, v2 := filepath.Join(tmp, "v1"), filepath.Join(tmp, "v2")
+	current := filepath.Join(tmp, "current")
+	must(os.Mkdir(v1, 0755))
+	must(os.Mkdir(v2, 0755))
+	must(os.Symlink(v1, current))
+	var o options
+	WithSymlinkTracking()(&o)
+	c := make(chan EventInfo, buffer)
+	if err := o.watch(tr.pipeTree, current, c, Create); err != nil {
+		t.Fatal(err)
+	}
+	next := func() EventInfo {
+		select {
+		case ei := <-c:
+			return ei
+		case <-time.After(timeout()):
+			t.Fatal("timed out")
+		}
+		return nil
+	}
+	must(ioutil.WriteFile(filepath.Join(v1, "a"), nil, 0644))
+	if ei := next(); ei.Path() != filepath.Join(v1, "a") {
+		t.Fatalf("want event for %q; got %v", filepath.Join(v1, "a"), ei)
+	}
+	// Re-point the symlink atomically, like ln -sfn does.
+	tmplink := filepath.Join(tmp, "current.tmp")
+	must(os.Symlink(v2, tmplink))
+	must(os.Rename(tmplink, current))
+	ei := next()
+	le, ok := ei.(LinkEventInfo)
+	if !ok {
+		t.Fatalf("want LinkEventInfo; got %v", ei)
+	}
+	if le.Path() != current || le.Target() != v2 {
+		t.Fatalf("want %q -> %q; got %q -> %q", current, v2, le.Path(), le.Target())
+	}
+	must(ioutil.WriteFile(filepath.Join(v1, "b"), nil, 0644))
+	must(ioutil.WriteFile(filepath.Join(v2, "c"), nil, 0644))
+	if ei := next(); ei.Path() != filepath.Join(v2, "c") {
+		t.Fatalf("want event for %q; got %v", filepath.Join(v2, "c"), ei)
+	}
+	tr.Stop(c)
+	if _, ok := tr.Events(v2); ok {
+		t.Errorf("want %q not to be watched after Stop", v2)
+	}
+	if _, ok := tr.Events(tmp); ok {
+		t.Errorf("want %q not to be watched after Stop", tmp)
+	}
+}
+
+func TestWithAliasPaths(t *testing.T) {
+	tmp, tr, ch := NewPipeTreeTest(t, 2)
+	defer tr.Close()
+	real, link := filepath.Join(tmp, "real"), filepath.Join(tmp, "link")
+	must(os.Mkdir(real, 0755))
+	must(os.Symlink(real, link))
+	must(tr.WatchWithOptions(real, ch[0], Create, WithAliasPaths()))
+	must(tr.WatchWithOptions(link, ch[1], Create, WithAliasPaths(), WithPathPair()))
+	must(ioutil.WriteFile(filepath.Join(real, "file"), nil, 0644))
+	for i, dir := range []string{real, link} {
+		select {
+		case ei := <-ch[i]:
+			want := filepath.Join(dir, "file")
+			if err := EqualEventInfo(&Call{P: want, E: Create}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+			if p, ok := ei.(PairedEventInfo); ok {
+				if abs, rel := p.PathPair(); abs != want || rel != "file" {
+					t.Fatalf("want PathPair=(%q, file); got (%q, %q) (i=%d)", want, abs, rel, i)
+				}
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+}
@@ -534,6 +534,7 @@ const (
 	FuncRecursiveUnwatch = FuncType("RecursiveUnwatch")
 	FuncRecursiveRewatch = FuncType("RecursiveRewatch")
 	FuncStop             = FuncType("Stop")
+	FuncReset            = FuncType("Reset")
 )
 
 type Chans []chan EventInfo
@@ -584,6 +585,71 @@ func (c Chans) Drain() (ei []EventInfo) {
 	return
 }
 
+// P is a test of a pipe tree watching the paths of a temporary directory.
+type P struct {
+	*pipeTree
+	w *W
+}
+
+// NewPipeTreeTest creates a pipe tree test with n channels. It gives the
+// cleaned path of the temporary directory, which is removed on Close.
+func NewPipeTreeTest(t *testing.T, n int) (string, *P, Chans) {
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatalf(`TempDir("", "notify")=%v`, err)
+	}
+	root, _, err := cleanpath(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf(`cleanpath(%q)=%v`, dir, err)
+	}
+	ch := NewChans(n)
+	p := &P{
+		pipeTree: newPipeTree(newTree()),
+		w:        &W{t: t, root: root},
+	}
+	if n != 0 {
+		p.w.C = ch[0]
+	}
+	return root, p, ch
+}
+
+// W gives a test of the temporary directory, which expects the events to be
+// delivered to the first channel.
+func (p *P) W() *W {
+	return p.w
+}
+
+func (p *P) Close() error {
+	defer os.RemoveAll(p.w.root)
+	return p.pipeTree.Close()
+}
+
+// Expect fails the test unless want is the next event received from c.
+func (p *P) Expect(c <-chan EventInfo, want EventInfo) {
+	select {
+	case ei := <-c:
+		if err := EqualEventInfo(want, ei); err != nil {
+			p.fatalf("%v", err)
+		}
+	case <-time.After(p.w.timeout()):
+		p.fatalf("timed out after %v waiting for %v", p.w.timeout(), want)
+	}
+}
+
+// ExpectDry fails the test if any of the channels receives an event.
+func (p *P) ExpectDry(ch ...chan EventInfo) {
+	if ei := Chans(ch).Drain(); len(ei) != 0 {
+		p.fatalf("want no events; got %v", ei)
+	}
+}
+
+// fatalf fails the test, telling the line of the test the failed expectation
+// comes from.
+func (p *P) fatalf(format string, v ...interface{}) {
+	p.w.t.Fatalf("%s: %s", callern(3), fmt.Sprintf(format, v...))
+}
+
 // Call represents single call to Watcher issued by the Tree
 // and recorded by a spy Watcher mock.
 type Call struct {
@@ -801,6 +867,8 @@ func (n *N) Call(calls ...Call) {
 			n.Watch(calls[i].P, calls[i].C, calls[i].E)
 		case FuncStop:
 			n.Stop(calls[i].C)
+		case FuncReset:
+			n.tree.Reset()
 		default:
 			panic("unsupported call type: " + string(calls[i].F))
 		}
@@ -4,19 +4,74 @@
 
 package notify
 
+import (
+	"sort"
+	"sync/atomic"
+)
+
 const buffer = 128
 
 type tree interface {
 	Watch(string, chan<- EventInfo, ...Event) error
 	Stop(chan<- EventInfo)
+	Reset()
 	Close() error
 }
 
 func newTree() tree {
-	c := make(chan EventInfo, buffer)
-	w := newWatcher(c)
+	n := atomic.LoadInt32(&internalBuffer)
+	c := make(chan EventInfo, n)
+	w := withLinger(withHooks(newWatcher(c)))
 	if rw, ok := w.(recursiveWatcher); ok {
-		return newRecursiveTree(rw, c)
+		t := newRecursiveTree(rw, c)
+		accountWith(w, t.act)
+		lockWith(w, &t.rw)
+		return t
+	}
+	t := newNonrecursiveTree(w, c, make(chan EventInfo, n))
+	t.th = throttleFor(w, t.act)
+	accountWith(w, t.act)
+	lockWith(w, &t.rw)
+	return t
+}
+
+// watcherOf gives the underlying watcher of t, nil if t is not backed by one.
+func watcherOf(t tree) interface{} {
+	switch t := t.(type) {
+	case *nonrecursiveTree:
+		return t.w
+	case *recursiveTree:
+		return t.w
+	}
+	return nil
+}
+
+// teardown is a list of watcher requests removing or shrinking watches, which
+// are run leaf-first, i.e. a watch of a directory is removed only after the
+// watches of all the paths under it. Requests for unrelated paths are run in
+// the reverse lexical order of the paths.
+type teardown []unwatchOp
+
+// unwatchOp is a single watcher request of a teardown.
+type unwatchOp struct {
+	path string
+	fn   func() error
+}
+
+func (td teardown) Len() int           { return len(td) }
+func (td teardown) Less(i, j int) bool { return td[i].path > td[j].path }
+func (td teardown) Swap(i, j int)      { td[i], td[j] = td[j], td[i] }
+
+// add queues the request fn for the path.
+func (td *teardown) add(path string, fn func() error) {
+	*td = append(*td, unwatchOp{path: path, fn: fn})
+}
+
+// run runs all the queued requests leaf-first, giving back the first error.
+func (td teardown) run() (err error) {
+	sort.Sort(td)
+	for _, op := range td {
+		err = nonil(err, op.fn())
 	}
-	return newNonrecursiveTree(w, c, make(chan EventInfo, buffer))
+	return err
 }
@@ -4,15 +4,25 @@
 
 package notify
 
-import "sync"
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+)
 
 // nonrecursiveTree TODO(rjeczalik)
 type nonrecursiveTree struct {
-	rw   sync.RWMutex // protects root
-	root root
-	w    watcher
-	c    chan EventInfo
-	rec  chan EventInfo
+	rw        sync.RWMutex // protects root, expansion and expanded
+	root      root
+	w         watcher
+	c         chan EventInfo
+	rec       chan EventInfo
+	expansion map[string]int // concurrency of the next recursive watch of a path
+	expanded  int            // directories watched once they were created
+	th        *throttle      // non-nil if the watcher is able to be paced
+	q         *queue         // events reported to c, which were not dispatched yet
+	act       *activity      // counts the events being dispatched
+	st        *stamps        // records the time of the events of the direct pipes
 }
 
 // newNonrecursiveTree TODO(rjeczalik)
@@ -25,76 +35,130 @@ func newNonrecursiveTree(w watcher, c, rec chan EventInfo) *nonrecursiveTree {
 		w:    w,
 		c:    c,
 		rec:  rec,
+		q:    newQueue(c),
+		act:  new(activity),
+		st:   newStamps(),
 	}
-	go t.dispatch(c)
+	go t.q.loop(t.dispatch)
 	go t.internal(rec)
 	return t
 }
 
 // dispatch TODO(rjeczalik)
-func (t *nonrecursiveTree) dispatch(c <-chan EventInfo) {
-	for ei := range c {
-		dbgprintf("dispatching %v on %q", ei.Event(), ei.Path())
-		go func(ei EventInfo) {
-			var nd node
-			var isrec bool
-			dir, base := split(ei.Path())
-			fn := func(it node, isbase bool) error {
-				isrec = isrec || it.Watch.IsRecursive()
-				if isbase {
-					nd = it
-				} else {
-					it.Watch.Dispatch(ei, recursive)
-				}
-				return nil
-			}
-			t.rw.RLock()
-			// Notify recursive watchpoints found on the path.
-			if err := t.root.WalkPath(dir, fn); err != nil {
-				dbgprint("dispatch did not reach leaf:", err)
-				t.rw.RUnlock()
-				return
-			}
-			// Notify parent watchpoint.
-			nd.Watch.Dispatch(ei, 0)
-			isrec = isrec || nd.Watch.IsRecursive()
-			// If leaf watchpoint exists, notify it.
-			if nd, ok := nd.Child[base]; ok {
-				isrec = isrec || nd.Watch.IsRecursive()
-				nd.Watch.Dispatch(ei, 0)
-			}
-			t.rw.RUnlock()
-			// If the event describes newly leaf directory created within
-			if !isrec || ei.Event() != Create {
-				return
-			}
-			if ok, err := ei.(isDirer).isDir(); !ok || err != nil {
-				return
-			}
-			t.rec <- ei
-		}(ei)
+func (t *nonrecursiveTree) dispatch(ei EventInfo) {
+	ei = userEvent(ei)
+	dbgprintf("dispatching %v on %q", ei.Event(), ei.Path())
+	t.act.enter()
+	go func(ei EventInfo) {
+		defer t.act.leave()
+		// If the event describes newly leaf directory created within
+		if !t.deliver(ei) || ei.Event() != Create {
+			return
+		}
+		if ok, err := ei.(isDirer).isDir(); !ok || err != nil {
+			return
+		}
+		t.rec <- ei
+	}(ei)
+}
+
+// deliver notifies the watchpoints of the event's path and the recursive ones
+// of its parent directories. It reports whether any of them was recursive.
+func (t *nonrecursiveTree) deliver(ei EventInfo) bool {
+	t.rw.RLock()
+	defer t.rw.RUnlock()
+	return t.deliverLocked(ei)
+}
+
+// deliverLocked works like deliver, but it expects t.rw to be held.
+func (t *nonrecursiveTree) deliverLocked(ei EventInfo) (isrec bool) {
+	var nd node
+	dir, base := split(normalize(ei.Path()))
+	fn := func(it node, isbase bool) error {
+		isrec = isrec || it.Watch.IsRecursive()
+		if isbase {
+			nd = it
+		} else {
+			it.Watch.dispatch(ei, recursive, t.th, t.st)
+		}
+		return nil
+	}
+	// Notify recursive watchpoints found on the path.
+	if err := t.root.WalkPath(dir, fn); err != nil {
+		unmatched(ei.Path())
+		return false
+	}
+	// Notify parent watchpoint.
+	nd.Watch.dispatch(ei, 0, t.th, t.st)
+	isrec = isrec || nd.Watch.IsRecursive()
+	// If leaf watchpoint exists, notify it.
+	if nd, ok := nd.Child[base]; ok {
+		isrec = isrec || nd.Watch.IsRecursive()
+		nd.Watch.dispatch(ei, 0, t.th, t.st)
 	}
+	return isrec
 }
 
 // internal TODO(rjeczalik)
+//
+// The directories and files found within the newly created one were most
+// likely created before the watch for their parent was set up, e.g. by mkdir -p
+// or tar -x, so their Create events were never reported. They are delivered as
+// synthetic Create events instead, once all of them are watched, in
+// parent-before-child order. The files of a directory are listed after its
+// watch was set up, so that none of them is missed. The events are delivered
+// before the lock is released, so that the events reported for the new watches
+// are delivered after them.
 func (t *nonrecursiveTree) internal(rec <-chan EventInfo) {
 	for ei := range rec {
-		var nd node
+		t.act.enter()
 		var eset = internal
+		var created []EventInfo
+		path := normalize(ei.Path())
 		t.rw.Lock()
-		t.root.WalkPath(ei.Path(), func(it node, _ bool) error {
-			if e := it.Watch[t.rec]; e != 0 && e > eset {
-				eset = e
-			}
-			nd = it
+		// The directory is watched for the events of all of the recursive
+		// watchpoints above it.
+		t.root.WalkPath(path, func(it node, _ bool) error {
+			eset |= it.Watch[t.rec]
 			return nil
 		})
 		if eset == internal {
 			t.rw.Unlock()
+			t.act.leave()
 			continue
 		}
-		err := nd.Add(ei.Path()).AddDir(t.recFunc(eset))
+		fn := t.recFunc(eset)
+		err := t.root.Add(path).AddDir(func(nd node) error {
+			if nd.Name != path {
+				created = append(created, &synthetic{path: nd.Name, event: Create, dir: true})
+			}
+			// The node may be left over from a directory removed before,
+			// whose watch was dropped along with it, so it is watched anew,
+			// even if its watchpoints already cover the recursive events.
+			if e := nd.Watch[t.rec]; e != 0 {
+				nd.Watch.Del(t.rec, e)
+			}
+			left := nd.Watch.Total()
+			if err := fn(nd); err != nil {
+				return err
+			}
+			if e := nd.Watch.Total(); left != 0 && e == left {
+				retrySubdir(func() error { return t.w.Rewatch(nd.Name, e, e) })
+			}
+			t.expanded++
+			fis, _ := ioutil.ReadDir(nd.Name)
+			for _, fi := range fis {
+				if !fi.IsDir() {
+					created = append(created, &synthetic{path: filepath.Join(nd.Name, fi.Name()), event: Create})
+				}
+			}
+			return nil
+		})
+		for _, ei := range created {
+			t.deliverLocked(ei)
+		}
 		t.rw.Unlock()
+		t.act.leave()
 		if err != nil {
 			dbgprintf("internal(%p) error: %v", rec, err)
 		}
@@ -119,14 +183,24 @@ func (t *nonrecursiveTree) watchDelMin(min Event, nd node, c chan<- EventInfo, e
 	}
 	diff := nd.Watch.Del(c, e)
 	if ok {
-		switch old &^= diff[0] &^ diff[1]; {
+		// The recursive events of the node are the ones of its parent and
+		// the ones of the recursive watchpoints left on the node itself;
+		// the events still watched by the plain watchpoints do not keep it
+		// recursive.
+		old = min
+		for ch, e := range nd.Watch {
+			if ch != nil && ch != t.rec && e&recursive != 0 {
+				old |= e
+			}
+		}
+		switch {
 		case old|internal == internal:
 			delete(nd.Watch, t.rec)
 			if set, ok := nd.Watch[nil]; ok && len(nd.Watch) == 1 && set == 0 {
 				delete(nd.Watch, nil)
 			}
 		default:
-			nd.Watch.Add(t.rec, old|Create)
+			nd.Watch.Add(t.rec, old|omit|Create)
 			switch {
 			case diff == none:
 			case diff[1]|Create == diff[0]:
@@ -161,8 +235,10 @@ func (t *nonrecursiveTree) Watch(path string, c chan<- EventInfo, events ...Even
 	t.rw.Lock()
 	defer t.rw.Unlock()
 	nd := t.root.Add(path)
-	if isrec {
-		return t.watchrec(nd, c, eset|recursive)
+	// The events are joined with the ones c already watches the path for,
+	// so all of them are watched recursively, once any of them is.
+	if isrec || nd.Watch[c]&recursive != 0 {
+		return t.watchrec(nd, c, eset|nd.Watch[c]|recursive)
 	}
 	return t.watch(nd, c, eset)
 }
@@ -195,9 +271,9 @@ func (t *nonrecursiveTree) recFunc(e Event) walkFunc {
 			// TODO(rjeczalik): cleanup this panic after implementation is stable
 			panic("eset is empty: " + nd.Name)
 		case diff[0] == 0:
-			t.w.Watch(nd.Name, diff[1])
+			retrySubdir(func() error { return t.w.Watch(nd.Name, diff[1]) })
 		default:
-			t.w.Rewatch(nd.Name, diff[0], diff[1])
+			retrySubdir(func() error { return t.w.Rewatch(nd.Name, diff[0], diff[1]) })
 		}
 		return nil
 	}
@@ -219,7 +295,7 @@ func (t *nonrecursiveTree) watchrec(nd node, c chan<- EventInfo, e Event) error
 	case diff[0] == 0:
 		// TODO(rjeczalik): BFS into directories and skip subtree as soon as first
 		// recursive watchpoint is encountered.
-		traverse = nd.AddDir
+		traverse = t.expandFunc(nd)
 	default:
 		traverse = nd.Walk
 	}
@@ -263,8 +339,18 @@ Traverse:
 	return nil
 }
 
+// rekey implements rekeyer.
+func (t *nonrecursiveTree) rekey(c, d chan<- EventInfo) {
+	t.rw.Lock()
+	rekey(t.root.nd, c, d)
+	t.rw.Unlock()
+}
+
 // Stop TODO(rjeczalik)
+//
+// The watches are removed leaf-first, see teardown.
 func (t *nonrecursiveTree) Stop(c chan<- EventInfo) {
+	var td teardown
 	fn := func(min Event, nd node) error {
 		// TODO(rjeczalik): aggregate watcher errors and retry; in worst case
 		// forward to the user.
@@ -272,18 +358,45 @@ func (t *nonrecursiveTree) Stop(c chan<- EventInfo) {
 		case diff == none:
 			return nil
 		case diff[1] == 0:
-			t.w.Unwatch(nd.Name)
+			name := nd.Name
+			td.add(name, func() error { return t.w.Unwatch(name) })
 		default:
-			t.w.Rewatch(nd.Name, diff[0], diff[1])
+			name := nd.Name
+			td.add(name, func() error { return t.w.Rewatch(name, diff[0], diff[1]) })
 		}
 		return nil
 	}
 	t.rw.Lock()
 	err := t.walkWatchpoint(t.root.nd, fn) // TODO(rjeczalik): store max root per c
+	err = nonil(err, td.run())
 	t.rw.Unlock()
 	dbgprintf("Stop(%p) error: %v\n", c, err)
 }
 
+// Reset unwatches every path registered within the tree and drops all of its
+// watchpoints, leaving the watcher ready to accept new watches. The paths are
+// unwatched leaf-first, see teardown.
+func (t *nonrecursiveTree) Reset() {
+	var td teardown
+	fn := func(nd node) error {
+		if nd.Watch.Total() != 0 {
+			name := nd.Name
+			td.add(name, func() error {
+				if err := t.w.Unwatch(name); err != nil {
+					dbgprintf("Reset: unwatch %q error: %v", name, err)
+				}
+				return nil
+			})
+		}
+		return nil
+	}
+	t.rw.Lock()
+	t.root.nd.Walk(fn)
+	td.run()
+	t.root = root{nd: newnode("")}
+	t.rw.Unlock()
+}
+
 // Close TODO(rjeczalik)
 func (t *nonrecursiveTree) Close() error {
 	err := t.w.Close()
@@ -541,3 +541,65 @@ func TestNonrecursiveTreeInternal(t *testing.T) {
 
 	n.ExpectTreeEvents(events[:], ch)
 }
+
+func TestNonrecursiveTreeReset(t *testing.T) {
+	n := NewNonrecursiveTreeTest(t, "testdata/vfs.txt")
+	defer n.Close()
+
+	ch := NewChans(2)
+
+	watches := [...]RCase{
+		// i=0
+		{
+			Call: Call{
+				F: FuncWatch,
+				P: "src/github.com/rjeczalik/fs/fs.go",
+				C: ch[0],
+				E: Rename,
+			},
+			Record: []Call{
+				{F: FuncWatch, P: "src/github.com/rjeczalik/fs/fs.go", E: Rename},
+			},
+		},
+		// i=1
+		{
+			Call: Call{
+				F: FuncWatch,
+				P: "src/github.com/rjeczalik/fs/cmd/...",
+				C: ch[1],
+				E: Remove,
+			},
+			Record: []Call{
+				{F: FuncWatch, P: "src/github.com/rjeczalik/fs/cmd", E: Create | Remove},
+				{F: FuncWatch, P: "src/github.com/rjeczalik/fs/cmd/gotree", E: Create | Remove},
+				{F: FuncWatch, P: "src/github.com/rjeczalik/fs/cmd/mktree", E: Create | Remove},
+			},
+		},
+		// i=2
+		{
+			Call: Call{
+				F: FuncReset,
+			},
+			Record: []Call{
+				{F: FuncUnwatch, P: "src/github.com/rjeczalik/fs/cmd"},
+				{F: FuncUnwatch, P: "src/github.com/rjeczalik/fs/cmd/gotree"},
+				{F: FuncUnwatch, P: "src/github.com/rjeczalik/fs/cmd/mktree"},
+				{F: FuncUnwatch, P: "src/github.com/rjeczalik/fs/fs.go"},
+			},
+		},
+		// i=3
+		{
+			Call: Call{
+				F: FuncWatch,
+				P: "src/github.com/rjeczalik/fs/fs.go",
+				C: ch[1],
+				E: Write,
+			},
+			Record: []Call{
+				{F: FuncWatch, P: "src/github.com/rjeczalik/fs/fs.go", E: Write},
+			},
+		},
+	}
+
+	n.ExpectRecordedCalls(watches[:])
+}
@@ -45,14 +45,12 @@ func watchCopy(src, dst node) {
 		}
 		watchAddInactive(dst, c, e)
 	}
-	if wpsrc := src.Child[""].Watch; len(wpsrc) != 0 {
-		wpdst := dst.Child[""].Watch
-		for c, e := range wpsrc {
-			if c == nil {
-				continue
-			}
-			wpdst.Add(c, e)
+	// The source may hold the inactive watchpoints only.
+	for c, e := range src.Child[""].Watch {
+		if c == nil {
+			continue
 		}
+		watchAddInactive(dst, c, e)
 	}
 }
 
@@ -61,7 +59,9 @@ func watchDel(nd node, c chan<- EventInfo, e Event) eventDiff {
 	diff := nd.Watch.Del(c, e)
 	if wp := nd.Child[""].Watch; len(wp) != 0 {
 		diffInactive := wp.Del(c, e)
-		e = wp.Total()
+		// The events still watched by the node itself are kept, also when
+		// only the inactive watchpoints changed.
+		e = wp.Total() | nd.Watch.Total()
 		// TODO(rjeczalik): add e if e != all?
 		diff[0] |= diffInactive[0] | e
 		diff[1] |= diffInactive[1] | e
@@ -103,7 +103,10 @@ type recursiveTree struct {
 		watcher
 		recursiveWatcher
 	}
-	c chan EventInfo
+	c   chan EventInfo
+	q   *queue    // events reported to c, which were not dispatched yet
+	act *activity // counts the events being dispatched
+	st  *stamps   // records the time of the events of the direct pipes
 }
 
 // newRecursiveTree TODO(rjeczalik)
@@ -114,42 +117,46 @@ func newRecursiveTree(w recursiveWatcher, c chan EventInfo) *recursiveTree {
 			watcher
 			recursiveWatcher
 		}{w.(watcher), w},
-		c: c,
+		c:   c,
+		q:   newQueue(c),
+		act: new(activity),
+		st:  newStamps(),
 	}
-	go t.dispatch()
+	go t.q.loop(t.dispatch)
 	return t
 }
 
 // dispatch TODO(rjeczalik)
-func (t *recursiveTree) dispatch() {
-	for ei := range t.c {
-		dbgprintf("dispatching %v on %q", ei.Event(), ei.Path())
-		go func(ei EventInfo) {
-			nd, ok := node{}, false
-			dir, base := split(ei.Path())
-			fn := func(it node, isbase bool) error {
-				if isbase {
-					nd = it
-				} else {
-					it.Watch.Dispatch(ei, recursive)
-				}
-				return nil
-			}
-			t.rw.RLock()
-			defer t.rw.RUnlock()
-			// Notify recursive watchpoints found on the path.
-			if err := t.root.WalkPath(dir, fn); err != nil {
-				dbgprint("dispatch did not reach leaf:", err)
-				return
-			}
-			// Notify parent watchpoint.
-			nd.Watch.Dispatch(ei, 0)
-			// If leaf watchpoint exists, notify it.
-			if nd, ok = nd.Child[base]; ok {
-				nd.Watch.Dispatch(ei, 0)
+func (t *recursiveTree) dispatch(ei EventInfo) {
+	ei = userEvent(ei)
+	dbgprintf("dispatching %v on %q", ei.Event(), ei.Path())
+	t.act.enter()
+	go func(ei EventInfo) {
+		defer t.act.leave()
+		nd, ok := node{}, false
+		dir, base := split(normalize(ei.Path()))
+		fn := func(it node, isbase bool) error {
+			if isbase {
+				nd = it
+			} else {
+				it.Watch.dispatch(ei, recursive, nil, t.st)
 			}
-		}(ei)
-	}
+			return nil
+		}
+		t.rw.RLock()
+		defer t.rw.RUnlock()
+		// Notify recursive watchpoints found on the path.
+		if err := t.root.WalkPath(dir, fn); err != nil {
+			unmatched(ei.Path())
+			return
+		}
+		// Notify parent watchpoint.
+		nd.Watch.dispatch(ei, 0, nil, t.st)
+		// If leaf watchpoint exists, notify it.
+		if nd, ok = nd.Child[base]; ok {
+			nd.Watch.dispatch(ei, 0, nil, t.st)
+		}
+	}(ei)
 }
 
 // Watch TODO(rjeczalik)
@@ -190,12 +197,25 @@ func (t *recursiveTree) Watch(path string, c chan<- EventInfo, events ...Event)
 		// information to shrink the eventset on eventual Stop.
 		// return t.resetwatchpoint(parent, parent, c, eventset|inactive)
 		var diff eventDiff
+		wasrec := watchIsRecursive(parent)
 		if self {
 			diff = watchAdd(cur, c, eventset)
 		} else {
 			diff = watchAddInactive(parent, c, eventset)
 		}
 		switch {
+		case diff == none && !wasrec && (isrec || watchIsRecursive(parent)):
+			// The parent watchpoint covers the requested subtree with its
+			// eventset, but it was not watched recursively.
+			e := watchTotal(parent)
+			if err = t.w.RecursiveRewatch(parent.Name, parent.Name, e, e); err != nil {
+				watchDel(parent, c, eventset)
+				return err
+			}
+			if !self {
+				watchAdd(cur, c, eventset)
+			}
+			return nil
 		case diff == none:
 			// the parent watchpoint already covers requested subtree with its
 			// eventset
@@ -226,7 +246,9 @@ func (t *recursiveTree) Watch(path string, c chan<- EventInfo, events ...Event)
 	// Look for children nodes, unwatch n-1 of them and rewatch the last one.
 	var children []node
 	fn := func(nd node) error {
-		if len(nd.Watch) == 0 {
+		// A child, whose own watchpoints were stopped, is still watched for
+		// its inactive ones.
+		if watchTotal(nd) == 0 {
 			return nil
 		}
 		children = append(children, nd)
@@ -300,13 +322,22 @@ func (t *recursiveTree) Watch(path string, c chan<- EventInfo, events ...Event)
 
 // Stop TODO(rjeczalik)
 //
+// rekey implements rekeyer.
+func (t *recursiveTree) rekey(c, d chan<- EventInfo) {
+	t.rw.Lock()
+	rekey(t.root.nd, c, d)
+	t.rw.Unlock()
+}
+
 // TODO(rjeczalik): Split parent watchpoint - transfer watches to children
 // if parent is no longer needed. This carries a risk that underlying
 // watcher calls could fail - reconsider if it's worth the effort.
 func (t *recursiveTree) Stop(c chan<- EventInfo) {
 	var err error
-	fn := func(nd node) (e error) {
+	var td teardown
+	fn := func(nd node) error {
 		diff := watchDel(nd, c, all)
+		name, rec := nd.Name, watchIsRecursive(nd)
 		switch {
 		case diff == none && watchTotal(nd) == 0:
 			// TODO(rjeczalik): There's no watchpoints deeper in the tree,
@@ -315,23 +346,25 @@ func (t *recursiveTree) Stop(c chan<- EventInfo) {
 		case diff == none:
 			// Removing c from nd does not require shrinking its eventset.
 		case diff[1] == 0:
-			if watchIsRecursive(nd) {
-				e = t.w.RecursiveUnwatch(nd.Name)
-			} else {
-				e = t.w.Unwatch(nd.Name)
-			}
+			td.add(name, func() error {
+				if rec {
+					return t.w.RecursiveUnwatch(name)
+				}
+				return t.w.Unwatch(name)
+			})
 		default:
-			if watchIsRecursive(nd) {
-				e = t.w.RecursiveRewatch(nd.Name, nd.Name, diff[0], diff[1])
-			} else {
-				e = t.w.Rewatch(nd.Name, diff[0], diff[1])
-			}
+			td.add(name, func() error {
+				if rec {
+					return t.w.RecursiveRewatch(name, name, diff[0], diff[1])
+				}
+				return t.w.Rewatch(name, diff[0], diff[1])
+			})
 		}
 		fn := func(nd node) error {
 			watchDel(nd, c, all)
 			return nil
 		}
-		err = nonil(err, e, nd.Walk(fn))
+		err = nonil(err, nd.Walk(fn))
 		// TODO(rjeczalik): if e != nil store dummy chan in nd.Watch just to
 		// retry un/rewatching next time and/or let the user handle the failure
 		// vie Error event?
@@ -339,13 +372,42 @@ func (t *recursiveTree) Stop(c chan<- EventInfo) {
 	}
 	t.rw.Lock()
 	e := t.root.Walk("", fn) // TODO(rjeczalik): use max root per c
+	err = nonil(td.run(), err, e)
 	t.rw.Unlock()
-	if e != nil {
-		err = nonil(err, e)
-	}
 	dbgprintf("Stop(%p) error: %v\n", c, err)
 }
 
+// Reset unwatches every path registered within the tree and drops all of its
+// watchpoints, leaving the watcher ready to accept new watches. The paths are
+// unwatched leaf-first, see teardown.
+func (t *recursiveTree) Reset() {
+	var td teardown
+	fn := func(nd node) error {
+		if watchTotal(nd) == 0 {
+			return nil
+		}
+		name, rec := nd.Name, watchIsRecursive(nd)
+		td.add(name, func() (err error) {
+			if rec {
+				err = t.w.RecursiveUnwatch(name)
+			} else {
+				err = t.w.Unwatch(name)
+			}
+			if err != nil {
+				dbgprintf("Reset: unwatch %q error: %v", name, err)
+			}
+			return nil
+		})
+		// Underlying watch of nd covers its whole subtree.
+		return errSkip
+	}
+	t.rw.Lock()
+	t.root.nd.Walk(fn)
+	td.run()
+	t.root = root{nd: newnode("")}
+	t.rw.Unlock()
+}
+
 // Close TODO(rjeczalik)
 func (t *recursiveTree) Close() error {
 	err := t.w.Close()
@@ -522,3 +522,79 @@ func TestRecursiveTree_Windows(t *testing.T) {
 
 	n.ExpectTreeEvents(events[:], ch)
 }
+
+func TestRecursiveTreeReset(t *testing.T) {
+	n := NewRecursiveTreeTest(t, "testdata/vfs.txt")
+	defer n.Close()
+
+	ch := NewChans(3)
+
+	watches := [...]RCase{
+		// i=0
+		{
+			Call: Call{
+				F: FuncWatch,
+				P: "src/github.com/rjeczalik/fs/cmd/...",
+				C: ch[0],
+				E: Create,
+			},
+			Record: []Call{
+				{F: FuncRecursiveWatch, P: "src/github.com/rjeczalik/fs/cmd", E: Create},
+			},
+		},
+		// i=1
+		{
+			Call: Call{
+				F: FuncWatch,
+				P: "src/github.com/rjeczalik/fs/cmd/gotree",
+				C: ch[1],
+				E: Remove,
+			},
+			Record: []Call{
+				{
+					F:  FuncRecursiveRewatch,
+					P:  "src/github.com/rjeczalik/fs/cmd",
+					NP: "src/github.com/rjeczalik/fs/cmd",
+					E:  Create,
+					NE: Create | Remove,
+				},
+			},
+		},
+		// i=2
+		{
+			Call: Call{
+				F: FuncWatch,
+				P: "src/github.com/pblaszczyk/qttu",
+				C: ch[2],
+				E: Write,
+			},
+			Record: []Call{
+				{F: FuncWatch, P: "src/github.com/pblaszczyk/qttu", E: Write},
+			},
+		},
+		// i=3
+		{
+			Call: Call{
+				F: FuncReset,
+			},
+			Record: []Call{
+				{F: FuncUnwatch, P: "src/github.com/pblaszczyk/qttu"},
+				{F: FuncRecursiveUnwatch, P: "src/github.com/rjeczalik/fs/cmd"},
+			},
+		},
+		// i=4
+		{
+			Call: Call{
+				F: FuncWatch,
+				P: "src/github.com/rjeczalik/fs/cmd",
+				C: ch[1],
+				E: Write,
+			},
+			Record: []Call{
+				{F: FuncWatch, P: "src/github.com/rjeczalik/fs/cmd", E: Write},
+			},
+		},
+	}
+
+	n.ExpectRecordedCalls(watches[:])
+}
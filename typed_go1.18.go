@@ -0,0 +1,124 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package notify
+
+import (
+	"hash/fnv"
+	"runtime"
+	"sync"
+)
+
+// Decoded is an event delivered by WatchTyped together with the value
+// of an application type it was decoded into.
+type Decoded[T any] struct {
+	EventInfo       // the event reported for the path
+	Value     T     // the value decoded from the event, valid if Err is nil
+	Err       error // the error the decode function failed with
+}
+
+// typedWorkers is the number of goroutines decoding the events of a single
+// WatchTyped watchpoint.
+var typedWorkers = runtime.NumCPU()
+
+// typed keeps the functions stopping the watchpoints of WatchTyped channels.
+var typed = struct {
+	sync.Mutex
+	m map[interface{}][]func()
+}{m: make(map[interface{}][]func())}
+
+// WatchTyped watches the path for the given events like Watch does, but it
+// delivers every event to c decoded into a value of type T by the decode
+// function, e.g. the parsed contents of the changed file:
+//
+//   c := make(chan notify.Decoded[Config], 1)
+//   err := notify.WatchTyped("./conf.d", c, func(ei notify.EventInfo) (Config, error) {
+//           return loadConfig(ei.Path())
+//   }, notify.Write)
+//
+// If decode fails, the event is delivered with the error set to Err instead.
+// The events are decoded by a pool of goroutines, so that a slow decode
+// function does not hold back the dispatch of the events. The events for the
+// same path are decoded by the same goroutine, so they are delivered in the
+// order they were reported; the events for distinct paths may be reordered.
+// Like with Watch, the events are delivered without blocking and the ones not
+// received in time are dropped.
+//
+// Use StopTyped to remove watchpoints set up with WatchTyped.
+func WatchTyped[T any](path string, c chan<- Decoded[T], decode func(EventInfo) (T, error), events ...Event) error {
+	if c == nil {
+		panic("notify: Watch using nil channel")
+	}
+	raw := make(chan EventInfo, buffer)
+	if err := Watch(path, raw, events...); err != nil {
+		return err
+	}
+	n := typedWorkers
+	if n < 1 {
+		n = 1
+	}
+	queues := make([]chan EventInfo, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := range queues {
+		queues[i] = make(chan EventInfo, buffer)
+		go func(q <-chan EventInfo) {
+			defer wg.Done()
+			for ei := range q {
+				v, err := decode(ei)
+				select {
+				case c <- Decoded[T]{EventInfo: ei, Value: v, Err: err}:
+				default: // Drop event if receiver is too slow
+					dropped(ei)
+				}
+			}
+		}(queues[i])
+	}
+	quit, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case ei := <-raw:
+				h := fnv.New32a()
+				h.Write([]byte(ei.Path()))
+				select {
+				case queues[h.Sum32()%uint32(n)] <- ei:
+				default: // Drop event if the decoders are too slow
+					dropped(ei)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+	stop := func() {
+		Stop(raw)
+		close(quit)
+		<-done
+		for _, q := range queues {
+			close(q)
+		}
+		wg.Wait()
+	}
+	typed.Lock()
+	typed.m[c] = append(typed.m[c], stop)
+	typed.Unlock()
+	return nil
+}
+
+// StopTyped removes all watchpoints set up with WatchTyped for c. When
+// StopTyped returns, no more events are delivered to c.
+func StopTyped[T any](c chan<- Decoded[T]) {
+	typed.Lock()
+	stops := typed.m[c]
+	delete(typed.m, c)
+	typed.Unlock()
+	for _, stop := range stops {
+		stop()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package notify
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchTyped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_typed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, _, err = cleanpath(dir); err != nil {
+		t.Fatal(err)
+	}
+	errEmpty := errors.New("empty file")
+	decode := func(ei EventInfo) (string, error) {
+		p, err := ioutil.ReadFile(ei.Path())
+		if err == nil && len(p) == 0 {
+			err = errEmpty
+		}
+		return string(p), err
+	}
+	c := make(chan Decoded[string], buffer)
+	must(WatchTyped(dir, c, decode, Create))
+	defer StopTyped(c)
+	cases := [...]struct {
+		name  string
+		data  string
+		value string
+		err   error
+	}{
+		// i=0
+		{"a", "hello", "hello", nil},
+		// i=1
+		{"b", "", "", errEmpty},
+	}
+	for i, cas := range cases {
+		file := filepath.Join(dir, cas.name)
+		// The file is written under another name first, so that it is
+		// complete once its Create is reported.
+		tmp := filepath.Join(filepath.Dir(dir), filepath.Base(dir)+".tmp")
+		must(ioutil.WriteFile(tmp, []byte(cas.data), 0644))
+		must(os.Rename(tmp, file))
+		select {
+		case d := <-c:
+			if err := EqualEventInfo(&Call{P: file, E: Create}, d); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+			if d.Value != cas.value || d.Err != cas.err {
+				t.Fatalf("want Value=%q, Err=%v; got %q, %v (i=%d)", cas.value, cas.err, d.Value, d.Err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errUnmounted = errors.New("filesystem of the watched path was unmounted")
+
+// UnmountEventInfo is delivered when the filesystem the watched path is on was
+// unmounted, after which the underlying watch no longer reports any events.
+// Its Path is the watched path and its Event is Remove. The watchpoint is kept,
+// but Verify reports it as stale from now on.
+//
+// The watchpoints set up with WithRetry are set up again once a filesystem
+// is mounted at the same path again, which is checked for with the backoff
+// and the number of attempts given to WithRetry.
+//
+// Unmounts are reported by inotify and FSEvents only.
+type UnmountEventInfo interface {
+	EventInfo
+	// Mountpoint gives the path the unmount was reported for: the mountpoint
+	// under FSEvents, the watched path under inotify.
+	Mountpoint() string
+}
+
+// unmount reports the filesystem of the watched path was unmounted.
+type unmount struct {
+	synthetic
+	mnt string
+}
+
+var _ UnmountEventInfo = (*unmount)(nil)
+var _ fmt.Stringer = (*unmount)(nil)
+
+func (e *unmount) Mountpoint() string { return e.mnt }
+
+// unmounts keeps the functions called once a watcher reported a filesystem
+// was unmounted.
+var unmounts = struct {
+	sync.Mutex
+	n   int
+	fns map[int]func(string)
+}{fns: make(map[int]func(string))}
+
+// onUnmount registers fn to be called with the path of every unmount, until
+// the returned function is called.
+func onUnmount(fn func(path string)) (cancel func()) {
+	unmounts.Lock()
+	unmounts.n++
+	id := unmounts.n
+	unmounts.fns[id] = fn
+	unmounts.Unlock()
+	return func() {
+		unmounts.Lock()
+		delete(unmounts.fns, id)
+		unmounts.Unlock()
+	}
+}
+
+// reportUnmount is called by the watchers, which were told the filesystem was
+// unmounted from the path.
+func reportUnmount(path string) {
+	dbgprintf("unmounted: %s", path)
+	unmounts.Lock()
+	fns := make([]func(string), 0, len(unmounts.fns))
+	for _, fn := range unmounts.fns {
+		fns = append(fns, fn)
+	}
+	unmounts.Unlock()
+	for _, fn := range fns {
+		fn(path)
+	}
+}
+
+// unmounted marks the watchpoints of the path, and the ones under it, as
+// unmounted, delivering an UnmountEventInfo to their pipes.
+func (t *pipeTree) unmounted(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.pipes {
+		for key, pipes := range m {
+			if key != path && !strings.HasPrefix(key, path+sep) {
+				continue
+			}
+			for _, p := range pipes {
+				if p.gone {
+					continue
+				}
+				p.gone = true
+				if p.events&Remove != 0 {
+					p.inject(&unmount{synthetic: synthetic{path: key, event: Remove, dir: true}, mnt: path})
+				}
+			}
+		}
+	}
+}
+
+// Remount sets up the watchpoint of p on path again, once a filesystem is
+// mounted at dir again, which is told by dir being on a different device
+// than the one it is on when Remount is called. It checks at most the given
+// number of times, like Rearm does. If the device of dir cannot be told,
+// it rearms p right away instead.
+func (t *pipeTree) Remount(p *pipe, path, dir string, e Event, attempts int, backoff time.Duration) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		t.Rearm(p, path, e, attempts, backoff)
+		return
+	}
+	under, _, ok := inode(dir, fi)
+	if !ok {
+		t.Rearm(p, path, e, attempts, backoff)
+		return
+	}
+	for i := 0; i < attempts; i++ {
+		sleep(backoff << uint(i))
+		if p.closed() {
+			return
+		}
+		fi, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		if dev, _, _ := inode(dir, fi); dev != under {
+			t.Rearm(p, path, e, 1, 0)
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPipeTreeUnmounted(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 3)
+	defer tr.Close()
+	mnt, other := filepath.Join(dir, "mnt"), filepath.Join(dir, "other")
+	must(os.MkdirAll(filepath.Join(mnt, "sub"), 0755))
+	must(os.Mkdir(other, 0755))
+	must(tr.Watch(filepath.Join(mnt, "..."), ch[0], Remove))
+	must(tr.Watch(filepath.Join(mnt, "sub"), ch[1], Create))
+	must(tr.Watch(other, ch[2], Remove))
+	tr.unmounted(mnt)
+	select {
+	case ei := <-ch[0]:
+		u, ok := ei.(UnmountEventInfo)
+		if !ok {
+			t.Fatalf("want UnmountEventInfo; got %T", ei)
+		}
+		if u.Path() != mnt || u.Event() != Remove || u.Mountpoint() != mnt {
+			t.Errorf("want %v: %q on %q; got %v on %q", Remove, mnt, mnt, u, u.Mountpoint())
+		}
+	case <-time.After(timeout()):
+		t.Fatal("timed out")
+	}
+	// The watchpoints are reported unmounted once.
+	tr.unmounted(mnt)
+	select {
+	case ei := <-ch[0]:
+		t.Fatalf("want no event; got %v", ei)
+	case ei := <-ch[1]:
+		t.Fatalf("want no event for a watchpoint not watching Remove; got %v", ei)
+	case ei := <-ch[2]:
+		t.Fatalf("want no event for a path not unmounted; got %v", ei)
+	case <-time.After(50 * time.Millisecond):
+	}
+	for i, s := range tr.Verify() {
+		if healthy := s.Path == other; s.Healthy != healthy {
+			t.Errorf("want healthy=%t; got %t, err=%v (path=%s, i=%d)", healthy, s.Healthy, s.Err, s.Path, i)
+		}
+	}
+}
@@ -9,12 +9,58 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const all = ^Event(0)
 const sep = string(os.PathSeparator)
 
-var errDepth = errors.New("exceeded allowed iteration count (circular symlink?)")
+// Errors reported while resolving symlinks of a watched path. They are wrapped
+// with an *os.PathError.
+var (
+	ErrPathDepth      = errors.New("exceeded maximum path depth")
+	ErrSymlinkCycle   = errors.New("circular symlink")
+	ErrResolveTimeout = errors.New("timed out resolving symlinks")
+)
+
+const defaultPathDepth = 128
+
+var maxPathDepth int32 = defaultPathDepth
+
+// SetMaxPathDepth sets the limit of iterations notify makes while resolving
+// symlinks of a watched path, which is roughly the number of path elements
+// plus the number of symlinks on the way, 128 by default. A limit lower than 1
+// restores the default. Resolving fails with ErrPathDepth once the limit is
+// exceeded, which makes it possible to watch deeper paths or to give up
+// earlier on pathological ones. Circular symlinks are detected regardless of
+// the limit and reported with ErrSymlinkCycle, unless they expand the path
+// on every round.
+func SetMaxPathDepth(n int) {
+	if n < 1 {
+		n = defaultPathDepth
+	}
+	atomic.StoreInt32(&maxPathDepth, int32(n))
+}
+
+var resolveTimeout int64
+
+// SetResolveTimeout bounds the time notify spends resolving symlinks of
+// a watched path, which takes a Lstat call per path element and a Readlink
+// call per symlink and may block for long on a slow or hung network
+// filesystem. Once d elapses, resolving fails with ErrResolveTimeout and so
+// does Watch, instead of blocking the caller until the filesystem responds.
+// The calls already made are left to finish in the background, their results
+// are discarded. A d lower than 1 removes the bound, which is the default.
+func SetResolveTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	atomic.StoreInt64(&resolveTimeout, int64(d))
+}
+
+// lstat is os.Lstat, replaced by tests.
+var lstat = os.Lstat
 
 func min(i, j int) int {
 	if i > j {
@@ -58,27 +104,89 @@ func cleanpath(path string) (realpath string, isrec bool, err error) {
 	if path, err = canonical(path); err != nil {
 		return "", false, err
 	}
-	return path, isrec, nil
+	return normalize(longpath(path)), isrec, nil
+}
+
+type normalizerFunc struct {
+	fn func(string) string
+}
+
+var normalizer atomic.Value
+
+// SetPathNormalizer registers a function, which is applied both to the paths
+// passed to Watch and to the paths of the events reported by the underlying
+// watcher before notify compares them. Calling SetPathNormalizer with nil
+// function unregisters it. By default paths are compared byte-wise.
+//
+// It is meant for filesystems, which normalize Unicode names, like APFS or HFS+
+// under macOS, where a file named "café" can be reported using either NFC or
+// NFD form, so it would not match the path of its watchpoint. For example
+// norm.NFC.String from the golang.org/x/text/unicode/norm package can be used
+// as the normalizer. Since the normalized paths are also passed to
+// the underlying watcher, the filesystem must treat both forms as equivalent.
+//
+// SetPathNormalizer is expected to be called before any watchpoint is set up.
+// Paths reported by EventInfo are not normalized.
+func SetPathNormalizer(fn func(string) string) {
+	normalizer.Store(normalizerFunc{fn})
+}
+
+// normalize applies the registered normalizer function to the path.
+func normalize(path string) string {
+	if n, ok := normalizer.Load().(normalizerFunc); ok && n.fn != nil {
+		return n.fn(path)
+	}
+	return path
 }
 
 // canonical resolves any symlink in the given path and returns it in a clean form.
 // It expects the path to be absolute. It fails to resolve circular symlinks by
-// maintaining a simple iteration limit.
+// remembering the paths it has already seen and by maintaining an iteration
+// limit, see SetMaxPathDepth. It gives up once the time set by
+// SetResolveTimeout elapses.
 func canonical(p string) (string, error) {
+	d := time.Duration(atomic.LoadInt64(&resolveTimeout))
+	if d == 0 {
+		return resolve(p)
+	}
+	type result struct {
+		path string
+		err  error
+	}
+	c := make(chan result, 1) // buffered, so that an abandoned resolve can finish
+	expired := make(chan struct{})
+	t := afterFunc(d, func() { close(expired) })
+	defer t.Stop()
+	go func() {
+		path, err := resolve(p)
+		c <- result{path, err}
+	}()
+	select {
+	case r := <-c:
+		return r.path, r.err
+	case <-expired:
+		return "", &os.PathError{Op: "canonical", Path: p, Err: ErrResolveTimeout}
+	}
+}
+
+// resolve does the work of canonical.
+func resolve(p string) (string, error) {
 	p, err := filepath.Abs(p)
 	if err != nil {
 		return "", err
 	}
+	limit := int(atomic.LoadInt32(&maxPathDepth))
+	seen := map[string]struct{}{p: {}}
 	for i, j, depth := 1, 0, 1; i < len(p); i, depth = i+1, depth+1 {
-		if depth > 128 {
-			return "", &os.PathError{Op: "canonical", Path: p, Err: errDepth}
+		if depth > limit {
+			return "", &os.PathError{Op: "canonical", Path: p, Err: ErrPathDepth}
 		}
 		if j = strings.IndexRune(p[i:], '/'); j == -1 {
 			j, i = i, len(p)
 		} else {
 			j, i = i, i+j
 		}
-		fi, err := os.Lstat(p[:i])
+		fi, err := lstat(p[:i])
 		if err != nil {
 			return "", err
 		}
@@ -92,6 +200,10 @@ func canonical(p string) (string, error) {
 			} else {
 				p = p[:j] + s + p[i:]
 			}
+			if _, ok := seen[p]; ok {
+				return "", &os.PathError{Op: "canonical", Path: p, Err: ErrSymlinkCycle}
+			}
+			seen[p] = struct{}{}
 			i = 1 // no guarantee s is canonical, start all over
 		}
 	}
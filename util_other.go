@@ -0,0 +1,12 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build !windows
+
+package notify
+
+// longpath is a nop, short names are specific to Windows.
+func longpath(path string) string {
+	return path
+}
@@ -5,7 +5,9 @@
 package notify
 
 import (
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -140,3 +142,25 @@ func TestTreeLastIndexSep(t *testing.T) {
 func TestCleanpath(t *testing.T) {
 	t.Skip("TODO(rjeczalik)")
 }
+
+func TestNormalize(t *testing.T) {
+	if p := normalize("/Café"); p != "/Café" {
+		t.Fatalf("want path unchanged; got %q", p)
+	}
+	SetPathNormalizer(strings.ToLower)
+	defer SetPathNormalizer(nil)
+	if p := normalize("/Café"); p != "/café" {
+		t.Fatalf("want p=%q; got %q", "/café", p)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, err := cleanpath(wd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != strings.ToLower(p) {
+		t.Fatalf("want cleanpath to give normalized path; got %q", p)
+	}
+}
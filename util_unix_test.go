@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func tmpfile(s string) (string, error) {
@@ -88,11 +89,62 @@ func TestCanonicalCircular(t *testing.T) {
 	if _, err = canonical(tmp1); err == nil {
 		t.Fatalf("want canonical(%q)!=nil", tmp1)
 	}
-	if _, ok := err.(*os.PathError); !ok {
-		t.Fatalf("want canonical(%q)=os.PathError; got %T", tmp1, err)
+	if e, ok := err.(*os.PathError); !ok || e.Err != ErrSymlinkCycle {
+		t.Fatalf("want canonical(%q)=os.PathError{Err: ErrSymlinkCycle}; got %v", tmp1, err)
 	}
 }
 
+func TestCanonicalMaxPathDepth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_depth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a", "b", "c", "d")
+	must(os.MkdirAll(path, 0755))
+	defer SetMaxPathDepth(0)
+	SetMaxPathDepth(2)
+	_, err = canonical(path)
+	if e, ok := err.(*os.PathError); !ok || e.Err != ErrPathDepth {
+		t.Fatalf("want canonical(%q)=os.PathError{Err: ErrPathDepth}; got %v", path, err)
+	}
+	SetMaxPathDepth(0)
+	if _, err = canonical(path); err != nil {
+		t.Fatalf("want canonical(%q) to succeed with the default limit; got %v", path, err)
+	}
+}
+
+func TestCanonicalResolveTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_resolve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "a")
+	must(os.Mkdir(path, 0755))
+	hung, release, done := filepath.Join(dir, "hung"), make(chan struct{}), make(chan struct{})
+	lstat = func(p string) (os.FileInfo, error) {
+		if p != hung {
+			return os.Lstat(p)
+		}
+		defer close(done)
+		<-release
+		return nil, os.ErrNotExist
+	}
+	defer func() { lstat = os.Lstat }()
+	defer SetResolveTimeout(0)
+	SetResolveTimeout(20 * time.Millisecond)
+	if _, err := canonical(path); err != nil {
+		t.Fatalf("want canonical(%q) to succeed within the timeout; got %v", path, err)
+	}
+	_, err = canonical(filepath.Join(hung, "x"))
+	if e, ok := err.(*os.PathError); !ok || e.Err != ErrResolveTimeout {
+		t.Fatalf("want canonical(%q)=os.PathError{Err: ErrResolveTimeout}; got %v", hung, err)
+	}
+	close(release)
+	<-done
+}
+
 // issue #83
 func TestCanonical_RelativeSymlink(t *testing.T) {
 	dir, err := ioutil.TempDir(wd, "")
@@ -123,3 +175,36 @@ func TestCanonical_RelativeSymlink(t *testing.T) {
 		t.Fatalf("want canonical()=%s; got %s", realpath, got)
 	}
 }
+
+func TestWatchFlagsCheckSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_harden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	link := filepath.Join(dir, "link")
+	must(os.Symlink(dir, link))
+	if err := flagOnlyDir.check(link); err != nil {
+		t.Errorf("want symlink to a directory to pass flagOnlyDir; got %v", err)
+	}
+	err = flagDontFollow.check(link)
+	if we, ok := err.(*WatchError); !ok || we.Err != ErrSymlink {
+		t.Errorf("want err=%v; got %v", ErrSymlink, err)
+	}
+	resolved, _, err := cleanpath(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = flagDontFollow.checkResolved(link, resolved)
+	if we, ok := err.(*WatchError); !ok || we.Err != ErrSymlink {
+		t.Errorf("want err=%v for the resolved symlink; got %v", ErrSymlink, err)
+	}
+	sub := filepath.Join(link, "sub")
+	must(os.Mkdir(sub, 0755))
+	if resolved, _, err = cleanpath(sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := flagDontFollow.checkResolved(sub, resolved); err != nil {
+		t.Errorf("want symlinked parent directories followed; got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build windows
+
+package notify
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// longpath expands short (8.3) names in the given path, e.g. C:\PROGRA~1 is
+// expanded to C:\Program Files. Since the path may no longer exist, e.g. for
+// Remove events, the names which cannot be expanded are left as they are.
+func longpath(path string) string {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return path
+	}
+	b := make([]uint16, syscall.MAX_PATH)
+	for {
+		n, err := syscall.GetLongPathName(p, &b[0], uint32(len(b)))
+		if err != nil {
+			dir := filepath.Dir(path)
+			if dir == path {
+				return path
+			}
+			return filepath.Join(longpath(dir), filepath.Base(path))
+		}
+		if n <= uint32(len(b)) {
+			return syscall.UTF16ToString(b[:n])
+		}
+		b = make([]uint16, n)
+	}
+}
+
+// longname expands short names in the given name of a file within dir, which
+// is expected to be expanded already.
+func longname(dir, name string) string {
+	if !strings.ContainsRune(name, '~') {
+		return name // no short names
+	}
+	if p := longpath(filepath.Join(dir, name)); strings.HasPrefix(p, dir+sep) {
+		return p[len(dir)+len(sep):]
+	}
+	return name
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+// +build windows
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func shortpath(t *testing.T, path string) string {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]uint16, syscall.MAX_PATH)
+	n, err := syscall.GetShortPathName(p, &b[0], uint32(len(b)))
+	if err != nil {
+		t.Fatalf("GetShortPathName(%q)=%v", path, err)
+	}
+	return syscall.UTF16ToString(b[:n])
+}
+
+func TestLongpath(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "notify_longpath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	tmp = longpath(tmp)
+	dir := filepath.Join(tmp, "long directory name")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	short := shortpath(t, dir)
+	if !strings.ContainsRune(short, '~') {
+		t.Skip("8.3 names are disabled on the volume")
+	}
+	cases := [...]struct {
+		path string
+		want string
+	}{
+		{short, dir}, // i=0
+		{filepath.Join(short, "removed.txt"), filepath.Join(dir, "removed.txt")}, // i=1
+		{dir, dir}, // i=2
+	}
+	for i, cas := range cases {
+		if got := longpath(cas.path); got != cas.want {
+			t.Errorf("want longpath(%q)=%q; got %q (i=%d)", cas.path, cas.want, got, i)
+		}
+	}
+	name := filepath.Base(short)
+	if got := longname(tmp, name+`\file.txt`); got != `long directory name\file.txt` {
+		t.Errorf("want longname=%q; got %q", `long directory name\file.txt`, got)
+	}
+	// Watched paths given with short names and mixed separators are expanded
+	// to the same form the events are reported in.
+	mixed := strings.Replace(short, `\`, "/", -1)
+	if got, _, err := cleanpath(mixed); err != nil || got != longpath(dir) {
+		t.Errorf("want cleanpath(%q)=%q; got %q, %v", mixed, dir, got, err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"os"
+	"sort"
+)
+
+var errReplaced = errors.New("watched path was replaced")
+
+// WatchStatus describes the state of a watchpoint as reported by Verify.
+type WatchStatus struct {
+	Path    string           // path the watchpoint was set up for
+	C       chan<- EventInfo // channel the watchpoint delivers events to
+	Healthy bool             // whether the watchpoint still delivers events
+	Err     error            // reason the watchpoint is stale, nil if healthy
+}
+
+type byStatusPath []WatchStatus
+
+func (s byStatusPath) Len() int           { return len(s) }
+func (s byStatusPath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+func (s byStatusPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// verifier is implemented by watchers, which are able to tell whether a watch
+// they set up for the path is still active.
+type verifier interface {
+	verify(path string) error
+}
+
+// verify implements verifier interface for the wrapped watcher, it never fails
+// if the watcher does not implement it.
+func (w hookWatcher) verify(path string) error {
+	if v, ok := w.watcher.(verifier); ok {
+		return v.verify(kernelPath(path))
+	}
+	return nil
+}
+
+// verifyTree checks with the underlying watcher of t whether the path is
+// still watched.
+func verifyTree(t tree, path string) error {
+	if v, ok := watcherOf(t).(verifier); ok {
+		return v.verify(path)
+	}
+	return nil
+}
+
+// Verify checks every watchpoint against the current state of the filesystem
+// and gives back its status, sorted by path. A watchpoint is reported to be
+// stale if its path no longer exists, was replaced with a different file
+// or directory since the watchpoint was set up, or the underlying watch is
+// no longer active, e.g. the volume was unmounted.
+//
+// Verify does not modify any of the watchpoints, stale ones are expected to be
+// stopped and set up again by the caller, e.g. with WatchReplace.
+func Verify() []WatchStatus {
+	return defaultTree.Verify()
+}
+
+// Verify gives back the status of every watchpoint registered within the tree.
+func (t *pipeTree) Verify() []WatchStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var status []WatchStatus
+	for c, m := range t.pipes {
+		for key, pipes := range m {
+			for _, p := range pipes {
+				err := t.verify(p, key)
+				status = append(status, WatchStatus{
+					Path:    key,
+					C:       c,
+					Healthy: err == nil,
+					Err:     err,
+				})
+			}
+		}
+	}
+	sort.Sort(byStatusPath(status))
+	return status
+}
+
+// verify checks whether the watchpoint of p registered for path is active.
+func (t *pipeTree) verify(p *pipe, path string) error {
+	if p.gone {
+		return &WatchError{Op: "verify", Path: path, Err: errUnmounted}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return &WatchError{Op: "verify", Path: path, Err: err}
+	}
+	if p.fi != nil && !os.SameFile(p.fi, fi) {
+		return &WatchError{Op: "verify", Path: path, Err: errReplaced}
+	}
+	if p.poll != nil {
+		return nil
+	}
+	return verifyTree(t.tree, path)
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPipeTreeVerify(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 2)
+	defer tr.Close()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	must(os.Mkdir(a, 0755))
+	must(os.Mkdir(b, 0755))
+	must(tr.Watch(a, ch[0], Create))
+	must(tr.Watch(b, ch[1], Create))
+	must(os.Remove(b))
+	status := tr.Verify()
+	if len(status) != 2 {
+		t.Fatalf("want 2 statuses; got %v", status)
+	}
+	cases := [...]struct {
+		path    string
+		c       chan<- EventInfo
+		healthy bool
+	}{
+		{a, ch[0], true},  // i=0
+		{b, ch[1], false}, // i=1
+	}
+	for i, cas := range cases {
+		s := status[i]
+		if s.Path != cas.path || s.C != cas.c {
+			t.Errorf("want path=%q, c=%v; got %q, %v (i=%d)", cas.path, cas.c, s.Path, s.C, i)
+		}
+		if s.Healthy != cas.healthy || (s.Err == nil) != cas.healthy {
+			t.Errorf("want healthy=%t; got %t, err=%v (i=%d)", cas.healthy, s.Healthy, s.Err, i)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// WaitFor blocks until fn reports true or ctx is done, whatever happens first.
+// The fn is expected to check a state of the file or directory given by
+// the path, e.g. whether the file exists and is non-empty or whether
+// the directory contains a specific entry.
+//
+// WaitFor checks fn right away and returns if the state is already reached.
+// Otherwise it watches the parent directory of the path, and the path itself
+// once it is a directory, checking fn again on every event. The parent
+// directory must exist.
+//
+// WaitFor returns ctx.Err() if ctx is done before fn reports true.
+func WaitFor(ctx context.Context, path string, fn func() bool) error {
+	if fn() {
+		return nil
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	c := make(chan EventInfo, buffer)
+	defer Stop(c)
+	if err := Watch(filepath.Dir(path), c, All); err != nil {
+		return err
+	}
+	var watched bool
+	watchdir := func() {
+		if fi, err := os.Stat(path); !watched && err == nil && fi.IsDir() {
+			watched = Watch(path, c, All) == nil
+		}
+	}
+	watchdir()
+	// The state may have been reached before the watchpoints were set up.
+	if fn() {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c:
+			watchdir()
+			if fn() {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWaitFor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_waitfor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub, file := filepath.Join(dir, "sub"), filepath.Join(dir, "sub", "file")
+	nonempty := func() bool {
+		fi, err := os.Stat(file)
+		return err == nil && fi.Size() != 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout())
+	defer cancel()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		must(os.Mkdir(sub, 0755))
+		time.Sleep(50 * time.Millisecond)
+		must(ioutil.WriteFile(file, nil, 0644))
+		time.Sleep(50 * time.Millisecond)
+		must(ioutil.WriteFile(file, []byte("XD"), 0644))
+	}()
+	if err := WaitFor(ctx, sub, nonempty); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	never := func() bool { return false }
+	if err := WaitFor(ctx, sub, never); err != context.DeadlineExceeded {
+		t.Fatalf("want err=%v; got %v", context.DeadlineExceeded, err)
+	}
+}
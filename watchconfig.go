@@ -0,0 +1,103 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// configSettle is the time WatchConfig waits for the changes of a save to
+// settle, before reporting them.
+var configSettle = 100 * time.Millisecond
+
+var errConfigRecursive = errors.New("WatchConfig does not support recursive paths")
+
+// WatchConfig watches the file given by the path, e.g. a configuration file,
+// and delivers a single Write event for it to c whenever it was changed,
+// regardless of how it was saved: written in place, or replaced atomically
+// with a temporary file renamed over it, like editors and sed -i do. Replacing
+// the file does not break the watchpoint, since the directory of the file is
+// watched instead of its inode.
+//
+// The changes made within a short time are reported with a single event, so
+// that a save, which for an atomic replace are several changes, is reported
+// once. If the file does not exist once the changes settled, e.g. it was
+// removed, a Remove event is delivered instead, and a Write again once it is
+// created.
+//
+// WatchConfig does not support recursive paths. Use Stop to remove watchpoints
+// set up with WatchConfig; the changes not yet reported are reported before
+// the watchpoint is removed.
+func WatchConfig(path string, c chan<- EventInfo) error {
+	return defaultTree.WatchConfig(path, c)
+}
+
+// WatchConfig watches the directory of the file with a pipe of c, which
+// reports changes of the file once they settle.
+func (t *pipeTree) WatchConfig(path string, c chan<- EventInfo) error {
+	file, isrec, err := cleanpath(path)
+	if err != nil {
+		return err
+	}
+	if isrec {
+		return errConfigRecursive
+	}
+	s, flush := settle(file, configSettle)
+	p, err := t.WatchPipe(filepath.Dir(file), c, []stage{s}, Create|Write|Remove|Rename)
+	if err != nil {
+		return err
+	}
+	t.OnStop(p, flush)
+	return nil
+}
+
+// settle gives a stage, which reports the events for file with a single Write
+// event once d elapses since the first of them, or with a Remove event if
+// the file does not exist at that time. The events for other paths are
+// dropped. Calling flush reports the pending changes right away.
+func settle(file string, d time.Duration) (s stage, flush func()) {
+	var fl flushers
+	s = func(next handler) handler {
+		var mu sync.Mutex
+		var t Timer
+		fire := func() {
+			mu.Lock()
+			armed := t != nil
+			t = nil
+			mu.Unlock()
+			if !armed {
+				return
+			}
+			e := Write
+			if _, err := os.Stat(file); os.IsNotExist(err) {
+				e = Remove
+			}
+			next(&synthetic{path: file, event: e})
+		}
+		fl.add(func() {
+			mu.Lock()
+			if t != nil {
+				t.Stop()
+			}
+			mu.Unlock()
+			fire()
+		})
+		return func(ei EventInfo) {
+			if normalize(ei.Path()) != file {
+				return
+			}
+			mu.Lock()
+			if t == nil {
+				t = afterFunc(d, fire)
+			}
+			mu.Unlock()
+		}
+	}
+	return s, fl.flush
+}
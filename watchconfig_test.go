@@ -0,0 +1,69 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchConfig(t *testing.T) {
+	dir, tr, _ := NewPipeTreeTest(t, 0)
+	defer tr.Close()
+	file, tmp := filepath.Join(dir, "app.conf"), filepath.Join(dir, ".app.conf.tmp")
+	must(ioutil.WriteFile(file, []byte("a=1"), 0644))
+	c := make(chan EventInfo, buffer)
+	must(tr.WatchConfig(file, c))
+	cases := [...]struct {
+		save func()
+		e    Event
+	}{
+		// i=0
+		{
+			func() { must(ioutil.WriteFile(file, []byte("a=2"), 0644)) },
+			Write,
+		},
+		// i=1
+		{
+			func() {
+				must(ioutil.WriteFile(tmp, []byte("a=3"), 0644))
+				must(os.Rename(tmp, file))
+			},
+			Write,
+		},
+		// i=2
+		{
+			func() { must(ioutil.WriteFile(file, []byte("a=4"), 0644)) },
+			Write,
+		},
+		// i=3
+		{
+			func() { must(os.Remove(file)) },
+			Remove,
+		},
+	}
+	for i, cas := range cases {
+		cas.save()
+		select {
+		case ei := <-c:
+			if err := EqualEventInfo(&Call{P: file, E: cas.e}, ei); err != nil {
+				t.Fatalf("%v (i=%d)", err, i)
+			}
+		case <-time.After(timeout()):
+			t.Fatalf("timed out (i=%d)", i)
+		}
+		select {
+		case ei := <-c:
+			t.Fatalf("want single event per save; got %v (i=%d)", ei, i)
+		case <-time.After(2 * configSettle):
+		}
+	}
+	if err := tr.WatchConfig(filepath.Join(dir, "..."), c); err != errConfigRecursive {
+		t.Fatalf("want err=%v; got %v", errConfigRecursive, err)
+	}
+}
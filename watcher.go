@@ -6,12 +6,39 @@ package notify
 
 import "errors"
 
+// Errors reported by watcher implementations. They are always wrapped with
+// a *WatchError, which tells which operation and path caused them.
 var (
-	errAlreadyWatched  = errors.New("path is already watched")
-	errNotWatched      = errors.New("path is not being watched")
-	errInvalidEventSet = errors.New("invalid event set provided")
+	ErrAlreadyWatched  = errors.New("path is already watched")
+	ErrNotWatched      = errors.New("path is not being watched")
+	ErrInvalidEventSet = errors.New("invalid event set provided")
+	ErrSymlink         = errors.New("path is a symlink")
+	ErrNotDir          = errors.New("path is not a directory")
+	ErrUnsupported     = errors.New("operation is not supported by the watcher")
+	ErrResourceLimit   = errors.New("out of watcher resources, consider raising " +
+		"the limit of open files (ulimit -n) or of inotify instances " +
+		"(fs.inotify.max_user_instances)")
 )
 
+// WatchError records an error together with the operation and the path that
+// caused it.
+type WatchError struct {
+	Op   string // e.g. watch, unwatch or rewatch
+	Path string // path passed to the operation
+	Err  error  // underlying error, e.g. ErrNotWatched
+}
+
+// Error implements error interface.
+func (e *WatchError) Error() string {
+	return "notify: " + e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap gives the underlying error, so errors.Is(err, ErrNotWatched) reports
+// true for a *WatchError wrapping ErrNotWatched.
+func (e *WatchError) Unwrap() error {
+	return e.Err
+}
+
 // Watcher is a intermediate interface for wrapping inotify, ReadDirChangesW,
 // FSEvents, kqueue and poller implementations.
 //
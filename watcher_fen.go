@@ -78,7 +78,7 @@ func (f *fen) Watched(n interface{}) (*watched, int64, error) {
 	}
 	w, ok := f.pthLkp[fo.Name]
 	if !ok {
-		return nil, 0, errNotWatched
+		return nil, 0, ErrNotWatched
 	}
 	return w, int64(pe.PortevEvents), nil
 }
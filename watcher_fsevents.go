@@ -7,8 +7,10 @@
 package notify
 
 import (
-	"errors"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 )
 
@@ -40,6 +42,10 @@ func splitflags(set uint32) (e []uint32) {
 // over FSEvents' stream, which implements filtering of file events based
 // on path and event set. It emulates non-recursive watch-point by filtering out
 // events which paths are more than 1 level deeper than the watched path.
+//
+// FSEvents streams can only be created for directories, thus watching a file
+// is done by streaming its parent directory and forwarding events only for
+// the exact path of the file.
 type watch struct {
 	// prev stores last event set  per path in order to filter out old flags
 	// for new events, which appratenly FSEvents likes to retain. It's a disgusting
@@ -50,7 +56,10 @@ type watch struct {
 	path    string
 	events  uint32
 	isrec   int32
+	file    bool
 	flushed bool
+	replay  bool          // whether the historical events are delivered
+	ready   chan struct{} // closed once flushed
 }
 
 // Example format:
@@ -121,28 +130,40 @@ func (w *watch) Dispatch(ev []FSEvent) {
 	isrec := (atomic.LoadInt32(&w.isrec) == 1)
 	for i := range ev {
 		if ev[i].Flags&FSEventsHistoryDone != 0 {
-			w.flushed = true
+			if !w.flushed {
+				w.flushed = true
+				close(w.ready)
+			}
 			continue
 		}
-		if !w.flushed {
+		if !w.flushed && !w.replay {
 			continue
 		}
 		dbgprintf("%v (0x%x) (%s, i=%d, ID=%d, len=%d)\n", Event(ev[i].Flags),
 			ev[i].Flags, ev[i].Path, i, ev[i].ID, len(ev))
+		if ev[i].Flags&FSEventsUnmount != 0 {
+			reportUnmount(normalize(ev[i].Path))
+		}
 		if ev[i].Flags&failure != 0 {
 			// TODO(rjeczalik): missing error handling
+			overflowed()
 			continue
 		}
-		if !strings.HasPrefix(ev[i].Path, w.path) {
+		path := normalize(ev[i].Path)
+		if w.file && path != w.path {
+			continue
+		}
+		if !strings.HasPrefix(path, w.path) {
+			unmatched(path)
 			continue
 		}
 		n := len(w.path)
 		base := ""
-		if len(ev[i].Path) > n {
-			if ev[i].Path[n] != '/' {
+		if len(path) > n {
+			if path[n] != '/' {
 				continue
 			}
-			base = ev[i].Path[n+1:]
+			base = path[n+1:]
 			if !isrec && strings.IndexByte(base, '/') != -1 {
 				continue
 			}
@@ -177,18 +198,21 @@ func (w *watch) Stop() {
 type fsevents struct {
 	watches map[string]*watch
 	c       chan<- EventInfo
+	mu      sync.Mutex        // protects since
+	since   map[string]uint64 // event IDs the next watches of the paths start at
 }
 
 func newWatcher(c chan<- EventInfo) watcher {
 	return &fsevents{
 		watches: make(map[string]*watch),
 		c:       c,
+		since:   make(map[string]uint64),
 	}
 }
 
 func (fse *fsevents) watch(path string, event Event, isrec int32) (err error) {
 	if _, ok := fse.watches[path]; ok {
-		return errAlreadyWatched
+		return &WatchError{Op: "watch", Path: path, Err: ErrAlreadyWatched}
 	}
 	w := &watch{
 		prev:   make(map[string]uint32),
@@ -196,8 +220,18 @@ func (fse *fsevents) watch(path string, event Event, isrec int32) (err error) {
 		path:   path,
 		events: uint32(event),
 		isrec:  isrec,
+		ready:  make(chan struct{}),
+	}
+	dir := path
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		w.file, dir = true, filepath.Dir(path)
 	}
-	w.stream = newStream(path, w.Dispatch)
+	w.stream = newStream(dir, w.Dispatch)
+	fse.mu.Lock()
+	w.stream.since = fse.since[path]
+	w.replay = w.stream.since != 0
+	delete(fse.since, path)
+	fse.mu.Unlock()
 	if err = w.stream.Start(); err != nil {
 		return err
 	}
@@ -205,10 +239,31 @@ func (fse *fsevents) watch(path string, event Event, isrec int32) (err error) {
 	return nil
 }
 
+// flush implements notify.flusher interface. It flushes all the streams, so
+// the events queued by FSEvents are all dispatched once it returns.
+func (fse *fsevents) flush() bool {
+	for _, w := range fse.watches {
+		w.stream.Flush()
+	}
+	return false
+}
+
+// setSince implements notify.sinceWatcher interface.
+func (fse *fsevents) setSince(path string, id uint64) bool {
+	fse.mu.Lock()
+	if id != 0 {
+		fse.since[path] = id
+	} else {
+		delete(fse.since, path)
+	}
+	fse.mu.Unlock()
+	return true
+}
+
 func (fse *fsevents) unwatch(path string) (err error) {
 	w, ok := fse.watches[path]
 	if !ok {
-		return errNotWatched
+		return &WatchError{Op: "unwatch", Path: path, Err: ErrNotWatched}
 	}
 	w.stream.Stop()
 	delete(fse.watches, path)
@@ -216,42 +271,42 @@ func (fse *fsevents) unwatch(path string) (err error) {
 }
 
 // Watch implements Watcher interface. It fails with non-nil error when setting
-// the watch-point by FSEvents fails or with errAlreadyWatched error when
+// the watch-point by FSEvents fails or with ErrAlreadyWatched error when
 // the given path is already watched.
 func (fse *fsevents) Watch(path string, event Event) error {
 	return fse.watch(path, event, 0)
 }
 
-// Unwatch implements Watcher interface. It fails with errNotWatched when
+// Unwatch implements Watcher interface. It fails with ErrNotWatched when
 // the given path is not being watched.
 func (fse *fsevents) Unwatch(path string) error {
 	return fse.unwatch(path)
 }
 
-// Rewatch implements Watcher interface. It fails with errNotWatched when
-// the given path is not being watched or with errInvalidEventSet when oldevent
+// Rewatch implements Watcher interface. It fails with ErrNotWatched when
+// the given path is not being watched or with ErrInvalidEventSet when oldevent
 // does not match event set the watch-point currently holds.
 func (fse *fsevents) Rewatch(path string, oldevent, newevent Event) error {
 	w, ok := fse.watches[path]
 	if !ok {
-		return errNotWatched
+		return &WatchError{Op: "rewatch", Path: path, Err: ErrNotWatched}
 	}
 	if !atomic.CompareAndSwapUint32(&w.events, uint32(oldevent), uint32(newevent)) {
-		return errInvalidEventSet
+		return &WatchError{Op: "rewatch", Path: path, Err: ErrInvalidEventSet}
 	}
 	atomic.StoreInt32(&w.isrec, 0)
 	return nil
 }
 
 // RecursiveWatch implements RecursiveWatcher interface. It fails with non-nil
-// error when setting the watch-point by FSEvents fails or with errAlreadyWatched
+// error when setting the watch-point by FSEvents fails or with ErrAlreadyWatched
 // error when the given path is already watched.
 func (fse *fsevents) RecursiveWatch(path string, event Event) error {
 	return fse.watch(path, event, 1)
 }
 
 // RecursiveUnwatch implements RecursiveWatcher interface. It fails with
-// errNotWatched when the given path is not being watched.
+// ErrNotWatched when the given path is not being watched.
 //
 // TODO(rjeczalik): fail if w.isrec == 0?
 func (fse *fsevents) RecursiveUnwatch(path string) error {
@@ -260,9 +315,9 @@ func (fse *fsevents) RecursiveUnwatch(path string) error {
 
 // RecrusiveRewatch implements RecursiveWatcher interface. It fails:
 //
-//   * with errNotWatched when the given path is not being watched
-//   * with errInvalidEventSet when oldevent does not match the current event set
-//   * with errAlreadyWatched when watch-point given by the oldpath was meant to
+//   * with ErrNotWatched when the given path is not being watched
+//   * with ErrInvalidEventSet when oldevent does not match the current event set
+//   * with ErrAlreadyWatched when watch-point given by the oldpath was meant to
 //     be relocated to newpath, but the newpath is already watched
 //   * a non-nil error when setting the watch-point with FSEvents fails
 //
@@ -273,17 +328,17 @@ func (fse *fsevents) RecursiveRewatch(oldpath, newpath string, oldevent, neweven
 	case [2]bool{true, true}:
 		w, ok := fse.watches[oldpath]
 		if !ok {
-			return errNotWatched
+			return &WatchError{Op: "rewatch", Path: oldpath, Err: ErrNotWatched}
 		}
 		atomic.StoreInt32(&w.isrec, 1)
 		return nil
 	case [2]bool{true, false}:
 		w, ok := fse.watches[oldpath]
 		if !ok {
-			return errNotWatched
+			return &WatchError{Op: "rewatch", Path: oldpath, Err: ErrNotWatched}
 		}
 		if !atomic.CompareAndSwapUint32(&w.events, uint32(oldevent), uint32(newevent)) {
-			return errors.New("invalid event state diff")
+			return &WatchError{Op: "rewatch", Path: oldpath, Err: ErrInvalidEventSet}
 		}
 		atomic.StoreInt32(&w.isrec, 1)
 		return nil
@@ -291,7 +346,7 @@ func (fse *fsevents) RecursiveRewatch(oldpath, newpath string, oldevent, neweven
 		// TODO(rjeczalik): rewatch newpath only if exists?
 		// TODO(rjeczalik): migrate w.prev to new watch?
 		if _, ok := fse.watches[newpath]; ok {
-			return errAlreadyWatched
+			return &WatchError{Op: "rewatch", Path: newpath, Err: ErrAlreadyWatched}
 		}
 		if err := fse.Unwatch(oldpath); err != nil {
 			return err
@@ -301,6 +356,33 @@ func (fse *fsevents) RecursiveRewatch(oldpath, newpath string, oldevent, neweven
 	}
 }
 
+// verify implements notify.verifier interface. It fails with ErrNotWatched
+// when neither the path nor any of its parents is watched recursively by
+// a running stream.
+func (fse *fsevents) verify(path string) error {
+	for p, w := range fse.watches {
+		if p != path && (atomic.LoadInt32(&w.isrec) == 0 || !strings.HasPrefix(path, p+"/")) {
+			continue
+		}
+		if w.stream.running() {
+			return nil
+		}
+	}
+	return &WatchError{Op: "verify", Path: path, Err: ErrNotWatched}
+}
+
+// ready implements notify.readier interface. The stream is ready once it
+// has replayed historical events, ready gives nil if there is no stream for
+// the path.
+func (fse *fsevents) ready(path string) <-chan struct{} {
+	for p, w := range fse.watches {
+		if p == path || (atomic.LoadInt32(&w.isrec) == 1 && strings.HasPrefix(path, p+"/")) {
+			return w.ready
+		}
+	}
+	return nil
+}
+
 // Close unwatches all watch-points.
 func (fse *fsevents) Close() error {
 	for _, w := range fse.watches {
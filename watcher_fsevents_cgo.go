@@ -19,6 +19,14 @@ static FSEventStreamRef EventStreamCreate(FSEventStreamContext * context, uintpt
 	return FSEventStreamCreate(NULL, (FSEventStreamCallback) gostream, context, paths, since, latency, flags);
 }
 
+static void StringRelease(CFStringRef s) {
+	CFRelease(s);
+}
+
+static void ArrayRelease(CFArrayRef a) {
+	CFRelease(a);
+}
+
 #cgo LDFLAGS: -framework CoreServices
 */
 import "C"
@@ -37,11 +45,14 @@ var nilstream C.FSEventStreamRef
 // Default arguments for FSEventStreamCreate function.
 var (
 	latency C.CFTimeInterval
-	flags   = C.FSEventStreamCreateFlags(C.kFSEventStreamCreateFlagFileEvents | C.kFSEventStreamCreateFlagNoDefer)
+	flags   = C.FSEventStreamCreateFlags(C.kFSEventStreamCreateFlagFileEvents | C.kFSEventStreamCreateFlagNoDefer | C.kFSEventStreamCreateFlagMarkSelf)
 	since   = uint64(C.FSEventsGetCurrentEventId())
 )
 
-var runloop C.CFRunLoopRef // global runloop which all streams are registered with
+// runloop is the global runloop which all streams are registered with. It is
+// shared by all the watchers and lives as long as the process does, so
+// creating and closing watchers does not start nor leak any threads.
+var runloop C.CFRunLoopRef
 var wg sync.WaitGroup      // used to wait until the runloop starts
 
 // source is used for synchronization purposes - it signals when runloop has
@@ -145,9 +156,10 @@ func (r *streamFuncRegistry) delete(id uintptr) {
 // Stream represents single watch-point which listens for events scheduled by
 // the global runloop.
 type stream struct {
-	path string
-	ref  C.FSEventStreamRef
-	info uintptr
+	path  string
+	ref   C.FSEventStreamRef
+	info  uintptr
+	since uint64 // historical event ID to start at, if non-zero
 }
 
 // NewStream creates a stream for given path, listening for file events and
@@ -160,7 +172,8 @@ func newStream(path string, fn streamFunc) *stream {
 }
 
 // Start creates a FSEventStream for the given path and schedules it with
-// global runloop. It's a nop if the stream was already started.
+// global runloop. It's a nop if the stream was already started. The stream
+// starts at s.since, if it was set, replaying the historical events.
 func (s *stream) Start() error {
 	if s.ref != nilstream {
 		return nil
@@ -169,13 +182,22 @@ func (s *stream) Start() error {
 	p := C.CFStringCreateWithCStringNoCopy(refZero, C.CString(s.path), C.kCFStringEncodingUTF8, refZero)
 	path := C.CFArrayCreate(refZero, (*unsafe.Pointer)(unsafe.Pointer(&p)), 1, nil)
 	ctx := C.FSEventStreamContext{}
-	ref := C.EventStreamCreate(&ctx, C.uintptr_t(s.info), path, C.FSEventStreamEventId(atomic.LoadUint64(&since)), latency, flags)
+	id := atomic.LoadUint64(&since)
+	if s.since != 0 {
+		id, s.since = s.since, 0
+	}
+	ref := C.EventStreamCreate(&ctx, C.uintptr_t(s.info), path, C.FSEventStreamEventId(id), latency, flags)
+	// The stream keeps its own copy of the paths. Releasing the string frees
+	// also the C string it was created with.
+	C.ArrayRelease(path)
+	C.StringRelease(p)
 	if ref == nilstream {
 		return errCreate
 	}
 	C.FSEventStreamScheduleWithRunLoop(ref, runloop, C.kCFRunLoopDefaultMode)
 	if C.FSEventStreamStart(ref) == C.Boolean(0) {
 		C.FSEventStreamInvalidate(ref)
+		C.FSEventStreamRelease(ref)
 		return errStart
 	}
 	C.CFRunLoopWakeUp(runloop)
@@ -183,7 +205,21 @@ func (s *stream) Start() error {
 	return nil
 }
 
-// Stop stops underlying FSEventStream and unregisters it from global runloop.
+// Flush makes FSEvents deliver the events it queued for the stream, it returns
+// once the callback was called for all of them.
+func (s *stream) Flush() {
+	if s.ref != nilstream {
+		C.FSEventStreamFlushSync(s.ref)
+	}
+}
+
+// running reports whether the stream was started and not stopped since then.
+func (s *stream) running() bool {
+	return s.ref != nilstream
+}
+
+// Stop stops underlying FSEventStream, unregisters it from global runloop and
+// releases it.
 func (s *stream) Stop() {
 	if s.ref == nilstream {
 		return
@@ -191,6 +227,7 @@ func (s *stream) Stop() {
 	wg.Wait()
 	C.FSEventStreamStop(s.ref)
 	C.FSEventStreamInvalidate(s.ref)
+	C.FSEventStreamRelease(s.ref)
 	C.CFRunLoopWakeUp(runloop)
 	s.ref = nilstream
 	streamFuncs.delete(s.info)
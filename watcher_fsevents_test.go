@@ -7,8 +7,13 @@
 package notify
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
+	"runtime"
+	"runtime/pprof"
 	"testing"
+	"time"
 )
 
 func TestSplitflags(t *testing.T) {
@@ -109,3 +114,161 @@ func TestWatcherShadowedWriteCreate(t *testing.T) {
 
 	w.ExpectAny(cases[:5]) // BUG(rjeczalik): #62
 }
+
+func TestWatchDispatchFile(t *testing.T) {
+	const (
+		create = uint32(FSEventsCreated)
+		write  = uint32(FSEventsModified)
+	)
+	c := make(chan EventInfo, 16)
+	w := &watch{
+		prev:    make(map[string]uint32),
+		c:       c,
+		path:    "/tmp/dir/file",
+		events:  filter,
+		file:    true,
+		flushed: true,
+	}
+	w.Dispatch([]FSEvent{
+		{Path: "/tmp/dir", Flags: write},           // i=0
+		{Path: "/tmp/dir/file2", Flags: create},    // i=1
+		{Path: "/tmp/dir/other", Flags: create},    // i=2
+		{Path: "/tmp/dir/file", Flags: create},     // i=3
+		{Path: "/tmp/dir/file/sub", Flags: create}, // i=4
+	})
+	close(c)
+	var got []string
+	for ei := range c {
+		got = append(got, ei.Path())
+	}
+	if want := []string{"/tmp/dir/file"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want paths=%v; got %v", want, got)
+	}
+}
+
+func TestWatcherFile(t *testing.T) {
+	w := newWatcherTest(t, "testdata/vfs.txt")
+	defer w.Close()
+
+	w.Watch("src/github.com/rjeczalik/fs/fs.go", Write)
+	drainall(w.c())
+
+	write(w, "src/github.com/rjeczalik/fs/appveyor.yml", []byte("XD")).Action()
+	write(w, "src/github.com/rjeczalik/fs/fs.go", []byte("XD")).Action()
+
+	file := w.clean("src/github.com/rjeczalik/fs/fs.go")
+	select {
+	case ei := <-w.c():
+		if ei.Path() != file {
+			t.Fatalf("want event for %q; got %v", file, ei)
+		}
+	case <-time.After(w.timeout()):
+		t.Fatalf("timed out after %v waiting for Write on %q", w.timeout(), file)
+	}
+}
+
+func TestWatcherCloseNoLeak(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_fsevents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cycle := func() {
+		w := newWatcher(make(chan EventInfo, 16))
+		if err := w.Watch(dir, All); err != nil {
+			t.Fatalf("Watch(%q)=%v", dir, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close()=%v", err)
+		}
+	}
+	cycle() // make sure the global runloop is running
+	time.Sleep(50 * time.Millisecond)
+	n, threads := runtime.NumGoroutine(), pprof.Lookup("threadcreate").Count()
+	for i := 0; i < 50; i++ {
+		cycle()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if m := runtime.NumGoroutine(); m > n {
+		t.Fatalf("want at most %d goroutines after 50 watchers were closed; got %d", n, m)
+	}
+	// The runtime may start a few threads on its own, but not one per watcher.
+	if m := pprof.Lookup("threadcreate").Count(); m > threads+10 {
+		t.Fatalf("want at most %d threads after 50 watchers were closed; got %d", threads+10, m)
+	}
+}
+
+func TestWatchDispatchReady(t *testing.T) {
+	w := &watch{
+		prev:  make(map[string]uint32),
+		c:     make(chan EventInfo, 16),
+		path:  "/tmp/dir",
+		ready: make(chan struct{}),
+	}
+	w.Dispatch([]FSEvent{{Path: "/tmp/dir/file", Flags: uint32(FSEventsCreated)}})
+	select {
+	case <-w.ready:
+		t.Fatal("want the stream not to be ready before history is replayed")
+	default:
+	}
+	w.Dispatch([]FSEvent{{Flags: uint32(FSEventsHistoryDone)}})
+	select {
+	case <-w.ready:
+	default:
+		t.Fatal("want the stream to be ready after history is replayed")
+	}
+	w.Dispatch([]FSEvent{{Flags: uint32(FSEventsHistoryDone)}})
+}
+
+func TestEventOwn(t *testing.T) {
+	cases := [...]struct {
+		flags uint32
+		own   bool
+	}{
+		{uint32(FSEventsCreated), false},                    // i=0
+		{uint32(FSEventsCreated | FSEventsOwnEvent), true},  // i=1
+		{uint32(FSEventsModified | FSEventsOwnEvent), true}, // i=2
+	}
+	for i, cas := range cases {
+		ei := &event{fse: FSEvent{Path: "/tmp/file", Flags: cas.flags}}
+		if own := isown(ei); own != cas.own {
+			t.Errorf("want own=%t; got %t (i=%d)", cas.own, own, i)
+		}
+	}
+}
+
+func TestEventSystem(t *testing.T) {
+	cases := [...]struct {
+		flags  uint32
+		system bool
+	}{
+		{uint32(FSEventsXattrMod), true},                             // i=0
+		{uint32(FSEventsInodeMetaMod | FSEventsFinderInfoMod), true}, // i=1
+		{uint32(FSEventsXattrMod | FSEventsModified), false},         // i=2
+		{uint32(FSEventsXattrMod | FSEventsOwnEvent), false},         // i=3
+		{uint32(FSEventsCreated), false},                             // i=4
+	}
+	for i, cas := range cases {
+		ei := &event{fse: FSEvent{Path: "/tmp/file", Flags: cas.flags}}
+		if system := issystem(ei); system != cas.system {
+			t.Errorf("want system=%t; got %t (i=%d)", cas.system, system, i)
+		}
+	}
+}
+
+func TestEventCount(t *testing.T) {
+	cases := [...]struct {
+		flags uint32
+		n     int
+	}{
+		{uint32(FSEventsCreated), 1},                                       // i=0
+		{uint32(FSEventsCreated | FSEventsModified | FSEventsXattrMod), 3}, // i=1
+		{uint32(FSEventsIsFile | FSEventsOwnEvent), 1},                     // i=2
+	}
+	for i, cas := range cases {
+		ei := &event{fse: FSEvent{Path: "/tmp/file", Flags: cas.flags}}
+		if n := count(ei); n != cas.n {
+			t.Errorf("want n=%d; got %d (i=%d)", cas.n, n, i)
+		}
+	}
+}
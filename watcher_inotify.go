@@ -30,8 +30,15 @@ const consumersCount = 2
 
 const invalidDescriptor = -1
 
+var errMalformedEvent = errors.New("malformed inotify event")
+
 // watched is a pair of file path and inotify mask used as a value in
 // watched files map.
+//
+// Many paths can share a single watch descriptor, since inotify watches are
+// kept per inode - it happens e.g. when a directory is bind-mounted in several
+// places or when a file has hard links. Each of such paths is stored separately,
+// so an event is reported under every path it was watched with.
 type watched struct {
 	path string
 	mask uint32
@@ -40,7 +47,8 @@ type watched struct {
 // inotify implements Watcher interface.
 type inotify struct {
 	sync.RWMutex                       // protects inotify.m map
-	m            map[int32]*watched    // watch descriptor to watched object
+	m            map[int32][]*watched  // watch descriptor to watched objects
+	flags        map[string]uint32     // path to extra inotify_add_watch(2) flags
 	fd           int32                 // inotify file descriptor
 	pipefd       []int                 // pipe's read and write descriptors
 	epfd         int                   // epoll descriptor
@@ -48,12 +56,15 @@ type inotify struct {
 	buffer       [eventBufferSize]byte // inotify event buffer
 	wg           sync.WaitGroup        // wait group used to close main loop
 	c            chan<- EventInfo      // event dispatcher channel
+	gate         *gate                 // non-nil if reading is paced, see pace
+	act          *activity             // counts the events read, which were not reported yet
 }
 
 // NewWatcher creates new non-recursive inotify backed by inotify.
 func newWatcher(c chan<- EventInfo) watcher {
 	i := &inotify{
-		m:      make(map[int32]*watched),
+		m:      make(map[int32][]*watched),
+		flags:  make(map[string]uint32),
 		fd:     invalidDescriptor,
 		pipefd: []int{invalidDescriptor, invalidDescriptor},
 		epfd:   invalidDescriptor,
@@ -83,31 +94,77 @@ func (i *inotify) Rewatch(path string, _, newevent Event) error {
 // one. If called for the first time, this function initializes inotify filesystem
 // monitor and starts producer-consumers goroutines.
 func (i *inotify) watch(path string, e Event) (err error) {
-	if e&^(All|Event(unix.IN_ALL_EVENTS)) != 0 {
-		return errors.New("notify: unknown event")
+	if e&^(All|Unknown|Event(unix.IN_ALL_EVENTS)) != 0 {
+		return &WatchError{Op: "watch", Path: path, Err: ErrInvalidEventSet}
 	}
 	if err = i.lazyinit(); err != nil {
-		return
-	}
-	iwd, err := unix.InotifyAddWatch(int(i.fd), path, encode(e))
-	if err != nil {
+		if err == unix.EMFILE || err == unix.ENFILE {
+			err = &WatchError{Op: "watch", Path: path, Err: ErrResourceLimit}
+		}
 		return
 	}
 	i.RLock()
-	wd := i.m[int32(iwd)]
+	flags := i.flags[path]
 	i.RUnlock()
-	if wd == nil {
-		i.Lock()
-		if i.m[int32(iwd)] == nil {
-			i.m[int32(iwd)] = &watched{path: path, mask: uint32(e)}
+	iwd, err := unix.InotifyAddWatch(int(i.fd), path, encode(e)|flags)
+	switch {
+	case err == unix.ENOTDIR && flags&unix.IN_ONLYDIR != 0:
+		return &WatchError{Op: "watch", Path: path, Err: ErrNotDir}
+	case err != nil:
+		return
+	}
+	i.Lock()
+	defer i.Unlock()
+	var wd *watched
+	mask := e
+	wds := i.m[int32(iwd)]
+	for _, w := range wds {
+		if w.path == path {
+			wd = w
+		} else {
+			mask |= Event(w.mask)
 		}
-		i.Unlock()
+	}
+	if wd == nil {
+		wd = &watched{path: path}
+		i.m[int32(iwd)] = append(wds, wd)
+	}
+	wd.mask = uint32(e)
+	if mask != e {
+		// The watch descriptor is shared with other paths, restore their
+		// events which were replaced by the inotify_add_watch(2) call above.
+		_, err = unix.InotifyAddWatch(int(i.fd), path, encode(mask)|flags)
+	}
+	return
+}
+
+// setFlags implements notify.flagWatcher interface.
+func (i *inotify) setFlags(path string, f watchFlags) {
+	var flags uint32
+	if f&flagDontFollow != 0 {
+		flags |= unix.IN_DONT_FOLLOW
+	}
+	if f&flagOnlyDir != 0 {
+		flags |= unix.IN_ONLYDIR
+	}
+	i.Lock()
+	if flags != 0 {
+		i.flags[path] = flags
 	} else {
-		i.Lock()
-		wd.mask = uint32(e)
-		i.Unlock()
+		delete(i.flags, path)
 	}
-	return nil
+	i.Unlock()
+}
+
+// account implements notify.accounter interface.
+func (i *inotify) account(a *activity) {
+	i.act = a
+}
+
+// pace implements notify.pacer interface.
+func (i *inotify) pace(g *gate) bool {
+	i.gate = g
+	return true
 }
 
 // lazyinit sets up all required file descriptors and starts 1+consumersCount
@@ -195,6 +252,12 @@ func (i *inotify) loop(esch chan<- []*event) {
 		case nil:
 			switch epes[0].Fd {
 			case fd:
+				// Leave the events in the kernel queue while a consumer
+				// set up with WithBackpressure is too slow.
+				if i.gate != nil {
+					i.gate.wait()
+				}
+				i.act.enter() // left once the events are dispatched by send
 				esch <- i.read()
 				epes[0].Fd = 0
 			case int32(i.pipefd[0]):
@@ -220,31 +283,58 @@ func (i *inotify) loop(esch chan<- []*event) {
 
 // read reads events from an inotify file descriptor. It does not handle errors
 // returned from read(2) function since they are not critical to watcher logic.
-func (i *inotify) read() (es []*event) {
+func (i *inotify) read() []*event {
 	n, err := unix.Read(int(i.fd), i.buffer[:])
 	if err != nil || n < unix.SizeofInotifyEvent {
-		return
+		return nil
+	}
+	es, err := parse(i.buffer[:n])
+	if err != nil {
+		dbgprintf("inotify: dropping malformed data: %v", err)
 	}
-	var sys *unix.InotifyEvent
-	nmin := n - unix.SizeofInotifyEvent
-	for pos, path := 0, ""; pos <= nmin; {
-		sys = (*unix.InotifyEvent)(unsafe.Pointer(&i.buffer[pos]))
+	return es
+}
+
+// parse decodes the events packed in buf in the format read(2) gives them
+// for an inotify file descriptor: a fixed-size header followed by Len bytes of
+// a NUL-padded name. It gives the events decoded before the first header
+// which does not fit in buf or which name would exceed it, together with
+// errMalformedEvent.
+func parse(buf []byte) (es []*event, err error) {
+	for pos := 0; pos < len(buf); {
+		if len(buf)-pos < unix.SizeofInotifyEvent {
+			return es, errMalformedEvent
+		}
+		var sys unix.InotifyEvent
+		copy((*[unix.SizeofInotifyEvent]byte)(unsafe.Pointer(&sys))[:], buf[pos:])
 		pos += unix.SizeofInotifyEvent
-		if path = ""; sys.Len > 0 {
-			endpos := pos + int(sys.Len)
-			path = string(bytes.TrimRight(i.buffer[pos:endpos], "\x00"))
-			pos = endpos
+		if uint64(sys.Len) > uint64(len(buf)-pos) {
+			return es, errMalformedEvent
 		}
+		end := pos + int(sys.Len)
 		es = append(es, &event{
 			sys: unix.InotifyEvent{
 				Wd:     sys.Wd,
 				Mask:   sys.Mask,
 				Cookie: sys.Cookie,
+				Len:    sys.Len,
 			},
-			path: path,
+			path: string(bytes.TrimRight(buf[pos:end], "\x00")),
 		})
+		pos = end
 	}
-	return
+	return es, nil
+}
+
+// flush implements notify.flusher interface. It reports whether the kernel
+// queue holds events, which were not read yet.
+func (i *inotify) flush() bool {
+	fd := atomic.LoadInt32(&i.fd)
+	if fd == invalidDescriptor {
+		return false
+	}
+	n, err := unix.IoctlGetInt(int(fd), unix.TIOCINQ)
+	return err == nil && n > 0
 }
 
 // send is a consumer function which sends events to event dispatcher channel.
@@ -257,45 +347,72 @@ func (i *inotify) send(esch <-chan []*event) {
 				i.c <- e
 			}
 		}
+		i.forget(es)
+		i.act.leave()
 	}
 	i.wg.Done()
 }
 
+// forget removes watch descriptors, which the kernel reported as removed
+// with IN_IGNORED, e.g. after the watched directory was deleted. It allows
+// for watching the path again once it is recreated.
+func (i *inotify) forget(es []*event) {
+	for _, e := range es {
+		if e.sys.Mask&unix.IN_IGNORED != 0 {
+			i.Lock()
+			delete(i.m, e.sys.Wd)
+			i.Unlock()
+		}
+	}
+}
+
 // transform prepares events read from inotify file descriptor for sending to
 // user. It removes invalid events and these which are no longer present in
 // inotify map. This method may also split one raw event into two different ones
 // when system-dependent result is required.
 func (i *inotify) transform(es []*event) []*event {
-	var multi []*event
+	var portable, multi []*event
+	var overflow bool
+	var unmount []string
 	i.RLock()
-	for idx, e := range es {
-		if e.sys.Mask&(unix.IN_IGNORED|unix.IN_Q_OVERFLOW) != 0 {
-			es[idx] = nil
-			continue
+	for _, e := range es {
+		if e.sys.Mask&unix.IN_UNMOUNT != 0 {
+			for _, wd := range i.m[e.sys.Wd] {
+				unmount = append(unmount, wd.path)
+			}
 		}
-		wd, ok := i.m[e.sys.Wd]
-		if !ok || e.sys.Mask&encode(Event(wd.mask)) == 0 {
-			es[idx] = nil
+		if e.sys.Mask&(unix.IN_IGNORED|unix.IN_Q_OVERFLOW) != 0 {
+			overflow = overflow || e.sys.Mask&unix.IN_Q_OVERFLOW != 0
 			continue
 		}
-		if e.path == "" {
-			e.path = wd.path
-		} else {
-			e.path = filepath.Join(wd.path, e.path)
-		}
-		multi = append(multi, decode(Event(wd.mask), e))
-		if e.event == 0 {
-			es[idx] = nil
+		for _, wd := range i.m[e.sys.Wd] {
+			if e.sys.Mask&encode(Event(wd.mask)) == 0 && !isunknown(Event(wd.mask), e.sys.Mask) {
+				continue
+			}
+			ev := &event{sys: e.sys, path: wd.path}
+			if e.path != "" {
+				ev.path = filepath.Join(wd.path, e.path)
+			}
+			multi = append(multi, decode(Event(wd.mask), ev))
+			if ev.event != 0 {
+				portable = append(portable, ev)
+			}
 		}
 	}
 	i.RUnlock()
-	es = append(es, multi...)
-	return es
+	if overflow {
+		overflowed()
+	}
+	for _, path := range unmount {
+		reportUnmount(path)
+	}
+	return append(portable, multi...)
 }
 
 // encode converts notify system-independent events to valid inotify mask
 // which can be passed to inotify_add_watch(2) function.
 func encode(e Event) uint32 {
+	e &^= Unknown
 	if e&Create != 0 {
 		e = (e ^ Create) | InCreate | InMovedTo
 	}
@@ -334,35 +451,101 @@ func decode(mask Event, e *event) (syse *event) {
 		e.event = Write
 	case mask&Rename != 0 && imask&uint32(InMovedFrom|InMoveSelf)&e.sys.Mask != 0:
 		e.event = Rename
+	case isunknown(mask, e.sys.Mask):
+		e.event = Unknown
 	default:
 		e.event = 0
 	}
 	return
 }
 
+// known is a logical sum of all inotify masks notify is able to map.
+const known = uint32(InAccess|InModify|InAttrib|InCloseWrite|InCloseNowrite|InOpen|
+	InMovedFrom|InMovedTo|InCreate|InDelete|InDeleteSelf|InMoveSelf) |
+	unix.IN_ISDIR | unix.IN_IGNORED | unix.IN_Q_OVERFLOW
+
+// isunknown reports whether Unknown event was requested by the mask and sysmask
+// contains any inotify mask notify is not able to map, e.g. IN_UNMOUNT.
+func isunknown(mask Event, sysmask uint32) bool {
+	return mask&Unknown != 0 && sysmask&^known != 0
+}
+
+// verify implements notify.verifier interface. It fails with ErrNotWatched
+// if there is no watch descriptor for the path, e.g. since the kernel removed
+// it after the path was deleted or its filesystem unmounted.
+func (i *inotify) verify(path string) error {
+	i.RLock()
+	defer i.RUnlock()
+	for _, wds := range i.m {
+		for _, wd := range wds {
+			if wd.path == path {
+				return nil
+			}
+		}
+	}
+	return &WatchError{Op: "verify", Path: path, Err: ErrNotWatched}
+}
+
 // Unwatch implements notify.watcher interface. It looks for watch descriptor
 // related to registered path and if found, calls inotify_rm_watch(2) function.
+// If the watch descriptor is shared with other paths, it is not removed -
+// only its mask is shrunk to the events requested for the remaining ones.
 // This method is allowed to return EINVAL error when concurrently requested to
 // delete identical path.
 func (i *inotify) Unwatch(path string) (err error) {
 	iwd := int32(invalidDescriptor)
+	var rest []*watched
 	i.RLock()
-	for iwdkey, wd := range i.m {
-		if wd.path == path {
-			iwd = iwdkey
-			break
+Lookup:
+	for iwdkey, wds := range i.m {
+		for n, wd := range wds {
+			if wd.path == path {
+				iwd = iwdkey
+				rest = append(append(rest, wds[:n]...), wds[n+1:]...)
+				break Lookup
+			}
 		}
 	}
 	i.RUnlock()
 	if iwd == invalidDescriptor {
-		return errors.New("notify: path " + path + " is already watched")
+		return &WatchError{Op: "unwatch", Path: path, Err: ErrNotWatched}
 	}
 	fd := atomic.LoadInt32(&i.fd)
+	if len(rest) != 0 {
+		var mask Event
+		for _, wd := range rest {
+			mask |= Event(wd.mask)
+		}
+		i.RLock()
+		flags := i.flags[rest[0].path]
+		i.RUnlock()
+		nwd, err := unix.InotifyAddWatch(int(fd), rest[0].path, encode(mask)|flags)
+		if err != nil {
+			return err
+		}
+		if int32(nwd) != iwd {
+			// The remaining path was replaced in the meantime, the call above
+			// has set up an unrelated watch descriptor.
+			i.RLock()
+			_, known := i.m[int32(nwd)]
+			i.RUnlock()
+			if !known {
+				removeInotifyWatch(fd, int32(nwd))
+			}
+			return &WatchError{Op: "unwatch", Path: rest[0].path, Err: ErrNotWatched}
+		}
+		i.Lock()
+		i.m[iwd] = rest
+		delete(i.flags, path)
+		i.Unlock()
+		return nil
+	}
 	if err = removeInotifyWatch(fd, iwd); err != nil {
 		return
 	}
 	i.Lock()
 	delete(i.m, iwd)
+	delete(i.flags, path)
 	i.Unlock()
 	return nil
 }
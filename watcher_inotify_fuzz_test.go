@@ -0,0 +1,39 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+//go:build linux && go1.18
+// +build linux,go1.18
+
+package notify
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(pack(1, unix.IN_CREATE, "file", 16))
+	f.Add(append(pack(1, unix.IN_CREATE, "a", 16), pack(2, unix.IN_MODIFY, "", 0)...))
+	f.Add(pack(1, unix.IN_CREATE, "a", 16)[:unix.SizeofInotifyEvent+4])
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		es, err := parse(buf)
+		n := 0
+		for _, e := range es {
+			n += unix.SizeofInotifyEvent + int(e.sys.Len)
+			if len(e.path) > int(e.sys.Len) {
+				t.Fatalf("path %q longer than its length %d", e.path, e.sys.Len)
+			}
+		}
+		switch {
+		case n > len(buf):
+			t.Fatalf("events span %d bytes of %d", n, len(buf))
+		case err == nil && n != len(buf):
+			t.Fatalf("want consistent buffer to be consumed; %d of %d bytes", n, len(buf))
+		case err != nil && n == len(buf):
+			t.Fatalf("want malformed data left over; got err=%v", err)
+		}
+	})
+}
@@ -7,9 +7,16 @@
 package notify
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 func icreate(w *W, path string) WCase {
@@ -130,3 +137,231 @@ func TestWatcherInotify(t *testing.T) {
 
 	w.ExpectAny(cases[:])
 }
+
+func TestWatcherInotifySharedDescriptor(t *testing.T) {
+	w := newWatcherTest(t, "testdata/vfs.txt")
+	defer w.Close()
+
+	// Hard links share the inode, inotify gives both paths single descriptor.
+	src := filepath.Join(w.root, "src/github.com/rjeczalik/fs/fs.go")
+	if err := os.Link(src, filepath.Join(w.root, "fs.go")); err != nil {
+		t.Fatalf("Link()=%v", err)
+	}
+	Sync()
+	w.Watch("src/github.com/rjeczalik/fs/fs.go", Write)
+	w.Watch("fs.go", Write|Remove)
+
+	cases := [...]WCase{
+		{
+			Action: write(w, "fs.go", []byte("XD")).Action,
+			Events: []EventInfo{
+				&Call{P: "src/github.com/rjeczalik/fs/fs.go", E: Write},
+				&Call{P: "fs.go", E: Write},
+			},
+		},
+	}
+	w.ExpectAll(cases[:])
+
+	w.Unwatch("src/github.com/rjeczalik/fs/fs.go")
+
+	cases = [...]WCase{
+		write(w, "src/github.com/rjeczalik/fs/fs.go", []byte("XD")),
+	}
+	cases[0].Events[0] = &Call{P: "fs.go", E: Write}
+	w.ExpectAll(cases[:])
+}
+
+func TestWatcherInotifySharedDescriptorReplaced(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	must(ioutil.WriteFile(src, nil, 0644))
+	must(os.Link(src, dst))
+	w := newWatcher(make(chan EventInfo, buffer))
+	defer w.Close()
+	if err := w.Watch(src, Write); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Watch(dst, Write|Remove); err != nil {
+		t.Fatal(err)
+	}
+	// The remaining path no longer leads to the shared inode.
+	must(os.Remove(dst))
+	must(ioutil.WriteFile(dst, nil, 0644))
+	err = w.Unwatch(src)
+	if we, ok := err.(*WatchError); !ok || we.Path != dst || we.Err != ErrNotWatched {
+		t.Fatalf("want err=%v for %q; got %v", ErrNotWatched, dst, err)
+	}
+}
+
+func TestWatcherInotifyVerify(t *testing.T) {
+	w := newWatcherTest(t, "testdata/vfs.txt")
+	defer w.Close()
+
+	w.Watch("src/github.com/rjeczalik/fs/cmd/gotree", Create)
+	v := w.watcher().(verifier)
+	path := w.clean("src/github.com/rjeczalik/fs/cmd/gotree")
+	if err := v.verify(path); err != nil {
+		t.Fatalf("want err=nil; got %v", err)
+	}
+	if err := v.verify(w.clean("src/github.com/rjeczalik/fs/cmd")); err == nil {
+		t.Fatal("want err!=nil for a path never watched")
+	}
+	remove(w, "src/github.com/rjeczalik/fs/cmd/gotree").Action()
+	deadline := time.Now().Add(w.timeout())
+	for v.verify(path) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("want err!=nil for a removed path")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatcherInotifyResourceLimit(t *testing.T) {
+	var lim unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &lim); err != nil {
+		t.Fatal(err)
+	}
+	low := lim
+	if low.Cur > 64 {
+		low.Cur = 64
+	}
+	if err := unix.Setrlimit(unix.RLIMIT_NOFILE, &low); err != nil {
+		t.Skipf("unable to lower the open files limit: %v", err)
+	}
+	var files []*os.File
+	release := func() {
+		for _, f := range files {
+			f.Close()
+		}
+		files = nil
+		unix.Setrlimit(unix.RLIMIT_NOFILE, &lim)
+	}
+	defer release()
+	for n := uint64(0); n < low.Cur; n++ {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			break
+		}
+		files = append(files, f)
+	}
+	w := newWatcher(make(chan EventInfo, buffer))
+	defer w.Close()
+	dir := os.TempDir()
+	err := w.Watch(dir, Create)
+	release()
+	if e, ok := err.(*WatchError); !ok || e.Err != ErrResourceLimit {
+		t.Fatalf("want err=%v; got %v", ErrResourceLimit, err)
+	}
+	if err := w.Watch(dir, Create); err != nil {
+		t.Fatalf("want err=nil once descriptors are released; got %v", err)
+	}
+}
+
+func TestWatcherInotifyFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_flags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, link := filepath.Join(dir, "file"), filepath.Join(dir, "link")
+	must(ioutil.WriteFile(file, nil, 0644))
+	must(os.Symlink(dir, link))
+	w := newWatcher(make(chan EventInfo, buffer))
+	defer w.Close()
+	fw := w.(flagWatcher)
+	// The flags are enforced by the kernel, the paths are not checked upfront.
+	fw.setFlags(file, flagOnlyDir)
+	err = w.Watch(file, Create)
+	if we, ok := err.(*WatchError); !ok || we.Err != ErrNotDir {
+		t.Fatalf("want err=%v; got %v", ErrNotDir, err)
+	}
+	fw.setFlags(link, flagOnlyDir|flagDontFollow)
+	err = w.Watch(link, Create)
+	if we, ok := err.(*WatchError); !ok || we.Err != ErrNotDir {
+		t.Fatalf("want symlink not to be followed; got %v", err)
+	}
+	fw.setFlags(file, 0)
+	if err := w.Watch(file, Write); err != nil {
+		t.Fatalf("want err=nil once the flags are cleared; got %v", err)
+	}
+}
+
+func TestDecodeUnknown(t *testing.T) {
+	cases := [...]struct {
+		mask  Event
+		sys   uint32
+		event Event
+	}{
+		{Create | Unknown, unix.IN_UNMOUNT, Unknown}, // i=0
+		{Create, unix.IN_UNMOUNT, 0},                 // i=1
+		{Create | Unknown, unix.IN_CREATE, Create},   // i=2
+		{Remove | Unknown, unix.IN_CREATE, 0},        // i=3
+	}
+	for i, cas := range cases {
+		e := &event{sys: unix.InotifyEvent{Mask: cas.sys}}
+		decode(cas.mask, e)
+		if e.event != cas.event {
+			t.Errorf("want event=%v; got %v (i=%d)", cas.event, e.event, i)
+		}
+	}
+}
+
+// pack encodes an inotify event the way read(2) gives it, with the name
+// NUL-padded to n bytes.
+func pack(wd int32, mask uint32, name string, n int) []byte {
+	sys := unix.InotifyEvent{Wd: wd, Mask: mask, Len: uint32(n)}
+	p := make([]byte, unix.SizeofInotifyEvent+n)
+	copy(p, (*[unix.SizeofInotifyEvent]byte)(unsafe.Pointer(&sys))[:])
+	copy(p[unix.SizeofInotifyEvent:], name)
+	return p
+}
+
+func TestParse(t *testing.T) {
+	join := func(p ...[]byte) []byte { return bytes.Join(p, nil) }
+	type ev struct {
+		wd   int32
+		mask uint32
+		path string
+	}
+	cases := [...]struct {
+		buf []byte
+		es  []ev
+		err error
+	}{
+		{nil, nil, nil}, // i=0
+		{pack(1, unix.IN_DELETE_SELF, "", 0), []ev{{1, unix.IN_DELETE_SELF, ""}}, nil}, // i=1
+		{pack(1, unix.IN_CREATE, "file", 16), []ev{{1, unix.IN_CREATE, "file"}}, nil},  // i=2
+		{ // i=3
+			join(pack(1, unix.IN_CREATE, "a", 16), pack(2, unix.IN_MODIFY, "b", 4)),
+			[]ev{{1, unix.IN_CREATE, "a"}, {2, unix.IN_MODIFY, "b"}},
+			nil,
+		},
+		{ // i=4: truncated header
+			join(pack(1, unix.IN_CREATE, "a", 16), []byte{1, 2, 3}),
+			[]ev{{1, unix.IN_CREATE, "a"}},
+			errMalformedEvent,
+		},
+		{ // i=5: name exceeding the buffer
+			pack(1, unix.IN_CREATE, "a", 16)[:unix.SizeofInotifyEvent+8],
+			nil,
+			errMalformedEvent,
+		},
+	}
+	for i, cas := range cases {
+		es, err := parse(cas.buf)
+		if err != cas.err {
+			t.Errorf("want err=%v; got %v (i=%d)", cas.err, err, i)
+		}
+		var got []ev
+		for _, e := range es {
+			got = append(got, ev{e.sys.Wd, e.sys.Mask, e.path})
+		}
+		if !reflect.DeepEqual(got, cas.es) {
+			t.Errorf("want events=%v; got %v (i=%d)", cas.es, got, i)
+		}
+	}
+}
@@ -142,7 +142,7 @@ func (k *kq) Watched(n interface{}) (*watched, int64, error) {
 		panic(fmt.Sprintf("kq: type should be syscall.Kevent_t, %T instead", kevn))
 	}
 	if _, ok = k.idLkp[int(kevn.Ident)]; !ok {
-		return nil, 0, errNotWatched
+		return nil, 0, ErrNotWatched
 	}
 	return k.idLkp[int(kevn.Ident)], int64(kevn.Fflags), nil
 }
@@ -21,6 +21,10 @@ import (
 // watched directories uses its own buffer for storing events.
 const readBufferSize = 4096
 
+// errBusy is the error of a request for a directory, which is being rewatched
+// or unwatched.
+var errBusy = errors.New("another re/unwatching operation in progress")
+
 // Since all operations which go through the Windows completion routine are done
 // asynchronously, filter may set one of the constants below. They were defined
 // in order to distinguish whether current folder should be re-registered in
@@ -130,7 +134,7 @@ func (g *grip) readDirChanges() error {
 // implementation specific bit fields, to value that can be used as NotifyFilter
 // parameter in ReadDirectoryChangesW function.
 func encode(filter uint32) uint32 {
-	e := Event(filter & (onlyNGlobalEvents | onlyNotifyChanges))
+	e := Event(filter&(onlyNGlobalEvents|onlyNotifyChanges)) &^ Unknown
 	if e&dirmarker != 0 {
 		return uint32(FileNotifyChangeDirName)
 	}
@@ -293,8 +297,8 @@ func (r *readdcw) RecursiveWatch(path string, event Event) error {
 // already exists, function tries to rewatch it with new filters(NOT VALID). Moreover,
 // watch starts the main event loop goroutine when called for the first time.
 func (r *readdcw) watch(path string, event Event, recursive bool) error {
-	if event&^(All|fileNotifyChangeAll) != 0 {
-		return errors.New("notify: unknown event")
+	if event&^(All|Unknown|fileNotifyChangeAll) != 0 {
+		return &WatchError{Op: "watch", Path: path, Err: ErrInvalidEventSet}
 	}
 
 	r.Lock()
@@ -399,6 +403,9 @@ func (r *readdcw) loopevent(n uint32, overEx *overlappedEx) {
 	for {
 		raw := (*syscall.FileNotifyInformation)(unsafe.Pointer(&overEx.parent.buffer[currOffset]))
 		name := syscall.UTF16ToString((*[syscall.MAX_LONG_PATH]uint16)(unsafe.Pointer(&raw.FileName))[:raw.FileNameLength>>1])
+		// ReadDirectoryChangesW may report short names, make them comparable
+		// with the paths of the watchpoints.
+		name = longname(syscall.UTF16ToString(overEx.parent.pathw), name)
 		events = append(events, &event{
 			pathw:  overEx.parent.pathw,
 			filter: overEx.parent.filter,
@@ -466,13 +473,13 @@ func (r *readdcw) RecursiveRewatch(oldpath, newpath string, oldevent,
 
 // TODO : (pknap) doc.
 func (r *readdcw) rewatch(path string, oldevent, newevent uint32, recursive bool) (err error) {
-	if Event(newevent)&^(All|fileNotifyChangeAll) != 0 {
-		return errors.New("notify: unknown event")
+	if Event(newevent)&^(All|Unknown|fileNotifyChangeAll) != 0 {
+		return &WatchError{Op: "rewatch", Path: path, Err: ErrInvalidEventSet}
 	}
 	var wd *watched
 	r.Lock()
 	defer r.Unlock()
-	if wd, err = r.nonStateWatchedLocked(path); err != nil {
+	if wd, err = r.nonStateWatchedLocked("rewatch", path); err != nil {
 		return
 	}
 	if wd.filter&(onlyNotifyChanges|onlyNGlobalEvents) != oldevent {
@@ -489,14 +496,14 @@ func (r *readdcw) rewatch(path string, oldevent, newevent uint32, recursive bool
 }
 
 // TODO : pknap
-func (r *readdcw) nonStateWatchedLocked(path string) (wd *watched, err error) {
+func (r *readdcw) nonStateWatchedLocked(op, path string) (wd *watched, err error) {
 	wd, ok := r.m[path]
 	if !ok || wd == nil {
-		err = errors.New(`notify: ` + path + ` path is unwatched`)
+		err = &WatchError{Op: op, Path: path, Err: ErrNotWatched}
 		return
 	}
 	if wd.filter&onlyMachineStates != 0 {
-		err = errors.New(`notify: another re/unwatching operation in progress`)
+		err = &WatchError{Op: op, Path: path, Err: errBusy}
 		return
 	}
 	return
@@ -518,7 +525,7 @@ func (r *readdcw) unwatch(path string) (err error) {
 
 	r.Lock()
 	defer r.Unlock()
-	if wd, err = r.nonStateWatchedLocked(path); err != nil {
+	if wd, err = r.nonStateWatchedLocked("unwatch", path); err != nil {
 		return
 	}
 
@@ -584,7 +591,10 @@ func decode(filter, action uint32) (Event, Event) {
 	case syscall.FILE_ACTION_RENAMED_NEW_NAME:
 		return gensys(filter, Rename, FileActionRenamedNewName)
 	}
-	panic(`notify: cannot decode internal mask`)
+	if filter&uint32(Unknown) != 0 {
+		return Unknown, 0
+	}
+	return 0, 0
 }
 
 // gensys decides whether the Windows action, system-independent event or both
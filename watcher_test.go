@@ -8,6 +8,7 @@ package notify
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -84,3 +85,26 @@ func TestWatcherUnwatch(t *testing.T) {
 	}
 	w.ExpectAny(cases[:])
 }
+
+func TestWatcherUnwatchNotWatched(t *testing.T) {
+	w := newWatcherTest(t, "testdata/vfs.txt")
+	defer w.Close()
+
+	w.Watch("src/github.com/rjeczalik", Create)
+
+	path := w.clean("src/github.com/ppknap")
+	err := w.watcher().Unwatch(path)
+	werr, ok := err.(*WatchError)
+	if !ok {
+		t.Fatalf("want err to be *WatchError; got %T (%v)", err, err)
+	}
+	if werr.Unwrap() != ErrNotWatched {
+		t.Errorf("want werr.Unwrap()=%v; got %v", ErrNotWatched, werr.Unwrap())
+	}
+	if werr.Path != path {
+		t.Errorf("want werr.Path=%q; got %q", path, werr.Path)
+	}
+	if !strings.Contains(werr.Error(), path) {
+		t.Errorf("want %q to contain %q", werr.Error(), path)
+	}
+}
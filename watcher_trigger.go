@@ -170,7 +170,7 @@ func (t *trg) singlewatch(p string, e Event, direct mode, fi os.FileInfo) (err e
 		t.t.Record(w)
 		return nil
 	}
-	return errAlreadyWatched
+	return &WatchError{Op: "watch", Path: p, Err: ErrAlreadyWatched}
 }
 
 // decode converts event received from native to notify.Event
@@ -192,7 +192,7 @@ func decode(o int64, w Event) (e Event) {
 
 func (t *trg) watch(p string, e Event, fi os.FileInfo) error {
 	if err := t.singlewatch(p, e, dir, fi); err != nil {
-		if err != errAlreadyWatched {
+		if underlying(err) != ErrAlreadyWatched {
 			return err
 		}
 	}
@@ -200,7 +200,7 @@ func (t *trg) watch(p string, e Event, fi os.FileInfo) error {
 		err := t.walk(p, func(fi os.FileInfo) (err error) {
 			if err = t.singlewatch(filepath.Join(p, fi.Name()), e, ndir,
 				fi); err != nil {
-				if err != errAlreadyWatched {
+				if underlying(err) != ErrAlreadyWatched {
 					return
 				}
 			}
@@ -236,7 +236,7 @@ func (t *trg) unwatch(p string, fi os.FileInfo) error {
 	if fi.IsDir() {
 		err := t.walk(p, func(fi os.FileInfo) error {
 			err := t.singleunwatch(filepath.Join(p, fi.Name()), ndir)
-			if err != errNotWatched {
+			if underlying(err) != ErrNotWatched {
 				return err
 			}
 			return nil
@@ -287,7 +287,10 @@ func (t *trg) Rewatch(p string, _, e Event) error {
 		err = t.watch(p, e, fi)
 	}
 	t.Unlock()
-	return nil
+	if underlying(err) == ErrNotWatched {
+		err = &WatchError{Op: "rewatch", Path: p, Err: ErrNotWatched}
+	}
+	return err
 }
 
 func (*trg) file(w *watched, n interface{}, e Event) (evn []event) {
@@ -310,7 +313,7 @@ func (t *trg) dir(w *watched, n interface{}, e, ge Event) (evn []event) {
 		if ge&not2nat[Rename] != 0 {
 			for p := range t.pthLkp {
 				if strings.HasPrefix(p, w.p+string(os.PathSeparator)) {
-					if err := t.singleunwatch(p, both); err != nil && err != errNotWatched &&
+					if err := t.singleunwatch(p, both); err != nil && underlying(err) != ErrNotWatched &&
 						!os.IsNotExist(err) {
 						dbgprintf("trg: failed stop watching moved file (%q): %q\n",
 							p, err)
@@ -333,7 +336,7 @@ func (t *trg) dir(w *watched, n interface{}, e, ge Event) (evn []event) {
 			switch err := t.singlewatch(p, w.eDir, ndir, fi); {
 			case os.IsNotExist(err) && ((w.eDir & Remove) != 0):
 				evn = append(evn, event{p, Remove, fi.IsDir(), n})
-			case err == errAlreadyWatched:
+			case underlying(err) == ErrAlreadyWatched:
 			case err != nil:
 				dbgprintf("trg: watching %q failed: %q", p, err)
 			case (w.eDir & Create) != 0:
@@ -364,7 +367,7 @@ const (
 func (t *trg) singleunwatch(p string, direct mode) error {
 	w, ok := t.pthLkp[p]
 	if !ok {
-		return errNotWatched
+		return &WatchError{Op: "unwatch", Path: p, Err: ErrNotWatched}
 	}
 	switch direct {
 	case dir:
@@ -383,7 +386,7 @@ func (t *trg) singleunwatch(p string, direct mode) error {
 			mod = ndir
 		}
 		if err := t.singlewatch(p, w.eNonDir|w.eDir, mod,
-			w.fi); err != nil && err != errAlreadyWatched {
+			w.fi); err != nil && underlying(err) != ErrAlreadyWatched {
 			return err
 		}
 	} else {
@@ -6,7 +6,11 @@
 
 package notify
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
 
 func TestWatcherCreateOnly(t *testing.T) {
 	w := NewWatcherTest(t, "testdata/vfs.txt", Create)
@@ -19,3 +23,25 @@ func TestWatcherCreateOnly(t *testing.T) {
 
 	w.ExpectAny(cases[:])
 }
+
+func TestWatcherRewatchNotWatched(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify_trigger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w := newWatcher(make(chan EventInfo, buffer))
+	defer w.Close()
+	if err, ok := w.Rewatch(dir, Create, Write).(*WatchError); !ok || err.Err != ErrNotWatched {
+		t.Fatalf("want err=ErrNotWatched; got %v", err)
+	}
+	if err := w.Watch(dir, Create); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Watch(dir, Create); err != nil {
+		t.Fatalf("want watching the path again to succeed; got %v", err)
+	}
+	if err := w.Rewatch(dir, Create, Write); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errMalformedLine = errors.New("malformed line")
+
+// WatchList watches every path named in the list file, delivering events
+// for them to c, and watches the list file itself: whenever it changes, it is
+// read again and the watchpoints of c are reconciled with it, like with
+// Reconcile. The file names one path per line, recursive ones with the "..."
+// suffix. Relative paths are resolved against the directory of the list file.
+// Blank lines and lines starting with # are skipped. Each path is watched for
+// the given events.
+//
+// Lines which are malformed or name paths which cannot be watched do not
+// abort the update, the other paths are still watched. WatchList fails with
+// a ReconcileError describing them, later updates report them through the
+// OnError hook, see SetHooks. If the list file cannot be read later on,
+// e.g. it was removed, the watchpoints are left as they were.
+//
+// Events for the list file itself are not delivered to c, unless the file
+// is also named in the list or watched by c otherwise. Use Stop to remove
+// watchpoints set up with WatchList; Reconcile called for c removes the
+// watchpoint of the list file as well.
+func WatchList(file string, c chan<- EventInfo, events ...Event) error {
+	return defaultTree.WatchList(file, c, joinevents(events))
+}
+
+// parselist parses the content of the list file, giving the watched paths
+// together with the errors of the malformed lines.
+func parselist(file string, p []byte, e Event) (map[string]Event, ReconcileError) {
+	var errs ReconcileError
+	desired := make(map[string]Event)
+	dir := filepath.Dir(file)
+	s := bufio.NewScanner(bytes.NewReader(p))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+		case strings.IndexByte(line, 0) != -1:
+			errs = append(errs, &WatchError{
+				Op:   "watchlist",
+				Path: file + ":" + strconv.Itoa(n),
+				Err:  errMalformedLine,
+			})
+			continue
+		}
+		path := filepath.FromSlash(line)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		desired[path] |= e
+	}
+	return desired, errs
+}
+
+// WatchList watches the list file with a pipe of c, which keeps the other
+// watchpoints of c in sync with the file.
+func (t *pipeTree) WatchList(file string, c chan<- EventInfo, e Event) error {
+	file, _, err := cleanpath(file)
+	if err != nil {
+		return err
+	}
+	var mu sync.Mutex // serializes updates
+	var p *pipe
+	update := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if p.closed() {
+			return nil
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return &WatchError{Op: "watchlist", Path: file, Err: err}
+		}
+		desired, errs := parselist(file, b, e)
+		return t.reconcile(c, desired, p, errs)
+	}
+	// The directory is watched, so that the list file is followed when
+	// editors replace it with a new one.
+	s := func(handler) handler {
+		return func(ei EventInfo) {
+			if normalize(ei.Path()) != file {
+				return
+			}
+			// Not to block the pipe, the tree may be waiting for it to exit.
+			go func() {
+				switch err := update().(type) {
+				case ReconcileError:
+					for _, err := range err {
+						report(err.Path, err, nil)
+					}
+				case *WatchError:
+					report(err.Path, err, nil)
+				}
+			}()
+		}
+	}
+	mu.Lock()
+	p, err = t.WatchPipe(filepath.Dir(file), c, []stage{s}, Create|Write|Rename)
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = update(); err != nil {
+		if _, ok := err.(ReconcileError); !ok {
+			t.mu.Lock()
+			t.remove(p)
+			t.unwatch(p)
+			p.stop()
+			t.mu.Unlock()
+		}
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2014-2018 The Notify Authors. All rights reserved.
+// Use of this source code is governed by the MIT license that can be
+// found in the LICENSE file.
+
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParselist(t *testing.T) {
+	file := filepath.FromSlash("/etc/notify/list")
+	p := []byte("/var/log\n\n# comment\n  data/...  \nbad\x00line\n")
+	desired, errs := parselist(file, p, Create)
+	want := map[string]Event{
+		filepath.FromSlash("/var/log"):             Create,
+		filepath.FromSlash("/etc/notify/data/..."): Create,
+	}
+	if !reflect.DeepEqual(desired, want) {
+		t.Errorf("want desired=%v; got %v", want, desired)
+	}
+	if len(errs) != 1 || errs[0].Path != file+":5" || errs[0].Err != errMalformedLine {
+		t.Errorf("want malformed line 5 reported; got %v", errs)
+	}
+}
+
+func TestPipeTreeWatchList(t *testing.T) {
+	dir, tr, ch := NewPipeTreeTest(t, 1)
+	defer tr.Close()
+	for _, name := range []string{"a", "b"} {
+		must(os.Mkdir(filepath.Join(dir, name), 0755))
+	}
+	list := filepath.Join(dir, "list")
+	must(ioutil.WriteFile(list, []byte("a\nmissing\n"), 0644))
+	err := tr.WatchList(list, ch[0], Create)
+	if errs, ok := err.(ReconcileError); !ok || len(errs) != 1 {
+		t.Fatalf("want ReconcileError for the missing path; got %v", err)
+	}
+	if _, ok := tr.Events(filepath.Join(dir, "a")); !ok {
+		t.Fatal("want a watched")
+	}
+	must(ioutil.WriteFile(list, []byte("b\n"), 0644))
+	deadline := time.Now().Add(timeout())
+	for {
+		_, a := tr.Events(filepath.Join(dir, "a"))
+		_, b := tr.Events(filepath.Join(dir, "b"))
+		if !a && b {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want only b watched after the list changed; got a=%t, b=%t", a, b)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	file := filepath.Join(dir, "b", "file")
+	must(ioutil.WriteFile(file, nil, 0644))
+	tr.Expect(ch[0], &Call{P: file, E: Create})
+	tr.Stop(ch[0])
+	if n := len(tr.pipes); n != 0 {
+		t.Fatalf("want no pipes left after Stop; got %d", n)
+	}
+}
@@ -79,21 +79,39 @@ func (wp watchpoint) Del(c chan<- EventInfo, e Event) (diff eventDiff) {
 }
 
 func (wp watchpoint) Dispatch(ei EventInfo, extra Event) {
+	wp.dispatch(ei, extra, nil, nil)
+}
+
+// dispatch works like Dispatch, but the events for the channels throttled by
+// th are queued by it instead of being dropped, when the channels are not
+// ready. The time of the events is recorded with st.
+func (wp watchpoint) dispatch(ei EventInfo, extra Event, th *throttle, st *stamps) {
 	e := eventmask(ei, extra)
 	if !matches(wp[nil], e) {
 		return
 	}
 	for ch, eset := range wp {
-		if ch != nil && matches(eset, e) {
+		if ch == nil || !matches(eset, e) {
+			continue
+		}
+		if st.mark(ch); !th.send(ch, ei) {
 			select {
 			case ch <- ei:
 			default: // Drop event if receiver is too slow
-				dbgprintf("dropped %s on %q: receiver too slow", ei.Event(), ei.Path())
+				dropped(ei)
 			}
 		}
 	}
 }
 
+// rekey moves the event set of c to d.
+func (wp watchpoint) rekey(c, d chan<- EventInfo) {
+	if e, ok := wp[c]; ok {
+		delete(wp, c)
+		wp[d] |= e
+	}
+}
+
 func (wp watchpoint) Total() Event {
 	return wp[nil] &^ internal
 }